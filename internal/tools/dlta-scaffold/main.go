@@ -4,30 +4,58 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"reflect"
-	"runtime"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	gomonkey "github.com/agiledragon/gomonkey/v2"
 	"github.com/fatih/color"
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-azure-sdk/sdk/auth"
+	"github.com/hashicorp/go-azure-sdk/sdk/environments"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	help "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/hashicorp/terraform-provider-azurerm/internal/provider"
 	"github.com/hashicorp/terraform-provider-azurerm/internal/sdk"
-	"github.com/hashicorp/terraform-provider-azurerm/internal/tf/validation"
+	"github.com/hashicorp/terraform-provider-azurerm/version"
+	"gopkg.in/yaml.v3"
 )
 
 // NOTE: since we're using `go run` for these tools all of the code needs to live within the main.go
 
+// generatorVersion is the version of dlta-scaffold, bump this when the generated artefacts change shape
+const generatorVersion = "0.2.0"
+
+// manuallyModifiedMarker is added to a generated file by hand to stop `-force y` from overwriting it
+const manuallyModifiedMarker = "dlta-scaffold: manually-modified"
+
 type documentationGenerator struct {
 	resource *schema.Resource
 
@@ -43,13 +71,185 @@ type documentationGenerator struct {
 	//TODO may not be needed if using is DataSource
 	isResource bool
 
-	isForced bool
+	// generatedAt is the time recorded in the header of each generated file
+	generatedAt time.Time
+
+	// naming holds the naming conventions read from `config/naming.json`
+	naming namingConfig
+
+	// shortCodes holds the short code overrides read from `config/short_codes.json`
+	shortCodes map[string]string
+
+	// nestedNames holds the nested name rules read from `config/nested_names.json`
+	nestedNames []nestedNameRule
+
+	// attributeRules holds the attributes excluded from (or included in) scaffolding, read from
+	// `config/attribute_rules.json`
+	attributeRules attributeRuleConfig
+
+	// publishRules set which attributes are published when the summary is initialised, read from
+	// `config/publish_rules.json`
+	publishRules []publishRule
+
+	// referenceRules set which attributes the template wires to the output of another asset's module, read from
+	// `config/reference_rules.json` ahead of defaultReferenceRules
+	referenceRules []referenceRule
+
+	// serviceName is the name of the service package registering the resource e.g. `Storage`
+	serviceName string
+
+	// websiteCategories are the documentation categories of the service package e.g. `Key Vault`
+	websiteCategories []string
+
+	// paletteCategories holds the palette category overrides read from `config/palette_categories.json`
+	paletteCategories paletteCategoryConfig
+
+	// costs holds the cost classification read from `config/costs.json`
+	costs costConfig
+
+	// paletteRules holds the rules for which palette controls are shown, read from `config/palette_rules.json`
+	paletteRules []paletteRule
+
+	// paletteOutputs are the computed attributes shown on the palette, read from `config/palette_outputs.json`
+	paletteOutputs []string
+
+	// liveOptions are the palette options fetched from Azure keyed by resource path (or `location`), see
+	// liveOptionSources
+	liveOptions map[string][]string
+
+	// registry returns what the azurerm provider registers beyond the resource's own schema, read the first time it's
+	// needed (see readProviderRegistry) and nil for the schemas of other providers
+	registry func() providerRegistry
+
+	// paletteRank is the position of the asset on the palette, lower ranks are shown first
+	paletteRank int
+
+	// paletteIcons holds the palette icons and colours read from `config/palette_icons.json`
+	paletteIcons paletteIconConfig
+
+	// usedInstanceIds holds the instance ids already used by the resource, read from the instance registry
+	usedInstanceIds []string
+
+	// providerSource is the source of the provider read from `-provider-schema` e.g. `hashicorp/azurerm`
+	providerSource string
+
+	// azapiBody is the body of the azapi resource read from `-rest-spec`, without it the body is untyped
+	azapiBody *schema.Resource
+
+	scaffoldOptions
 
 	ShortCode string
 
 	NamingConvention string
 }
 
+// scaffoldOptions holds the behavioural switches passed on the command line
+type scaffoldOptions struct {
+	// dltaPathFlag is the `-dlta-path` as passed, used in the regenerate command of each file's header so it doesn't
+	// embed the absolute path of the machine which generated it
+	dltaPathFlag string
+
+	isForced bool
+
+	// isImport defines if import blocks should be generated so existing resources can be adopted
+	isImport bool
+
+	// allowInvalid defines if .tf artefacts which fail to parse should be written regardless
+	allowInvalid bool
+
+	// includeDeprecated defines if deprecated attributes should be published (with a deprecation note)
+	includeDeprecated bool
+
+	// azapiType is the ARM resource type and API version scaffolded with azapi_resource e.g.
+	// `Microsoft.App/containerApps@2023-05-01`, for resources the azurerm provider doesn't support yet
+	azapiType string
+
+	// moduleRefType defines how moduleVersion is interpreted, either `tag`, `branch` or `commit`
+	moduleRefType string
+
+	// moduleVersion is the git ref the template pins the module source to e.g. `v1.2.0`
+	moduleVersion string
+
+	// checkName is the generated name checked for availability by `-output-type check-name`
+	checkName string
+
+	// subscriptionId is used to check name availability with ARM, without it DNS is used instead
+	subscriptionId string
+
+	// paletteTable is the `schema.table` the palette SQL upserts the asset into
+	paletteTable string
+
+	// showOutputs defines if computed attributes (id, endpoints, FQDNs) are shown as read only palette controls
+	showOutputs bool
+
+	// autoRank defines if the palette rank is ordered by usage rather than read from `config/palette_ranks.json`
+	autoRank bool
+
+	// migrateInput is the controls json upgraded by `-output-type migrate`, the resource's palette.json if not given
+	migrateInput string
+
+	// hardDelete defines if `-output-type retire` deletes the palette row rather than setting `deleted_at`
+	hardDelete bool
+
+	// paletteFormat defines how the palette is written, either `sql`, `json` or `both`
+	paletteFormat string
+
+	// paletteTargets are the frontends the palette is rendered for, see paletteRenderers
+	paletteTargets []string
+
+	// dsn is the PostgreSQL connection string `-output-type diff` reads the live palette with (using psql)
+	dsn string
+
+	// refreshOptions defines if the live palette options are fetched from Azure when the cached ones are stale
+	refreshOptions bool
+
+	// refreshSchema defines if the schema is read from the provider rather than the schema cache
+	refreshSchema bool
+
+	// providerSchema is the output of `terraform providers schema -json` the schema is read from rather than the
+	// azurerm provider the tool is compiled against
+	providerSchema string
+
+	// restSpec is the Azure REST API spec (swagger) the `-azapi` body is typed from, without it the body is `any`
+	restSpec string
+
+	// strict defines if scaffold fails rather than warns when the summary was initialised with another provider version,
+	// and the deprecation report when it finds published attributes deprecated or removed
+	strict bool
+
+	// schemaFrom and schemaTo are the provider versions (or schema json paths) compared by `-output-type schema-diff`
+	schemaFrom string
+	schemaTo   string
+
+	// maxDepth is how deeply nested blocks of the schema are walked, deeper blocks are left out with a warning
+	maxDepth int
+
+	// layout defines how the resources are laid out within the dlta path, either `flat` or `service`
+	layout string
+
+	// profile is the publish profile of the summary scaffolded, a palette asset of its own, or empty for the resource
+	profile string
+
+	// attributePatterns are the resource paths `-output-type publish` and `unpublish` edit, `*` matching one element
+	attributePatterns []string
+
+	// apply defines if the resource is scaffolded once published or unpublished
+	apply bool
+
+	// matrixFormat defines how `-output-type matrix` is written, either `markdown` or `csv`
+	matrixFormat string
+
+	// lockTimeout is how long to wait for another run scaffolding the same resource (or exporting the catalog) to
+	// finish, see acquireLock
+	lockTimeout time.Duration
+
+	// moduleName is the module name of the asset, a moved block is generated when it differs from previousModuleName
+	moduleName string
+
+	// previousModuleName is the module name of the asset before it was renamed on the canvas
+	previousModuleName string
+}
+
 type NameValue map[string]interface{}
 
 type KeyValue struct {
@@ -58,36 +258,167 @@ type KeyValue struct {
 }
 
 type PaletteProp struct {
-	ID           string      `json:"id"`
-	Name         string      `json:"name"`
-	Description  *string     `json:"description"`
-	Type         string      `json:"type"`
-	CurrentValue interface{} `json:"value"`
-	FlattenName  *string     `json:"flatten_name"`
-	Filter       *string     `json:"filter"`
-	Disabled     bool        `json:"disabled"`
-	ReadOnly     bool        `json:"readonly"`
-	Validators   NameValue   `json:"validators"`
-	Options      []KeyValue  `json:"options"`
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Description   *string     `json:"description"`
+	Type          string      `json:"type"`
+	CurrentValue  interface{} `json:"value"`
+	FlattenName   *string     `json:"flatten_name"`
+	Filter        *string     `json:"filter"`
+	Disabled      bool        `json:"disabled"`
+	ReadOnly      bool        `json:"readonly"`
+	IsDefault     bool        `json:"is_default"`
+	Validators    NameValue   `json:"validators"`
+	Options       []KeyValue  `json:"options"`
+	Lookup        *string     `json:"lookup"`
+	Group         string      `json:"group"`
+	Output        *string     `json:"output"`
+	SecretRef     *string     `json:"secret_ref"`
+	DefaultSource *string     `json:"default_source"`
+	DefaultEnv    []string    `json:"default_env"`
+	Deprecated    *string     `json:"deprecated"`
+	ForceNew      bool        `json:"force_new"`
+	AssetType     *string     `json:"asset_type"` // the assets a paletteAssetType control picks from
 }
 
+const (
+	// paletteGroupRequired groups the controls of required attributes, which must be set before the template renders
+	paletteGroupRequired = "required"
+
+	// paletteGroupOptional groups the controls of optional attributes
+	paletteGroupOptional = "optional"
+
+	// paletteGroupOutput groups the read only controls of computed attributes, set from the module's outputs
+	paletteGroupOutput = "output"
+)
+
+// PaletteObj is the palette_design of an asset, how it's drawn on the canvas palette
 type PaletteObj struct {
-	SVG   string `json:"svg"`
-	Color string `json:"color"`
+	SVG      string `json:"svg"`
+	Color    string `json:"color"`
+	Category string `json:"category"`
+	Service  string `json:"service,omitempty"` // the service package registering the resource, grouped within the category
 }
 
 type Creator struct {
-	CreateFunction string `json:"create_function"`
+	CreateFunction    string `json:"create_function"`
+	FormSchemaVersion int    `json:"form_schema_version"`
 
 	Props []PaletteProp `json:"controls"`
 }
 
+// moduleMeta is written alongside each module so downstream tooling can track provenance and detect drift
+type moduleMeta struct {
+	GeneratorVersion    string   `json:"generator_version"`
+	ProviderVersion     string   `json:"provider_version"`
+	ResourceType        string   `json:"resource_type"`
+	NamingConvention    string   `json:"naming_convention"`
+	PublishedAttributes []string `json:"published_attributes"`
+	ContentHash         string   `json:"content_hash"`
+}
+
+// backendConfig is the azurerm backend configuration for a single environment, the key may contain dlta tokens
+// e.g. `${dlta_business_short_code}-${dlta_application_short_code}.tfstate` which are rendered by the canvas
+type backendConfig struct {
+	ResourceGroupName  string `json:"resource_group_name"`
+	StorageAccountName string `json:"storage_account_name"`
+	ContainerName      string `json:"container_name"`
+	Key                string `json:"key"`
+	SubscriptionId     string `json:"subscription_id,omitempty"`
+	UseAzureAdAuth     bool   `json:"use_azuread_auth,omitempty"`
+}
+
+// defaultBackendKey is used when an environment's backend configuration doesn't define a key pattern
+const defaultBackendKey = "${dlta_business_short_code}-${dlta_application_short_code}-${dlta_environment_char}-${dlta_location_short_code}.tfstate"
+
 type namingStruct struct {
 	Delimiter    string
 	StaticName   string
 	IsDataSource bool
 	Prefix       string
 	Fields       []string
+	Lookup       string // how a data source finds what it reads, see dataSourceLookup
+	Transforms   namingTransforms
+}
+
+// namingTransforms are applied to a generated name so it satisfies the rules for the resource type, by default they're
+// derived from nameRules e.g. storage account names are lowercased, alphanumeric and truncated to 24 characters
+type namingTransforms struct {
+	Lowercase       bool `json:"lowercase"`
+	StripDelimiters bool `json:"strip_delimiters"`
+	Alphanumeric    bool `json:"alphanumeric"`
+	MaxLength       int  `json:"max_length"`
+}
+
+const (
+	// dataSourceLookupResourceGroup data sources look up by name within a resource group e.g. azurerm_subnet
+	dataSourceLookupResourceGroup = "resource_group"
+
+	// dataSourceLookupId data sources look up by name within a parent resource id e.g. azurerm_key_vault_certificate
+	dataSourceLookupId = "id"
+
+	// dataSourceLookupName data sources look up by name alone e.g. azurerm_subscription
+	dataSourceLookupName = "name"
+)
+
+// defaultNaming is used for any resource without a specific naming convention
+var defaultNaming = namingStruct{Delimiter: "-", Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}}
+
+// namingConfig is `config/naming.json`, a convention for a resource replaces the built in convention for it and
+// default replaces defaultNaming e.g.
+// `{"default": {"delimiter": "-", "fields": ["dlta_application_short_code"]}, "resources": {"azurerm_storage_account": {"delimiter": ""}}}`
+type namingConfig struct {
+	Default     *namingConfigEntry           `json:"default"`
+	Resources   map[string]namingConfigEntry `json:"resources"`
+	DataSources map[string]namingConfigEntry `json:"data_sources"`
+
+	// Provider selects how names are built, either `token` (the default), `azurecaf` or `webhook`
+	Provider string `json:"provider"`
+
+	// WebhookUrl is called by the `webhook` provider with the resource type and naming fields as query parameters
+	// and must respond with `{"name": "..."}`
+	WebhookUrl string `json:"webhook_url"`
+
+	// DataSourcePrefix is prefixed to the names of data sources which don't set their own prefix e.g. `ds`, by default
+	// only the built in conventions have one
+	DataSourcePrefix *string `json:"data_source_prefix"`
+
+	// UniqueSuffix configures the `dlta_unique_suffix` naming field e.g. `{"mode": "random", "length": 6}`
+	UniqueSuffix *uniqueSuffixConfig `json:"unique_suffix"`
+
+	// InstanceId configures how `dlta_instance_id` is picked e.g. `{"mode": "registry"}`
+	InstanceId *instanceIdConfig `json:"instance_id"`
+
+	// Tokens are user defined naming fields e.g. `{"dlta_cost_center": {"options": ["cc01", "cc02"]}}`, a token used by
+	// a naming convention is injected onto the palette and declared as a module variable
+	Tokens map[string]namingToken `json:"tokens"`
+}
+
+// namingToken is a user defined naming field within `config/naming.json`
+type namingToken struct {
+	Description string   `json:"description"`
+	Options     []string `json:"options"`
+}
+
+// namingTokenRegex matches the name of a user defined naming field
+var namingTokenRegex = regexp.MustCompile(`^dlta_[a-z0-9_]+$`)
+
+// namingConfigEntry is a naming convention within `config/naming.json`, anything not set is taken from the default
+type namingConfigEntry struct {
+	Delimiter  *string  `json:"delimiter"`
+	StaticName string   `json:"static_name"`
+	Prefix     string   `json:"prefix"`
+	Fields     []string `json:"fields"`
+
+	// Lookup overrides how a data source finds what it reads, either `resource_group`, `id` or `name`
+	Lookup string `json:"lookup"`
+
+	// Transforms replace the transforms derived from the resource's name rule
+	Transforms *namingTransforms `json:"transforms"`
+
+	// Environments override the convention for a `dlta_environment_char` e.g. `{"s": {"prefix": "tmp"}}`, anything
+	// not set is taken from the convention being overridden
+	Environments map[string]namingConfigEntry `json:"environments"`
 }
 
 type attribute struct {
@@ -103,9 +434,139 @@ type attribute struct {
 	PossibleOptions []string
 	DataTypeString  string
 	Attributes      map[string]attribute
-	Default         string //TODO Find out how this works  SchemaDefaultFunc
+	Default         string   // the schema's Default, a DefaultFunc is recorded by DefaultSource but not called
+	DefaultSource   string   // where the default comes from, one of the defaultSource constants
+	DefaultEnv      []string // the environment variables the description says a DefaultFunc reads
 	ConflictsWith   []string
+	ExactlyOneOf    []string
+	AtLeastOneOf    []string
+	RequiredWith    []string
+	Deprecated      string
+	Sensitive       bool
 	ResourcePath    string
+	TypeConstraint  string             // overrides the variable type derived from DataTypeString
+	OptionsOverride []string           // the options the summary restricts the attribute to, which the variable validates
+	Environments    []string           // the dlta_environment_char values the attribute is published for, every one when empty
+	Reference       *proposedReference // the reference the summary confirms the attribute is, see referenceFor
+	Validations     []variableValidation
+	Constraints     constraints // read from the schema's validation functions
+	Shape           string      // how the value is modelled, one of the shape constants
+	ElemType        string      // the DataTypeString of a list, set or map's primitive elements
+}
+
+// the shapes of an attribute's value, a block is a list or set of objects and a collection of primitives isn't a block
+const (
+	shapePrimitive       = "primitive"
+	shapeListOfObject    = "list_of_object"
+	shapeSetOfObject     = "set_of_object"
+	shapeListOfPrimitive = "list_of_primitive"
+	shapeSetOfPrimitive  = "set_of_primitive"
+	shapeMapOfPrimitive  = "map_of_primitive"
+)
+
+// schemaShape returns the shape of the schema and the type of a collection's primitive elements, a collection
+// without an element schema holds strings (a map's `Elem: &schema.Resource{}` is the legacy form of a map of strings)
+func schemaShape(s *schema.Schema) (string, string) {
+
+	elemType := schema.TypeString.String()
+	if e, ok := s.Elem.(*schema.Schema); ok {
+		elemType = e.Type.String()
+	}
+	_, isResource := s.Elem.(*schema.Resource)
+
+	switch s.Type {
+	case schema.TypeList:
+		if isResource {
+			return shapeListOfObject, ""
+		}
+		return shapeListOfPrimitive, elemType
+	case schema.TypeSet:
+		if isResource {
+			return shapeSetOfObject, ""
+		}
+		return shapeSetOfPrimitive, elemType
+	case schema.TypeMap:
+		return shapeMapOfPrimitive, elemType
+	default:
+		return shapePrimitive, ""
+	}
+}
+
+// isPrimitiveCollection returns whether the attribute holds a list, set or map of primitive values, an attribute
+// which isn't cloned from the schema (e.g. an injected one) has no shape so is judged by its type
+func isPrimitiveCollection(at attribute) bool {
+	switch at.Shape {
+	case shapeListOfPrimitive, shapeSetOfPrimitive, shapeMapOfPrimitive:
+		return true
+	case "":
+		return !at.IsBlock && (isCollectionType(at.DataTypeString) || at.DataTypeString == schema.TypeMap.String())
+	}
+	return false
+}
+
+// the sources of an attribute's default
+const (
+	defaultSourceSchema      = "schema"
+	defaultSourceFunc        = "default_func"
+	defaultSourceEnvironment = "environment"
+	defaultSourceSummary     = "summary" // overridden in the summary, see valueOverride
+)
+
+// withOverride returns the attribute with the default and possible values overridden in the summary
+func (at attribute) withOverride(o valueOverride) attribute {
+
+	if len(o.Options) > 0 {
+		at.PossibleValues = o.Options
+		at.OptionsOverride = o.Options
+		if len(at.Constraints.OneOf) > 0 {
+			at.Constraints.OneOf = o.Options
+		}
+	}
+	if o.Default != "" {
+		at.Default = o.Default
+		at.DefaultSource = defaultSourceSummary
+		at.DefaultEnv = nil
+	}
+
+	return at
+}
+
+// hasStaticDefault is true when the attribute defaults to the same value wherever the module is applied, which a
+// DefaultFunc's may not
+func (at attribute) hasStaticDefault() bool {
+	return at.Default != "" && at.DefaultSource != defaultSourceEnvironment
+}
+
+// constraints are the limits read back from a schema's validation functions, kept structured so each generator
+// renders them its own way (palette validators, variable validation blocks). Min and Max hold either an IntBetween
+// or a FloatBetween bound, several Patterns or Formats come from an Any composition (any one of them is valid)
+type constraints struct {
+	MinLength  *int
+	MaxLength  *int
+	Min        *float64
+	Max        *float64
+	OneOf      []string // StringInSlice or IntInSlice values
+	Patterns   []string // StringMatch regular expressions, one with its own error message hides the regex
+	Formats    []string // cidr, ip, ipv4, ipv6, url or uuid
+	URLSchemes []string // the schemes of an IsURLWithScheme (IsURLWithHTTPS) url
+	ResourceID string   // the azurerm ID the value is parsed as e.g. `Subnet`, anyAssetType for any resource's ID
+}
+
+// the constraint formats of the IsCIDR, IsIPAddress, IsIPv4Address, IsIPv6Address, IsURLWithScheme and IsUUID
+// validation functions
+const (
+	formatCIDR = "cidr"
+	formatIP   = "ip"
+	formatIPv4 = "ipv4"
+	formatIPv6 = "ipv6"
+	formatURL  = "url"
+	formatUUID = "uuid"
+)
+
+// variableValidation is a validation block rendered into a module variable
+type variableValidation struct {
+	Condition    string
+	ErrorMessage string
 }
 
 // a.IsBlock = isBlock
@@ -118,6 +579,7 @@ type attributeSummary struct {
 	ResourcePath   string
 	Attributes     map[string]attributeSummary
 	Published      bool
+	Deprecated     string
 }
 
 type summaryAttribute struct {
@@ -127,8 +589,68 @@ type summaryAttribute struct {
 	Optional              bool
 	Computed              bool
 	DependentResourcePath string
+	Deprecated            string `json:",omitempty"` // the schema's deprecation message, so it's seen when publishing
+	Sensitive             bool   `json:",omitempty"` // set from the schema, or by the user for a secret it doesn't flag
+
+	// the resource paths of the schema's rules, so publishing respects them
+	ConflictsWith []string `json:",omitempty"`
+	ExactlyOneOf  []string `json:",omitempty"`
+	AtLeastOneOf  []string `json:",omitempty"`
+	RequiredWith  []string `json:",omitempty"`
+
+	// the schema of the attribute (from format 2), so consumers of the summary don't have to load the provider
+	DataType       string       `json:",omitempty"`
+	ElemType       string       `json:",omitempty"` // the type of a list, set or map's primitive elements
+	Description    string       `json:",omitempty"`
+	PossibleValues []string     `json:",omitempty"` // of the attribute or its elements, see schemaPossibleValues
+	Constraints    *constraints `json:",omitempty"`
+
+	// set by the user, the module variable and palette control honor it over the schema
+	Override *valueOverride `json:",omitempty"`
+
+	// set by the user, the `dlta_environment_char` values the attribute is published for e.g. `["d"]`, every
+	// environment when empty. Elsewhere the module applies the default and the palette hides the control
+	Environments []string `json:",omitempty"`
+
+	// proposed when the attribute looks like a reference to another asset, see detectReference. Until the user
+	// confirms it the attribute is a value like any other
+	Reference *proposedReference `json:",omitempty"`
+
+	// set by the user when the summary extends another, the publishing of the attribute is its own rather than
+	// inherited, see applyExtends
+	Overridden bool `json:",omitempty"`
+}
+
+// valueOverride replaces the schema's default and possible values of a published attribute e.g. `{"Default":
+// "standard", "Options": ["standard", "premium"]}` restricts a SKU to an approved subset. The Options must be among
+// the schema's possible values when it has them, and the Default among the Options
+type valueOverride struct {
+	Default string   `json:",omitempty"`
+	Options []string `json:",omitempty"`
 }
 
+// proposedReference is an attribute detected as a reference to the Output of an asset of AssetType e.g. `{"AssetType":
+// "azurerm_key_vault", "Output": "id", "DetectedBy": "id_format"}`. The user sets Confirmed to wire it as a reference
+// rule would, or empties the Output to reject it so re-initialising doesn't propose it again
+type proposedReference struct {
+	AssetType  string
+	Output     string
+	DetectedBy string `json:",omitempty"` // one of the referenceDetection constants
+	Confirmed  bool
+}
+
+// how a reference was detected, from the most to the least certain
+const (
+	// referenceDetectionIDFormat is a reference whose validation parses the value as an azurerm ID
+	referenceDetectionIDFormat = "id_format"
+
+	// referenceDetectionName is a reference whose name is a resource type followed by `_id` or `_name`
+	referenceDetectionName = "name"
+
+	// referenceDetectionDescription is a reference whose description reads `the ID (or name) of the <resource>`
+	referenceDetectionDescription = "description"
+)
+
 // Variables
 var (
 	terraform_azurerm_azurerm_source = attribute{
@@ -200,6 +722,11 @@ var (
 		Description:    "Terraform Data Source Tyoe e.g. resource|data",
 	}
 
+	dlta_import_resource_id = attribute{
+		DataTypeString: "TypeString",
+		Description:    "Resource ID of an existing resource to adopt e.g. /subscriptions/.../resourceGroups/rg-demo",
+	}
+
 	dlta_location_options = []KeyValue{
 		{Key: "northeurope", Value: "northeurope"},
 		{Key: "westeurope", Value: "westeurope"},
@@ -266,8 +793,33 @@ const (
 	LocalBlock
 	OutputBlock
 	PalletteBlock
+	ImportBlock
+	MovedBlock
+	ModuleMeta
+	DataBlock
+	TfvarsExample
+	ProviderBlock
+	RetireBlock
+	MigrateBlock
+	PaletteJson
+	PaletteJsonSchema
+	PaletteBackstage
+	BackstageSkeleton
+	CostMeta
+	InfracostConfig
+	DependencyGraphJson
+	DependencyGraphDot
 )
 
+// templateOnlyAttributes are injected into the palette and the template but never become module variables
+var templateOnlyAttributes = map[string]bool{
+	"dlta_terraform_template":       true,
+	"dlta_naming_convention":        true,
+	"dlta_terraform_module_name":    true,
+	"dlta_terraform_is_data_source": true,
+	"dlta_import_resource_id":       true,
+}
+
 func main() {
 	f := flag.NewFlagSet("example", flag.ExitOnError)
 
@@ -277,6 +829,41 @@ func main() {
 	outputType := f.String("output-type", "", "Custom prop")
 
 	force := f.String("force", "n", "Custom prop")
+	importExisting := f.String("import", "n", "Whether import blocks should be generated to adopt existing resources (y/n)")
+	moduleName := f.String("module-name", "", "The module name of the asset, a moved block is generated when it differs from `-previous-module-name`")
+	previousModuleName := f.String("previous-module-name", "", "The module name of the asset before it was renamed on the canvas")
+	allowInvalid := f.String("allow-invalid", "n", "Whether .tf artefacts which fail HCL validation should be written anyway (y/n)")
+	includeDeprecated := f.String("include-deprecated", "n", "Whether deprecated attributes should be included in the generated artefacts (y/n)")
+	azapiType := f.String("azapi", "", "The ARM resource type to scaffold with azapi_resource e.g. `Microsoft.App/containerApps@2023-05-01`")
+	moduleRefType := f.String("module-ref-type", "branch", "How the module source is pinned, either `tag`, `branch` or `commit`")
+	moduleVersion := f.String("module-version", "main", "The tag, branch or commit the generated template pins the module source to")
+	checkName := f.String("check-name", "", "The generated name to check is available when using `-output-type check-name`")
+	subscriptionId := f.String("subscription-id", os.Getenv("ARM_SUBSCRIPTION_ID"), "The subscription used to check name availability with ARM (authenticated with the Azure CLI)")
+	paletteOutputs := f.String("palette-outputs", "n", "Whether computed attributes (those in `config/palette_outputs.json`, otherwise the id, endpoints and FQDNs) are shown as read only palette controls and module outputs (y/n)")
+	autoRank := f.String("auto-rank", "n", "Whether the palette rank should be ordered by the usage counts in `config/asset_usage.json` (y/n)")
+	dsn := f.String("dsn", "", "The PostgreSQL connection string `-output-type diff` reads the live palette with, using psql")
+	refreshOptions := f.String("refresh-options", "n", "Whether palette options (locations, SKUs) should be fetched from Azure with `-subscription-id` rather than read from the cache (y/n)")
+	paletteTarget := f.String("palette-target", paletteTargetInfraAsset, "The comma separated frontends the palette is rendered for, any of `infra-asset` (the palette table, see -palette-format), `jsonschema` (react-jsonschema-form) or `backstage` (a Backstage template)")
+	paletteFormat := f.String("palette-format", paletteFormatSql, "How the palette is written, either `sql` (pallette.sql), `json` (palette.json with just the controls) or `both`")
+	migrateInput := f.String("migrate-input", "", "The controls json (form_fields) upgraded by `-output-type migrate`, defaults to the resource's palette.json")
+	hardDelete := f.String("hard-delete", "n", "Whether `-output-type retire` should delete the palette row rather than soft delete it (y/n)")
+	paletteTable := f.String("palette-table", defaultPaletteTable, "The `schema.table` the palette SQL upserts the asset into")
+	providerSchema := f.String("provider-schema", "", "The output of `terraform providers schema -json` to read the schema from, for providers (or azurerm versions) the tool isn't compiled against")
+	restSpec := f.String("rest-spec", "", "The Azure REST API spec (the swagger json from azure-rest-api-specs) of the `-azapi` type and API version, which types the body variable")
+	schemaFrom := f.String("from", "", "The provider version `-output-type schema-diff` compares from, read from the schema cache, or the path of a `terraform providers schema -json` output")
+	schemaTo := f.String("to", "", "The provider version (or schema json) `-output-type schema-diff` compares to, defaults to the provider the tool is compiled against")
+	strict := f.String("strict", "n", "Whether scaffold should fail rather than warn when `<resource>.json` was initialised with another provider version, and `-output-type deprecations` when published attributes are deprecated or removed (y/n)")
+	maxDepth := f.String("max-depth", strconv.Itoa(defaultMaxDepth), "How deeply nested blocks of the schema are walked, deeper blocks (and blocks which contain themselves) are left out with a warning")
+	layout := f.String("layout", layoutFlat, "How the resources are laid out within the dlta path, either `flat` (`r/<resource>`) or `service` (`r/<service>/<resource>`, grouped by the service registering them)")
+	profile := f.String("profile", "", "The publish profile of `<resource>.json` to scaffold as a palette asset of its own e.g. `minimal`, init adds it from the attributes published when it isn't there")
+	attr := f.String("attr", "", "The comma separated resource paths `-output-type publish` and `unpublish` edit in `<resource>.json`, where `*` matches one element e.g. `azurerm_key_vault.network_acls.*`")
+	apply := f.String("apply", "n", "Whether the resource should be scaffolded once `-output-type publish` or `unpublish` has edited `<resource>.json` (y/n)")
+	all := f.String("all", "n", "Whether `-output-type init` should initialise (or merge) the summary of every resource and data source the provider registers, rather than the one named (y/n)")
+	service := f.String("service", "", "The service `-output-type init -all y` is limited to e.g. `KeyVault`, case and punctuation are ignored")
+	matrixFormat := f.String("matrix-format", matrixFormatMarkdown, "How `-output-type matrix` writes the matrix of the attributes, either `markdown` (matrix.md) or `csv` (matrix.csv)")
+	lockTimeout := f.String("lock-timeout", defaultLockTimeout.String(), "How long to wait for another run writing the same resource (or the catalog) to release its lock e.g. `2m`, `0s` fails straight away")
+	solutionPath := f.String("solution", "", "The canvas export (a solution's assets, their values and connections) `-output-type solution` renders the root configuration of, to `<dlta-path>/solutions/<name>`")
+	refreshSchema := f.String("refresh-schema", "n", "Whether the schema should be read from the provider rather than the schema cache in `<dlta-path>/cache/schema`, which is keyed by the provider version so must be refreshed when a development build's schemas change (y/n)")
 
 	_ = f.Parse(os.Args[1:])
 
@@ -285,23 +872,47 @@ func main() {
 		os.Exit(1)
 	}
 
-	if resourceName == nil || *resourceName == "" {
+	// exporting the catalog covers every scaffolded resource, and initialising all every registered one, so neither
+	// takes a resource, nor does a solution which names its own. The matrix, the deprecation report and migrating the
+	// summaries cover every summary unless limited to the resources named
+	isExport := *outputType == "export-all"
+	isInitAll := *outputType == "init" && *all == "y"
+	isMatrix := *outputType == "matrix"
+	isDeprecations := *outputType == "deprecations"
+	isMigrateSummaries := *outputType == "migrate-summaries"
+	isSolution := *outputType == "solution"
+
+	if *all == "y" && *outputType != "init" {
+		quitWithError("`-all y` can only be used with `-output-type init`")
+		return
+	}
+	if *service != "" && !isInitAll {
+		quitWithError("`-service` limits `-output-type init -all y` so needs `-all y`")
+		return
+	}
+
+	if *matrixFormat != matrixFormatMarkdown && *matrixFormat != matrixFormatCsv {
+		quitWithError("`-matrix-format` must be either `markdown` or `csv`")
+		return
+	}
+
+	if !isExport && !isInitAll && !isMatrix && !isDeprecations && !isMigrateSummaries && !isSolution && (resourceName == nil || *resourceName == "") {
 		quitWithError("The name of the Data Source/Resource must be specified via `-name`")
 		return
 	}
 
-	if resourceType == nil || *resourceType == "" {
+	if !isExport && !isInitAll && !isMatrix && !isDeprecations && !isMigrateSummaries && !isSolution && (resourceType == nil || *resourceType == "") {
 		quitWithError("The type of the Data Source/Resource must be specified via `-type`")
 		return
 	}
 
-	if *resourceType != "data" && *resourceType != "resource" {
+	if !isExport && !isInitAll && !isMatrix && !isDeprecations && !isMigrateSummaries && !isSolution && *resourceType != "data" && *resourceType != "resource" {
 		quitWithError("The type of the Data Source/Resource specified via `-type` must be either `data` or `resource`")
 		return
 	}
 
-	if *outputType != "init" && *outputType != "scaffold" && *outputType != "config" {
-		quitWithError("`-output-type` must be either `init`, `scaffold` or `config`")
+	if *outputType != "init" && *outputType != "scaffold" && *outputType != "config" && *outputType != "check-name" && *outputType != "retire" && *outputType != "migrate" && *outputType != "diff" && *outputType != "schema-diff" && *outputType != "publish" && *outputType != "unpublish" && *outputType != "history" && !isMatrix && !isDeprecations && !isMigrateSummaries && !isSolution && !isExport {
+		quitWithError("`-output-type` must be either `init`, `scaffold`, `config`, `check-name`, `retire`, `migrate`, `diff`, `schema-diff`, `publish`, `unpublish`, `history`, `matrix`, `deprecations`, `migrate-summaries`, `solution` or `export-all`")
 		return
 	}
 
@@ -315,1462 +926,10693 @@ func main() {
 		return
 	}
 
-	isForced := *force == "y"
-	isResource := *resourceType == "resource"
+	if *azapiType != "" {
+		if *resourceType != "resource" {
+			quitWithError("`-azapi` can only be used with `-type resource`")
+			return
+		}
+		if !azapiTypeRegex.MatchString(*azapiType) {
+			quitWithError("`-azapi` must be an ARM resource type and API version e.g. `Microsoft.App/containerApps@2023-05-01`")
+			return
+		}
+		if *providerSchema != "" {
+			quitWithError("`-azapi` and `-provider-schema` can't be used together")
+			return
+		}
+	}
 
-	if err := run(*resourceName, isResource, *dltaPath, *outputType, isForced); err != nil {
-		panic(err)
+	if *restSpec != "" && *azapiType == "" {
+		quitWithError("`-rest-spec` types the body of an `-azapi` resource so needs `-azapi`")
+		return
 	}
-}
 
-func run(resourceName string, isResource bool, dltaPath string, outputType string, isForced bool) error {
-	_, err := getContent(resourceName, isResource, dltaPath, outputType, isForced)
+	resolvedDltaPath, err := resolveDltaPath(*dltaPath)
 	if err != nil {
-		return fmt.Errorf("building content: %s", err)
+		quitWithError(err.Error())
+		return
 	}
 
-	return err
-	// return saveContent(resourceName, websitePath, *content, isResource)
-}
-
-func getContent(resourceName string, isResource bool, dltaPath string, outputType string, isForced bool) (*string, error) {
-	generator := documentationGenerator{
-		resourceName: resourceName,
-		isDataSource: !isResource,
-		// exampleSource: expsrc,
-		dltaPath:   dltaPath,
-		isResource: isResource,
-		isForced:   isForced,
+	if err := validateModuleRef(*moduleRefType, *moduleVersion); err != nil {
+		quitWithError(err.Error())
+		return
 	}
 
-	if resourceName != "terraform_azurerm" && resourceName != "devops_pipeline" {
+	if isSolution != (*solutionPath != "") {
+		quitWithError("`-output-type solution` needs the canvas export specified via `-solution`, which only it uses")
+		return
+	}
 
-		if !isResource {
-			for _, service := range provider.SupportedTypedServices() {
-				for _, ds := range service.DataSources() {
-					if ds.ResourceType() == resourceName {
-						wrapper := sdk.NewDataSourceWrapper(ds)
-						dsWrapper, err := wrapper.DataSource()
-						if err != nil {
-							return nil, fmt.Errorf("wrapping Data Source %q: %+v", ds.ResourceType(), err)
-						}
+	if *outputType == "schema-diff" && *schemaFrom == "" {
+		quitWithError("`-output-type schema-diff` needs the provider version (or `terraform providers schema -json` output) to compare from via `-from`")
+		return
+	}
 
-						generator.resource = dsWrapper
-						// generator.websiteCategories = service.WebsiteCategories()
-						break
-					}
-				}
-			}
-			for _, service := range provider.SupportedUntypedServices() {
-				for key, ds := range service.SupportedDataSources() {
-					if key == resourceName {
-						generator.resource = ds
-						// generator.websiteCategories = service.WebsiteCategories()
-						break
-					}
-				}
-			}
+	attributePatterns := make([]string, 0)
+	for _, p := range strings.Split(*attr, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			attributePatterns = append(attributePatterns, p)
+		}
+	}
+	if (*outputType == "publish" || *outputType == "unpublish") && len(attributePatterns) == 0 {
+		quitWithError(fmt.Sprintf("`-output-type %s` needs the resource paths specified via `-attr` e.g. `-attr %s.sku_name`", *outputType, *resourceName))
+		return
+	}
 
-			if generator.resource == nil {
-				return nil, fmt.Errorf("Data Source %q was not registered!", resourceName)
-			}
-		} else {
-			for _, service := range provider.SupportedTypedServices() {
-				for _, rs := range service.Resources() {
-					if rs.ResourceType() == resourceName {
-						wrapper := sdk.NewResourceWrapper(rs)
-						rsWrapper, err := wrapper.Resource()
-						if err != nil {
-							return nil, fmt.Errorf("wrapping Resource %q: %+v", rs.ResourceType(), err)
-						}
+	if *outputType == "diff" && *dsn == "" {
+		quitWithError("`-output-type diff` needs the palette database specified via `-dsn`")
+		return
+	}
 
-						generator.resource = rsWrapper
-						// generator.websiteCategories = service.WebsiteCategories()
-						break
-					}
-				}
-			}
-			for _, service := range provider.SupportedUntypedServices() {
-				for key, rs := range service.SupportedResources() {
-					if key == resourceName {
-						generator.resource = rs
-						// generator.websiteCategories = service.WebsiteCategories()
-						break
-					}
-				}
-			}
+	if *refreshOptions == "y" && *subscriptionId == "" {
+		quitWithError("`-refresh-options y` needs a subscription specified via `-subscription-id` or ARM_SUBSCRIPTION_ID")
+		return
+	}
 
-			if generator.resource == nil {
-				return nil, fmt.Errorf("Resource %q was not registered!", resourceName)
-			}
-		}
-	} else {
-		generator.resourceName = resourceName
+	if *paletteFormat != paletteFormatSql && *paletteFormat != paletteFormatJson && *paletteFormat != paletteFormatBoth {
+		quitWithError("`-palette-format` must be either `sql`, `json` or `both`")
+		return
 	}
 
-	generator.ShortCode = getResourceShortCode(generator.resourceName)
-	generator.NamingConvention = generator.getResourceNamingConvention(generator.resourceName, generator.isDataSource)
+	paletteTargets, err := parsePaletteTargets(*paletteTarget)
+	if err != nil {
+		quitWithError(err.Error())
+		return
+	}
 
-	if outputType == "init" {
-		_ = generator.writeInitResourceProperties()
-		// _ = generator.writeAllInputAttributesSummary()
-	} else if outputType == "scaffold" {
-		_ = generator.scaffoldConfiguation()
-		// return &docs, nil
+	if !paletteTableRegex.MatchString(*paletteTable) {
+		quitWithError("`-palette-table` must be a lowercase `schema.table` e.g. `core.infra_asset`")
+		return
 	}
 
-	return nil, nil
-}
+	if *layout != layoutFlat && *layout != layoutService {
+		quitWithError("`-layout` must be either `flat` or `service`")
+		return
+	}
 
-// Full Attributes
-func (gen documentationGenerator) getAllInputAttributes(input map[string]*schema.Schema, parent attribute, isChild bool, parentPath string) map[string]attribute {
+	if *profile != "" && !profileNameRegex.MatchString(*profile) {
+		quitWithError("`-profile` must be lowercase letters, digits, `-` and `_` e.g. `minimal`")
+		return
+	}
 
-	// resourceName := gen.resourceName
-	// gen.resource.Schema, gen.resourceName, attribute{}, false, gen.resourceName
-	retAttributes := make(map[string]attribute)
+	lockWait, err := time.ParseDuration(*lockTimeout)
+	if err != nil || lockWait < 0 {
+		quitWithError("`-lock-timeout` must be a duration of at least `0s` e.g. `30s` or `2m`")
+		return
+	}
 
-	for _, fieldName := range gen.sortFields(input) {
+	depth, err := strconv.Atoi(*maxDepth)
+	if err != nil || depth < 1 {
+		quitWithError("`-max-depth` must be a whole number of at least 1")
+		return
+	}
 
-		if input[fieldName].Required || input[fieldName].Optional {
-			a := attribute{}
-			if isBlock(input[fieldName]) {
+	options := scaffoldOptions{
+		dltaPathFlag: *dltaPath,
+
+		isForced:          *force == "y",
+		isImport:          *importExisting == "y",
+		allowInvalid:      *allowInvalid == "y",
+		includeDeprecated: *includeDeprecated == "y",
+		azapiType:         *azapiType,
+		moduleRefType:     *moduleRefType,
+		moduleVersion:     *moduleVersion,
+		checkName:         *checkName,
+		subscriptionId:    *subscriptionId,
+		paletteTable:      *paletteTable,
+		autoRank:          *autoRank == "y",
+		showOutputs:       *paletteOutputs == "y",
+		hardDelete:        *hardDelete == "y",
+		migrateInput:      *migrateInput,
+		dsn:               *dsn,
+		paletteFormat:     *paletteFormat,
+		paletteTargets:    paletteTargets,
+		refreshOptions:    *refreshOptions == "y",
+		refreshSchema:     *refreshSchema == "y",
+		providerSchema:    *providerSchema,
+		restSpec:          *restSpec,
+		strict:            *strict == "y",
+		schemaFrom:        *schemaFrom,
+		schemaTo:          *schemaTo,
+		maxDepth:          depth,
+		layout:            *layout,
+		profile:           *profile,
+		matrixFormat:      *matrixFormat,
+		attributePatterns: attributePatterns,
+		apply:             *apply == "y",
+		lockTimeout:       lockWait,
+
+		moduleName:         *moduleName,
+		previousModuleName: *previousModuleName,
+	}
+	isResource := *resourceType == "resource"
 
-				cloneSchemaToAttributes(&a, input[fieldName], true, parentPath, fieldName)
-				//attrib = attribute{IsBlock: true, MaxItems: input[fieldName].MaxItems, Required: input[fieldName].Required, DataTypeString: input[fieldName].Type.String(), Optional: input[fieldName].Optional, MinItems: input[fieldName].MinItems, ForceNew: input[fieldName].ForceNew}
-				// retap := gen.getAllAttributes(input[fieldName].Elem.(*schema.Resource).Schema, resourceName, a, true, parentPath+"."+fieldName)
+	if isExport {
+		if err := exportPaletteCatalog(resolvedDltaPath, options); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-				retap := gen.getAllInputAttributes(input[fieldName].Elem.(*schema.Resource).Schema, a, true, parentPath+"."+fieldName)
-				a.Attributes = retap
-			} else {
-				cloneSchemaToAttributes(&a, input[fieldName], false, parentPath, fieldName)
+	if isSolution {
+		if err := exportSolution(resolvedDltaPath, *solutionPath, options); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-				//attrib = attribute{IsBlock: false, MaxItems: input[fieldName].MaxItems, Required: input[fieldName].Required, DataTypeString: input[fieldName].Type.String(), Optional: input[fieldName].Optional, MinItems: input[fieldName].MinItems, ForceNew: input[fieldName].ForceNew}
+	if isMatrix || isDeprecations || isMigrateSummaries {
+		names := make([]string, 0)
+		for _, name := range strings.Split(*resourceName, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		report := exportSummaryMatrix
+		if isDeprecations {
+			report = printDeprecations
+		} else if isMigrateSummaries {
+			report = migrateSummaries
+		}
+		if err := report(resolvedDltaPath, names, options); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-				b := input[fieldName]
+	if isInitAll {
+		if err := initAllResourceProperties(resolvedDltaPath, *service, options); err != nil {
+			panic(err)
+		}
+		return
+	}
 
-				if possibleValues := getSchemaPossibleValues(b); len(possibleValues) > 0 {
-					for i := 0; i < len(possibleValues); i++ {
-						a.PossibleValues = append(a.PossibleValues, possibleValues[i])
+	if err := run(*resourceName, isResource, resolvedDltaPath, *outputType, options); err != nil {
+		panic(err)
+	}
+}
 
-					}
-				}
+func run(resourceName string, isResource bool, dltaPath string, outputType string, options scaffoldOptions) error {
+	_, err := getContent(resourceName, isResource, dltaPath, outputType, options)
+	if err != nil {
+		return fmt.Errorf("building content: %s", err)
+	}
 
-				b, isSchema := input[fieldName].Elem.(*schema.Schema)
+	return err
+	// return saveContent(resourceName, websitePath, *content, isResource)
+}
 
-				if isSchema {
-					if possibleValues := getSchemaPossibleValues(b); len(possibleValues) > 0 {
-						for i := 0; i < len(possibleValues); i++ {
-							a.PossibleValues = append(a.PossibleValues, possibleValues[i])
+func getContent(resourceName string, isResource bool, dltaPath string, outputType string, options scaffoldOptions) (content *string, err error) {
+	generator, err := newDocumentationGenerator(resourceName, isResource, dltaPath, options)
+	if err != nil {
+		return nil, err
+	}
 
-						}
-					}
-				}
-				// writeDebugJson(attrib)
-				// fmt.Printf("field	%s	%t\n", fieldName, isChild)
+	// output types writing the summary or artefacts hold the resource's lock, so concurrent runs take turns
+	switch outputType {
+	case "init", "scaffold", "publish", "unpublish", "retire", "migrate":
+		release, lockErr := acquireLock(generator.resourceLockDir(), generator.lockTimeout)
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		defer release()
+
+		// recorded while the lock is held, once the operation has succeeded
+		operation := outputType
+		defer func() {
+			if err == nil {
+				generator.recordHistory(operation)
 			}
+		}()
+	}
 
-			retAttributes[fieldName] = a
+	// the history is only read, so like retiring it doesn't need the schema
+	if outputType == "history" {
+		return nil, generator.printHistory()
+	}
+
+	// a retired resource may no longer be registered by the provider, so it's retired before the schema is looked up
+	if outputType == "retire" {
+		generator.writeResource(generator.paletteRetireBlock(), RetireBlock)
+		return nil, nil
+	}
+
+	// migrating rewrites the controls already in the palette, so like retiring it doesn't need the schema
+	if outputType == "migrate" {
+		migrateBlock, err := generator.paletteMigrateBlock()
+		if err != nil {
+			return nil, err
+		}
+		if migrateBlock != "" {
+			generator.writeResource(migrateBlock, MigrateBlock)
 		}
+		return nil, nil
+	}
 
+	// the schemas compared are read from the cache (or schema json), the loaded provider is only needed without `-to`
+	if outputType == "schema-diff" {
+		return nil, generator.printSchemaDiff()
 	}
 
-	return retAttributes
+	if err := generator.lookupResource(); err != nil {
+		return nil, err
+	}
+
+	// publishing edits the summary, which is scaffolded with `-apply y`
+	if outputType == "publish" || outputType == "unpublish" {
+		if _, err := generator.setPublished(generator.attributePatterns, outputType == "publish"); err != nil {
+			return nil, err
+		}
+		if !generator.apply {
+			return nil, nil
+		}
+		outputType = "scaffold"
+	}
+
+	if outputType == "init" {
+		_ = generator.writeInitResourceProperties()
+		// _ = generator.writeAllInputAttributesSummary()
+	} else if outputType == "scaffold" {
+		if content, err := os.ReadFile(generator.resourcePropertiesPath()); err == nil {
+			if err := generator.validateResourceSummary(content); err != nil {
+				return nil, err
+			}
+			for _, unknown := range generator.unknownResourceSummaryPaths(content) {
+				fmt.Printf("scaffold \"drift\": %s, re-initialise it with `-output-type init` to drop it\n", unknown)
+			}
+			raw := make(map[string]json.RawMessage)
+			if json.Unmarshal(content, &raw) == nil && summaryFormatOf(raw) < summaryFormat {
+				fmt.Printf("scaffold \"summary format\": %s is format %d, upgrade it to %d with `-output-type migrate-summaries`\n", generator.resourcePropertiesPath(), summaryFormatOf(raw), summaryFormat)
+			}
+		}
+		if err := generator.validateProfile(); err != nil {
+			return nil, err
+		}
+		if err := generator.validatePublishConsistency(); err != nil {
+			return nil, err
+		}
+		if warning, drifted := generator.summaryDriftWarning(); drifted && generator.strict {
+			return nil, fmt.Errorf("%s, re-initialise it with `-output-type init` or scaffold without `-strict y`", warning)
+		}
+		_ = generator.scaffoldConfiguation()
+		// return &docs, nil
+	} else if outputType == "check-name" {
+		return nil, generator.checkNameAvailability(context.Background())
+	} else if outputType == "diff" {
+		return nil, generator.printPaletteDiff(context.Background(), generator.psqlQuery)
+	}
 
+	return nil, nil
 }
 
-func (gen documentationGenerator) getAllOutputAttributes(input map[string]*schema.Schema, parent attribute, isChild bool, parentPath string) map[string]attribute {
+// the formats of `-output-type matrix`
+const (
+	matrixFormatMarkdown = "markdown"
+	matrixFormatCsv      = "csv"
+)
 
-	retAttributes := make(map[string]attribute)
+// matrixColumns are the columns of the matrix of the attributes, see exportSummaryMatrix
+var matrixColumns = []string{"Resource", "Attribute", "Type", "Required", "Published", "Constraints"}
 
-	var (
-		id = attribute{
-			DataTypeString: "TypeString",
-			Description:    "The resource id",
-		}
+// summarisedResources returns the resources (then data sources) under the dlta path which have been initialised, the
+// palette they're scaffolded to isn't needed
+func summarisedResources(dltaPath string) ([]scaffoldedResource, error) {
 
-		name = attribute{
-			DataTypeString: "TypeString",
-			Description:    "The resource name",
+	resources := make([]scaffoldedResource, 0)
+	for _, kind := range []string{"r", "d"} {
+		dirs, err := scaffoldedDirs(dltaPath, kind)
+		if err != nil {
+			return nil, err
 		}
-	)
 
-	retAttributes["id"] = id
-	retAttributes["name"] = name
+		for _, name := range sortedKeys(dirs) {
+			if _, err := os.Stat(filepath.Join(dirs[name], "resource", name+".json")); err == nil {
+				resources = append(resources, scaffoldedResource{name: name, isResource: kind == "r"})
+			}
+		}
+	}
 
-	// writeDebugJson(retAttributes)
-	return retAttributes
+	return resources, nil
 }
 
-func (gen documentationGenerator) summariseAttributes(a map[string]attribute, rn string, parentRequired bool) map[string]summaryAttribute {
+// namedSummarisedResources returns the resources under the dlta path which have been initialised, limited to those
+// named when there are any
+func namedSummarisedResources(dltaPath string, names []string) ([]scaffoldedResource, error) {
 
-	printAttributes := func(a map[string]attribute, rn string) map[string]summaryAttribute {
-		retAttributes := make(map[string]summaryAttribute)
-		for k, a := range a {
-			published := false
-			if parentRequired {
-				if a.Required {
-					published = true
+	resources, err := summarisedResources(dltaPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		named := make([]scaffoldedResource, 0, len(names))
+		for _, name := range names {
+			found := false
+			for _, r := range resources {
+				if r.name == name {
+					named = append(named, r)
+					found = true
 				}
-			} else {
-				published = false
 			}
-			newrn := rn + "." + k
-
-			if a.IsBlock {
-				retAttributes[a.ResourcePath] = summaryAttribute{Published: published, IsBlock: a.IsBlock, Required: a.Required, Optional: a.Optional, Computed: a.Computed}
-				a := gen.summariseAttributes(a.Attributes, newrn, published)
-				for n, v := range a {
-					retAttributes[n] = summaryAttribute{Published: v.Published, IsBlock: v.IsBlock}
-				}
-
-			} else {
-				retAttributes[a.ResourcePath] = summaryAttribute{Published: published, IsBlock: a.IsBlock, Required: a.Required, Optional: a.Optional, Computed: a.Computed}
+			if !found {
+				return nil, fmt.Errorf("%s hasn't been initialised under %s", name, dltaPath)
 			}
 		}
-
-		return retAttributes
+		resources = named
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no initialised resources were found under %s", dltaPath)
 	}
 
-	return printAttributes(a, rn)
+	return resources, nil
 }
 
-func (gen documentationGenerator) writeResource(s string, a Artefact) string {
+// exportSummaryMatrix writes the attributes of the summaries (of every resource initialised, or those named) to
+// `<dlta-path>/catalog` as a matrix platform owners can sign off, what each resource publishes is resolved as for
+// scaffolding (extends and `-profile`). Only the summaries are read, so the provider isn't loaded
+func exportSummaryMatrix(dltaPath string, names []string, options scaffoldOptions) error {
 
-	var fileName string
-	var subDir string
+	resources, err := namedSummarisedResources(dltaPath, names)
+	if err != nil {
+		return err
+	}
 
-	resourceKind := "r"
-	if !gen.isResource {
-		resourceKind = "d"
+	rows := make([][]string, 0)
+	for _, r := range resources {
+		gen := documentationGenerator{resourceName: r.name, isResource: r.isResource, dltaPath: dltaPath, scaffoldOptions: options}
+		sa := gen.readResourceProperties()
+		for _, rp := range sortedKeys(sa) {
+			rows = append(rows, matrixRow(r.name, rp, sa[rp]))
+		}
 	}
 
-	// fileName := strings.TrimPrefix(gen.resourceName, "azurerm_")
-	if a == TerraformTemplate {
-		fileName = "template.json"
-		subDir = "resource"
-	} else if a == ModuleBlock {
-		fileName = "main.tf"
-		subDir = "module"
-	} else if a == VariableBlock {
-		fileName = "variables.tf"
-		subDir = "module"
-	} else if a == PalletteBlock {
-		fileName = "pallette.sql"
-		subDir = "resource"
-	} else if a == OutputBlock {
-		fileName = "output.tf"
-		subDir = "module"
-	} else if a == LocalBlock {
-		fileName = "local.tf"
-		subDir = "module"
+	fileName, content := "matrix.md", renderMarkdownMatrix(rows)
+	if options.matrixFormat == matrixFormatCsv {
+		fileName = "matrix.csv"
+		if content, err = renderCsvMatrix(rows); err != nil {
+			return err
+		}
+	}
+	release, err := acquireLock(filepath.Join(dltaPath, catalogDir), options.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := writeCatalogFile(dltaPath, fileName, content, options.isForced); err != nil {
+		return err
 	}
 
-	dirName := gen.resourceName
-	// /home/dermot/source/repo/Repo.DltaModules
+	fmt.Printf("exportSummaryMatrix \"exported\" %d attributes of %d resources to %s\n", len(rows), len(resources), filepath.Join(dltaPath, catalogDir, fileName))
+	return nil
+}
+
+// matrixRow returns the columns of an attribute in the matrix, see matrixColumns
+func matrixRow(resourceName string, rp string, a summaryAttribute) []string {
 
-	outputDirectoryName := fmt.Sprintf("%s//%s//%s//%s//", gen.dltaPath, resourceKind, dirName, subDir)
-	// outputDirectoryPath, err := filepath.Abs(outputDirectoryName)
-	outputDirectoryPath := outputDirectoryName
+	dataType := strings.ToLower(strings.TrimPrefix(a.DataType, "Type"))
+	if a.IsBlock {
+		dataType = "block"
+	} else if a.ElemType != "" {
+		dataType = fmt.Sprintf("%s(%s)", dataType, strings.ToLower(strings.TrimPrefix(a.ElemType, "Type")))
+	}
 
-	// if err != nil {
-	// 	fmt.Printf("writeResource \"0. directory error\": %v\n", err.Error())
-	// }
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
 
-	outputFileName := fmt.Sprintf("%s//%s//%s//%s//%s", gen.dltaPath, resourceKind, dirName, subDir, fileName)
-	// outputPath, err := filepath.Abs(outputFileName)
+	return []string{resourceName, strings.TrimPrefix(rp, resourceName+"."), dataType, yesNo(a.Required), yesNo(a.Published), strings.Join(matrixConstraints(a), "; ")}
+}
 
-	outputPath := outputFileName
+// matrixConstraints describes what an attribute is limited to, the options and default overridden in the summary
+// replace the schema's possible values
+func matrixConstraints(a summaryAttribute) []string {
 
-	// if err != nil {
-	// 	fmt.Printf("writeResource \"1. file error\": %v\n", err.Error())
-	// }
+	described := make([]string, 0)
+	values := a.PossibleValues
+	if a.Override != nil && len(a.Override.Options) > 0 {
+		values = a.Override.Options
+	}
+	if len(values) > 0 {
+		described = append(described, "one of "+strings.Join(values, ", "))
+	}
+	if a.Override != nil && a.Override.Default != "" {
+		described = append(described, "defaults to "+a.Override.Default)
+	}
+	if len(a.Environments) > 0 {
+		described = append(described, "only in "+strings.Join(a.Environments, ", "))
+	}
 
-	if gen.isForced {
-		if _, err := os.Stat(outputPath); err == nil {
-			// fmt.Printf("initResourceProperties \"2. Clearing out previous version of file\": %s\n", outputPath)
-			os.Remove(outputPath)
+	if c := a.Constraints; c != nil {
+		number := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+		switch {
+		case c.MinLength != nil && c.MaxLength != nil:
+			described = append(described, fmt.Sprintf("%d to %d characters", *c.MinLength, *c.MaxLength))
+		case c.MinLength != nil:
+			described = append(described, fmt.Sprintf("at least %d characters", *c.MinLength))
+		case c.MaxLength != nil:
+			described = append(described, fmt.Sprintf("at most %d characters", *c.MaxLength))
+		}
+		switch {
+		case c.Min != nil && c.Max != nil:
+			described = append(described, fmt.Sprintf("between %s and %s", number(*c.Min), number(*c.Max)))
+		case c.Min != nil:
+			described = append(described, "at least "+number(*c.Min))
+		case c.Max != nil:
+			described = append(described, "at most "+number(*c.Max))
+		}
+		for _, p := range c.Patterns {
+			described = append(described, "matches "+p)
+		}
+		if len(c.Formats) > 0 {
+			described = append(described, "a "+strings.Join(c.Formats, " or "))
 		}
 	}
 
-	if _, err := os.Stat(outputPath); err == nil {
+	return described
+}
 
-		fmt.Printf("writeResource \"3. File exists error\"  on path: %s\n", outputPath)
+// renderMarkdownMatrix renders the matrix as a markdown table
+func renderMarkdownMatrix(rows [][]string) string {
 
-		// fmt.Printf("\"After stat, file exists error\": %s\n", outputPath)
-	} else {
-		err := os.MkdirAll(outputDirectoryPath, os.ModePerm)
-		if err != nil {
-			fmt.Printf("writeResource \"4.1 directory error\": %v\n", err.Error())
+	cell := func(v string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(v, "|", "\\|"), "\n", " ")
+	}
+	line := func(values []string) string {
+		cells := make([]string, 0, len(values))
+		for _, v := range values {
+			cells = append(cells, cell(v))
 		}
+		return "| " + strings.Join(cells, " | ") + " |\n"
+	}
 
-		file, err := os.Create(outputPath)
-		if err != nil {
-			fmt.Printf("writeResource \"4. file error\": %v\n", err.Error())
-		}
-		if os.IsExist(err) {
-			os.Remove(outputPath)
-			file, err = os.Create(outputPath)
-			if err != nil {
-				fmt.Printf("writeResource \"5. file error\": %v\n", err.Error())
-			}
-		}
-		defer file.Close()
+	separator := make([]string, len(matrixColumns))
+	for i := range separator {
+		separator[i] = "---"
+	}
 
-		// s = strings.TrimSpace(s)
-		_, _ = file.WriteString(s)
-		file.Sync()
+	content := fmt.Sprintf("<!-- Code generated by dlta-scaffold %s; DO NOT EDIT. -->\n\n", generatorVersion)
+	content += line(matrixColumns) + line(separator)
+	for _, row := range rows {
+		content += line(row)
 	}
+	return content
+}
 
-	return ""
+// renderCsvMatrix renders the matrix as csv with a header row
+func renderCsvMatrix(rows [][]string) (string, error) {
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.WriteAll(append([][]string{matrixColumns}, rows...)); err != nil {
+		return "", fmt.Errorf("writing the matrix: %+v", err)
+	}
+	return b.String(), nil
 }
 
-func (gen documentationGenerator) writeInitResourceProperties() string {
+// deprecatedAttribute is a published attribute of a summary which the loaded provider deprecates or has removed
+type deprecatedAttribute struct {
+	Resource     string
+	ResourcePath string // the resource's name when the provider no longer registers it
+	Removed      bool
+	Message      string // the deprecation message of the schema (or of the summary, for an attribute since removed)
+	Replacement  string // the resource path the message points to instead, when there is one
+}
 
-	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" {
-		attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+// deprecationReferenceRegex matches the names quoted in a deprecation message e.g. "use `sku_name` instead"
+var deprecationReferenceRegex = regexp.MustCompile("[`'\"]([a-z0-9_.]+)[`'\"]")
 
-		// f := gen.getAllInputAttributesSummary(gen.resource.Schema, attributeSummary{}, false, gen.resourceName)
-		// writeDebugJson(f)
+// deprecationWordRegex matches the unquoted attribute names of a deprecation message, those with an underscore
+var deprecationWordRegex = regexp.MustCompile(`\b[a-z0-9]+(_[a-z0-9]+)+\b`)
 
-		flatted := gen.summariseAttributes(attributes, gen.resourceName, true)
+// findDeprecations cross-references the summaries (of every resource initialised, or those named) with the loaded
+// provider's schemas, returning the published attributes (of the summary or any of its profiles) it now deprecates
+// or has removed
+func findDeprecations(dltaPath string, names []string, options scaffoldOptions) ([]deprecatedAttribute, int, error) {
 
-		content := writeJson(flatted)
+	resources, err := namedSummarisedResources(dltaPath, names)
+	if err != nil {
+		return nil, 0, err
+	}
 
-		resourceKind := "r"
-		if !gen.isResource {
-			resourceKind = "d"
+	found := make([]deprecatedAttribute, 0)
+	for _, r := range resources {
+		gen, err := newDocumentationGenerator(r.name, r.isResource, dltaPath, options)
+		if err != nil {
+			return nil, 0, err
 		}
 
-		// fileName := strings.TrimPrefix(gen.resourceName, "azurerm_")
-		fileName := gen.resourceName
-		dirName := gen.resourceName
-		subDir := "resource"
-		outputDirectoryName := fmt.Sprintf("%s//%s//%s//%s//", gen.dltaPath, resourceKind, dirName, subDir)
-		// outputDirectoryPath, err := filepath.Abs(outputDirectoryName)
-
-		outputDirectoryPath := outputDirectoryName
+		content, err := os.ReadFile(gen.resourcePropertiesPath())
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading %s: %+v", gen.resourcePropertiesPath(), err)
+		}
+		// read before the schema is looked up, so the attributes it has since removed aren't rejected as unknown
+		sa := gen.readResourceProperties()
+		published := make(map[string]bool)
+		for rp, a := range sa {
+			published[rp] = a.Published
+		}
+		for _, paths := range readSummaryProfiles(content) {
+			for _, rp := range paths {
+				published[rp] = true
+			}
+		}
 
-		// if err != nil {
-		// 	fmt.Printf("initResourceProperties \"0. directory error\": %v\n", err.Error())
-		// }
+		// the provider no longer registering the resource removes it, any other error is reported as it is
+		if err := gen.lookupResource(); errors.Is(err, errNotRegistered) {
+			found = append(found, deprecatedAttribute{Resource: r.name, ResourcePath: r.name, Removed: true, Message: err.Error()})
+			continue
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("looking up %s: %+v", r.name, err)
+		}
 
-		outputFileName := fmt.Sprintf("%s//%s//%s//%s//%s.json", gen.dltaPath, resourceKind, dirName, subDir, fileName)
-		// outputPath, err := filepath.Abs(outputFileName)
+		for _, rp := range sortedKeys(published) {
+			if !published[rp] {
+				continue
+			}
+			siblings, s := schemaAtPath(gen.resource.Schema, gen.attributePath(rp))
+			switch {
+			case s == nil:
+				d := deprecatedAttribute{Resource: r.name, ResourcePath: rp, Removed: true, Message: sa[rp].Deprecated}
+				d.Replacement = deprecationReplacement(rp, d.Message, siblings)
+				found = append(found, d)
+			case s.Deprecated != "":
+				d := deprecatedAttribute{Resource: r.name, ResourcePath: rp, Message: s.Deprecated}
+				d.Replacement = deprecationReplacement(rp, d.Message, siblings)
+				found = append(found, d)
+			}
+		}
+	}
 
-		outputPath := outputFileName
+	return found, len(resources), nil
+}
 
-		// if err != nil {
-		// 	fmt.Printf("initResourceProperties \"1. file error\": %v\n", err.Error())
-		// }
+// schemaAtPath returns the schema of an attribute by its path relative to the resource (e.g. `network_acls.bypass`)
+// along with the schemas alongside it, the schema is nil when the attribute (or a block containing it) isn't there
+func schemaAtPath(input map[string]*schema.Schema, path string) (map[string]*schema.Schema, *schema.Schema) {
 
-		if gen.isForced {
-			if _, err := os.Stat(outputPath); err == nil {
-				// fmt.Printf("initResourceProperties \"2. Clearing out previous version of file\": %s\n", outputPath)
-				os.Remove(outputPath)
-			}
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		s, ok := input[part]
+		if !ok {
+			return input, nil
 		}
+		if i == len(parts)-1 {
+			return input, s
+		}
+		block, ok := s.Elem.(*schema.Resource)
+		if !ok {
+			return nil, nil
+		}
+		input = block.Schema
+	}
 
-		if _, err := os.Stat(outputPath); err == nil {
+	return input, nil
+}
 
-			fmt.Printf("initResourceProperties \"3. File exists error\": %s\n", outputPath)
+// deprecationReplacement returns the resource path of the attribute a deprecation message suggests instead, the first
+// name it quotes (or failing that, mentions) which is an attribute alongside the deprecated one, or within a block
+// alongside it
+func deprecationReplacement(rp string, message string, siblings map[string]*schema.Schema) string {
 
-			// fmt.Printf("\"After stat, file exists error\": %s\n", outputPath)
-		} else {
-			err := os.MkdirAll(outputDirectoryPath, os.ModePerm)
-			if err != nil {
-				fmt.Printf("initResourceProperties \"4.1 directory error\": %v\n", err.Error())
-			}
+	parent, name := rp[:strings.LastIndex(rp, ".")], rp[strings.LastIndex(rp, ".")+1:]
+	candidates := make([]string, 0)
+	for _, m := range deprecationReferenceRegex.FindAllStringSubmatch(message, -1) {
+		candidates = append(candidates, m[1])
+	}
+	candidates = append(candidates, deprecationWordRegex.FindAllString(message, -1)...)
 
-			file, err := os.Create(outputPath)
-			if err != nil {
-				fmt.Printf("initResourceProperties \"4. file error\": %v\n", err.Error())
-			}
-			if os.IsExist(err) {
-				os.Remove(outputPath)
-				file, err = os.Create(outputPath)
-				if err != nil {
-					fmt.Printf("initResourceProperties \"5. file error\": %v\n", err.Error())
+	for _, c := range candidates {
+		c = c[strings.LastIndex(c, ".")+1:]
+		s, ok := siblings[c]
+		if !ok || c == name || s.Deprecated != "" {
+			continue
+		}
+		// "renamed to `x` within the `y` block" points to the attribute of the block the message names
+		if block, ok := s.Elem.(*schema.Resource); ok {
+			for _, nested := range candidates {
+				if n, ok := block.Schema[nested]; ok && n.Deprecated == "" {
+					return parent + "." + c + "." + nested
 				}
 			}
-			defer file.Close()
-
-			content = strings.TrimSpace(content)
-			_, _ = file.WriteString(content)
-			file.Sync()
 		}
+		return parent + "." + c
 	}
+
 	return ""
 }
 
-func (gen documentationGenerator) readResourceProperties() map[string]summaryAttribute {
-
-	data := make(map[string]summaryAttribute)
+// printDeprecations reports the published attributes the loaded provider deprecates or has removed, with `-strict y`
+// finding any is an error so the report can gate CI
+func printDeprecations(dltaPath string, names []string, options scaffoldOptions) error {
 
-	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" {
+	found, summaries, err := findDeprecations(dltaPath, names, options)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		color.Green("None of the attributes published by the %d summaries are deprecated or removed in provider %s", summaries, version.ProviderVersion)
+		return nil
+	}
 
-		resourceKind := "r"
-		if !gen.isResource {
-			resourceKind = "d"
+	fmt.Printf("Published attributes deprecated or removed in provider %s:\n", version.ProviderVersion)
+	resource := ""
+	for _, d := range found {
+		if d.Resource != resource {
+			resource = d.Resource
+			fmt.Printf("%s:\n", resource)
 		}
 
-		fileName := gen.resourceName
-		dirName := gen.resourceName
-		subDir := "resource"
-
-		outputFileName := fmt.Sprintf("%s//%s//%s//%s//%s.json", gen.dltaPath, resourceKind, dirName, subDir, fileName)
-
-		outputPath, err := filepath.Abs(outputFileName)
-		if err != nil {
-			fmt.Printf("readResourceProperties \"1. file error\": %v\n", err.Error())
-			return data
+		line := fmt.Sprintf("  %s deprecated", d.ResourcePath)
+		printLine := color.Yellow
+		if d.Removed {
+			line = fmt.Sprintf("  %s removed", d.ResourcePath)
+			printLine = color.Red
 		}
-
-		if _, err := os.Stat(outputPath); err != nil {
-			fmt.Printf("readResourceProperties \"2. File does not exist\": %s\n", outputPath)
-			fmt.Printf("readResourceProperties \"2. You may not have run init to create\": %s\n", outputPath)
-			return data
+		if d.Message != "" {
+			line += ": " + strings.Join(strings.Fields(d.Message), " ")
 		}
-
-		fileContent, err := os.ReadFile(outputPath)
-
-		if err != nil {
-			fmt.Printf("readResourceProperties \"file error\": %v\n", err.Error())
-			return data
+		if d.Replacement != "" {
+			line += fmt.Sprintf(" (use %s)", d.Replacement)
 		}
+		printLine("%s", line)
+	}
 
-		_ = json.Unmarshal([]byte(fileContent), &data)
+	if options.strict {
+		return fmt.Errorf("%d published attributes are deprecated or removed in provider %s", len(found), version.ProviderVersion)
 	}
-	return data
+	return nil
 }
 
-func (gen documentationGenerator) getPublishedAttributes() map[string]attribute {
+// registeredResource is a resource (or data source) registered by a service of the provider
+type registeredResource struct {
+	name              string
+	isResource        bool
+	resource          *schema.Resource
+	serviceName       string
+	websiteCategories []string
+}
 
-	publishedAttributes := make(map[string]attribute)
+// registeredResources returns the resources and data sources registered by the services of the provider sorted by
+// name, limited to the service named when serviceFilter isn't empty. The case and punctuation of the name are ignored so
+// `KeyVault`, `Key Vault` and `key-vault` are the same service
+func registeredResources(serviceFilter string) ([]registeredResource, error) {
 
-	if gen.resource != nil {
-		inputAttributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	letters := func(name string) string {
+		return strings.ReplaceAll(serviceDirName(name), "-", "")
+	}
+	matchesService := func(name string) bool {
+		return serviceFilter == "" || letters(name) == letters(serviceFilter)
+	}
 
-		sa := gen.readResourceProperties()
+	found := make(map[string]registeredResource)
+	add := func(r registeredResource) {
+		kind := "d"
+		if r.isResource {
+			kind = "r"
+		}
+		found[kind+"/"+r.name] = r
+	}
 
-		// sa := summariseAttributesForConfig(attributes, gen.resourceName, true)
+	services := 0
+	for _, service := range provider.SupportedTypedServices() {
+		if !matchesService(service.Name()) {
+			continue
+		}
+		services++
+		for _, ds := range service.DataSources() {
+			wrapper := sdk.NewDataSourceWrapper(ds)
+			dsWrapper, err := wrapper.DataSource()
+			if err != nil {
+				return nil, fmt.Errorf("wrapping Data Source %q: %+v", ds.ResourceType(), err)
+			}
+			add(registeredResource{name: ds.ResourceType(), resource: dsWrapper, serviceName: service.Name(), websiteCategories: service.WebsiteCategories()})
+		}
+		for _, rs := range service.Resources() {
+			wrapper := sdk.NewResourceWrapper(rs)
+			rsWrapper, err := wrapper.Resource()
+			if err != nil {
+				return nil, fmt.Errorf("wrapping Resource %q: %+v", rs.ResourceType(), err)
+			}
+			add(registeredResource{name: rs.ResourceType(), isResource: true, resource: rsWrapper, serviceName: service.Name(), websiteCategories: service.WebsiteCategories()})
+		}
+	}
+	for _, service := range provider.SupportedUntypedServices() {
+		if !matchesService(service.Name()) {
+			continue
+		}
+		services++
+		for name, ds := range service.SupportedDataSources() {
+			add(registeredResource{name: name, resource: ds, serviceName: service.Name(), websiteCategories: service.WebsiteCategories()})
+		}
+		for name, rs := range service.SupportedResources() {
+			add(registeredResource{name: name, isResource: true, resource: rs, serviceName: service.Name(), websiteCategories: service.WebsiteCategories()})
+		}
+	}
 
-		publishedAttributes = gen.getAllPublishedAttributes(inputAttributes, sa)
+	if services == 0 {
+		return nil, fmt.Errorf("no service %q is registered by the provider", serviceFilter)
 	}
 
-	return publishedAttributes
+	resources := make([]registeredResource, 0, len(found))
+	for _, key := range sortedKeys(found) {
+		resources = append(resources, found[key])
+	}
+	return resources, nil
 }
 
-func (gen documentationGenerator) getAllPublishedAttributes(allAttr map[string]attribute, sa map[string]summaryAttribute) map[string]attribute {
-
-	retAttributes := make(map[string]attribute)
-
-	for k, a := range allAttr {
+// initAllResourceProperties initialises the summary of every resource and data source registered by the provider (or
+// the service), merging those which exist as `-output-type init` does. The provider is loaded once for them all
+func initAllResourceProperties(dltaPath string, serviceFilter string, options scaffoldOptions) error {
 
-		t := attribute{}
+	resources, err := registeredResources(serviceFilter)
+	if err != nil {
+		return err
+	}
 
-		if !sa[a.ResourcePath].Published {
+	initialised := 0
+	for _, r := range resources {
+		gen, err := newDocumentationGenerator(r.name, r.isResource, dltaPath, options)
+		if err != nil {
+			fmt.Printf("initAllResourceProperties \"skipping\" %s: %v\n", r.name, err)
 			continue
 		}
-		t = a
-
-		if a.IsBlock {
-			tempAttributes := make(map[string]attribute)
-			for k2, a2 := range gen.getAllPublishedAttributes(a.Attributes, sa) {
-				if sa[a2.ResourcePath].Published {
-					tempAttributes[k2] = a2
-				}
-			}
-			t.Attributes = tempAttributes
+		gen.resource = r.resource
+		gen.serviceName = r.serviceName
+		gen.websiteCategories = r.websiteCategories
 
+		release, err := acquireLock(gen.resourceLockDir(), gen.lockTimeout)
+		if err != nil {
+			fmt.Printf("initAllResourceProperties \"skipping\" %s: %v\n", r.name, err)
+			continue
 		}
-
-		retAttributes[k] = t
+		gen.writeInitResourceProperties()
+		gen.recordHistory("init")
+		release()
+		initialised++
 	}
 
-	return retAttributes
+	fmt.Printf("initAllResourceProperties \"initialised\" %d of %d resources and data sources in %s\n", initialised, len(resources), dltaPath)
+	return nil
 }
 
-func (gen documentationGenerator) scaffoldConfiguation() string {
-
-	gen.writeResource(gen.terraformTemplateBlock(), TerraformTemplate)
+// paletteCatalogEntry is an asset in the json palette catalog, holding the columns of the palette table
+type paletteCatalogEntry struct {
+	AssetType     string     `json:"asset_type"`
+	Name          string     `json:"name"`
+	Label         string     `json:"label"`
+	PaletteDesign PaletteObj `json:"palette_design"`
+	FormFields    Creator    `json:"form_fields"`
+	Rank          int        `json:"rank"`
+	HasCost       bool       `json:"has_cost"`
+	SvgIcon       string     `json:"svg_icon"`
+}
 
-	// writeDebug("#### Template block:\n" + gen.terraformTemplateBlock() + "\n")
-	gen.writeResource(gen.terraformModuleBlock(), ModuleBlock)
-	// writeDebug("#### Module block:\n" + gen.terraformModuleBlock() + "\n")
+// catalogDir is the directory under the dlta path `-output-type export-all` writes the palette catalog to
+const catalogDir = "catalog"
 
-	gen.writeResource(gen.terraformVariableBlock(), VariableBlock)
-	// writeDebug("#### Variable block:\n" + gen.terraformVariableBlock() + "\n")
+// scaffoldedDirs returns the directories of the resources (`r`) or data sources (`d`) within the dlta path keyed by
+// name. With `-layout service` they're within a directory per service, which unlike a resource has no `_` in its name
+func scaffoldedDirs(dltaPath string, kind string) (map[string]string, error) {
 
-	gen.writeResource(gen.terraformLocalBlock(), LocalBlock)
-	// writeDebug("#### Local block:\n" + gen.terraformLocalBlock() + "\n")
+	dirs := make(map[string]string)
 
-	gen.writeResource(gen.dltaPalletteCodeBlock(), PalletteBlock)
-	// writeDebug("#### Pallette block:\n" + gen.dltaPalletteCodeBlock() + "\n")
+	var read func(dir string, withinService bool) error
+	read = func(dir string, withinService bool) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("reading %s: %+v", dir, err)
+		}
 
-	gen.writeResource(gen.terraformOutputBlock(), OutputBlock)
-	// writeDebug("#### Output block:\n" + gen.terraformOutputBlock() + "\n")
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if !withinService && !strings.Contains(entry.Name(), "_") {
+				if err := read(filepath.Join(dir, entry.Name()), true); err != nil {
+					return err
+				}
+				continue
+			}
+			dirs[entry.Name()] = filepath.Join(dir, entry.Name())
+		}
+		return nil
+	}
 
-	//TODO
-	// OutputBlock
+	if err := read(filepath.Join(dltaPath, kind), false); err != nil {
+		return nil, err
+	}
 
-	return ""
+	return dirs, nil
 }
 
-func (gen documentationGenerator) getInjectAttributes() map[string]attribute {
-
-	injectAttributes := make(map[string]attribute)
-
-	if gen.resourceName == "terraform_azurerm" {
-		injectAttributes["terraform_azurerm_azurerm_source"] = terraform_azurerm_azurerm_source
-		injectAttributes["terraform_azurerm_azurerm_version"] = terraform_azurerm_azurerm_version
-		injectAttributes["terraform_azurerm_azapi_source"] = terraform_azurerm_azapi_source
-		injectAttributes["terraform_azurerm_azapi_version"] = terraform_azurerm_azapi_version
-		injectAttributes["dlta_terraform_template"] = dlta_terraform_template
-		injectAttributes["dlta_naming_convention"] = dlta_naming_convention
-
-	} else if gen.resourceName == "devops_pipeline" {
+// scaffoldedResource is a resource or data source with a palette written by `scaffold`
+type scaffoldedResource struct {
+	name       string
+	isResource bool
+}
 
-		//TODO
-	} else {
+// scaffoldedResources returns the resources (then data sources) under the dlta path which have a palette, sorted
+// by name. Retired resources are skipped as their palette row has been removed
+func scaffoldedResources(dltaPath string) ([]scaffoldedResource, error) {
 
-		//TODO Dedupe this
-		if gen.isDataSource {
-			injectAttributes["dlta_environment_char"] = dlta_environment_char
-			injectAttributes["dlta_application_short_code"] = dlta_application_short_code
-			injectAttributes["dlta_business_short_code"] = dlta_business_short_code
-			injectAttributes["dlta_instance_id"] = dlta_instance_id
-			injectAttributes["dlta_location_short_code"] = dlta_location_short_code
-			injectAttributes["dlta_vendor_asset_short_code"] = dlta_vendor_asset_short_code
-			injectAttributes["dlta_terraform_template"] = dlta_terraform_template
-			injectAttributes["dlta_naming_convention"] = dlta_naming_convention
-			injectAttributes["dlta_terraform_module_name"] = dlta_terraform_module_name
-			injectAttributes["dlta_terraform_data_source_name"] = dlta_terraform_data_source_name
-			injectAttributes["dlta_terraform_is_data_source"] = dlta_terraform_is_data_source
-		} else {
-			injectAttributes["dlta_environment_char"] = dlta_environment_char
-			injectAttributes["dlta_application_short_code"] = dlta_application_short_code
-			injectAttributes["dlta_business_short_code"] = dlta_business_short_code
-			injectAttributes["dlta_instance_id"] = dlta_instance_id
-			injectAttributes["dlta_location_short_code"] = dlta_location_short_code
-			injectAttributes["dlta_vendor_asset_short_code"] = dlta_vendor_asset_short_code
-			injectAttributes["dlta_terraform_template"] = dlta_terraform_template
-			injectAttributes["dlta_naming_convention"] = dlta_naming_convention
-			injectAttributes["dlta_terraform_module_name"] = dlta_terraform_module_name
-			injectAttributes["dlta_terraform_is_data_source"] = dlta_terraform_is_data_source
+	resources := make([]scaffoldedResource, 0)
+	for _, kind := range []string{"r", "d"} {
+		dirs, err := scaffoldedDirs(dltaPath, kind)
+		if err != nil {
+			return nil, err
 		}
 
+		for _, name := range sortedKeys(dirs) {
+			resourceDir := filepath.Join(dirs[name], "resource")
+			if _, err := os.Stat(filepath.Join(resourceDir, retireFileName)); err == nil {
+				fmt.Printf("scaffoldedResources \"retired\" skipping %s\n", name)
+				continue
+			}
+			for _, fileName := range []string{"pallette.sql", "palette.json"} {
+				if _, err := os.Stat(filepath.Join(resourceDir, fileName)); err == nil {
+					resources = append(resources, scaffoldedResource{name: name, isResource: kind == "r"})
+					break
+				}
+			}
+		}
 	}
 
-	return injectAttributes
+	return resources, nil
 }
 
-func (gen documentationGenerator) injectAttributes() map[string]attribute {
+// exportPaletteCatalog regenerates the palette of every scaffolded resource into a single catalog for seeding the
+// palette table of a new environment in one go, `catalog/palette.sql` upserting every asset in a transaction and/or
+// `catalog/palette.json` with an entry per asset (as chosen with `-palette-format`)
+func exportPaletteCatalog(dltaPath string, options scaffoldOptions) error {
 
-	allAttributes := make(map[string]attribute)
+	release, err := acquireLock(filepath.Join(dltaPath, catalogDir), options.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	injectAttributes := gen.getInjectAttributes()
-	publishedAttributes := gen.getPublishedAttributes()
+	resources, err := scaffoldedResources(dltaPath)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		return fmt.Errorf("no scaffolded resources were found under %s", dltaPath)
+	}
 
-	// allAttributes = injectAttributes + publishedAttributes
+	statements := make([]string, 0)
+	entries := make([]paletteCatalogEntry, 0)
+	edges := make([]dependencyEdge, 0)
+	for _, r := range resources {
+		gen, err := newDocumentationGenerator(r.name, r.isResource, dltaPath, options)
+		if err == nil {
+			err = gen.lookupResource()
+		}
+		if err != nil {
+			fmt.Printf("exportPaletteCatalog \"skipping\" %s: %v\n", r.name, err)
+			continue
+		}
 
-	for k, a := range injectAttributes {
-		allAttributes[k] = a
+		statements = append(statements, gen.dltaPalletteCodeBlock())
+		entries = append(entries, gen.paletteCatalogEntry())
+		edges = append(edges, gen.dependencyEdges()...)
+
+		// each profile is an asset of its own
+		if content, err := os.ReadFile(gen.resourcePropertiesPath()); err == nil {
+			for _, profile := range sortedKeys(readSummaryProfiles(content)) {
+				gen.profile = profile
+				statements = append(statements, gen.dltaPalletteCodeBlock())
+				entries = append(entries, gen.paletteCatalogEntry())
+				edges = append(edges, gen.dependencyEdges()...)
+			}
+		}
 	}
 
-	for k, a := range publishedAttributes {
-		allAttributes[k] = a
+	// the solution's graph, of every asset the catalog holds
+	graph := newDependencyGraph(edges)
+	if err := writeCatalogFile(dltaPath, "graph.json", writeJson(graph), options.isForced); err != nil {
+		return err
+	}
+	if err := writeCatalogFile(dltaPath, "graph.dot", renderDependencyGraphDot(graph), options.isForced); err != nil {
+		return err
 	}
 
-	return allAttributes
+	if options.paletteFormat != paletteFormatJson {
+		catalog := "begin;\n\n" + strings.Join(statements, "\n\n") + "\n\ncommit;\n"
+		if err := writeCatalogFile(dltaPath, "palette.sql", catalog, options.isForced); err != nil {
+			return err
+		}
+	}
+	if options.paletteFormat == paletteFormatJson || options.paletteFormat == paletteFormatBoth {
+		if err := writeCatalogFile(dltaPath, "palette.json", writeJson(entries), options.isForced); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("exportPaletteCatalog \"exported\" %d assets of %d resources to %s\n", len(entries), len(resources), filepath.Join(dltaPath, catalogDir))
+	return nil
 }
 
-func (gen documentationGenerator) terraformTemplateBlock() string {
+// writeCatalogFile writes a file of the palette catalog, an existing catalog is only replaced with `-force`
+func writeCatalogFile(dltaPath string, fileName string, content string, isForced bool) error {
 
-	attributes := gen.injectAttributes()
+	outputPath := filepath.Join(dltaPath, catalogDir, fileName)
+	if _, err := os.Stat(outputPath); err == nil && !isForced {
+		return fmt.Errorf("%s already exists, use `-force y` to replace it", outputPath)
+	}
 
-	var templateBlock string
-	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" {
-		//TODO  Add module name
+	if strings.HasSuffix(fileName, ".sql") {
+		header := fmt.Sprintf("-- Code generated by dlta-scaffold %s; DO NOT EDIT.\n", generatorVersion)
+		header += fmt.Sprintf("-- Provider version: %s\n", version.ProviderVersion)
+		header += fmt.Sprintf("-- Regenerate with: go run ./internal/tools/dlta-scaffold -dlta-path %s -output-type export-all -force y\n\n", dltaPath)
+		content = header + content
+	}
 
-		if gen.isDataSource {
-			//data "azurerm_subnet" "sub" {
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return fmt.Errorf("creating %s: %+v", filepath.Dir(outputPath), err)
+	}
+	if err := writeFileAtomically(outputPath, []byte(content)); err != nil {
+		return fmt.Errorf("writing %s: %+v", outputPath, err)
+	}
 
-			templateBlock += fmt.Sprintf("data \"%s\" \"${%s}\" {\n", gen.resourceName, "dlta_terraform_module_name")
+	return nil
+}
 
-			// templateBlock += fmt.Sprintf("\tsource                      = \"__repo_path__/%s//module?ref=main\"\n", "Module."+resource.Name)
-		} else {
-			templateBlock += fmt.Sprintf("module \"${%s}\" {\n", "dlta_terraform_module_name")
+// solutionsDir is where the root configuration of each solution is written within the dlta path
+const solutionsDir = "solutions"
+
+// solution is a canvas export, the assets placed on the canvas with the values of their palette controls and the
+// connections wiring a reference of one asset to another e.g. a web app's service plan. The root configuration is
+// sourced from the modules at ModulesPath (which replaces `__modules_path__` in the templates) and applied to each of
+// the Environments, every `dlta_environment_char` when there are none
+type solution struct {
+	Name         string               `json:"name"`
+	ModulesPath  string               `json:"modules_path"`
+	Environments []string             `json:"environments"`
+	Assets       []solutionAsset      `json:"assets"`
+	Connections  []solutionConnection `json:"connections"`
+}
 
-			templateBlock += fmt.Sprintf("\tsource                      = \"__modules_path__//r//%s//module?ref=main\"\n", gen.resourceName)
-		}
+// solutionAsset is an asset on the canvas, its module is named by Name (else its `dlta_terraform_module_name` value).
+// The values are those of the palette controls, Environments overrides them by `dlta_environment_char` e.g.
+// `{"p": {"sku_name": "P1v3"}}`. An asset of type `terraform_azurerm` sets the terraform block rather than a module
+type solutionAsset struct {
+	Name         string                            `json:"name"`
+	AssetType    string                            `json:"asset_type"`
+	Values       map[string]interface{}            `json:"values"`
+	Environments map[string]map[string]interface{} `json:"environments"`
+}
 
-		if attributes["location"].DataTypeString != "" {
-			templateBlock += fmt.Sprintf("\tlocation                    = \"${%s}\"\n", "location")
-		}
+// solutionConnection wires the reference Control of the asset From to the module of the asset To, or to an Existing
+// resource looked up by a data source with the arguments e.g. `{"name": "rg-shared"}`
+type solutionConnection struct {
+	From     string                 `json:"from"`
+	Control  string                 `json:"control"`
+	To       string                 `json:"to"`
+	Existing map[string]interface{} `json:"existing"`
+}
 
-		templateBlock += fmt.Sprintf("\tdlta_location_short_code    = ${%s}\n", "dlta_location_short_code")
-		templateBlock += fmt.Sprintf("\tdlta_environment_char       = ${%s}\n", "dlta_environment_char")
-		templateBlock += fmt.Sprintf("\tdlta_business_short_code    = ${%s}\n", "dlta_business_short_code")
-		templateBlock += fmt.Sprintf("\tdlta_application_short_code = ${%s}\n", "dlta_application_short_code")
-		templateBlock += fmt.Sprintf("\tdlta_instance_id            = ${%s}\n", "dlta_instance_id")
-		templateBlock += fmt.Sprintf("\tdlta_vendor_asset_short_code	= ${%s}\n", "dlta_vendor_asset_short_code")
+var (
+	// solutionNameRegex matches the name of a solution, its directory within solutionsDir
+	solutionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
 
-		for n, at := range attributes {
-			// Exclude location as we are overriding the name above
-			// Exclude name as this will be
-			if n == "location" || strings.Contains(n, "dlta") || n == "name" {
-				continue
-			} else {
-				if !at.Computed {
+	// moduleNameRegex matches the name of a module call, a terraform identifier
+	moduleNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
 
-					if !at.IsBlock {
+	// templateReferenceRegex matches a reference of a template, the output of the module named by a control
+	templateReferenceRegex = regexp.MustCompile(`module\.\$\{([A-Za-z0-9_]+)\}\.([A-Za-z0-9_]+)`)
 
-						if n == "resource_group_name" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tresource_group_name		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tresource_group_name		= module.${ResourceGroup}.name\n") // BUG, Resource Group is camel case in solution
-							}
+	// templateSecretRegex matches the root variable a template reads a sensitive attribute from, see secretReference
+	templateSecretRegex = regexp.MustCompile(`var\.\$\{dlta_terraform_module_name\}_([A-Za-z0-9_]+)`)
 
-						} else if n == "virtual_network_name" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tvirtual_network_name		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tvirtual_network_name		= module.${virtual_network_name}.name\n") // BUG, Resource Group is camel case in solution
-							}
-						} else if n == "private_connection_resource_id" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tprivate_connection_resource_id		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tprivate_connection_resource_id		= module.${private_connection_resource_id}.id\n") // BUG, Resource Group is camel case in solution
-							}
-						} else if n == "subnet_id" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tsubnet_id		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tsubnet_id		= module.${subnet_id}.id\n") // BUG, Resource Group is camel case in solution
-							}
-						} else if n == "service_plan_id" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tservice_plan_id		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tservice_plan_id		= module.${service_plan_id}.id\n") // BUG, Resource Group is camel case in solution
-							}
-						} else if n == "storage_account_name" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tstorage_account_name		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tstorage_account_name		= module.${storage_account_name}.name\n") // BUG, Resource Group is camel case in solution
-							}
-						} else if n == "storage_uses_managed_identity" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tstorage_account_name		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tstorage_uses_managed_identity				= ${storage_uses_managed_identity}\n") // BUG, Resource Group is camel case in solution
-							}
-						} else if n == "virtual_network_subnet_id" {
-							if gen.isDataSource {
-								templateBlock += fmt.Sprintf("\tstorage_account_name		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-							} else {
-								templateBlock += fmt.Sprintf("\tvirtual_network_subnet_id				= module.${virtual_network_subnet_id}.id\n") // BUG, Resource Group is camel case in solution
-							}
-						} else {
-							if at.DataTypeString == schema.TypeList.String() {
-								templateBlock += fmt.Sprintf("\t%s		= ${%s}\n", n, n)
-							} else {
-								templateBlock += fmt.Sprintf("\t%s		= \"${%s}\"\n", n, n)
-							}
+	// quotedPlaceholderRegex matches a placeholder which is the whole of a quoted string
+	quotedPlaceholderRegex = regexp.MustCompile(`"\$\{([A-Za-z0-9_]+)\}"`)
+)
 
-						}
-					} else {
+// moduleName returns the name of the asset's module call
+func (a solutionAsset) moduleName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	name, _ := a.Values["dlta_terraform_module_name"].(string)
+	return name
+}
 
-						for n1, at1 := range at.Attributes {
-							if !at1.IsBlock {
-								if n1 == "name" {
-									continue
-								} else if n1 == "private_connection_resource_id" {
-									if gen.isDataSource {
-										templateBlock += fmt.Sprintf("\tprivate_connection_resource_id		= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-									} else {
-										templateBlock += fmt.Sprintf("\tprivate_connection_resource_id		= module.${private_connection_resource_id}.id\n") // BUG, Resource Group is camel case in solution
-									}
-								} else if n1 == "is_manual_connection		" {
-									if gen.isDataSource {
-										templateBlock += fmt.Sprintf("\tis_manual_connection				= \"${DataResourceGroup}\"\n") // BUG, Resource Group is camel case in solution
-									} else {
-										templateBlock += fmt.Sprintf("\tis_manual_connection				= ${is_manual_connection		}\n") // BUG, Resource Group is camel case in solution
-									}
-								} else {
-									if at1.DataTypeString == schema.TypeList.String() {
-										templateBlock += fmt.Sprintf("\t%s		= ${%s}\n", n1, n1)
-									} else {
-										templateBlock += fmt.Sprintf("\t%s		= \"${%s}\"\n", n1, n1)
-									}
-								}
-							} else {
-								for n2, at2 := range at1.Attributes {
-									if n2 == "name" && at2.ResourcePath != "azurerm_subnet.delegation.service_delegation.name" {
-										continue
-									} else {
+// isDataSource reports whether the asset is a data source, as its `dlta_terraform_is_data_source` control says
+func (a solutionAsset) isDataSource() bool {
+	return a.Values["dlta_terraform_is_data_source"] == "data"
+}
 
-										if n2 == "name" {
+// readSolution reads the canvas export at the path, the connections are applied to the values of the controls they
+// wire and the environments default to every `dlta_environment_char`
+func readSolution(solutionPath string) (solution, error) {
 
-											vn := genVariableNameFromResourcePath(at2.ResourcePath)
+	var s solution
+	content, err := os.ReadFile(solutionPath)
+	if err != nil {
+		return s, fmt.Errorf("reading %s: %+v", solutionPath, err)
+	}
+	if err := json.Unmarshal(content, &s); err != nil {
+		return s, fmt.Errorf("parsing %s: %+v", solutionPath, err)
+	}
 
-											templateBlock += fmt.Sprintf("\t%s		= ${%s}\n", vn, vn)
-										} else {
-											if at2.DataTypeString == schema.TypeList.String() {
-												templateBlock += fmt.Sprintf("\t%s		= ${%s}\n", n2, n2)
-											} else {
-												templateBlock += fmt.Sprintf("\t%s		= \"${%s}\"\n", n2, n2)
-											}
-										}
+	if len(s.Environments) == 0 {
+		for _, o := range dlta_environment_char_options {
+			s.Environments = append(s.Environments, o.Value)
+		}
+	}
 
-									}
-								}
+	problems := make([]string, 0)
+	if !solutionNameRegex.MatchString(s.Name) {
+		problems = append(problems, fmt.Sprintf("name: must be lowercase letters, digits, `-` and `_` e.g. `web-app`, got %q", s.Name))
+	}
+	for i, e := range s.Environments {
+		if !isEnvironmentChar(e) {
+			problems = append(problems, fmt.Sprintf("environments: [%d]: %q isn't a `dlta_environment_char`", i, e))
+		}
+	}
 
-							}
-						}
-					}
-				}
+	assets := make(map[string]int)
+	for i, a := range s.Assets {
+		if a.Values == nil {
+			s.Assets[i].Values = make(map[string]interface{})
+		}
+		if a.AssetType == "" {
+			problems = append(problems, fmt.Sprintf("assets: [%d]: `asset_type` must be set", i))
+		}
+		if a.AssetType == "terraform_azurerm" {
+			continue
+		}
+		name := a.moduleName()
+		if !moduleNameRegex.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("assets: [%d]: `name` must be a module name, got %q", i, name))
+		} else if _, ok := assets[name]; ok {
+			problems = append(problems, fmt.Sprintf("assets: [%d]: %q is the name of another asset", i, name))
+		}
+		assets[name] = i
+		for _, e := range sortedKeys(a.Environments) {
+			if !slices.Contains(s.Environments, e) {
+				problems = append(problems, fmt.Sprintf("assets: [%d]: environments: %q isn't one of the solution's environments %s", i, e, strings.Join(s.Environments, ", ")))
 			}
-
 		}
+	}
 
-		templateBlock += "}\n"
-	} else if gen.resourceName == "terraform_azurerm" {
-		templateBlock += "terraform {\n"
-		templateBlock += "	required_providers {\n"
-		templateBlock += "		azurerm = {\n"
-		templateBlock += fmt.Sprintf("			source =  \"${%s}\"\n", "terraform_azurerm_azurerm_source")
-		templateBlock += fmt.Sprintf("			version = \"${%s}\"\n", "terraform_azurerm_azurerm_version")
-		templateBlock += "		}\n"
-		templateBlock += "		azapi = {\n"
-		templateBlock += fmt.Sprintf("			source =  \"${%s}\"\n", "terraform_azurerm_azapi_source")
-		templateBlock += fmt.Sprintf("			version = \"${%s}\"\n", "terraform_azurerm_azapi_version")
-		templateBlock += "		}\n"
-		templateBlock += "	}\n"
-		templateBlock += "	backend \"azurerm\" {\n"
-		templateBlock += "	}\n"
-		templateBlock += "}\n"
+	for i, c := range s.Connections {
+		from, ok := assets[c.From]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("connections: [%d]: `from` %q isn't an asset", i, c.From))
+			continue
+		}
+		if c.Control == "" {
+			problems = append(problems, fmt.Sprintf("connections: [%d]: `control` must be set", i))
+			continue
+		}
+		if c.Existing != nil {
+			if c.To != "" || len(c.Existing) == 0 {
+				problems = append(problems, fmt.Sprintf("connections: [%d]: `existing` needs the arguments looking the resource up, instead of `to`", i))
+				continue
+			}
+			s.Assets[from].Values[c.Control+referenceModeSuffix] = referenceModeExisting
+			for argument, value := range c.Existing {
+				s.Assets[from].Values[c.Control+existingArgumentInfix+argument] = value
+			}
+			continue
+		}
+		if _, ok := assets[c.To]; !ok {
+			problems = append(problems, fmt.Sprintf("connections: [%d]: `to` %q isn't an asset", i, c.To))
+			continue
+		}
+		s.Assets[from].Values[c.Control] = c.To
+	}
 
-		templateBlock += "provider \"azurerm\" {\n"
-		templateBlock += "	features {\n"
-		templateBlock += "	}\n"
-		templateBlock += "}\n"
-	} else if gen.resourceName == "devops_pipeline" {
-		templateBlock += "name: $(connection)-$(Date:yyyyMMdd)$(Rev:.r)\n"
-		templateBlock += "variables:\n"
-		templateBlock += "  connection: 'sub-ret-d-001'\n"
-		templateBlock += "trigger: none\n"
-		templateBlock += "resources:\n"
-		templateBlock += "  repositories:\n"
-		templateBlock += "	- repository: Repo.Pipelines\n"
-		templateBlock += "	  type: git\n"
-		templateBlock += "	  name: Repo.Pipelines\n"
-		templateBlock += "	  ref: refs/heads/main\n"
-		templateBlock += "stages:\n"
-		templateBlock += "- template: TerraformStages.yml@Repo.Pipelines\n"
-		templateBlock += "  parameters:\n"
-		templateBlock += "	ServiceShort      : storage_policy_test\n"
-		templateBlock += "	serviceConnection : 'ServiceConnection.sub-ret-d-001'\n"
-		templateBlock += "	EnvironmentShort  : dev\n"
+	if len(problems) > 0 {
+		return s, fmt.Errorf("%s:\n  %s", filepath.Base(solutionPath), strings.Join(problems, "\n  "))
 	}
+	return s, nil
+}
 
-	return templateBlock
+// assetTemplate returns the template of the asset, the `dlta_terraform_template` the canvas exported with it or else
+// the one scaffolded (for its profile) under the dlta path
+func assetTemplate(dltaPath string, a solutionAsset) (string, error) {
+
+	if template, ok := a.Values["dlta_terraform_template"].(string); ok && template != "" {
+		return template, nil
+	}
+
+	dir, err := assetDir(dltaPath, a)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "resource", "template.json"))
+	if err != nil {
+		return "", fmt.Errorf("reading the template of %s: %+v", a.AssetType, err)
+	}
+	return string(content), nil
 }
 
-func (gen documentationGenerator) terraformModuleBlock() string {
+// assetReferenceTypes returns the asset type each reference control of the asset is to, read from the dependency
+// graph scaffolded with it
+func assetReferenceTypes(dltaPath string, a solutionAsset) (map[string]string, error) {
 
-	attributes := gen.injectAttributes()
+	dir, err := assetDir(dltaPath, a)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "resource", "graph.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading the dependency graph of %s, scaffold it again to write one: %+v", a.AssetType, err)
+	}
+	var graph dependencyGraph
+	if err := json.Unmarshal(content, &graph); err != nil {
+		return nil, fmt.Errorf("parsing the dependency graph of %s: %+v", a.AssetType, err)
+	}
 
-	var moduleBlock string
-	var appendBlock string
+	types := make(map[string]string, len(graph.Edges))
+	for _, e := range graph.Edges {
+		types[e.Control] = e.To
+	}
+	return types, nil
+}
 
-	moduleBlock += fmt.Sprintf("resource \"%s\" \"this\" {\n", gen.resourceName)
-	moduleBlock += "\tname = local.name\n"
-	for n, at := range attributes {
-		if at.Computed {
-			continue //Ignore computed values for time being
-		}
-		if n == "name" {
-			fmt.Printf("terraformModuleBlock name at.DataTypeString: %v\n", len(at.PossibleValues))
-			continue
+// assetDir returns the directory the asset (or its profile) is scaffolded to under the dlta path
+func assetDir(dltaPath string, a solutionAsset) (string, error) {
+
+	kind := "r"
+	if a.isDataSource() {
+		kind = "d"
+	}
+	dirs, err := scaffoldedDirs(dltaPath, kind)
+	if err != nil {
+		return "", err
+	}
+
+	resourceName, profile, _ := strings.Cut(a.AssetType, ":")
+	dir, ok := dirs[resourceName]
+	if !ok {
+		return "", fmt.Errorf("%s hasn't been scaffolded under %s", a.AssetType, dltaPath)
+	}
+	if profile != "" {
+		dir = filepath.Join(dir, "profiles", profile)
+	}
+	return dir, nil
+}
+
+// hclValue renders a value decoded from json as an HCL expression
+func hclValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("\"%s\"", escapeHclString(v))
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, hclValue(item))
 		}
-		if strings.Contains(n, "dlta_") { //Ignore any parameters that are for dlta, these are used elsewhere
-			continue
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			items = append(items, fmt.Sprintf("\"%s\" = %s", escapeHclString(k), hclValue(v[k])))
 		}
-		if !at.IsBlock {
-			if at.DataTypeString == schema.TypeList.String() {
-				appendBlock += fmt.Sprintf("\t%s = var.%s\n", n, n)
-			} else {
-				moduleBlock += fmt.Sprintf("\t%s = var.%s\n", n, n)
-			}
-		} else {
-			moduleBlock += fmt.Sprintf("\t%s {\n", n)
-
-			//TODO multi level
-			for k, a := range at.Attributes {
+		return "{ " + strings.Join(items, ", ") + " }"
+	default:
+		// bools and numbers render the same in JSON and HCL
+		return writeJson(v)
+	}
+}
 
-				if !a.IsBlock {
-					if k == "name" {
+// solutionVariable is a variable of the root configuration, a value which differs by environment or a secret
+type solutionVariable struct {
+	name        string
+	description string
+	sensitive   bool
+	values      map[string]interface{} // by `dlta_environment_char`, written to the tfvars of each environment
+}
 
-						var cs string
+// renderedAsset is the module call of an asset, with the modules it references
+type renderedAsset struct {
+	name       string
+	block      string
+	references []string
+}
 
-						for i, v := range strings.Split(a.ResourcePath, ".") {
-							if i == 0 {
-								continue
-							}
-							cs += v
-							if i < (len(strings.Split(a.ResourcePath, ".")) - 1) {
-								cs += "_"
-							}
-						}
-						moduleBlock += fmt.Sprintf("\t\tname = local.%s\n", cs)
-					} else {
-						moduleBlock += fmt.Sprintf("\t\t%s = var.%s\n", k, k)
-					}
-				} else {
+// renderAsset substitutes the values of the asset's controls into its template. References become the output of the
+// module they're connected to (or of the data source looking up an existing resource), values overridden by
+// environment and secrets become root variables and naming takes the root's `dlta_environment_char`. A control without
+// a value is left to the module's default
+func (s solution) renderAsset(dltaPath string, a solutionAsset, template string, variables map[string]*solutionVariable, lookups *solutionLookups) (renderedAsset, error) {
 
-					moduleBlock += fmt.Sprintf("\t\t%s {\n", k)
-					for k2, a2 := range a.Attributes {
-						if k2 == "name" {
+	name := a.moduleName()
+	rendered := renderedAsset{name: name}
+	problems := make([]string, 0)
 
-							vn := genVariableNameFromResourcePath(a2.ResourcePath)
-							moduleBlock += fmt.Sprintf("\t\t\tname = var.%s\n", vn)
-						} else {
-							moduleBlock += fmt.Sprintf("\t\t\t%s = var.%s\n", k2, k2)
-						}
+	if strings.Contains(template, "__modules_path__") {
+		if s.ModulesPath == "" {
+			return rendered, fmt.Errorf("%s: `modules_path` must be set to where the modules are sourced from e.g. `git::https://dev.azure.com/org/project/_git/modules`", name)
+		}
+		template = strings.ReplaceAll(template, "__modules_path__", s.ModulesPath)
+	}
 
-					}
-					moduleBlock += "\t\t}\n"
+	template = templateSecretRegex.ReplaceAllStringFunc(template, func(m string) string {
+		variable := name + "_" + templateSecretRegex.FindStringSubmatch(m)[1]
+		variables[variable] = &solutionVariable{name: variable, description: fmt.Sprintf("The secret %s of %s, set from the pipeline's secrets", strings.TrimPrefix(variable, name+"_"), name), sensitive: true}
+		return "var." + variable
+	})
+
+	expression := func(control string) string {
+		switch control {
+		case "dlta_environment_char":
+			return "var.dlta_environment_char"
+		case "dlta_terraform_module_name":
+			return hclValue(name)
+		}
 
-				}
+		byEnvironment := false
+		for _, e := range s.Environments {
+			if _, ok := a.Environments[e][control]; ok {
+				byEnvironment = true
+			}
+		}
+		if !byEnvironment {
+			return hclValue(a.Values[control])
+		}
 
+		variable := &solutionVariable{name: name + "_" + control, description: fmt.Sprintf("The %s of %s, which differs by environment", control, name), values: make(map[string]interface{})}
+		for _, e := range s.Environments {
+			value, ok := a.Environments[e][control]
+			if !ok {
+				value = a.Values[control]
 			}
+			variable.values[e] = value
+		}
+		variables[variable.name] = variable
+		return "var." + variable.name
+	}
 
-			moduleBlock += "\t}\n"
+	var referenceTypes map[string]string
+	template = templateReferenceRegex.ReplaceAllStringFunc(template, func(m string) string {
+		parts := templateReferenceRegex.FindStringSubmatch(m)
 
+		// an existing resource is looked up by a data source, the graph scaffolded with the asset says of which type
+		if a.Values[parts[1]+referenceModeSuffix] == referenceModeExisting {
+			if referenceTypes == nil {
+				types, err := assetReferenceTypes(dltaPath, a)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s is existing: %v", name, parts[1], err))
+					return m
+				}
+				referenceTypes = types
+			}
+			address, err := lookups.add(name, parts[1], referenceTypes[parts[1]], a, expression)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s is existing: %v", name, parts[1], err))
+				return m
+			}
+			return fmt.Sprintf("%s.%s", address, parts[2])
 		}
 
+		value, ok := a.Values[parts[1]]
+		if !ok || value == nil || value == "" {
+			return "null"
+		}
+		target, _ := value.(string)
+		if !slices.ContainsFunc(s.Assets, func(other solutionAsset) bool {
+			return other.moduleName() == target && other.AssetType != "terraform_azurerm"
+		}) {
+			problems = append(problems, fmt.Sprintf("%s: %s references %q which isn't an asset on the canvas", name, parts[1], value))
+			return m
+		}
+		if !slices.Contains(rendered.references, target) {
+			rendered.references = append(rendered.references, target)
+		}
+		return fmt.Sprintf("module.%s.%s", target, parts[2])
+	})
+
+	template = quotedPlaceholderRegex.ReplaceAllStringFunc(template, func(m string) string {
+		return expression(quotedPlaceholderRegex.FindStringSubmatch(m)[1])
+	})
+	template = templatePlaceholderRegex.ReplaceAllStringFunc(template, func(m string) string {
+		return expression(templatePlaceholderRegex.FindStringSubmatch(m)[1])
+	})
+
+	if len(problems) > 0 {
+		return rendered, fmt.Errorf("%s", strings.Join(problems, "\n  "))
 	}
-	moduleBlock += appendBlock
-	moduleBlock += "}\n"
-
-	return moduleBlock
+	rendered.block = template
+	return rendered, nil
 }
 
-// TODO.....
-func (gen documentationGenerator) terraformVariableBlock() string {
+// solutionLookups are the data sources looking up the existing resources a solution references, one per resource
+// however many assets reference it
+type solutionLookups struct {
+	addresses map[string]string // keyed by the data source's block, less its name
+	blocks    []string
+	registry  func() providerRegistry // the data sources of the provider, read once the first is looked up
+}
 
-	attributes := gen.injectAttributes()
+// add returns the address of the data source looking up the existing resource of the asset's reference control, from
+// the values of its `<control>_existing_<argument>` controls
+func (l *solutionLookups) add(name string, control string, assetType string, a solutionAsset, expression func(string) string) (string, error) {
 
-	var variableBlock string
+	if assetType == "" || assetType == anyAssetType {
+		return "", fmt.Errorf("the type of the existing resource isn't known, only a reference to a type can be existing")
+	}
 
-	for n, at := range attributes {
+	prefix := control + existingArgumentInfix
+	arguments, ok := l.registry().DataSources[assetType]
+	if !ok {
+		return "", fmt.Errorf("the provider has no data source looking up an existing %s", assetType)
+	}
+	missing := []string{}
+	for _, argument := range arguments {
+		if v, ok := a.Values[prefix+argument]; !ok || v == nil || v == "" {
+			missing = append(missing, prefix+argument)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("set the %s controls looking it up", strings.Join(missing, ", "))
+	}
 
-		if n == "name" { // TODO  We need to work out the scenarios for this
-			continue
+	body := ""
+	for _, k := range sortedKeys(a.Values) {
+		if argument, ok := strings.CutPrefix(k, prefix); ok {
+			body += fmt.Sprintf("\t%s = %s\n", argument, expression(k))
 		}
+	}
 
-		if n != "dlta_terraform_template" && n != "dlta_naming_convention" && n != "dlta_terraform_module_name" && n != "dlta_terraform_is_data_source" {
+	key := assetType + "\n" + body
+	if address, ok := l.addresses[key]; ok {
+		return address, nil
+	}
 
-			if !at.Computed { // Computed fields are never variables
-				if !at.IsBlock {
+	dataName := strings.ToLower(name + "_" + control)
+	if l.addresses == nil {
+		l.addresses = make(map[string]string)
+	}
+	l.addresses[key] = fmt.Sprintf("data.%s.%s", assetType, dataName)
+	l.blocks = append(l.blocks, fmt.Sprintf("data \"%s\" \"%s\" {\n%s}\n", assetType, dataName, body))
+	return l.addresses[key], nil
+}
 
-					variableBlock += fmt.Sprintf("variable \"%s\" {\n", n)
-					variableBlock += fmt.Sprintf("\tdescription = \"%s\"\n", at.Description)
-					variableBlock += fmt.Sprintf("\ttype = %s\n", translateDataType(at.DataTypeString))
-					if at.Default != "" {
-						variableBlock += fmt.Sprintf("\tdefault = \"%s\"\n", at.Default)
-					}
-					variableBlock += "}\n"
-				} else {
+// dependencyOrder returns the modules ordered so each comes after those it references, otherwise by name, or an error
+// naming the modules which reference each other
+func dependencyOrder(assets []renderedAsset) ([]renderedAsset, error) {
 
-					//TODO multi level
-					for n1, at1 := range at.Attributes {
+	remaining := make(map[string]renderedAsset, len(assets))
+	for _, a := range assets {
+		remaining[a.name] = a
+	}
 
-						if !at1.IsBlock {
-							if n1 == "name" { // TODO  We need to work out the scenarios for this
-								continue
-							}
+	ordered := make([]renderedAsset, 0, len(assets))
+	for len(remaining) > 0 {
+		ready := make([]string, 0)
+		for _, name := range sortedKeys(remaining) {
+			if !slices.ContainsFunc(remaining[name].references, func(reference string) bool {
+				_, waiting := remaining[reference]
+				return waiting
+			}) {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			return nil, fmt.Errorf("the assets %s reference each other in a cycle", strings.Join(sortedKeys(remaining), ", "))
+		}
+		for _, name := range ready {
+			ordered = append(ordered, remaining[name])
+			delete(remaining, name)
+		}
+	}
 
-							variableBlock += fmt.Sprintf("variable \"%s\" {\n", n1)
-							variableBlock += fmt.Sprintf("\tdescription = \"%s\"\n", at1.Description)
-							variableBlock += fmt.Sprintf("\ttype = %s\n", translateDataType(at1.DataTypeString))
-							if at.Default != "" {
-								variableBlock += fmt.Sprintf("\tdefault = \"%s\"\n", at1.Default)
-							}
-							variableBlock += "}\n"
-						} else {
-							for n2, at2 := range at1.Attributes {
-								if n2 == "name" {
-									var cs string
+	return ordered, nil
+}
 
-									for i, v := range strings.Split(at2.ResourcePath, ".") {
-										if i == 0 {
-											continue
-										}
-										cs += v
-										if i < (len(strings.Split(at2.ResourcePath, ".")) - 1) {
-											cs += "_"
-										}
-									}
+// renderSolution renders the root configuration of the solution keyed by file name, `main.tf` with the terraform
+// block, the data sources of the existing resources referenced and a module call per asset (in dependency order),
+// `variables.tf` and the tfvars of each environment
+func renderSolution(dltaPath string, s solution) (map[string]string, error) {
+
+	variables := make(map[string]*solutionVariable)
+	lookups := &solutionLookups{registry: sync.OnceValue(func() providerRegistry { return readProviderRegistry(dltaPath, false) })}
+	assets := make([]renderedAsset, 0, len(s.Assets))
+	problems := make([]string, 0)
+
+	// the terraform block is set by the terraform_azurerm asset, the first option of each of its controls it doesn't set
+	terraform := solutionAsset{Name: "terraform", AssetType: "terraform_azurerm", Values: map[string]interface{}{
+		"terraform_azurerm_azurerm_source":  terraform_azurerm_azurerm_source_options[0].Value,
+		"terraform_azurerm_azurerm_version": terraform_azurerm_azurerm_version_options[0].Value,
+		"terraform_azurerm_azapi_source":    terraform_azurerm_azapi_source_options[0].Value,
+		"terraform_azurerm_azapi_version":   terraform_azurerm_azapi_version_options[0].Value,
+	}}
+
+	for _, a := range s.Assets {
+		if a.AssetType == "terraform_azurerm" {
+			for control, value := range a.Values {
+				terraform.Values[control] = value
+			}
+			terraform.Environments = a.Environments
+			continue
+		}
 
-									variableBlock += fmt.Sprintf("variable \"%s\" {\n", cs)
-									variableBlock += fmt.Sprintf("\tdescription = \"%s\"\n", at2.Description)
-									variableBlock += fmt.Sprintf("\ttype = %s\n", translateDataType(at2.DataTypeString))
-									if at.Default != "" {
-										variableBlock += fmt.Sprintf("\tdefault = \"%s\"\n", at2.Default)
-									}
-									variableBlock += "}\n"
+		template, err := assetTemplate(dltaPath, a)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", a.moduleName(), err))
+			continue
+		}
+		rendered, err := s.renderAsset(dltaPath, a, template, variables, lookups)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		assets = append(assets, rendered)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("rendering %s:\n  %s", s.Name, strings.Join(problems, "\n  "))
+	}
 
-								} else {
-									variableBlock += fmt.Sprintf("variable \"%s\" {\n", n2)
-									variableBlock += fmt.Sprintf("\tdescription = \"%s\"\n", at2.Description)
-									variableBlock += fmt.Sprintf("\ttype = %s\n", translateDataType(at2.DataTypeString))
-									if at.Default != "" {
-										variableBlock += fmt.Sprintf("\tdefault = \"%s\"\n", at2.Default)
-									}
-									variableBlock += "}\n"
-								}
+	template := documentationGenerator{resourceName: terraform.AssetType, dltaPath: dltaPath}.terraformTemplateBlock()
+	if exported, ok := terraform.Values["dlta_terraform_template"].(string); ok && exported != "" {
+		template = exported
+	}
+	terraformBlock, err := s.renderAsset(dltaPath, terraform, template, variables, lookups)
+	if err != nil {
+		return nil, err
+	}
 
-							}
+	ordered, err := dependencyOrder(assets)
+	if err != nil {
+		return nil, err
+	}
 
-						}
+	blocks := append([]string{terraformBlock.block}, lookups.blocks...)
+	for _, a := range ordered {
+		blocks = append(blocks, a.block)
+	}
 
-					}
+	quoted := make([]string, 0, len(s.Environments))
+	for _, e := range s.Environments {
+		quoted = append(quoted, strconv.Quote(e))
+	}
+	variablesBlock := "variable \"dlta_environment_char\" {\n"
+	variablesBlock += fmt.Sprintf("\tdescription = \"%s\"\n", escapeHclString(dlta_environment_char.Description))
+	variablesBlock += "\ttype        = string\n"
+	variablesBlock += "\tvalidation {\n"
+	variablesBlock += fmt.Sprintf("\t\tcondition     = contains([%s], var.dlta_environment_char)\n", strings.Join(quoted, ", "))
+	variablesBlock += fmt.Sprintf("\t\terror_message = \"dlta_environment_char must be one of %s.\"\n", strings.Join(s.Environments, ", "))
+	variablesBlock += "\t}\n"
+	variablesBlock += "}\n"
+	for _, n := range sortedKeys(variables) {
+		v := variables[n]
+		variablesBlock += fmt.Sprintf("\nvariable \"%s\" {\n", v.name)
+		variablesBlock += fmt.Sprintf("\tdescription = \"%s\"\n", escapeHclString(v.description))
+		if v.sensitive {
+			variablesBlock += "\ttype        = string\n"
+			variablesBlock += "\tsensitive   = true\n"
+		}
+		variablesBlock += "}\n"
+	}
 
-				}
+	files := map[string]string{
+		"main.tf":      formatHcl(strings.Join(blocks, "\n")),
+		"variables.tf": formatHcl(variablesBlock),
+	}
+	for _, e := range s.Environments {
+		tfvars := fmt.Sprintf("dlta_environment_char = %q\n", e)
+		for _, n := range sortedKeys(variables) {
+			if v := variables[n]; !v.sensitive {
+				tfvars += fmt.Sprintf("%s = %s\n", v.name, hclValue(v.values[e]))
 			}
 		}
-
+		files[e+".tfvars"] = formatHcl(tfvars)
 	}
 
-	return variableBlock
+	return files, nil
 }
 
-func (gen documentationGenerator) terraformLocalBlock() string {
-
-	var localBlock string
-
-	attributes := gen.injectAttributes()
-
-	//TODO Use the global naming convention
+// exportSolution writes the root configuration of the canvas export at solutionPath to `<dlta-path>/solutions/<name>`,
+// an existing configuration is only replaced with `-force`
+func exportSolution(dltaPath string, solutionPath string, options scaffoldOptions) error {
 
-	localBlock += "locals {\n"
-	for n, at := range attributes {
-		resShort1 := "dlta_vendor_asset_short_code"
-		bizShort2 := "dlta_business_short_code"
-		appShort3 := "dlta_application_short_code"
-		envChar4 := "dlta_environment_char"
-		locShort5 := "dlta_location_short_code"
-		instId6 := "dlta_instance_id"
+	s, err := readSolution(solutionPath)
+	if err != nil {
+		return err
+	}
 
-		if n == "name" {
-			if gen.resourceName == "azurerm_storage_account" {
+	outputDir := filepath.Join(dltaPath, solutionsDir, s.Name)
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("creating %s: %+v", outputDir, err)
+	}
+	release, err := acquireLock(outputDir, options.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-				localBlock += fmt.Sprintf("\tname = format(\"%%s%%s%%s%%s%%s%%s\",var.%s,var.%s,var.%s,var.%s,var.%s,var.%s)\n", resShort1, bizShort2, appShort3, envChar4, locShort5, instId6)
-			} else {
-				localBlock += fmt.Sprintf("\tname = format(\"%%s-%%s-%%s-%%s-%%s-%%s\",var.%s,var.%s,var.%s,var.%s,var.%s,var.%s)\n", resShort1, bizShort2, appShort3, envChar4, locShort5, instId6)
+	files, err := renderSolution(dltaPath, s)
+	if err != nil {
+		return err
+	}
 
+	header := fmt.Sprintf("# Code generated by dlta-scaffold %s; DO NOT EDIT.\n", generatorVersion)
+	header += fmt.Sprintf("# Regenerate with: go run ./internal/tools/dlta-scaffold -dlta-path %s -output-type solution -solution %s -force y\n\n", dltaPath, solutionPath)
+	for _, fileName := range sortedKeys(files) {
+		outputPath := filepath.Join(outputDir, fileName)
+		if _, err := os.Stat(outputPath); err == nil && !options.isForced {
+			return fmt.Errorf("%s already exists, use `-force y` to replace it", outputPath)
+		}
+		if err := validateHcl(fileName, files[fileName]); err != nil {
+			if !options.allowInvalid {
+				return fmt.Errorf("refusing to write %s (use `-allow-invalid y` to override): %v", outputPath, err)
 			}
+			fmt.Printf("exportSolution \"validation error\" writing invalid %s: %v\n", outputPath, err)
+		}
+	}
+	for _, fileName := range sortedKeys(files) {
+		if err := writeFileAtomically(filepath.Join(outputDir, fileName), []byte(header+files[fileName])); err != nil {
+			return fmt.Errorf("writing %s: %+v", filepath.Join(outputDir, fileName), err)
 		}
+	}
 
-		if at.IsBlock {
+	fmt.Printf("exportSolution \"exported\" %d assets of %s to %s\n", len(s.Assets), s.Name, outputDir)
+	return nil
+}
 
-			for k, a := range at.Attributes {
+// defaultLockTimeout is how long a run waits for another's lock by default, staleLockAge how old a lock must be
+// before it's taken to have been left by a run which crashed (the run holding a lock touches it every
+// lockRefreshInterval, however long it runs) and staleBreakAge the same for the lock held while breaking one
+const (
+	defaultLockTimeout  = 30 * time.Second
+	staleLockAge        = 10 * time.Minute
+	lockRefreshInterval = time.Minute
+	staleBreakAge       = time.Minute
+	lockPollInterval    = 100 * time.Millisecond
+	lockFileName        = ".lock"
+)
 
-				if k == "name" {
-					var variableName string
-					var resourceName string
+// acquireLock takes the advisory lock of a directory, a `.lock` file created exclusively and naming its holder, so
+// engineers or CI jobs writing the same resource (or the catalog) concurrently take turns rather than interleaving
+// their writes. It waits up to the timeout for another run's lock, breaking a stale one, and returns its release
+func acquireLock(dir string, timeout time.Duration) (func(), error) {
 
-					// fmt.Printf("a.ResourcePath: %v\n", a.ResourcePath)
-					for i, v := range strings.Split(a.ResourcePath, ".") {
-						if i == 0 {
-							continue
-						}
-						if i == 1 {
-							resourceName = v
-						}
-						variableName += v
-						if i < (len(strings.Split(a.ResourcePath, ".")) - 1) {
-							variableName += "_"
-						}
-					}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("creating %s: %+v", dir, err)
+	}
 
-					fmt.Printf("resourceName: %v\n", resourceName)
-					resShort1 = getResourceShortCode(resourceName)
-					fmt.Printf("resShort1: %v\n", resShort1)
-					localBlock += fmt.Sprintf("\t%s = format(\"%%s-%%s-%%s-%%s-%%s-%%s\",\"%s\",var.%s,var.%s,var.%s,var.%s,var.%s)\n", variableName, resShort1, bizShort2, appShort3, envChar4, locShort5, instId6)
+	lockPath := filepath.Join(dir, lockFileName)
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("%s pid %d at %s", hostname, os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = file.WriteString(holder + "\n")
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("writing %s: %+v", lockPath, err)
+			}
+			return holdLock(lockPath), nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("creating %s: %+v", lockPath, err)
+		}
 
-				}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge && breakStaleLock(lockPath) {
+			continue
+		}
 
+		if !time.Now().Before(deadline) {
+			by := "another run"
+			if existing, err := os.ReadFile(lockPath); err == nil && strings.TrimSpace(string(existing)) != "" {
+				by = strings.TrimSpace(string(existing))
 			}
+			return nil, fmt.Errorf("%s is locked by %s, try again once it's finished (or raise `-lock-timeout`), removing %s if the run holding it crashed", dir, by, lockPath)
 		}
+		time.Sleep(lockPollInterval)
 	}
-	localBlock += "}\n"
+}
 
-	return localBlock
+// holdLock touches the lock every lockRefreshInterval until it's released, so a run holding it for longer than
+// staleLockAge (e.g. exporting a large catalog) doesn't have it broken, returning its release
+func holdLock(lockPath string) func() {
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				if err := os.Chtimes(lockPath, now, now); err != nil {
+					fmt.Printf("acquireLock \"refresh error\": %+v\n", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			os.Remove(lockPath)
+		})
+	}
 }
 
-func (gen documentationGenerator) getPalletProp(at attribute, name string) PaletteProp {
+// breakStaleLock removes a stale lock, returning whether it did. Runs finding the same stale lock take turns breaking
+// it with a `.lock.break` created exclusively, checking the lock is still stale once they hold it as another may have
+// broken it and taken the lock since. The break lock is only held for that check, so one older than staleBreakAge was
+// left by a run which crashed breaking the lock and is removed
+func breakStaleLock(lockPath string) bool {
 
-	var pp PaletteProp
-	var flattenName string
+	breakPath := lockPath + ".break"
+	file, err := os.OpenFile(breakPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if info, err := os.Stat(breakPath); err == nil && time.Since(info.ModTime()) > staleBreakAge {
+			fmt.Printf("acquireLock \"stale lock\": removing %s, which is older than %s\n", breakPath, staleBreakAge)
+			os.Remove(breakPath)
+		}
+		return false
+	}
+	file.Close()
+	defer os.Remove(breakPath)
 
-	flattenName = ""
-	pp.ID = name
-	pp.Type = translateDataType(at.DataTypeString)
-	pp.Name = convertNameToLabel(name)
-	pp.Disabled = false
-	pp.FlattenName = &flattenName
-	pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+	info, err := os.Stat(lockPath)
+	if err != nil || time.Since(info.ModTime()) <= staleLockAge {
+		return false
+	}
+	fmt.Printf("acquireLock \"stale lock\": removing %s, which is older than %s\n", lockPath, staleLockAge)
+	return os.Remove(lockPath) == nil
+}
+
+// resourceLockDir is the directory of the resource locked while its summary and artefacts (and those of its profiles)
+// are written
+func (gen documentationGenerator) resourceLockDir() string {
+	return filepath.Join(append([]string{gen.dltaPath}, gen.resourceLayout()...)...)
+}
+
+// historyFileName is the file within a resource's directory its history is appended to, a json object per line
+const historyFileName = "history.jsonl"
+
+// historyEntry records an operation which wrote the summary or artefacts of a resource, so teams can audit when (and
+// by which versions of dlta-scaffold and the provider) they changed
+type historyEntry struct {
+	Time             time.Time `json:"time"`
+	Operation        string    `json:"operation"`
+	GeneratorVersion string    `json:"generator_version"`
+	ProviderVersion  string    `json:"provider_version"`
+	Profile          string    `json:"profile,omitempty"`
+	User             string    `json:"user,omitempty"`
+}
+
+// historyPath is the history of the resource, shared by its profiles
+func (gen documentationGenerator) historyPath() string {
+	return filepath.Join(gen.resourceLockDir(), historyFileName)
+}
+
+// recordHistory appends the operation to the resource's history, it's called holding the resource's lock. Failing to
+// record it is only a warning as the operation has already succeeded
+func (gen documentationGenerator) recordHistory(operation string) {
+
+	entry := historyEntry{
+		Time:             time.Now().UTC().Truncate(time.Second),
+		Operation:        operation,
+		GeneratorVersion: generatorVersion,
+		ProviderVersion:  version.ProviderVersion,
+		Profile:          gen.profile,
+		User:             os.Getenv("USER"),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Printf("recordHistory \"error\": %v\n", err)
+		return
+	}
+
+	file, err := os.OpenFile(gen.historyPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("recordHistory \"error\": %s: %v\n", gen.historyPath(), err)
+	}
+}
+
+// readHistory returns the resource's history oldest first, a line which can't be parsed is skipped with a warning
+func (gen documentationGenerator) readHistory() ([]historyEntry, error) {
+
+	entries := make([]historyEntry, 0)
+	content, err := os.ReadFile(gen.historyPath())
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %+v", gen.historyPath(), err)
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			fmt.Printf("readHistory \"skipping\" %s: line %d: %v\n", gen.historyPath(), i+1, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// printHistory lists when the summary and artefacts of the resource were written, see `-output-type history`
+func (gen documentationGenerator) printHistory() error {
+
+	entries, err := gen.readHistory()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("There's no history of %s in %s\n", gen.resourceName, gen.historyPath())
+		return nil
+	}
+
+	fmt.Printf("History of %s:\n", gen.resourceName)
+	for _, e := range entries {
+		line := fmt.Sprintf("  %s  %-17s dlta-scaffold %s, provider %s", e.Time.Format(time.RFC3339), e.Operation, e.GeneratorVersion, e.ProviderVersion)
+		if e.Profile != "" {
+			line += ", profile " + e.Profile
+		}
+		if e.User != "" {
+			line += ", by " + e.User
+		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// writeFileAtomically writes a file alongside its path then renames it into place, so a reader (or a run which is
+// interrupted) never sees it partly written
+func writeFileAtomically(path string, content []byte) error {
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	temporaryPath := file.Name()
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temporaryPath, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(temporaryPath, path)
+	}
+	if err != nil {
+		os.Remove(temporaryPath)
+	}
+
+	return err
+}
+
+// newDocumentationGenerator returns the generator for a resource with the configuration in `<dlta-path>/config` read,
+// the resource's schema is looked up separately with lookupResource
+func newDocumentationGenerator(resourceName string, isResource bool, dltaPath string, options scaffoldOptions) (*documentationGenerator, error) {
+	generator := &documentationGenerator{
+		resourceName: resourceName,
+		isDataSource: !isResource,
+		// exampleSource: expsrc,
+		dltaPath:        dltaPath,
+		isResource:      isResource,
+		scaffoldOptions: options,
+		generatedAt:     time.Now().UTC(),
+	}
+
+	naming, err := generator.readNamingConfig()
+	if err != nil {
+		return nil, err
+	}
+	generator.naming = naming
+
+	shortCodes, err := generator.readShortCodes()
+	if err != nil {
+		return nil, err
+	}
+	generator.shortCodes = shortCodes
+
+	nestedNames, err := generator.readNestedNames()
+	if err != nil {
+		return nil, err
+	}
+	generator.nestedNames = nestedNames
+
+	attributeRules, err := generator.readAttributeRules()
+	if err != nil {
+		return nil, err
+	}
+	generator.attributeRules = attributeRules
+
+	publishRules, err := generator.readPublishRules()
+	if err != nil {
+		return nil, err
+	}
+	generator.publishRules = publishRules
+
+	referenceRules, err := generator.readReferenceRules()
+	if err != nil {
+		return nil, err
+	}
+	generator.referenceRules = referenceRules
+
+	paletteIcons, err := generator.readPaletteIcons()
+	if err != nil {
+		return nil, err
+	}
+	generator.paletteIcons = paletteIcons
+
+	paletteCategories, err := generator.readPaletteCategories()
+	if err != nil {
+		return nil, err
+	}
+	generator.paletteCategories = paletteCategories
+
+	costs, err := generator.readCostConfig()
+	if err != nil {
+		return nil, err
+	}
+	generator.costs = costs
+
+	paletteRules, err := generator.readPaletteRules()
+	if err != nil {
+		return nil, err
+	}
+	generator.paletteRules = paletteRules
+
+	paletteOutputs, err := generator.readPaletteOutputs()
+	if err != nil {
+		return nil, err
+	}
+	generator.paletteOutputs = paletteOutputs
+
+	usedInstanceIds, err := generator.readInstanceRegistry()
+	if err != nil {
+		return nil, err
+	}
+	generator.usedInstanceIds = usedInstanceIds
+
+	return generator, nil
+}
+
+// lookupResource finds the schema of the resource in the provider along with what's derived from it e.g. the short
+// code and palette rank
+// errNotRegistered is wrapped by the error of lookupResource when the provider doesn't register the resource
+var errNotRegistered = errors.New("not registered")
+
+func (gen *documentationGenerator) lookupResource() error {
+
+	resourceName := gen.resourceName
+	isResource := gen.isResource
+
+	liveOptions, err := gen.loadLiveOptions(context.Background(), gen.armOptionsFetcher())
+	if err != nil {
+		return err
+	}
+	gen.liveOptions = liveOptions
+
+	if gen.azapiType != "" {
+		gen.resource = azapiResourceSchema()
+		if gen.restSpec != "" {
+			body, err := readRestSpecBody(gen.restSpec, gen.azapiType)
+			if err != nil {
+				return err
+			}
+			gen.azapiBody = body
+		}
+	} else if resourceName == "terraform_azurerm" || resourceName == "devops_pipeline" {
+		gen.resourceName = resourceName
+	} else if utilityResource, ok := utilityResourceSchemas[resourceName]; ok && isResource {
+		gen.resource = utilityResource()
+		gen.providerSource = utilityProviders[strings.SplitN(resourceName, "_", 2)[0]]
+		gen.serviceName = utilityServiceName
+		gen.websiteCategories = []string{utilityServiceName}
+	} else if gen.providerSchema != "" {
+		resource, source, err := readProviderSchema(gen.providerSchema, resourceName, isResource)
+		if err != nil {
+			return err
+		}
+		gen.resource = resource
+		gen.providerSource = source
+		if serviceName, ok := providerServiceNames[source]; ok {
+			gen.serviceName = serviceName
+			gen.websiteCategories = []string{serviceName}
+		}
+	} else if !gen.readSchemaCache() {
+		// loading the provider's schemas is slow, so the schema read from it is cached for this provider version
+
+		if !isResource {
+			for _, service := range provider.SupportedTypedServices() {
+				for _, ds := range service.DataSources() {
+					if ds.ResourceType() == resourceName {
+						wrapper := sdk.NewDataSourceWrapper(ds)
+						dsWrapper, err := wrapper.DataSource()
+						if err != nil {
+							return fmt.Errorf("wrapping Data Source %q: %+v", ds.ResourceType(), err)
+						}
+
+						gen.resource = dsWrapper
+						gen.serviceName = service.Name()
+						gen.websiteCategories = service.WebsiteCategories()
+						break
+					}
+				}
+			}
+			for _, service := range provider.SupportedUntypedServices() {
+				for key, ds := range service.SupportedDataSources() {
+					if key == resourceName {
+						gen.resource = ds
+						gen.serviceName = service.Name()
+						gen.websiteCategories = service.WebsiteCategories()
+						break
+					}
+				}
+			}
+
+			if gen.resource == nil {
+				return fmt.Errorf("Data Source %q was %w!", resourceName, errNotRegistered)
+			}
+		} else {
+			for _, service := range provider.SupportedTypedServices() {
+				for _, rs := range service.Resources() {
+					if rs.ResourceType() == resourceName {
+						wrapper := sdk.NewResourceWrapper(rs)
+						rsWrapper, err := wrapper.Resource()
+						if err != nil {
+							return fmt.Errorf("wrapping Resource %q: %+v", rs.ResourceType(), err)
+						}
+
+						gen.resource = rsWrapper
+						gen.serviceName = service.Name()
+						gen.websiteCategories = service.WebsiteCategories()
+						break
+					}
+				}
+			}
+			for _, service := range provider.SupportedUntypedServices() {
+				for key, rs := range service.SupportedResources() {
+					if key == resourceName {
+						gen.resource = rs
+						gen.serviceName = service.Name()
+						gen.websiteCategories = service.WebsiteCategories()
+						break
+					}
+				}
+			}
+
+			if gen.resource == nil {
+				return fmt.Errorf("Resource %q was %w!", resourceName, errNotRegistered)
+			}
+		}
+
+		if err := gen.writeSchemaCache(); err != nil {
+			return err
+		}
+	}
+
+	// the registry is only listed from the provider (and cached) when a reference of the azurerm schema needs it
+	if gen.stampsProviderVersion() {
+		dltaPath, refresh := gen.dltaPath, gen.refreshSchema
+		gen.registry = sync.OnceValue(func() providerRegistry { return readProviderRegistry(dltaPath, refresh) })
+	}
+
+	gen.ShortCode = gen.resourceShortCode(gen.resourceName)
+	if err := gen.checkShortCodeCollisions(); err != nil {
+		return err
+	}
+
+	// the rank can depend on the category, so is read once the service of the resource is known
+	paletteRank, err := gen.readPaletteRank()
+	if err != nil {
+		return err
+	}
+	gen.paletteRank = paletteRank
+	gen.NamingConvention = gen.getNamingProvider().convention()
+
+	return nil
+}
+
+// Full Attributes
+func (gen documentationGenerator) getAllInputAttributes(input map[string]*schema.Schema, parent attribute, isChild bool, parentPath string, ancestors ...*schema.Resource) map[string]attribute {
+
+	// resourceName := gen.resourceName
+	// gen.resource.Schema, gen.resourceName, attribute{}, false, gen.resourceName
+	retAttributes := make(map[string]attribute)
+
+	for _, fieldName := range gen.sortFields(input) {
+
+		resourcePath := parentPath + "." + fieldName
+		if gen.isExcludedAttribute(resourcePath) {
+			continue
+		}
+		included := gen.isIncludedAttribute(resourcePath)
+		if included && !input[fieldName].Required && !input[fieldName].Optional {
+			fmt.Printf("getAllInputAttributes \"computed only\": %s is included but can't be set, show it with palette_outputs.json instead\n", resourcePath)
+		}
+
+		if input[fieldName].Required || input[fieldName].Optional {
+			a := attribute{}
+			if isBlock(input[fieldName]) && !gen.isObjectAttribute(input[fieldName]) {
+
+				block := input[fieldName].Elem.(*schema.Resource)
+				if !gen.canDescend("getAllInputAttributes", resourcePath, block, ancestors) {
+					continue
+				}
+
+				cloneSchemaToAttributes(&a, input[fieldName], true, parentPath, fieldName)
+				//attrib = attribute{IsBlock: true, MaxItems: input[fieldName].MaxItems, Required: input[fieldName].Required, DataTypeString: input[fieldName].Type.String(), Optional: input[fieldName].Optional, MinItems: input[fieldName].MinItems, ForceNew: input[fieldName].ForceNew}
+				// retap := gen.getAllAttributes(input[fieldName].Elem.(*schema.Resource).Schema, resourceName, a, true, parentPath+"."+fieldName)
+
+				retap := gen.getAllInputAttributes(block.Schema, a, true, resourcePath, append(ancestors, block)...)
+				a.Attributes = retap
+			} else {
+				cloneSchemaToAttributes(&a, input[fieldName], false, parentPath, fieldName)
+
+				if gen.isAzapiBody(a) {
+					a.TypeConstraint = gen.azapiBodyTypeConstraint()
+				} else if gen.isObjectAttribute(input[fieldName]) {
+					a.TypeConstraint = objectTypeConstraint(input[fieldName])
+				}
+
+				//attrib = attribute{IsBlock: false, MaxItems: input[fieldName].MaxItems, Required: input[fieldName].Required, DataTypeString: input[fieldName].Type.String(), Optional: input[fieldName].Optional, MinItems: input[fieldName].MinItems, ForceNew: input[fieldName].ForceNew}
+
+				a.Constraints = getSchemaConstraints(input[fieldName])
+				a.PossibleValues = schemaPossibleValues(parentPath+"."+fieldName, input[fieldName])
+
+				// writeDebugJson(attrib)
+				// fmt.Printf("field	%s	%t\n", fieldName, isChild)
+			}
+
+			// an included attribute the provider computes when it's not set is scaffolded as an input
+			if included {
+				a.Computed = false
+			}
+
+			retAttributes[fieldName] = a
+		}
+
+	}
+
+	return retAttributes
+
+}
+
+// defaultMaxDepth is how deeply blocks can be nested before the walk of the schema is truncated, without `-max-depth`
+const defaultMaxDepth = 10
+
+// schemaMaxDepth returns how deeply nested blocks of the schema are walked
+func (gen documentationGenerator) schemaMaxDepth() int {
+	if gen.maxDepth > 0 {
+		return gen.maxDepth
+	}
+
+	return defaultMaxDepth
+}
+
+// canDescend reports whether the walk of the schema continues into a block, the ancestors are the blocks the walk is
+// already within. A block which contains itself (which would never end) or is nested deeper than `-max-depth` is left
+// out with a warning
+func (gen documentationGenerator) canDescend(walker string, resourcePath string, block *schema.Resource, ancestors []*schema.Resource) bool {
+	for _, ancestor := range ancestors {
+		if ancestor == block {
+			fmt.Printf("%s \"cycle\": %s contains itself, the block is left out\n", walker, resourcePath)
+			return false
+		}
+	}
+	if depth := len(ancestors) + 1; depth > gen.schemaMaxDepth() {
+		fmt.Printf("%s \"max depth\": %s is nested %d blocks deep, the block is left out (see -max-depth)\n", walker, resourcePath, depth)
+		return false
+	}
+
+	return true
+}
+
+// getAllOutputAttributes returns the read side of the schema: the attributes which are only computed (a computed
+// block with all of its attributes) and the blocks set by the user which have computed attributes, holding just those
+func (gen documentationGenerator) getAllOutputAttributes(input map[string]*schema.Schema, parent attribute, isChild bool, parentPath string, ancestors ...*schema.Resource) map[string]attribute {
+
+	retAttributes := make(map[string]attribute)
+
+	// the attributes of a computed block are read whether or not the schema marks them computed
+	parentComputed := isChild && parent.Computed && !parent.Optional && !parent.Required
+
+	for _, fieldName := range gen.sortFields(input) {
+		s := input[fieldName]
+		computed := parentComputed || (s.Computed && !s.Optional && !s.Required)
+
+		a := attribute{}
+		if isBlock(s) {
+			block := s.Elem.(*schema.Resource)
+			if !gen.canDescend("getAllOutputAttributes", parentPath+"."+fieldName, block, ancestors) {
+				continue
+			}
+			cloneSchemaToAttributes(&a, s, true, parentPath, fieldName)
+			if parentComputed {
+				a.Computed, a.Optional, a.Required = true, false, false
+			}
+			a.Attributes = gen.getAllOutputAttributes(block.Schema, a, true, parentPath+"."+fieldName, append(ancestors, block)...)
+			if !computed && len(a.Attributes) == 0 {
+				continue
+			}
+		} else if computed {
+			cloneSchemaToAttributes(&a, s, false, parentPath, fieldName)
+		} else {
+			continue
+		}
+
+		retAttributes[fieldName] = a
+	}
+
+	return retAttributes
+}
+
+// getModuleOutputAttributes returns the attributes the module outputs, the id and name plus any shown on the palette
+func (gen documentationGenerator) getModuleOutputAttributes() map[string]attribute {
+
+	retAttributes := make(map[string]attribute)
+
+	var (
+		id = attribute{
+			DataTypeString: "TypeString",
+			Description:    "The resource id",
+		}
+
+		name = attribute{
+			DataTypeString: "TypeString",
+			Description:    "The resource name",
+		}
+	)
+
+	retAttributes["id"] = id
+	if gen.hasName() {
+		retAttributes["name"] = name
+	}
+
+	// the computed attributes shown on the palette are wired from the module's outputs
+	for n, a := range gen.getPaletteOutputAttributes() {
+		if _, ok := retAttributes[n]; !ok {
+			retAttributes[n] = a
+		}
+	}
+
+	// writeDebugJson(retAttributes)
+	return retAttributes
+}
+
+func (gen documentationGenerator) summariseAttributes(a map[string]attribute, rn string, parentRequired bool) map[string]summaryAttribute {
+
+	printAttributes := func(a map[string]attribute, rn string) map[string]summaryAttribute {
+		retAttributes := make(map[string]summaryAttribute)
+		for k, a := range a {
+			published := false
+			if parentRequired {
+				if a.Required || gen.isIncludedAttribute(a.ResourcePath) {
+					published = true
+				}
+			} else {
+				published = false
+			}
+			// a deprecated attribute is left for the user to publish, unless deprecated attributes are included
+			if a.Deprecated != "" && !gen.includeDeprecated {
+				published = false
+			}
+			newrn := rn + "." + k
+
+			if a.IsBlock {
+				retAttributes[a.ResourcePath] = gen.summariseAttribute(a, published)
+				a := gen.summariseAttributes(a.Attributes, newrn, published)
+				for n, v := range a {
+					retAttributes[n] = v
+				}
+
+			} else {
+				retAttributes[a.ResourcePath] = gen.summariseAttribute(a, published)
+			}
+		}
+
+		return retAttributes
+	}
+
+	return printAttributes(a, rn)
+}
+
+// summariseAttribute returns the entry of an attribute in the resource summary json
+func (gen documentationGenerator) summariseAttribute(a attribute, published bool) summaryAttribute {
+
+	var elemType string
+	if isPrimitiveCollection(a) {
+		elemType = a.ElemType
+	}
+	var c *constraints
+	if !a.Constraints.isEmpty() {
+		c = &a.Constraints
+	}
+
+	return summaryAttribute{
+		Published:      published,
+		IsBlock:        a.IsBlock,
+		Required:       a.Required,
+		Optional:       a.Optional,
+		Computed:       a.Computed,
+		Deprecated:     a.Deprecated,
+		Sensitive:      a.Sensitive,
+		ConflictsWith:  gen.constraintPaths(a.ConflictsWith),
+		ExactlyOneOf:   gen.constraintPaths(a.ExactlyOneOf),
+		AtLeastOneOf:   gen.constraintPaths(a.AtLeastOneOf),
+		RequiredWith:   gen.constraintPaths(a.RequiredWith),
+		DataType:       a.DataTypeString,
+		ElemType:       elemType,
+		Description:    a.Description,
+		PossibleValues: a.PossibleValues,
+		Constraints:    c,
+		Reference:      gen.detectReference(a.ResourcePath[strings.LastIndex(a.ResourcePath, ".")+1:], a),
+	}
+}
+
+// constraintKeyIndexRegex matches the list index of a nested schema key, e.g. the `.0.` of `block.0.field`
+var constraintKeyIndexRegex = regexp.MustCompile(`\.\d+\.`)
+
+// constraintPaths returns the resource paths of the schema keys of a ConflictsWith, ExactlyOneOf, AtLeastOneOf or
+// RequiredWith rule
+func (gen documentationGenerator) constraintPaths(keys []string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(keys))
+	for _, key := range keys {
+		paths = append(paths, gen.resourceName+"."+constraintKeyIndexRegex.ReplaceAllString(key, "."))
+	}
+	return paths
+}
+
+// publishConsistencyErrors returns the attributes whose publishing is inconsistent with their block: a published
+// attribute within a block which isn't (it would be left out of the module), or a required attribute which isn't
+// published within a block which is (the module couldn't set it)
+func (gen documentationGenerator) publishConsistencyErrors(sa map[string]summaryAttribute) []string {
+
+	problems := make([]string, 0)
+	for _, rp := range sortedKeys(sa) {
+		a := sa[rp]
+		parent := rp[:strings.LastIndex(rp, ".")]
+		if parent == gen.resourceName {
+			continue
+		}
+		if _, ok := sa[parent]; !ok {
+			continue
+		}
+
+		if a.Published && !sa[parent].Published {
+			problems = append(problems, fmt.Sprintf("%s is published but its block %s isn't", rp, parent))
+		}
+		if !a.Published && a.Required && sa[parent].Published && (a.Deprecated == "" || gen.includeDeprecated) {
+			problems = append(problems, fmt.Sprintf("%s is published but its required %s isn't", parent, rp))
+		}
+	}
+
+	return problems
+}
+
+// validatePublishConsistency returns an error listing the attributes whose publishing is inconsistent with their
+// block, see publishConsistencyErrors
+func (gen documentationGenerator) validatePublishConsistency() error {
+
+	if problems := gen.publishConsistencyErrors(gen.readResourceProperties()); len(problems) > 0 {
+		return fmt.Errorf("%s publishes attributes inconsistently with their blocks, publish or unpublish them with `-output-type publish` or `unpublish`:\n  %s", filepath.Base(gen.resourcePropertiesPath()), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// publishConstraintWarnings returns the schema rules the published attributes can't satisfy: an ExactlyOneOf or
+// AtLeastOneOf none of whose attributes are published, or a published attribute whose RequiredWith attributes aren't
+func (gen documentationGenerator) publishConstraintWarnings(sa map[string]summaryAttribute) []string {
+
+	paths := make([]string, 0, len(sa))
+	for rp := range sa {
+		paths = append(paths, rp)
+	}
+	sort.Strings(paths)
+
+	// a rule within a block which isn't published doesn't apply
+	applies := func(rp string) bool {
+		parent := rp[:strings.LastIndex(rp, ".")]
+		return parent == gen.resourceName || sa[parent].Published
+	}
+	unpublished := func(group []string) []string {
+		missing := make([]string, 0)
+		for _, rp := range group {
+			if !sa[rp].Published {
+				missing = append(missing, rp)
+			}
+		}
+		return missing
+	}
+
+	warnings := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, rp := range paths {
+		a := sa[rp]
+		if !applies(rp) {
+			continue
+		}
+
+		for rule, group := range map[string][]string{"exactly one": a.ExactlyOneOf, "at least one": a.AtLeastOneOf} {
+			key := rule + strings.Join(sortedCopy(group), ",")
+			if len(group) == 0 || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if len(unpublished(group)) == len(group) {
+				warnings = append(warnings, fmt.Sprintf("%s of %s must be set but none are published", rule, strings.Join(sortedCopy(group), ", ")))
+			}
+		}
+
+		if a.Published {
+			if missing := unpublished(a.RequiredWith); len(missing) > 0 {
+				warnings = append(warnings, fmt.Sprintf("%s requires %s which isn't published", rp, strings.Join(missing, ", ")))
+			}
+		}
+	}
+	sort.Strings(warnings)
+
+	return warnings
+}
+
+func (gen documentationGenerator) writeResource(s string, a Artefact) string {
+
+	var fileName string
+	var subDir string
+
+	// fileName := strings.TrimPrefix(gen.resourceName, "azurerm_")
+	if a == TerraformTemplate {
+		fileName = "template.json"
+		subDir = "resource"
+	} else if a == ModuleBlock {
+		fileName = "main.tf"
+		subDir = "module"
+	} else if a == VariableBlock {
+		fileName = "variables.tf"
+		subDir = "module"
+	} else if a == PalletteBlock {
+		fileName = "pallette.sql"
+		subDir = "resource"
+	} else if a == OutputBlock {
+		fileName = "output.tf"
+		subDir = "module"
+	} else if a == LocalBlock {
+		fileName = "local.tf"
+		subDir = "module"
+	} else if a == ImportBlock {
+		fileName = "import.tf"
+		subDir = "resource"
+	} else if a == MovedBlock {
+		fileName = "moved.tf"
+		subDir = "resource"
+	} else if a == ModuleMeta {
+		fileName = "module-meta.json"
+		subDir = "module"
+	} else if a == DataBlock {
+		fileName = "data.tf"
+		subDir = "module"
+	} else if a == TfvarsExample {
+		fileName = "terraform.tfvars.example"
+		subDir = "module"
+	} else if a == ProviderBlock {
+		fileName = "provider.tf"
+		subDir = "resource"
+	} else if a == InfracostConfig {
+		fileName = "infracost.yml"
+		subDir = "module"
+	} else if a == CostMeta {
+		fileName = "cost.json"
+		subDir = "resource"
+	} else if a == PaletteJsonSchema {
+		fileName = "palette.schema.json"
+		subDir = "resource"
+	} else if a == PaletteBackstage {
+		fileName = "template.yaml"
+		subDir = "backstage"
+	} else if a == BackstageSkeleton {
+		fileName = backstageSkeletonFileName
+		subDir = filepath.Join("backstage", backstageSkeletonDir)
+	} else if a == PaletteJson {
+		fileName = "palette.json"
+		subDir = "resource"
+	} else if a == MigrateBlock {
+		fileName = migrateFileName
+		subDir = "resource"
+	} else if a == RetireBlock {
+		fileName = retireFileName
+		subDir = "resource"
+	} else if a == DependencyGraphJson {
+		fileName = "graph.json"
+		subDir = "resource"
+	} else if a == DependencyGraphDot {
+		fileName = "graph.dot"
+		subDir = "resource"
+	}
+
+	return gen.writeResourceFile(s, subDir, fileName)
+}
+
+// fileHeader returns the provenance comment written at the top of a generated file, json has no comments so those
+// files are written without one (module-meta.json records the provenance for the module instead)
+func (gen documentationGenerator) fileHeader(fileName string) string {
+
+	var prefix string
+	switch filepath.Ext(fileName) {
+	case ".tf", ".hcl", ".example", ".yml", ".yaml":
+		prefix = "#"
+	case ".sql":
+		prefix = "--"
+	case ".dot":
+		prefix = "//"
+	default:
+		return ""
+	}
+
+	resourceType := "resource"
+	if gen.isDataSource {
+		resourceType = "data"
+	}
+
+	outputType := "scaffold"
+	if fileName == retireFileName {
+		outputType = "retire"
+	} else if fileName == migrateFileName {
+		outputType = "migrate"
+	}
+
+	dltaPath := gen.dltaPathFlag
+	if dltaPath == "" {
+		dltaPath = gen.dltaPath
+	}
+
+	command := fmt.Sprintf("go run ./internal/tools/dlta-scaffold -name %s -type %s -dlta-path %s -output-type %s -force y", gen.resourceName, resourceType, dltaPath, outputType)
+	if gen.azapiType != "" {
+		command += fmt.Sprintf(" -azapi %s", gen.azapiType)
+	}
+	if gen.providerSchema != "" {
+		command += fmt.Sprintf(" -provider-schema %s", gen.providerSchema)
+	}
+	if gen.restSpec != "" {
+		command += fmt.Sprintf(" -rest-spec %s", gen.restSpec)
+	}
+	if gen.maxDepth > 0 && gen.maxDepth != defaultMaxDepth {
+		command += fmt.Sprintf(" -max-depth %d", gen.maxDepth)
+	}
+	if gen.layout == layoutService {
+		command += fmt.Sprintf(" -layout %s", gen.layout)
+	}
+	if gen.profile != "" {
+		command += fmt.Sprintf(" -profile %s", gen.profile)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Code generated by dlta-scaffold %s; DO NOT EDIT.", generatorVersion),
+		fmt.Sprintf("Provider version: %s", version.ProviderVersion),
+		fmt.Sprintf("Generated at: %s", gen.generatedAt.Format(time.RFC3339)),
+		fmt.Sprintf("Resource: %s", gen.resourceName),
+		fmt.Sprintf("Regenerate with: %s", command),
+		fmt.Sprintf("To keep manual changes through regeneration add the line `%s %s`", prefix, manuallyModifiedMarker),
+	}
+
+	var header string
+	for _, line := range lines {
+		header += fmt.Sprintf("%s %s\n", prefix, line)
+	}
+
+	return header + "\n"
+}
+
+// isManuallyModified returns whether a line of the file is a comment holding only the manually modified marker
+func isManuallyModified(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{"#", "--", "//"} {
+			if strings.HasPrefix(line, prefix) && strings.TrimSpace(strings.TrimPrefix(line, prefix)) == manuallyModifiedMarker {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// resourceDir returns `<dlta-path>/<r|d>/<resource>/<subDir>` using the separator for the current platform, a profile's
+// artefacts are within `<resource>/profiles/<profile>`
+func (gen documentationGenerator) resourceDir(subDir string) string {
+	return filepath.Join(append(append([]string{gen.dltaPath}, gen.artefactLayout()...), subDir)...)
+}
+
+// artefactLayout returns the directories the artefacts are written to within the dlta path, those of the resource
+// unless scaffolding a profile
+func (gen documentationGenerator) artefactLayout() []string {
+	if gen.profile == "" {
+		return gen.resourceLayout()
+	}
+	return append(gen.resourceLayout(), "profiles", gen.profile)
+}
+
+const (
+	// layoutFlat writes each resource to its own directory e.g. `r/azurerm_key_vault`
+	layoutFlat = "flat"
+
+	// layoutService groups the resources by the service registering them e.g. `r/key-vault/azurerm_key_vault`
+	layoutService = "service"
+)
+
+// serviceDirRegex matches the characters of a service name which aren't used in its directory
+var serviceDirRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// serviceDirName returns the directory of a service's resources with `-layout service` e.g. `Key Vault` => `key-vault`,
+// it never has a `_` so can't be mistaken for a resource
+func serviceDirName(serviceName string) string {
+	return strings.Trim(serviceDirRegex.ReplaceAllString(strings.ToLower(serviceName), "-"), "-")
+}
+
+// resourceLayout returns the directories the resource is written to within the dlta path, see `-layout`. A resource
+// being retired isn't looked up so its service isn't known, it's found where it was scaffolded
+func (gen documentationGenerator) resourceLayout() []string {
+	resourceKind := "r"
+	if !gen.isResource {
+		resourceKind = "d"
+	}
+
+	if gen.layout != layoutService {
+		return []string{resourceKind, gen.resourceName}
+	}
+
+	if serviceDir := serviceDirName(gen.serviceName); serviceDir != "" {
+		return []string{resourceKind, serviceDir, gen.resourceName}
+	}
+	if dirs, err := scaffoldedDirs(gen.dltaPath, resourceKind); err == nil {
+		if dir, ok := dirs[gen.resourceName]; ok {
+			if rel, err := filepath.Rel(gen.dltaPath, dir); err == nil {
+				return strings.Split(filepath.ToSlash(rel), "/")
+			}
+		}
+	}
+
+	return []string{resourceKind, gen.resourceName}
+}
+
+// resourcePropertiesPath returns the path of the summary json written by `init` and read by `scaffold`
+func (gen documentationGenerator) resourcePropertiesPath() string {
+	// the summary holds the profiles so is shared by them
+	return filepath.Join(append(append([]string{gen.dltaPath}, gen.resourceLayout()...), "resource", gen.resourceName+".json")...)
+}
+
+// resolveDltaPath cleans the path passed via `-dlta-path` and makes it absolute so relative and absolute paths
+// behave the same, the path may not exist yet (`init` creates it) but mustn't be a file
+func resolveDltaPath(dltaPath string) (string, error) {
+	if strings.TrimSpace(dltaPath) == "" {
+		return "", fmt.Errorf("the dlta path must be specified via `-dlta-path`")
+	}
+
+	absolutePath, err := filepath.Abs(filepath.Clean(dltaPath))
+	if err != nil {
+		return "", fmt.Errorf("resolving %q: %+v", dltaPath, err)
+	}
+
+	if info, err := os.Stat(absolutePath); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("the dlta path %q must be a directory", absolutePath)
+	}
+
+	return absolutePath, nil
+}
+
+// writeResourceFile writes an artefact to `<dlta-path>/<r|d>/<resource>/<subDir>/<fileName>`, used directly for
+// artefacts which have more than one file e.g. a backend configuration per environment
+func (gen documentationGenerator) writeResourceFile(s string, subDir string, fileName string) string {
+
+	outputDirectoryPath := gen.resourceDir(subDir)
+	outputPath := filepath.Join(outputDirectoryPath, fileName)
+
+	if gen.isForced {
+		if existing, err := os.ReadFile(outputPath); err == nil {
+			if isManuallyModified(string(existing)) {
+				fmt.Printf("writeResource \"2. Manually modified\" not overwriting %s\n", outputPath)
+				return ""
+			}
+			// fmt.Printf("initResourceProperties \"2. Clearing out previous version of file\": %s\n", outputPath)
+			os.Remove(outputPath)
+		}
+	}
+
+	if strings.HasSuffix(fileName, ".tf") {
+		if err := validateHcl(fileName, s); err != nil {
+			if !gen.allowInvalid {
+				fmt.Printf("writeResource \"6. validation error\" refusing to write %s (use `-allow-invalid y` to override): %v\n", outputPath, err)
+				return ""
+			}
+			fmt.Printf("writeResource \"6. validation error\" writing invalid %s: %v\n", outputPath, err)
+		}
+	}
+
+	if _, err := os.Stat(outputPath); err == nil {
+
+		fmt.Printf("writeResource \"3. File exists error\"  on path: %s\n", outputPath)
+
+		// fmt.Printf("\"After stat, file exists error\": %s\n", outputPath)
+	} else {
+		err := os.MkdirAll(outputDirectoryPath, os.ModePerm)
+		if err != nil {
+			fmt.Printf("writeResource \"4.1 directory error\": %v\n", err.Error())
+		}
+
+		s = gen.fileHeader(fileName) + s
+
+		// s = strings.TrimSpace(s)
+		if err := writeFileAtomically(outputPath, []byte(s)); err != nil {
+			fmt.Printf("writeResource \"4. file error\": %v\n", err.Error())
+		}
+	}
+
+	return ""
+}
+
+func (gen documentationGenerator) writeInitResourceProperties() string {
+
+	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" {
+		attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+
+		// f := gen.getAllInputAttributesSummary(gen.resource.Schema, attributeSummary{}, false, gen.resourceName)
+		// writeDebugJson(f)
+
+		flatted := gen.summariseAttributes(attributes, gen.resourceName, true)
+
+		outputPath := gen.resourcePropertiesPath()
+		outputDirectoryPath := filepath.Dir(outputPath)
+
+		// an existing summary is merged with the schema so publishing choices survive, -force starts it afresh
+		profiles := make(map[string][]string)
+		var extends string
+		if existing, err := os.ReadFile(outputPath); err == nil && !gen.isForced {
+			if err := gen.validateResourceSummary(existing); err != nil {
+				fmt.Printf("initResourceProperties \"invalid summary, fix it or re-initialise with -force y\": %v\n", err.Error())
+				return ""
+			}
+			_, sa := parseResourceSummary(existing)
+			var added, removed []string
+			flatted, added, removed = mergeResourceSummary(sa, flatted)
+			fmt.Printf("initResourceProperties \"merged\": %s, kept %d, added %d, removed %d\n", outputPath, len(flatted)-len(added), len(added), len(removed))
+			for _, rp := range added {
+				fmt.Printf("initResourceProperties \"added\": %s\n", rp)
+			}
+			for _, rp := range removed {
+				if sa[rp].Published {
+					fmt.Printf("initResourceProperties \"removed published attribute\": %s\n", rp)
+				} else {
+					fmt.Printf("initResourceProperties \"removed\": %s\n", rp)
+				}
+			}
+
+			extends = readSummaryExtends(existing)
+
+			// the attributes removed are dropped from the profiles too
+			for name, paths := range readSummaryProfiles(existing) {
+				kept := make([]string, 0, len(paths))
+				for _, rp := range paths {
+					if _, ok := flatted[rp]; ok {
+						kept = append(kept, rp)
+					}
+				}
+				profiles[name] = kept
+			}
+		}
+
+		// the publish rules take precedence over the publishing choices, which are kept for the attributes not matched
+		if published, unpublished := gen.applyPublishRules(flatted); len(published) > 0 || len(unpublished) > 0 {
+			fmt.Printf("initResourceProperties \"publish rules\": published %d, unpublished %d\n", len(published), len(unpublished))
+		}
+
+		// the references detected are left for the user to confirm (or reject) in the summary
+		for _, rp := range sortedKeys(flatted) {
+			if r := flatted[rp].Reference; r != nil && !r.Confirmed && r.Output != "" {
+				fmt.Printf("initResourceProperties \"proposed reference\": %s => %s.%s by %s, confirm it in %s\n", rp, r.AssetType, r.Output, r.DetectedBy, filepath.Base(outputPath))
+			}
+		}
+
+		// a profile starts with the attributes published for the resource
+		if _, ok := profiles[gen.profile]; gen.profile != "" && !ok {
+			published := make([]string, 0)
+			for _, rp := range sortedKeys(flatted) {
+				if flatted[rp].Published {
+					published = append(published, rp)
+				}
+			}
+			profiles[gen.profile] = published
+			fmt.Printf("initResourceProperties \"added profile\": %s\n", gen.profile)
+		}
+
+		summary := make(map[string]any, len(flatted)+4)
+		for rp, a := range flatted {
+			summary[rp] = a
+		}
+		summary[summaryFormatKey] = summaryFormat
+		if gen.serviceName != "" {
+			summary[summaryServiceKey] = gen.serviceName
+			summary[summaryWebsiteCategoriesKey] = gen.websiteCategories
+		}
+		if gen.stampsProviderVersion() {
+			summary[summaryProviderVersionKey] = version.ProviderVersion
+		}
+		if len(profiles) > 0 {
+			summary[summaryProfilesKey] = profiles
+		}
+		if extends != "" {
+			summary[summaryExtendsKey] = extends
+		}
+
+		content := strings.TrimSpace(writeJson(summary))
+
+		if err := os.MkdirAll(outputDirectoryPath, os.ModePerm); err != nil {
+			fmt.Printf("initResourceProperties \"4.1 directory error\": %v\n", err.Error())
+		}
+		if err := writeFileAtomically(outputPath, []byte(content)); err != nil {
+			fmt.Printf("initResourceProperties \"4. file error\": %v\n", err.Error())
+		}
+	}
+	return ""
+}
+
+// mergeResourceSummary merges the summary being re-initialised with the one summarised from the schema, the schema's
+// view of each attribute is taken but the user's publishing choices are kept, returning the merged summary with the
+// resource paths added to and removed from the schema since
+func mergeResourceSummary(existing, current map[string]summaryAttribute) (map[string]summaryAttribute, []string, []string) {
+
+	merged := make(map[string]summaryAttribute, len(current))
+	added := make([]string, 0)
+	for _, rp := range sortedKeys(current) {
+		a := current[rp]
+		if previous, ok := existing[rp]; ok {
+			a.Published = previous.Published
+			a.Sensitive = a.Sensitive || previous.Sensitive
+			if previous.DependentResourcePath != "" {
+				a.DependentResourcePath = previous.DependentResourcePath
+			}
+			a.Override = previous.Override
+			a.Environments = previous.Environments
+			a.Overridden = previous.Overridden
+			// a reference the user confirmed or rejected isn't proposed again
+			if previous.Reference != nil {
+				a.Reference = previous.Reference
+			}
+		} else {
+			added = append(added, rp)
+		}
+		merged[rp] = a
+	}
+
+	removed := make([]string, 0)
+	for _, rp := range sortedKeys(existing) {
+		if _, ok := current[rp]; !ok {
+			removed = append(removed, rp)
+		}
+	}
+
+	return merged, added, removed
+}
+
+func (gen documentationGenerator) readResourceProperties() map[string]summaryAttribute {
+
+	data := make(map[string]summaryAttribute)
+
+	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" {
+
+		outputPath := gen.resourcePropertiesPath()
+
+		if _, err := os.Stat(outputPath); err != nil {
+			fmt.Printf("readResourceProperties \"2. File does not exist\": %s\n", outputPath)
+			fmt.Printf("readResourceProperties \"2. You may not have run init to create\": %s\n", outputPath)
+			return data
+		}
+
+		fileContent, err := os.ReadFile(outputPath)
+
+		if err != nil {
+			fmt.Printf("readResourceProperties \"file error\": %v\n", err.Error())
+			return data
+		}
+
+		if err := gen.validateResourceSummary(fileContent); err != nil {
+			fmt.Printf("readResourceProperties \"invalid summary\": %v\n", err.Error())
+			return data
+		}
+
+		_, data = parseResourceSummary(fileContent)
+
+		if err := gen.applyExtends(data, fileContent, nil); err != nil {
+			fmt.Printf("readResourceProperties \"extends error\": %v\n", err.Error())
+			return make(map[string]summaryAttribute)
+		}
+
+		if gen.profile != "" {
+			paths, ok := readSummaryProfiles(fileContent)[gen.profile]
+			if !ok {
+				fmt.Printf("readResourceProperties \"unknown profile\" %s: %s\n", gen.profile, outputPath)
+				return make(map[string]summaryAttribute)
+			}
+			published := make(map[string]bool, len(paths))
+			for _, rp := range paths {
+				published[rp] = true
+			}
+			for rp, a := range data {
+				a.Published = published[rp]
+				data[rp] = a
+			}
+		}
+	}
+	return data
+}
+
+// readSummaryExtends returns the resource whose summary the summary extends, or empty when it doesn't
+func readSummaryExtends(content []byte) string {
+
+	var summary struct {
+		Extends string `json:"extends"`
+	}
+	_ = json.Unmarshal(content, &summary)
+	return summary.Extends
+}
+
+// applyExtends inherits the publishing (Published, Sensitive, DependentResourcePath and Override) of the attributes
+// the summary shares with the summary it extends, matched by their path within the resource. Attributes which are
+// Overridden keep their own, as do those the extended summary doesn't have. What that summary extends is applied to
+// it first, seen holds the resources already extending it
+func (gen documentationGenerator) applyExtends(sa map[string]summaryAttribute, content []byte, seen []string) error {
+
+	extends := readSummaryExtends(content)
+	if extends == "" {
+		return nil
+	}
+
+	seen = append(seen, gen.resourceName)
+	if slices.Contains(seen, extends) {
+		return fmt.Errorf("%s can't be extended by a summary it extends: %s", extends, strings.Join(append(seen, extends), " extends "))
+	}
+
+	base := documentationGenerator{resourceName: extends, isResource: gen.isResource, dltaPath: gen.dltaPath}
+	base.layout = gen.layout
+	baseContent, err := os.ReadFile(base.resourcePropertiesPath())
+	if err != nil {
+		return fmt.Errorf("%s extends %s which hasn't been initialised: %+v", gen.resourceName, extends, err)
+	}
+	_, inherited := parseResourceSummary(baseContent)
+	if err := base.applyExtends(inherited, baseContent, seen); err != nil {
+		return err
+	}
+
+	for rp, a := range sa {
+		b, ok := inherited[extends+"."+gen.attributePath(rp)]
+		if !ok || a.Overridden {
+			continue
+		}
+		a.Published = b.Published
+		a.Sensitive = a.Sensitive || b.Sensitive
+		a.DependentResourcePath = b.DependentResourcePath
+		a.Override = b.Override
+		a.Environments = b.Environments
+		sa[rp] = a
+	}
+
+	return nil
+}
+
+// readSummaryProfiles returns the publish profiles of the summary, the resource paths published by each keyed by name
+func readSummaryProfiles(content []byte) map[string][]string {
+
+	var summary struct {
+		Profiles map[string][]string `json:"profiles"`
+	}
+	_ = json.Unmarshal(content, &summary)
+	if summary.Profiles == nil {
+		return make(map[string][]string)
+	}
+	return summary.Profiles
+}
+
+// validateProfile returns an error when the profile scaffolded isn't in the summary
+func (gen documentationGenerator) validateProfile() error {
+
+	if gen.profile == "" {
+		return nil
+	}
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		return fmt.Errorf("reading %s: %+v", gen.resourcePropertiesPath(), err)
+	}
+	if _, ok := readSummaryProfiles(content)[gen.profile]; !ok {
+		return fmt.Errorf("%s has no profile %q, add it with `-output-type init -profile %s`", filepath.Base(gen.resourcePropertiesPath()), gen.profile, gen.profile)
+	}
+	return nil
+}
+
+// setPublished publishes (or unpublishes) the attributes of the summary matching the patterns, resource paths where
+// `*` matches a single element, editing the profile instead with `-profile`. The summary is only written when it's
+// valid and every pattern matches an attribute of the schema, it returns the resource paths changed
+func (gen documentationGenerator) setPublished(patterns []string, publish bool) ([]string, error) {
+
+	outputPath := gen.resourcePropertiesPath()
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s, initialise it with `-output-type init`: %+v", outputPath, err)
+	}
+	if err := gen.validateResourceSummary(content); err != nil {
+		return nil, err
+	}
+	_, sa := parseResourceSummary(content)
+
+	schemaPaths := sortedKeys(gen.summariseAttributes(gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName), gen.resourceName, true))
+	matched := make(map[string]bool)
+	for _, p := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(p, ".", "/"), ""); err != nil {
+			return nil, fmt.Errorf("%q is not a valid pattern: %+v", p, err)
+		}
+
+		matches := 0
+		for _, rp := range schemaPaths {
+			if matchResourcePath(p, rp) {
+				if _, ok := sa[rp]; !ok {
+					return nil, fmt.Errorf("%s isn't in %s, re-initialise it with `-output-type init`", rp, filepath.Base(outputPath))
+				}
+				matched[rp] = true
+				matches++
+			}
+		}
+		if matches == 0 {
+			message := fmt.Sprintf("%q doesn't match an attribute of %s", p, gen.resourceName)
+			if suggestion := closestName(p, schemaPaths); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q", suggestion)
+			}
+			return nil, fmt.Errorf("%s", message)
+		}
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %+v", outputPath, err)
+	}
+
+	changed := make([]string, 0)
+	isPublished := func(rp string) bool { return sa[rp].Published }
+	if gen.profile != "" {
+		profiles := readSummaryProfiles(content)
+		paths, ok := profiles[gen.profile]
+		if !ok {
+			return nil, fmt.Errorf("%s has no profile %q, add it with `-output-type init -profile %s`", filepath.Base(outputPath), gen.profile, gen.profile)
+		}
+
+		inProfile := make(map[string]bool, len(paths))
+		for _, rp := range paths {
+			inProfile[rp] = true
+		}
+		for _, rp := range sortedKeys(matched) {
+			if inProfile[rp] != publish {
+				inProfile[rp] = publish
+				changed = append(changed, rp)
+			}
+		}
+		profiles[gen.profile] = make([]string, 0, len(inProfile))
+		for _, rp := range sortedKeys(inProfile) {
+			if inProfile[rp] {
+				profiles[gen.profile] = append(profiles[gen.profile], rp)
+			}
+		}
+		raw[summaryProfilesKey] = json.RawMessage(writeJson(profiles))
+		isPublished = func(rp string) bool { return inProfile[rp] }
+	} else {
+		// publishing an attribute of a summary extending another overrides what it inherits
+		extends := readSummaryExtends(content) != ""
+		effective := make(map[string]summaryAttribute, len(sa))
+		for rp, a := range sa {
+			effective[rp] = a
+		}
+		if err := gen.applyExtends(effective, content, nil); err != nil {
+			return nil, err
+		}
+		isPublished = func(rp string) bool { return effective[rp].Published }
+
+		for _, rp := range sortedKeys(matched) {
+			a := sa[rp]
+			if effective[rp].Published == publish && (a.Overridden || !extends) {
+				continue
+			}
+			a.Published = publish
+			a.Overridden = a.Overridden || extends
+			sa[rp] = a
+			effective[rp] = a
+			raw[rp] = json.RawMessage(writeJson(a))
+			changed = append(changed, rp)
+		}
+	}
+
+	// a nested attribute is only scaffolded with its block
+	for _, rp := range changed {
+		parent := rp[:strings.LastIndex(rp, ".")]
+		if publish && parent != gen.resourceName && !isPublished(parent) {
+			fmt.Printf("setPublished \"parent isn't published\": %s isn't scaffolded until %s is published\n", rp, parent)
+		}
+	}
+
+	if len(changed) == 0 {
+		fmt.Printf("setPublished \"unchanged\": %s\n", outputPath)
+		return changed, nil
+	}
+
+	if err := writeFileAtomically(outputPath, []byte(strings.TrimSpace(writeJson(raw)))); err != nil {
+		return nil, fmt.Errorf("writing %s: %+v", outputPath, err)
+	}
+
+	verb := "published"
+	if !publish {
+		verb = "unpublished"
+	}
+	for _, rp := range changed {
+		fmt.Printf("setPublished \"%s\": %s\n", verb, rp)
+	}
+
+	return changed, nil
+}
+
+// summaryProviderVersionKey holds the provider version the summary was initialised with, it isn't a resource path so
+// can't clash with an attribute
+const summaryProviderVersionKey = "provider_version"
+
+// summaryFormatKey holds the format of the summary, format 2 added the schema of each attribute (summaries without it
+// are format 1)
+const summaryFormatKey = "format"
+
+// summaryFormat is the format of the summaries written, bumped when the content of the summary changes along with a
+// migration from the previous format in summaryMigrations
+const summaryFormat = 2
+
+// summaryMigration upgrades a summary to the format after the one it's keyed by in summaryMigrations, editing its json
+// in place so the keys it doesn't know about are kept
+type summaryMigration func(gen *documentationGenerator, raw map[string]json.RawMessage) error
+
+// summaryMigrations upgrade the summaries written by earlier versions of dlta-scaffold, see `-output-type
+// migrate-summaries`, keyed by the format they upgrade from
+var summaryMigrations = map[int]summaryMigration{
+	1: migrateSummaryFormat1,
+}
+
+// summaryFormatOf returns the format of a summary, those without one are format 1
+func summaryFormatOf(raw map[string]json.RawMessage) int {
+	format := 1
+	if v, ok := raw[summaryFormatKey]; ok {
+		_ = json.Unmarshal(v, &format)
+	}
+	return format
+}
+
+// migrateSummaryFormat1 adds the schema of each attribute, and the service registering the resource, read from the
+// loaded provider. The publishing is left as it is, as is an attribute the provider has since removed (which init drops)
+func migrateSummaryFormat1(gen *documentationGenerator, raw map[string]json.RawMessage) error {
+
+	if err := gen.lookupResource(); err != nil {
+		return err
+	}
+	current := gen.summariseAttributes(gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName), gen.resourceName, true)
+
+	for _, rp := range sortedKeys(raw) {
+		s, ok := current[rp]
+		if _, reserved := summaryReservedKeys[rp]; reserved || !ok {
+			continue
+		}
+		var a summaryAttribute
+		if err := json.Unmarshal(raw[rp], &a); err != nil {
+			return fmt.Errorf("%s: %+v", rp, err)
+		}
+		a.DataType, a.ElemType, a.Description, a.PossibleValues, a.Constraints = s.DataType, s.ElemType, s.Description, s.PossibleValues, s.Constraints
+		raw[rp] = json.RawMessage(writeJson(a))
+	}
+
+	if _, ok := raw[summaryServiceKey]; !ok && gen.serviceName != "" {
+		raw[summaryServiceKey] = json.RawMessage(writeJson(gen.serviceName))
+		raw[summaryWebsiteCategoriesKey] = json.RawMessage(writeJson(gen.websiteCategories))
+	}
+
+	return nil
+}
+
+// migrateSummary upgrades the summary to the newest format one migration at a time, returning whether it was changed.
+// A summary written by a newer dlta-scaffold can't be downgraded so is an error
+func (gen *documentationGenerator) migrateSummary() (bool, error) {
+
+	release, err := acquireLock(gen.resourceLockDir(), gen.lockTimeout)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	outputPath := gen.resourcePropertiesPath()
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %+v", outputPath, err)
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return false, fmt.Errorf("parsing %s: %+v", outputPath, err)
+	}
+
+	format := summaryFormatOf(raw)
+	if format > summaryFormat {
+		return false, fmt.Errorf("%s is format %d, newer than the format %d this dlta-scaffold writes, upgrade dlta-scaffold", outputPath, format, summaryFormat)
+	}
+	if format == summaryFormat {
+		return false, nil
+	}
+
+	for ; format < summaryFormat; format++ {
+		migrate, ok := summaryMigrations[format]
+		if !ok {
+			return false, fmt.Errorf("there's no migration of %s from format %d", outputPath, format)
+		}
+		if err := migrate(gen, raw); err != nil {
+			return false, fmt.Errorf("migrating %s from format %d: %+v", outputPath, format, err)
+		}
+		fmt.Printf("migrateSummary \"migrated\": %s from format %d to %d\n", outputPath, format, format+1)
+	}
+	raw[summaryFormatKey] = json.RawMessage(strconv.Itoa(summaryFormat))
+
+	if err := writeFileAtomically(outputPath, []byte(strings.TrimSpace(writeJson(raw)))); err != nil {
+		return false, fmt.Errorf("writing %s: %+v", outputPath, err)
+	}
+	gen.recordHistory("migrate-summaries")
+	return true, nil
+}
+
+// migrateSummaries upgrades the summaries (of every resource initialised, or those named) to the newest format, so
+// catalogs initialised by earlier versions of dlta-scaffold keep working as the format evolves. A summary which can't
+// be migrated doesn't stop the others
+func migrateSummaries(dltaPath string, names []string, options scaffoldOptions) error {
+
+	resources, err := namedSummarisedResources(dltaPath, names)
+	if err != nil {
+		return err
+	}
+
+	migrated, failed := 0, 0
+	for _, r := range resources {
+		gen, err := newDocumentationGenerator(r.name, r.isResource, dltaPath, options)
+		if err != nil {
+			return err
+		}
+		changed, err := gen.migrateSummary()
+		if err != nil {
+			fmt.Printf("migrateSummaries \"failed\" %s: %v\n", r.name, err)
+			failed++
+			continue
+		}
+		if changed {
+			migrated++
+		}
+	}
+
+	fmt.Printf("migrateSummaries \"migrated\" %d of %d summaries to format %d\n", migrated, len(resources), summaryFormat)
+	if failed > 0 {
+		return fmt.Errorf("%d summaries couldn't be migrated", failed)
+	}
+	return nil
+}
+
+// summaryServiceKey and summaryWebsiteCategoriesKey hold the service package registering the resource and its
+// documentation categories, they're left out when the service isn't known
+const (
+	summaryServiceKey           = "service"
+	summaryWebsiteCategoriesKey = "website_categories"
+)
+
+// summaryExtendsKey holds the resource whose summary this one extends e.g. a `azurerm_linux_web_app` summary extending
+// `azurerm_windows_web_app` inherits its publishing, see applyExtends
+const summaryExtendsKey = "extends"
+
+// summaryProfilesKey holds the publish profiles, each a list of the resource paths it publishes in place of the
+// Published flags e.g. `{"minimal": ["azurerm_foobar.name"]}`, see `-profile`
+const summaryProfilesKey = "profiles"
+
+// profileNameRegex matches the name of a profile, which is used as a directory
+var profileNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// parseResourceSummary returns the provider version the summary was initialised with (empty for summaries written
+// before it was recorded) and its attributes keyed by resource path
+func parseResourceSummary(content []byte) (string, map[string]summaryAttribute) {
+
+	data := make(map[string]summaryAttribute)
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		fmt.Printf("readResourceProperties \"parse error\": %v\n", err.Error())
+		return "", data
+	}
+
+	var providerVersion string
+	for k, v := range raw {
+		if k == summaryProviderVersionKey {
+			_ = json.Unmarshal(v, &providerVersion)
+			continue
+		}
+		if k == summaryFormatKey || k == summaryServiceKey || k == summaryWebsiteCategoriesKey || k == summaryProfilesKey || k == summaryExtendsKey {
+			continue
+		}
+
+		var a summaryAttribute
+		if err := json.Unmarshal(v, &a); err != nil {
+			fmt.Printf("readResourceProperties \"parse error\" %s: %v\n", k, err.Error())
+			continue
+		}
+		data[k] = a
+	}
+
+	return providerVersion, data
+}
+
+// validateResourceSummary validates the summary, see validateSummaryContent. The resource paths the schema doesn't
+// know are drift rather than problems, see unknownResourceSummaryPaths
+func (gen documentationGenerator) validateResourceSummary(content []byte) error {
+	return validateSummaryContent(filepath.Base(gen.resourcePropertiesPath()), content, gen.knownSummaryPaths(content))
+}
+
+// unknownResourceSummaryPaths returns the resource paths of the summary the schema of the provider loaded doesn't
+// know, see unknownSummaryPaths
+func (gen documentationGenerator) unknownResourceSummaryPaths(content []byte) []string {
+	return unknownSummaryPaths(filepath.Base(gen.resourcePropertiesPath()), content, gen.knownSummaryPaths(content))
+}
+
+// knownSummaryPaths returns the attributes of the schema loaded keyed by resource path, or nil when the summary was
+// initialised with another provider as its paths are then compared by summaryDrift
+func (gen documentationGenerator) knownSummaryPaths(content []byte) map[string]summaryAttribute {
+
+	if gen.resource == nil {
+		return nil
+	}
+
+	var stamped struct {
+		ProviderVersion string `json:"provider_version"`
+	}
+	_ = json.Unmarshal(content, &stamped)
+	if gen.stampsProviderVersion() && stamped.ProviderVersion != "" && stamped.ProviderVersion != version.ProviderVersion {
+		return nil
+	}
+
+	return gen.summariseAttributes(gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName), gen.resourceName, true)
+}
+
+// summaryReservedKeys are the keys of the summary which aren't resource paths, with the types of their values
+var summaryReservedKeys = map[string]reflect.Type{
+	summaryProviderVersionKey:   reflect.TypeOf(""),
+	summaryFormatKey:            reflect.TypeOf(0),
+	summaryServiceKey:           reflect.TypeOf(""),
+	summaryWebsiteCategoriesKey: reflect.TypeOf([]string{}),
+	summaryProfilesKey:          reflect.TypeOf(map[string][]string{}),
+	summaryExtendsKey:           reflect.TypeOf(""),
+}
+
+// validateSummaryContent validates a summary as json.Unmarshal would silently skip or zero what it doesn't expect,
+// returning every unknown key and type mismatch located by resource path and key e.g.
+// `azurerm_foobar.json: azurerm_foobar.name: Publshed: unknown key, did you mean "Published"`. An override is checked
+// against the possible values of its attribute in known
+func validateSummaryContent(fileName string, content []byte, known map[string]summaryAttribute) error {
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			// the offset is of the byte after the invalid one
+			line, column := jsonOffsetPosition(content, syntaxErr.Offset-1)
+			return fmt.Errorf("%s: line %d, column %d: %+v", fileName, line, column, err)
+		}
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return fmt.Errorf("%s: expected an object, got %s", fileName, typeErr.Value)
+		}
+		return fmt.Errorf("%s: %+v", fileName, err)
+	}
+
+	problems := make([]string, 0)
+	for _, k := range sortedKeys(raw) {
+		if t, ok := summaryReservedKeys[k]; ok {
+			if problem := jsonValueProblem(raw[k], t); problem != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s: %s", fileName, k, problem))
+				continue
+			}
+			if format := summaryFormatOf(raw); k == summaryFormatKey && format > summaryFormat {
+				problems = append(problems, fmt.Sprintf("%s: %s: %d is newer than the format %d this dlta-scaffold writes, upgrade dlta-scaffold", fileName, k, format, summaryFormat))
+				continue
+			}
+			if k == summaryProfilesKey {
+				profiles := readSummaryProfiles(content)
+				for _, name := range sortedKeys(profiles) {
+					if !profileNameRegex.MatchString(name) {
+						problems = append(problems, fmt.Sprintf("%s: %s: %s: the name must be lowercase letters, digits, `-` and `_`", fileName, k, name))
+					}
+				}
+			}
+			continue
+		}
+
+		objectProblems := jsonObjectProblems(raw[k], reflect.TypeOf(summaryAttribute{}))
+		for _, problem := range objectProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s: %s", fileName, k, problem))
+		}
+
+		var a summaryAttribute
+		if len(objectProblems) == 0 && json.Unmarshal(raw[k], &a) == nil {
+			if a.Override != nil {
+				for _, problem := range a.Override.problems(known[k].PossibleValues) {
+					problems = append(problems, fmt.Sprintf("%s: %s: Override: %s", fileName, k, problem))
+				}
+			}
+			for _, problem := range environmentProblems(a) {
+				problems = append(problems, fmt.Sprintf("%s: %s: Environments: %s", fileName, k, problem))
+			}
+			for _, problem := range referenceProblems(a) {
+				problems = append(problems, fmt.Sprintf("%s: %s: Reference: %s", fileName, k, problem))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// unknownSummaryPaths returns the resource paths of the summary (and of its profiles) which aren't in known, with the
+// path most likely meant, e.g. `azurerm_foobar.json: azurerm_foobar.locaton: unknown resource path, did you mean
+// "azurerm_foobar.location"`. They're drift from the schema (or from the attribute rules and `-max-depth`, which leave
+// attributes out) since the summary was initialised, and are dropped when it's initialised again
+func unknownSummaryPaths(fileName string, content []byte, known map[string]summaryAttribute) []string {
+
+	unknown := make([]string, 0)
+	raw := make(map[string]json.RawMessage)
+	if known == nil || json.Unmarshal(content, &raw) != nil {
+		return unknown
+	}
+
+	for _, k := range sortedKeys(raw) {
+		if _, ok := summaryReservedKeys[k]; ok {
+			continue
+		}
+		if _, ok := known[k]; !ok {
+			problem := fmt.Sprintf("%s: %s: unknown resource path", fileName, k)
+			if suggestion := closestName(k, sortedKeys(known)); suggestion != "" {
+				problem += fmt.Sprintf(", did you mean %q", suggestion)
+			}
+			unknown = append(unknown, problem)
+		}
+	}
+
+	profiles := readSummaryProfiles(content)
+	for _, name := range sortedKeys(profiles) {
+		for i, rp := range profiles[name] {
+			if _, ok := known[rp]; !ok {
+				unknown = append(unknown, fmt.Sprintf("%s: %s: %s: [%d]: unknown resource path %q", fileName, summaryProfilesKey, name, i, rp))
+			}
+		}
+	}
+
+	return unknown
+}
+
+// problems returns why the override can't be honored, options which aren't among the schema's possible values (when
+// it has them) or a default which isn't among the options
+func (o valueOverride) problems(possibleValues []string) []string {
+
+	problems := make([]string, 0)
+	if len(possibleValues) > 0 {
+		for i, option := range o.Options {
+			if !slices.Contains(possibleValues, option) {
+				problems = append(problems, fmt.Sprintf("Options: [%d]: %q isn't one of %s", i, option, strings.Join(possibleValues, ", ")))
+			}
+		}
+	}
+
+	allowed := o.Options
+	if len(allowed) == 0 {
+		allowed = possibleValues
+	}
+	if o.Default != "" && len(allowed) > 0 && !slices.Contains(allowed, o.Default) {
+		problems = append(problems, fmt.Sprintf("Default: %q isn't one of %s", o.Default, strings.Join(allowed, ", ")))
+	}
+
+	return problems
+}
+
+// environmentProblems returns why the attribute can't be published by environment, values which aren't a
+// `dlta_environment_char`, or a block or required attribute which the module can't leave out
+func environmentProblems(a summaryAttribute) []string {
+
+	problems := make([]string, 0)
+	if len(a.Environments) == 0 {
+		return problems
+	}
+
+	options := make([]string, 0, len(dlta_environment_char_options))
+	for _, o := range dlta_environment_char_options {
+		options = append(options, o.Value)
+	}
+	for i, e := range a.Environments {
+		if !isEnvironmentChar(e) {
+			problems = append(problems, fmt.Sprintf("[%d]: %q isn't one of %s", i, e, strings.Join(options, ", ")))
+		}
+	}
+	if a.IsBlock {
+		problems = append(problems, "a block is published in every environment, publish its attributes by environment instead")
+	} else if a.Required {
+		problems = append(problems, "a required attribute is published in every environment")
+	}
+
+	return problems
+}
+
+// jsonObjectProblems returns the unknown keys and type mismatches of a json object decoded into the struct t, prefixed
+// by their key. Keys must match the field names exactly, json.Unmarshal's case insensitive match hides typos
+func jsonObjectProblems(content json.RawMessage, t reflect.Type) []string {
+
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields[t.Field(i).Name] = t.Field(i)
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return []string{jsonValueProblem(content, t)}
+	}
+
+	problems := make([]string, 0)
+	for _, k := range sortedKeys(raw) {
+		field, ok := fields[k]
+		if !ok {
+			problem := fmt.Sprintf("%s: unknown key", k)
+			if suggestion := closestName(k, sortedKeys(fields)); suggestion != "" {
+				problem += fmt.Sprintf(", did you mean %q", suggestion)
+			}
+			problems = append(problems, problem)
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && string(raw[k]) != "null" {
+			for _, problem := range jsonObjectProblems(raw[k], ft) {
+				problems = append(problems, fmt.Sprintf("%s: %s", k, problem))
+			}
+			continue
+		}
+		if problem := jsonValueProblem(raw[k], field.Type); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", k, problem))
+		}
+	}
+
+	return problems
+}
+
+// jsonValueProblem describes why the json value can't be decoded into t, or is empty when it can
+func jsonValueProblem(content json.RawMessage, t reflect.Type) string {
+
+	err := json.Unmarshal(content, reflect.New(t).Interface())
+	if err == nil {
+		return ""
+	}
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		if typeErr.Type != t && (t.Kind() != reflect.Pointer || typeErr.Type != t.Elem()) {
+			// an element of a list is mismatched
+			return fmt.Sprintf("expected %s, got %s", jsonTypeName(t), content)
+		}
+		return fmt.Sprintf("expected %s, got %s", jsonTypeName(t), typeErr.Value)
+	}
+	return err.Error()
+}
+
+// jsonTypeName names the json type a value decoded into t is written as
+func jsonTypeName(t reflect.Type) string {
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		return "a list of " + strings.TrimPrefix(strings.TrimPrefix(jsonTypeName(t.Elem()), "a "), "an ") + "s"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return t.String()
+}
+
+// jsonOffsetPosition returns the 1-based line and column of the byte offset into the json content
+func jsonOffsetPosition(content []byte, offset int64) (int, int) {
+
+	offset = max(0, min(offset, int64(len(content))))
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// closestName returns the name a mistyped one was probably meant to be, one differing only by case or within a couple
+// of edits, or empty when none is close
+func closestName(name string, names []string) string {
+
+	closest, distance := "", 3
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(n)); d < distance {
+			closest, distance = n, d
+		}
+	}
+	return closest
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// stampsProviderVersion reports whether the schema comes from the azurerm provider the tool is compiled against, so
+// the summary records its version. Schemas from `-provider-schema`, `-azapi` or the helper providers have no version
+func (gen documentationGenerator) stampsProviderVersion() bool {
+	return gen.providerSource == "" && gen.azapiType == ""
+}
+
+// summaryDrift compares the summary with the schema of the provider now loaded when it was initialised with another
+// version, returning that version with the resource paths added to and removed from the schema since
+func (gen documentationGenerator) summaryDrift() (string, []string, []string, bool) {
+
+	if gen.resource == nil || !gen.stampsProviderVersion() {
+		return "", nil, nil, false
+	}
+
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		return "", nil, nil, false
+	}
+	initialisedWith, sa := parseResourceSummary(content)
+	if initialisedWith == "" || initialisedWith == version.ProviderVersion {
+		return "", nil, nil, false
+	}
+
+	current := gen.summariseAttributes(gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName), gen.resourceName, true)
+
+	added := make([]string, 0)
+	for _, rp := range sortedKeys(current) {
+		if _, ok := sa[rp]; !ok {
+			added = append(added, rp)
+		}
+	}
+	removed := make([]string, 0)
+	for _, rp := range sortedKeys(sa) {
+		if _, ok := current[rp]; !ok {
+			removed = append(removed, rp)
+		}
+	}
+
+	return initialisedWith, added, removed, true
+}
+
+// summaryDriftWarning describes the drift between the summary and the provider now loaded, see summaryDrift
+func (gen documentationGenerator) summaryDriftWarning() (string, bool) {
+
+	initialisedWith, added, removed, ok := gen.summaryDrift()
+	if !ok {
+		return "", false
+	}
+
+	warning := fmt.Sprintf("%s was initialised with provider %s but %s is loaded", filepath.Base(gen.resourcePropertiesPath()), initialisedWith, version.ProviderVersion)
+	if len(added) > 0 {
+		warning += fmt.Sprintf(", added: %s", strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		warning += fmt.Sprintf(", removed: %s", strings.Join(removed, ", "))
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		warning += ", no attributes were added or removed"
+	}
+
+	return warning, true
+}
+
+func (gen documentationGenerator) getPublishedAttributes() map[string]attribute {
+
+	publishedAttributes := make(map[string]attribute)
+
+	if gen.resource != nil {
+		inputAttributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+
+		sa := gen.readResourceProperties()
+
+		// sa := summariseAttributesForConfig(attributes, gen.resourceName, true)
+
+		publishedAttributes = gen.getAllPublishedAttributes(inputAttributes, sa)
+	}
+
+	return publishedAttributes
+}
+
+func (gen documentationGenerator) getAllPublishedAttributes(allAttr map[string]attribute, sa map[string]summaryAttribute) map[string]attribute {
+
+	retAttributes := make(map[string]attribute)
+
+	for k, a := range allAttr {
+
+		t := attribute{}
+
+		if !sa[a.ResourcePath].Published {
+			continue
+		}
+		if a.Deprecated != "" && !gen.includeDeprecated {
+			continue
+		}
+		t = a
+		t.Sensitive = a.Sensitive || sa[a.ResourcePath].Sensitive
+		if override := sa[a.ResourcePath].Override; override != nil {
+			t = t.withOverride(*override)
+		}
+		t.Environments = sa[a.ResourcePath].Environments
+		t.Reference = sa[a.ResourcePath].Reference
+
+		if a.Deprecated != "" {
+			t.Description = strings.TrimSpace(fmt.Sprintf("%s (Deprecated: %s)", a.Description, a.Deprecated))
+		}
+
+		if a.IsBlock {
+			tempAttributes := make(map[string]attribute)
+			for k2, a2 := range gen.getAllPublishedAttributes(a.Attributes, sa) {
+				if sa[a2.ResourcePath].Published {
+					tempAttributes[k2] = a2
+				}
+			}
+			t.Attributes = tempAttributes
+
+		}
+
+		retAttributes[k] = t
+	}
+
+	return retAttributes
+}
+
+func (gen documentationGenerator) scaffoldConfiguation() string {
+
+	if gen.isDataSource && gen.resource != nil {
+		return gen.scaffoldDataSourceConfiguation()
+	}
+
+	gen.writeResource(gen.terraformTemplateBlock(), TerraformTemplate)
+
+	// writeDebug("#### Template block:\n" + gen.terraformTemplateBlock() + "\n")
+	// the module's files are formatted here rather than when written, so module-meta.json hashes what's written
+	moduleBlock := formatHcl(gen.terraformModuleBlock())
+	gen.writeResource(moduleBlock, ModuleBlock)
+	// writeDebug("#### Module block:\n" + gen.terraformModuleBlock() + "\n")
+
+	variableBlock := formatHcl(gen.terraformVariableBlock())
+	gen.writeResource(variableBlock, VariableBlock)
+	// writeDebug("#### Variable block:\n" + gen.terraformVariableBlock() + "\n")
+
+	localBlock := formatHcl(gen.terraformLocalBlock())
+	gen.writeResource(localBlock, LocalBlock)
+	// writeDebug("#### Local block:\n" + gen.terraformLocalBlock() + "\n")
+
+	gen.writePalette()
+	// writeDebug("#### Pallette block:\n" + gen.dltaPalletteCodeBlock() + "\n")
+
+	outputBlock := formatHcl(gen.terraformOutputBlock())
+	gen.writeResource(outputBlock, OutputBlock)
+	// writeDebug("#### Output block:\n" + gen.terraformOutputBlock() + "\n")
+
+	gen.writeResource(formatHcl(terraformTfvarsExample(gen.getModuleVariables())), TfvarsExample)
+
+	if gen.hasCost() {
+		gen.writeResource(gen.infracostConfigBlock(), InfracostConfig)
+	}
+
+	gen.writeResource(gen.moduleMetaBlock(moduleBlock, variableBlock, localBlock, outputBlock), ModuleMeta)
+	gen.writeDependencyGraph()
+
+	if gen.canImport() {
+		gen.writeResource(gen.terraformImportBlock(), ImportBlock)
+	}
+
+	if gen.canMove() {
+		gen.writeResource(gen.terraformMovedBlock(), MovedBlock)
+	}
+
+	//TODO
+	// OutputBlock
+
+	if gen.resourceName == "terraform_azurerm" {
+		gen.writeResource(gen.terraformProviderBlock(), ProviderBlock)
+
+		backends, err := gen.backendConfigBlocks()
+		if err != nil {
+			fmt.Printf("scaffoldConfiguation \"backend config error\": %v\n", err.Error())
+		}
+		for _, environment := range sortBackendEnvironments(backends) {
+			gen.writeResourceFile(backends[environment], "resource", fmt.Sprintf("backend-%s.hcl", environment))
+		}
+	}
+
+	gen.printRunReport()
+
+	return ""
+}
+
+// scaffoldDataSourceConfiguation writes a module wrapping the data source, the module takes the lookup arguments
+// as variables and exposes the data source's computed attributes as outputs
+func (gen documentationGenerator) scaffoldDataSourceConfiguation() string {
+
+	gen.writeResource(gen.terraformTemplateBlock(), TerraformTemplate)
+
+	dataBlock := gen.terraformDataBlock()
+	gen.writeResource(dataBlock, DataBlock)
+
+	variableBlock := gen.terraformDataSourceVariableBlock()
+	gen.writeResource(variableBlock, VariableBlock)
+
+	gen.writePalette()
+
+	outputBlock := gen.terraformDataSourceOutputBlock()
+	gen.writeResource(outputBlock, OutputBlock)
+
+	gen.writeResource(formatHcl(terraformTfvarsExample(gen.getDataSourceVariables())), TfvarsExample)
+
+	gen.writeResource(gen.moduleMetaBlock(dataBlock, variableBlock, outputBlock), ModuleMeta)
+	gen.writeDependencyGraph()
+
+	gen.printRunReport()
+
+	return ""
+}
+
+func (gen documentationGenerator) terraformDataBlock() string {
+
+	attributes := gen.getPublishedAttributes()
+
+	var dataBlock string
+
+	dataBlock += fmt.Sprintf("data \"%s\" \"this\" {\n", gen.resourceName)
+	for _, n := range sortAttributeNames(attributes) {
+		at := attributes[n]
+		if at.IsBlock {
+			dataBlock += fmt.Sprintf("\t%s {\n", n)
+			for _, k := range sortAttributeNames(at.Attributes) {
+				if !at.Attributes[k].IsBlock {
+					dataBlock += fmt.Sprintf("\t\t%s = var.%s\n", k, genVariableNameFromResourcePath(at.Attributes[k].ResourcePath))
+				}
+			}
+			dataBlock += "\t}\n"
+		} else {
+			dataBlock += fmt.Sprintf("\t%s = var.%s\n", n, n)
+		}
+	}
+	dataBlock += "}\n"
+
+	return dataBlock
+}
+
+// terraformDataSourceVariableBlock declares the lookup arguments, unlike resources no dlta naming variables are
+// needed as the data source looks up something which already exists
+func (gen documentationGenerator) terraformDataSourceVariableBlock() string {
+
+	var variableBlock string
+
+	for _, v := range gen.getDataSourceVariables() {
+		variableBlock += variableDeclaration(v.Name, v.Attribute)
+	}
+
+	return variableBlock
+}
+
+// getDataSourceVariables returns the lookup variables declared by the generated data source module, the arguments of a
+// block are qualified by their path (as the resource module names them) as two blocks can have arguments of one name
+func (gen documentationGenerator) getDataSourceVariables() []moduleVariable {
+
+	variables := make([]moduleVariable, 0)
+
+	attributes := gen.getPublishedAttributes()
+	for _, n := range sortAttributeNames(attributes) {
+		at := attributes[n]
+		if at.IsBlock {
+			for _, k := range sortAttributeNames(at.Attributes) {
+				if !at.Attributes[k].IsBlock {
+					variables = append(variables, moduleVariable{Name: genVariableNameFromResourcePath(at.Attributes[k].ResourcePath), Attribute: at.Attributes[k]})
+				}
+			}
+		} else {
+			variables = append(variables, moduleVariable{Name: n, Attribute: at})
+		}
+	}
+
+	return variables
+}
+
+// terraformTfvarsExample lists every module variable with its description and a plausible value, optional
+// variables are commented out as the module doesn't need them to be set
+func terraformTfvarsExample(variables []moduleVariable) string {
+
+	var tfvarsBlock string
+
+	for i, v := range variables {
+		if i > 0 {
+			tfvarsBlock += "\n"
+		}
+
+		optional := v.Attribute.Optional && !v.Attribute.Required
+		description := strings.Join(strings.Fields(v.Attribute.Description), " ")
+		if optional {
+			description = strings.TrimSpace(description + " (optional)")
+		}
+		if description != "" {
+			tfvarsBlock += fmt.Sprintf("# %s\n", description)
+		}
+
+		if optional && !v.Attribute.hasStaticDefault() {
+			tfvarsBlock += "# "
+		}
+		tfvarsBlock += fmt.Sprintf("%s = %s\n", v.Name, placeholderValue(v.Name, v.Attribute))
+	}
+
+	return tfvarsBlock
+}
+
+// placeholderValue returns an example value for a variable, preferring the schema default, then the first
+// possible value (or palette option for the injected dlta variables) and finally a value of the right type
+func placeholderValue(name string, at attribute) string {
+
+	literal := func(value string) string {
+		return hclLiteral(at, value)
+	}
+
+	if at.Default != "" {
+		return literal(at.Default)
+	}
+	if name == identityVariableName && at.TypeConstraint != "" {
+		return fmt.Sprintf("{ type = %q }", at.PossibleValues[0])
+	}
+	if len(at.PossibleValues) > 0 {
+		return literal(at.PossibleValues[0])
+	}
+	if options := injectedOptions(name); len(options) > 0 {
+		return literal(options[0].Value)
+	}
+
+	switch at.DataTypeString {
+	case "TypeBool":
+		return "false"
+	case "TypeInt", "TypeFloat":
+		return "0"
+	case "TypeList", "TypeSet":
+		return "[]"
+	case "TypeMap":
+		return "{}"
+	default:
+		return fmt.Sprintf("\"<%s>\"", name)
+	}
+}
+
+// secretName returns the token naming the secret holding the value of a sensitive attribute, it's per asset so
+// each asset on the canvas has its own secret e.g. `${dlta_terraform_module_name}_admin_password`
+func secretName(n string) string {
+	return fmt.Sprintf("${dlta_terraform_module_name}_%s", n)
+}
+
+// secretReference returns the template expression for a sensitive attribute, the value is never written into the
+// template but read from the root variable set from the secret (e.g. with a TF_VAR_ pipeline secret)
+func secretReference(n string) string {
+	return "var." + secretName(n)
+}
+
+var (
+	htmlTagRegex          = regexp.MustCompile(`<[^>]*>`)
+	markdownLinkRegex     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasisRegex = regexp.MustCompile("\\*\\*|__|`")
+)
+
+// sanitizeDescription returns a schema description as plain text for the palette's help text, html is removed
+// (after unescaping, so escaped tags are removed too) and markdown links and emphasis reduced to their text
+func sanitizeDescription(description string) string {
+	description = html.UnescapeString(description)
+	description = htmlTagRegex.ReplaceAllString(description, "")
+	description = markdownLinkRegex.ReplaceAllString(description, "$1")
+	description = markdownEmphasisRegex.ReplaceAllString(description, "")
+
+	return strings.Join(strings.Fields(description), " ")
+}
+
+// hclLiteral returns a value of the attribute as HCL, quoted unless the attribute is a bool or number
+func hclLiteral(at attribute, value string) string {
+	switch at.DataTypeString {
+	case "TypeBool", "TypeInt", "TypeFloat":
+		return value
+	case "TypeList", "TypeSet":
+		return fmt.Sprintf("[\"%s\"]", escapeHclString(value))
+	default:
+		return fmt.Sprintf("\"%s\"", escapeHclString(value))
+	}
+}
+
+// defaultValue returns the attribute's Default typed as the palette control's value
+func defaultValue(at attribute) interface{} {
+	switch at.DataTypeString {
+	case "TypeBool":
+		if b, err := strconv.ParseBool(at.Default); err == nil {
+			return b
+		}
+	case "TypeInt":
+		if i, err := strconv.Atoi(at.Default); err == nil {
+			return i
+		}
+	case "TypeFloat":
+		if f, err := strconv.ParseFloat(at.Default, 64); err == nil {
+			return f
+		}
+	}
+
+	return at.Default
+}
+
+// injectedOptions returns the palette options offered for an injected attribute
+func injectedOptions(name string) []KeyValue {
+	switch name {
+	case "location":
+		return dlta_location_options
+	case "dlta_environment_char":
+		return dlta_environment_char_options
+	case "dlta_application_short_code":
+		return dlta_application_short_code_options
+	case "dlta_business_short_code":
+		return dlta_business_short_code_options
+	case "dlta_instance_id":
+		return dlta_instance_id_options
+	case "dlta_location_short_code":
+		return dlta_location_short_code_options
+	}
+
+	return nil
+}
+
+// getDataSourceComputedAttributes returns the top level attributes which are only read by the data source, blocks
+// holding their computed attributes
+func (gen documentationGenerator) getDataSourceComputedAttributes() map[string]attribute {
+
+	retAttributes := make(map[string]attribute)
+
+	retAttributes["id"] = attribute{
+		DataTypeString: "TypeString",
+		Description:    "The resource id",
+		Computed:       true,
+	}
+
+	for n, a := range gen.getAllOutputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName) {
+		if a.Computed && !a.Optional && !a.Required {
+			retAttributes[n] = a
+		}
+	}
+
+	return retAttributes
+}
+
+func (gen documentationGenerator) terraformDataSourceOutputBlock() string {
+
+	var outputBlock string
+
+	attributes := gen.getDataSourceComputedAttributes()
+	for _, k := range sortAttributeNames(attributes) {
+		outputBlock += "output \"" + k + "\" {\n"
+		if description := attributes[k].Description; description != "" {
+			outputBlock += "\tdescription = \"" + escapeHclString(description) + "\"\n"
+		}
+		outputBlock += "\tvalue = data." + gen.resourceName + ".this." + k + "\n"
+		if attributes[k].Sensitive {
+			outputBlock += "\tsensitive = true\n"
+		}
+		outputBlock += "}\n"
+	}
+
+	return outputBlock
+}
+
+// readDltaConfig reads `<dlta-path>/config/<fileName>` into v, returning false if the file doesn't exist so
+// callers can fall back to the built in defaults
+func (gen documentationGenerator) readDltaConfig(fileName string, v interface{}) (bool, error) {
+
+	configPath := filepath.Join(gen.dltaPath, "config", fileName)
+
+	fileContent, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("reading %s: %+v", configPath, err)
+	}
+
+	if err := json.Unmarshal(fileContent, v); err != nil {
+		return false, fmt.Errorf("parsing %s: %+v", configPath, err)
+	}
+
+	return true, nil
+}
+
+// terraformProviderBlock renders the azurerm provider block, the contents of `features {}` come from
+// `config/features.json` e.g. `{"key_vault": {"purge_soft_delete_on_destroy": false}}`
+func (gen documentationGenerator) terraformProviderBlock() string {
+
+	features := make(map[string]interface{})
+	if _, err := gen.readDltaConfig("features.json", &features); err != nil {
+		fmt.Printf("terraformProviderBlock \"features config error\": %v\n", err.Error())
+	}
+
+	if err := validateFeatures(features); err != nil {
+		fmt.Printf("terraformProviderBlock \"features config error\": %v\n", err.Error())
+	}
+
+	var providerBlock string
+
+	providerBlock += "provider \"azurerm\" {\n"
+	providerBlock += "	features {\n"
+	providerBlock += renderHclBody(features, 2)
+	providerBlock += "	}\n"
+	providerBlock += "}\n"
+
+	return providerBlock
+}
+
+// validateFeatures checks the configured features against the provider's `features` schema so typos are caught at
+// generation time rather than by `terraform validate`
+func validateFeatures(features map[string]interface{}) error {
+
+	featuresSchema, ok := provider.AzureProvider().Schema["features"].Elem.(*schema.Resource)
+	if !ok {
+		return nil
+	}
+
+	var walk func(config map[string]interface{}, s map[string]*schema.Schema, path string) []string
+	walk = func(config map[string]interface{}, s map[string]*schema.Schema, path string) []string {
+		unknown := make([]string, 0)
+		for k, v := range config {
+			item, ok := s[k]
+			if !ok {
+				unknown = append(unknown, path+k)
+				continue
+			}
+			if nested, isMap := v.(map[string]interface{}); isMap {
+				if r, isResource := item.Elem.(*schema.Resource); isResource {
+					unknown = append(unknown, walk(nested, r.Schema, path+k+".")...)
+				}
+			}
+		}
+		return unknown
+	}
+
+	if unknown := walk(features, featuresSchema.Schema, "features."); len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unsupported provider features: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// renderHclBody renders decoded JSON as HCL arguments, nested objects become blocks
+func renderHclBody(body map[string]interface{}, depth int) string {
+
+	var output string
+
+	indent := strings.Repeat("\t", depth)
+	keys := make([]string, 0)
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := body[k].(type) {
+		case map[string]interface{}:
+			output += fmt.Sprintf("%s%s {\n", indent, k)
+			output += renderHclBody(v, depth+1)
+			output += fmt.Sprintf("%s}\n", indent)
+		case string:
+			output += fmt.Sprintf("%s%s = \"%s\"\n", indent, k, escapeHclString(v))
+		default:
+			// bools and numbers render the same in JSON and HCL
+			output += fmt.Sprintf("%s%s = %s\n", indent, k, writeJson(v))
+		}
+	}
+
+	return output
+}
+
+// backendConfigBlocks renders a partial azurerm backend configuration per environment from `config/backend.json`,
+// which maps the dlta_environment_char to the backend for that environment
+func (gen documentationGenerator) backendConfigBlocks() (map[string]string, error) {
+
+	backends := make(map[string]backendConfig)
+	if _, err := gen.readDltaConfig("backend.json", &backends); err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[string]string)
+	for environment, backend := range backends {
+		if backend.Key == "" {
+			backend.Key = defaultBackendKey
+		}
+
+		var block string
+		if backend.SubscriptionId != "" {
+			block += fmt.Sprintf("subscription_id      = \"%s\"\n", escapeHclString(backend.SubscriptionId))
+		}
+		block += fmt.Sprintf("resource_group_name  = \"%s\"\n", escapeHclString(backend.ResourceGroupName))
+		block += fmt.Sprintf("storage_account_name = \"%s\"\n", escapeHclString(backend.StorageAccountName))
+		block += fmt.Sprintf("container_name       = \"%s\"\n", escapeHclString(backend.ContainerName))
+		// the key keeps its `${...}` tokens unescaped so they can be rendered by the canvas
+		block += fmt.Sprintf("key                  = \"%s\"\n", escapeHclTemplateString(backend.Key))
+		if backend.UseAzureAdAuth {
+			block += "use_azuread_auth     = true\n"
+		}
+
+		blocks[environment] = block
+	}
+
+	return blocks, nil
+}
+
+func sortBackendEnvironments(backends map[string]string) []string {
+	environments := make([]string, 0)
+	for environment := range backends {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+	return environments
+}
+
+// printRunReport summarises anything the user should know about once the artefacts have been written
+func (gen documentationGenerator) printRunReport() {
+
+	if gen.resource == nil {
+		return
+	}
+
+	inputAttributes := flattenAttributes(gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName))
+	sa := gen.readResourceProperties()
+
+	deprecated := make([]string, 0)
+	for rp, a := range inputAttributes {
+		if a.Deprecated != "" {
+			deprecated = append(deprecated, rp)
+		}
+	}
+	sort.Strings(deprecated)
+
+	if warning, ok := gen.summaryDriftWarning(); ok {
+		color.Yellow("Provider drift for %s: %s", gen.resourceName, warning)
+	}
+	for _, warning := range gen.namingConventionWarnings() {
+		color.Yellow("Naming convention for %s: %s", gen.resourceName, warning)
+	}
+	for _, warning := range gen.publishConstraintWarnings(sa) {
+		color.Yellow("Published attributes for %s: %s", gen.resourceName, warning)
+	}
+
+	if destructive := gen.destructiveInputs(); len(destructive) > 0 {
+		color.Yellow("Destructive inputs for %s, changing them recreates the resource:", gen.resourceName)
+		for _, rp := range destructive {
+			fmt.Printf("  %s\n", rp)
+		}
+	}
+
+	if len(deprecated) > 0 {
+		color.Yellow("Deprecated attributes for %s:", gen.resourceName)
+		for _, rp := range deprecated {
+			state := "not published"
+			if sa[rp].Published {
+				state = "excluded"
+				if gen.includeDeprecated {
+					state = "included"
+				}
+			}
+			fmt.Printf("  %s (%s): %s\n", rp, state, inputAttributes[rp].Deprecated)
+		}
+	}
+}
+
+// destructiveInputs returns the resource paths of the published inputs which are ForceNew, so a change to them on the
+// canvas destroys and recreates the resource
+func (gen documentationGenerator) destructiveInputs() []string {
+
+	attributes := flattenAttributes(gen.getPublishedAttributes())
+
+	// the inputs within a ForceNew block recreate the resource too
+	isForceNew := func(rp string) bool {
+		for rp != "" {
+			if a, ok := attributes[rp]; ok && a.ForceNew {
+				return true
+			}
+			rp = rp[:max(strings.LastIndex(rp, "."), 0)]
+		}
+		return false
+	}
+
+	destructive := make([]string, 0)
+	for rp, a := range attributes {
+		if !a.Computed && !a.IsBlock && isForceNew(rp) {
+			destructive = append(destructive, rp)
+		}
+	}
+	sort.Strings(destructive)
+
+	return destructive
+}
+
+// timeoutOperations are the operations whose timeout can be tuned, reads are quick enough not to need it
+var timeoutOperations = []string{"create", "update", "delete"}
+
+// timeoutsPrefix prefixes the variables (and palette controls) of the timeouts block e.g. `timeouts_create`
+const timeoutsPrefix = "timeouts_"
+
+// timeoutPattern matches a duration the timeouts block accepts e.g. `1h30m`
+const timeoutPattern = "^([0-9]+h)?([0-9]+m)?([0-9]+s)?$"
+
+// getTimeoutAttributes returns an attribute for each operation of the resource with a timeout, defaulting to the
+// provider's timeout when it's known. Data sources only time out reading so have none
+func (gen documentationGenerator) getTimeoutAttributes() map[string]attribute {
+
+	attributes := make(map[string]attribute)
+	if gen.isDataSource || gen.resource == nil || gen.resource.Timeouts == nil {
+		return attributes
+	}
+
+	timeouts := map[string]*time.Duration{
+		"create": gen.resource.Timeouts.Create,
+		"update": gen.resource.Timeouts.Update,
+		"delete": gen.resource.Timeouts.Delete,
+	}
+	for _, operation := range timeoutOperations {
+		d := timeouts[operation]
+		if d == nil {
+			continue
+		}
+
+		a := attribute{
+			DataTypeString: schema.TypeString.String(),
+			Optional:       true,
+			ResourcePath:   fmt.Sprintf("%s.timeouts.%s", gen.resourceName, operation),
+			Description:    fmt.Sprintf("How long to wait for the resource to %s e.g. `1h30m`, the provider's default is used when empty.", operation),
+			Constraints:    constraints{Patterns: []string{timeoutPattern}},
+		}
+		if *d > 0 {
+			a.Default = formatTimeout(*d)
+			a.DefaultSource = defaultSourceSchema
+			a.Description = fmt.Sprintf("How long to wait for the resource to %s e.g. `1h30m`, defaults to %s.", operation, a.Default)
+		}
+		attributes[timeoutsPrefix+operation] = a
+	}
+
+	return attributes
+}
+
+// formatTimeout formats a timeout the way the provider documents it e.g. `30m` rather than `30m0s`
+func formatTimeout(d time.Duration) string {
+	formatted := d.String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+
+	return formatted
+}
+
+// terraformTimeoutsBlock renders the resource's timeouts block from the timeout variables, an empty value (as the
+// canvas renders an unset control) leaves the provider's default in place
+func (gen documentationGenerator) terraformTimeoutsBlock() string {
+
+	attributes := gen.getTimeoutAttributes()
+	if len(attributes) == 0 {
+		return ""
+	}
+
+	block := "\ttimeouts {\n"
+	for _, operation := range timeoutOperations {
+		n := timeoutsPrefix + operation
+		if _, ok := attributes[n]; ok {
+			block += fmt.Sprintf("\t\t%s = var.%s == \"\" ? null : var.%s\n", operation, n, n)
+		}
+	}
+	block += "\t}\n"
+
+	return block
+}
+
+const (
+	// identityVariableName is the variable the resource's managed identity block is declared as
+	identityVariableName = "identity"
+
+	// identityTypeControl is the palette control selecting the type of the resource's managed identity
+	identityTypeControl = "identity_type"
+)
+
+// identityTypes are the types of managed identity offered when the schema doesn't validate the type
+var identityTypes = []string{"SystemAssigned", "UserAssigned"}
+
+// isIdentityBlock reports whether the attribute is the resource's managed identity block, the common shape of a `type`
+// and (optionally) the `identity_ids` of the user assigned identities
+func (gen documentationGenerator) isIdentityBlock(at attribute) bool {
+	if !at.IsBlock || at.MaxItems != 1 || at.ResourcePath != gen.resourceName+"."+identityVariableName {
+		return false
+	}
+	if _, ok := at.Attributes["type"]; !ok {
+		return false
+	}
+	for n := range at.Attributes {
+		if n != "type" && n != "identity_ids" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// identityTypeOptions returns the types of managed identity the resource supports
+func identityTypeOptions(at attribute) []string {
+	if types := at.Attributes["type"].PossibleValues; len(types) > 0 {
+		return types
+	}
+
+	return identityTypes
+}
+
+// identityVariable returns the identity block as a single object variable rather than a variable for each of its
+// arguments, an empty type (as the canvas renders an unset control) is no identity
+func identityVariable(at attribute) attribute {
+
+	variable := at
+	variable.IsBlock = false
+	variable.Attributes = nil
+	variable.PossibleValues = identityTypeOptions(at)
+
+	fields := []string{"type = string"}
+	if _, ok := at.Attributes["identity_ids"]; ok {
+		fields = append(fields, "identity_ids = optional(list(string))")
+	}
+	variable.TypeConstraint = fmt.Sprintf("object({ %s })", strings.Join(fields, ", "))
+
+	quoted := make([]string, 0, len(variable.PossibleValues))
+	for _, t := range variable.PossibleValues {
+		quoted = append(quoted, fmt.Sprintf("%q", t))
+	}
+	variable.Validations = append(variable.Validations, variableValidation{
+		Condition:    fmt.Sprintf("try(var.%s.type, \"\") == \"\" ? true : contains([%s], var.%s.type)", identityVariableName, strings.Join(quoted, ", "), identityVariableName),
+		ErrorMessage: fmt.Sprintf("The identity type must be one of %s.", strings.Join(quoted, ", ")),
+	})
+
+	return variable
+}
+
+// terraformIdentityBlock renders the identity block of the resource from the identity variable, the block is only
+// added when there's an identity
+func terraformIdentityBlock(at attribute) string {
+
+	block := fmt.Sprintf("\tdynamic \"%s\" {\n", identityVariableName)
+	block += fmt.Sprintf("\t\tfor_each = try(var.%s.type, \"\") == \"\" ? [] : [var.%s]\n", identityVariableName, identityVariableName)
+	block += "\t\tcontent {\n"
+	block += fmt.Sprintf("\t\t\ttype = %s.value.type\n", identityVariableName)
+	if _, ok := at.Attributes["identity_ids"]; ok {
+		block += fmt.Sprintf("\t\t\tidentity_ids = %s.value.identity_ids\n", identityVariableName)
+	}
+	block += "\t\t}\n"
+	block += "\t}\n"
+
+	return block
+}
+
+// templateIdentityValue returns the identity object of the template from the identity controls
+func templateIdentityValue(at attribute) string {
+	if _, ok := at.Attributes["identity_ids"]; ok {
+		return fmt.Sprintf("{ type = \"${%s}\", identity_ids = ${identity_ids} }", identityTypeControl)
+	}
+
+	return fmt.Sprintf("{ type = \"${%s}\" }", identityTypeControl)
+}
+
+// identityPaletteRule shows the identity ids control only when a user assigned identity type is selected
+func (gen documentationGenerator) identityPaletteRule(at attribute) (paletteRule, bool) {
+	if _, ok := at.Attributes["identity_ids"]; !ok {
+		return paletteRule{}, false
+	}
+
+	rule := paletteRule{Control: "identity_ids", When: identityTypeControl}
+	for _, t := range identityTypeOptions(at) {
+		if strings.Contains(t, "UserAssigned") {
+			rule.In = append(rule.In, t)
+		}
+	}
+
+	return rule, len(rule.In) > 0
+}
+
+func (gen documentationGenerator) getInjectAttributes() map[string]attribute {
+
+	injectAttributes := make(map[string]attribute)
+
+	if gen.resourceName == "terraform_azurerm" {
+		injectAttributes["terraform_azurerm_azurerm_source"] = terraform_azurerm_azurerm_source
+		injectAttributes["terraform_azurerm_azurerm_version"] = terraform_azurerm_azurerm_version
+		injectAttributes["terraform_azurerm_azapi_source"] = terraform_azurerm_azapi_source
+		injectAttributes["terraform_azurerm_azapi_version"] = terraform_azurerm_azapi_version
+		injectAttributes["dlta_terraform_template"] = dlta_terraform_template
+		injectAttributes["dlta_naming_convention"] = dlta_naming_convention
+
+	} else if gen.resourceName == "devops_pipeline" {
+
+		//TODO
+	} else {
+
+		//TODO Dedupe this
+		if gen.isDataSource {
+			injectAttributes["dlta_environment_char"] = dlta_environment_char
+			injectAttributes["dlta_application_short_code"] = dlta_application_short_code
+			injectAttributes["dlta_business_short_code"] = dlta_business_short_code
+			injectAttributes["dlta_instance_id"] = dlta_instance_id
+			injectAttributes["dlta_location_short_code"] = dlta_location_short_code
+			injectAttributes["dlta_vendor_asset_short_code"] = dlta_vendor_asset_short_code
+			injectAttributes["dlta_terraform_template"] = dlta_terraform_template
+			injectAttributes["dlta_naming_convention"] = dlta_naming_convention
+			injectAttributes["dlta_terraform_module_name"] = dlta_terraform_module_name
+			injectAttributes["dlta_terraform_data_source_name"] = dlta_terraform_data_source_name
+			injectAttributes["dlta_terraform_is_data_source"] = dlta_terraform_is_data_source
+		} else {
+			injectAttributes["dlta_environment_char"] = dlta_environment_char
+			injectAttributes["dlta_application_short_code"] = dlta_application_short_code
+			injectAttributes["dlta_business_short_code"] = dlta_business_short_code
+			injectAttributes["dlta_instance_id"] = dlta_instance_id
+			injectAttributes["dlta_location_short_code"] = dlta_location_short_code
+			injectAttributes["dlta_vendor_asset_short_code"] = dlta_vendor_asset_short_code
+			injectAttributes["dlta_terraform_template"] = dlta_terraform_template
+			injectAttributes["dlta_naming_convention"] = dlta_naming_convention
+			injectAttributes["dlta_terraform_module_name"] = dlta_terraform_module_name
+			injectAttributes["dlta_terraform_is_data_source"] = dlta_terraform_is_data_source
+
+			if gen.canImport() {
+				injectAttributes["dlta_import_resource_id"] = dlta_import_resource_id
+			}
+
+			for n, a := range gen.getTimeoutAttributes() {
+				injectAttributes[n] = a
+			}
+		}
+
+		for n, a := range gen.getNamingTokens() {
+			injectAttributes[n] = a
+		}
+
+	}
+
+	return injectAttributes
+}
+
+func (gen documentationGenerator) injectAttributes() map[string]attribute {
+
+	allAttributes := make(map[string]attribute)
+
+	injectAttributes := gen.getInjectAttributes()
+	publishedAttributes := gen.getPublishedAttributes()
+
+	// allAttributes = injectAttributes + publishedAttributes
+
+	for k, a := range injectAttributes {
+		allAttributes[k] = a
+	}
+
+	for k, a := range publishedAttributes {
+		allAttributes[k] = a
+	}
+
+	return allAttributes
+}
+
+// referenceRule wires the attributes matching Attribute (a pattern of the attribute's name e.g. `*_subnet_id`) to the
+// Output of the module of another asset, of AssetType, chosen on the canvas with the Control (the attribute's name by
+// default) e.g. `{"attribute": "subnet_id", "asset_type": "azurerm_subnet", "output": "id"}`. A rule without an Output
+// stops the attribute being a reference
+type referenceRule struct {
+	Attribute string `json:"attribute"`
+	AssetType string `json:"asset_type"`
+	Output    string `json:"output"`
+	Control   string `json:"control"`
+}
+
+// defaultReferenceRules are the references of the template unless `config/reference_rules.json` says otherwise, the
+// resource group is chosen with the ResourceGroup control the canvas sets from the group the asset is placed in
+var defaultReferenceRules = []referenceRule{
+	{Attribute: "resource_group_name", AssetType: "azurerm_resource_group", Output: "name", Control: "ResourceGroup"},
+	{Attribute: "virtual_network_name", AssetType: "azurerm_virtual_network", Output: "name"},
+	{Attribute: "storage_account_name", AssetType: "azurerm_storage_account", Output: "name"},
+	{Attribute: "subnet_id", AssetType: "azurerm_subnet", Output: "id"},
+	{Attribute: "virtual_network_subnet_id", AssetType: "azurerm_subnet", Output: "id"},
+	{Attribute: "service_plan_id", AssetType: "azurerm_service_plan", Output: "id"},
+	{Attribute: "private_connection_resource_id", Output: "id"},
+}
+
+// referenceOutputRegex matches the output of a module (and the asset type) a reference rule names
+var referenceOutputRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// readReferenceRules returns the reference rules of `config/reference_rules.json` followed by defaultReferenceRules,
+// the first rule matching an attribute wins so the file's override the defaults
+func (gen documentationGenerator) readReferenceRules() ([]referenceRule, error) {
+
+	rules := make([]referenceRule, 0)
+	if _, err := gen.readDltaConfig("reference_rules.json", &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if _, err := path.Match(rule.Attribute, ""); err != nil || rule.Attribute == "" {
+			return nil, fmt.Errorf("reference_rules.json: [%d]: `attribute` %q is not a valid pattern", i, rule.Attribute)
+		}
+		if rule.Output != "" && !referenceOutputRegex.MatchString(rule.Output) {
+			return nil, fmt.Errorf("reference_rules.json: [%d]: `output` must be an attribute name, got %q", i, rule.Output)
+		}
+		if rule.AssetType != "" && !referenceOutputRegex.MatchString(rule.AssetType) {
+			return nil, fmt.Errorf("reference_rules.json: [%d]: `asset_type` must be a resource type e.g. `azurerm_subnet`, got %q", i, rule.AssetType)
+		}
+		if rule.Control != "" && !paletteControlRegex.MatchString(rule.Control) {
+			return nil, fmt.Errorf("reference_rules.json: [%d]: `control` must be a control id, got %q", i, rule.Control)
+		}
+	}
+
+	return append(rules, defaultReferenceRules...), nil
+}
+
+// dataResourceGroupControl names the resource group a data source looks in, unlike a resource's it's entered rather
+// than set from the group the data source is placed in as what it reads can be in any group
+const dataResourceGroupControl = "DataResourceGroup"
+
+// referenceFor returns the reference the attribute is wired as, the one its summary confirms or else the first rule
+// matching its name, and whether it's a reference. A data source's resource group isn't, see dataResourceGroupControl
+func (gen documentationGenerator) referenceFor(n string, at attribute) (referenceRule, bool) {
+	if gen.isDataSource && n == "resource_group_name" {
+		return referenceRule{}, false
+	}
+	if r := at.Reference; r != nil && r.Confirmed && r.Output != "" {
+		return referenceRule{Attribute: n, AssetType: r.AssetType, Output: r.Output, Control: n}, true
+	}
+
+	return gen.referenceRuleFor(n)
+}
+
+// referenceRuleFor returns the first reference rule matching the attribute's name, and whether it's a reference. A
+// generator which hasn't read the config uses the defaults
+func (gen documentationGenerator) referenceRuleFor(n string) (referenceRule, bool) {
+	rules := gen.referenceRules
+	if rules == nil {
+		rules = defaultReferenceRules
+	}
+
+	for _, rule := range rules {
+		if matched, _ := path.Match(rule.Attribute, n); !matched {
+			continue
+		}
+		if rule.Control == "" {
+			rule.Control = n
+		}
+		return rule, rule.Output != ""
+	}
+
+	return referenceRule{}, false
+}
+
+// referenceExpression returns the template's value of a reference attribute, the output of the module named by its
+// control, for resources and data sources alike
+func (gen documentationGenerator) referenceExpression(n string, at attribute) (string, bool) {
+	rule, ok := gen.referenceFor(n, at)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("module.${%s}.%s", rule.Control, rule.Output), true
+}
+
+var (
+	// referenceDescriptionRegex matches the resource a description says the attribute is the ID or name of
+	referenceDescriptionRegex = regexp.MustCompile(`(?i)\b(ID|name) of (?:the |an? |existing )*([A-Za-z][A-Za-z ]*?)(?: (?:which|that|where|to|in|for|on|with|used)\b|[.,;(]|$)`)
+
+	// resourceIDWordRegex splits the words of an azurerm ID's name e.g. `UserAssignedIdentity`
+	resourceIDWordRegex = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+)
+
+// providerRegistry is what the provider registers beyond the schema of the resource scaffolded, the resource types
+// references are detected as and the required arguments each data source looks an existing resource up with
+type providerRegistry struct {
+	ProviderVersion string              `json:"provider_version"`
+	ResourceTypes   []string            `json:"resource_types"`
+	DataSources     map[string][]string `json:"data_sources"`
+}
+
+// providerRegistryPath returns where the registry of a version of the provider is cached, alongside its schemas
+func providerRegistryPath(dltaPath string) string {
+	return filepath.Join(dltaPath, "cache", "schema", version.ProviderVersion, "registry.json")
+}
+
+// readProviderRegistry returns the registry of the provider from the cache, listing it from the provider's services
+// (and caching it) when it isn't cached for this provider version or refresh is set
+func readProviderRegistry(dltaPath string, refresh bool) providerRegistry {
+
+	cachePath := providerRegistryPath(dltaPath)
+	if content, err := os.ReadFile(cachePath); err == nil && !refresh {
+		var registry providerRegistry
+		if err := json.Unmarshal(content, &registry); err == nil && registry.ProviderVersion == version.ProviderVersion {
+			return registry
+		}
+		fmt.Printf("readProviderRegistry \"invalid cache\": %s\n", cachePath)
+	}
+
+	// only the names and arguments are read so no resource is wrapped, which is what makes listing them all slow
+	registry := providerRegistry{ProviderVersion: version.ProviderVersion, ResourceTypes: make([]string, 0), DataSources: make(map[string][]string)}
+	requiredArguments := func(arguments map[string]*schema.Schema) []string {
+		required := make([]string, 0)
+		for _, n := range sortedKeys(arguments) {
+			if arguments[n].Required {
+				required = append(required, n)
+			}
+		}
+		return required
+	}
+	for _, service := range provider.SupportedTypedServices() {
+		for _, ds := range service.DataSources() {
+			registry.DataSources[ds.ResourceType()] = requiredArguments(ds.Arguments())
+		}
+		for _, rs := range service.Resources() {
+			registry.ResourceTypes = append(registry.ResourceTypes, rs.ResourceType())
+		}
+	}
+	for _, service := range provider.SupportedUntypedServices() {
+		for name, ds := range service.SupportedDataSources() {
+			registry.DataSources[name] = requiredArguments(ds.Schema)
+		}
+		for name := range service.SupportedResources() {
+			registry.ResourceTypes = append(registry.ResourceTypes, name)
+		}
+	}
+	sort.Strings(registry.ResourceTypes)
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		fmt.Printf("readProviderRegistry \"cache error\": %+v\n", err)
+	} else if err := writeFileAtomically(cachePath, []byte(writeJson(registry))); err != nil {
+		fmt.Printf("readProviderRegistry \"cache error\": %s: %+v\n", cachePath, err)
+	}
+
+	return registry
+}
+
+// providerRegistry returns the registry of the azurerm provider, empty when the schema is another provider's
+func (gen documentationGenerator) providerRegistry() providerRegistry {
+	if gen.registry == nil || !gen.stampsProviderVersion() {
+		return providerRegistry{}
+	}
+	return gen.registry()
+}
+
+// hasResourceType reports whether the provider registers the resource type
+func (r providerRegistry) hasResourceType(resourceType string) bool {
+	_, found := slices.BinarySearch(r.ResourceTypes, resourceType)
+	return found
+}
+
+// referencedResourceType returns the resource type the words name, dropping leading words until the provider
+// registers it e.g. `virtual_network_subnet` => `azurerm_subnet`
+func (r providerRegistry) referencedResourceType(words string) (string, bool) {
+	parts := strings.Split(strings.Trim(words, "_"), "_")
+	for i := range parts {
+		if resourceType := "azurerm_" + strings.Join(parts[i:], "_"); parts[i] != "" && r.hasResourceType(resourceType) {
+			return resourceType, true
+		}
+	}
+	return "", false
+}
+
+// detectReference proposes the reference a string attribute of the provider probably is, one not already matched by a
+// reference rule, from (most certain first) the azurerm ID its validation parses, a name which is a resource type
+// followed by `_id` or `_name`, or a description reading `the ID (or name) of the <resource>`
+func (gen documentationGenerator) detectReference(n string, a attribute) *proposedReference {
+
+	if !gen.stampsProviderVersion() || a.IsBlock || a.Computed || a.DataTypeString != "TypeString" || n == "name" || strings.Contains(n, "dlta") {
+		return nil
+	}
+	if rule, _ := gen.referenceRuleFor(n); rule.Attribute != "" {
+		return nil
+	}
+	registry := gen.providerRegistry()
+
+	if id := a.Constraints.ResourceID; id == anyAssetType {
+		return &proposedReference{AssetType: anyAssetType, Output: "id", DetectedBy: referenceDetectionIDFormat}
+	} else if id != "" {
+		if resourceType, ok := registry.referencedResourceType(strings.ToLower(resourceIDWordRegex.ReplaceAllString(id, "${1}_${2}"))); ok {
+			return &proposedReference{AssetType: resourceType, Output: "id", DetectedBy: referenceDetectionIDFormat}
+		}
+	}
+
+	for _, output := range []string{"id", "name"} {
+		if words, ok := strings.CutSuffix(n, "_"+output); ok {
+			if resourceType, ok := registry.referencedResourceType(words); ok {
+				return &proposedReference{AssetType: resourceType, Output: output, DetectedBy: referenceDetectionName}
+			}
+		}
+	}
+
+	if m := referenceDescriptionRegex.FindStringSubmatch(a.Description); m != nil {
+		if resourceType, ok := registry.referencedResourceType(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(m[2])), " ", "_")); ok {
+			return &proposedReference{AssetType: resourceType, Output: strings.ToLower(m[1]), DetectedBy: referenceDetectionDescription}
+		}
+	}
+
+	return nil
+}
+
+// referenceProblems returns why the attribute's reference can't be wired, an Output or AssetType which isn't a name
+// or a confirmed reference without an Output
+func referenceProblems(a summaryAttribute) []string {
+
+	problems := make([]string, 0)
+	r := a.Reference
+	if r == nil {
+		return problems
+	}
+
+	if r.Output != "" && !referenceOutputRegex.MatchString(r.Output) {
+		problems = append(problems, fmt.Sprintf("Output: must be an attribute name, got %q", r.Output))
+	}
+	if r.Output == "" && r.Confirmed {
+		problems = append(problems, "Output: a confirmed reference needs the output it's wired to")
+	}
+	if r.AssetType != anyAssetType && !referenceOutputRegex.MatchString(r.AssetType) {
+		problems = append(problems, fmt.Sprintf("AssetType: must be a resource type e.g. `azurerm_subnet` or %q for any, got %q", anyAssetType, r.AssetType))
+	}
+	if a.IsBlock {
+		problems = append(problems, "a block can't be a reference")
+	}
+
+	return problems
+}
+
+// anyAssetType is the target of a reference which can connect to an asset of any type e.g. a private endpoint's
+const anyAssetType = "*"
+
+// dependencyEdge is a reference attribute of an asset, which the canvas can wire to the output of an asset of type To
+type dependencyEdge struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	ResourcePath string `json:"resource_path"`
+	Output       string `json:"output"`
+	Control      string `json:"control"`
+	Required     bool   `json:"required"`
+}
+
+// dependencyGraph holds the asset types and the references between them, of a resource or (exported) the solution
+type dependencyGraph struct {
+	Nodes []string         `json:"nodes"`
+	Edges []dependencyEdge `json:"edges"`
+}
+
+// dependencyEdges returns the references of the asset the template wires (see referenceExpression), so they're what
+// it publishes along with the injected resource group
+func (gen documentationGenerator) dependencyEdges() []dependencyEdge {
+
+	edges := make([]dependencyEdge, 0)
+	var walk func(attributes map[string]attribute, required bool)
+	walk = func(attributes map[string]attribute, required bool) {
+		for _, n := range sortAttributeNames(attributes) {
+			at := attributes[n]
+			if at.Computed || strings.Contains(n, "dlta") {
+				continue
+			}
+			if at.IsBlock {
+				walk(at.Attributes, required && at.Required)
+				continue
+			}
+			rule, ok := gen.referenceFor(n, at)
+			if !ok {
+				continue
+			}
+			to := rule.AssetType
+			if to == "" {
+				to = anyAssetType
+			}
+			rp := at.ResourcePath
+			if rp == "" {
+				rp = gen.resourceName + "." + n
+			}
+			edges = append(edges, dependencyEdge{From: gen.assetType(), To: to, ResourcePath: rp, Output: rule.Output, Control: rule.Control, Required: required && at.Required})
+		}
+	}
+	walk(gen.injectAttributes(), true)
+
+	return edges
+}
+
+// newDependencyGraph returns the graph of the edges, its nodes are the asset types they connect
+func newDependencyGraph(edges []dependencyEdge) dependencyGraph {
+
+	nodes := make(map[string]bool)
+	for _, e := range edges {
+		nodes[e.From], nodes[e.To] = true, true
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].ResourcePath < edges[j].ResourcePath
+	})
+
+	return dependencyGraph{Nodes: sortedKeys(nodes), Edges: edges}
+}
+
+// renderDependencyGraphDot renders the graph for Graphviz, labelling each edge with its attribute and dashing those
+// of optional attributes
+func renderDependencyGraphDot(graph dependencyGraph) string {
+
+	dot := "digraph dependencies {\n"
+	dot += "\trankdir = LR\n"
+	for _, n := range graph.Nodes {
+		dot += fmt.Sprintf("\t%q\n", n)
+	}
+	for _, e := range graph.Edges {
+		attributes := fmt.Sprintf("label = %q", strings.TrimPrefix(e.ResourcePath, strings.SplitN(e.From, ":", 2)[0]+"."))
+		if !e.Required {
+			attributes += ", style = dashed"
+		}
+		dot += fmt.Sprintf("\t%q -> %q [%s]\n", e.From, e.To, attributes)
+	}
+	dot += "}\n"
+
+	return dot
+}
+
+// writeDependencyGraph writes the graph of the asset's references as json (for the canvas) and DOT (for reviewers)
+func (gen documentationGenerator) writeDependencyGraph() {
+	graph := newDependencyGraph(gen.dependencyEdges())
+	gen.writeResource(writeJson(graph), DependencyGraphJson)
+	gen.writeResource(renderDependencyGraphDot(graph), DependencyGraphDot)
+}
+
+func (gen documentationGenerator) terraformTemplateBlock() string {
+
+	attributes := gen.injectAttributes()
+
+	var templateBlock string
+	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" {
+		//TODO  Add module name
+
+		if gen.isDataSource {
+			// Data sources are wrapped in a module which takes the lookup arguments, it has no naming variables
+			templateBlock += fmt.Sprintf("module \"${%s}\" {\n", "dlta_terraform_module_name")
+
+			templateBlock += fmt.Sprintf("\tsource                      = \"__modules_path__//%s//module?ref=%s\"\n", strings.Join(gen.artefactLayout(), "//"), gen.moduleRef())
+
+			if at, ok := attributes["name"]; ok {
+				templateBlock += templateComment(at.Description)
+				templateBlock += fmt.Sprintf("\tname                        = \"${%s}\"\n", "name")
+			}
+		} else {
+			templateBlock += fmt.Sprintf("module \"${%s}\" {\n", "dlta_terraform_module_name")
+
+			templateBlock += fmt.Sprintf("\tsource                      = \"__modules_path__//%s//module?ref=%s\"\n", strings.Join(gen.artefactLayout(), "//"), gen.moduleRef())
+		}
+
+		if attributes["location"].DataTypeString != "" {
+			templateBlock += templateComment(attributes["location"].Description)
+			templateBlock += fmt.Sprintf("\tlocation                    = \"${%s}\"\n", "location")
+		}
+
+		if !gen.isDataSource {
+			templateBlock += gen.terraformTemplateNamingArguments()
+		}
+
+		for n, at := range attributes {
+			// Exclude location as we are overriding the name above
+			// Exclude name as this will be
+			if n == "location" || strings.Contains(n, "dlta") || n == "name" {
+				continue
+			} else {
+				if !at.Computed {
+
+					if !at.IsBlock {
+
+						templateBlock += templateComment(at.Description)
+
+						if gen.isDataSource && n == "resource_group_name" {
+							templateBlock += fmt.Sprintf("\t%s		= \"${%s}\"\n", n, dataResourceGroupControl)
+						} else if reference, ok := gen.referenceExpression(n, at); ok {
+							templateBlock += fmt.Sprintf("\t%s		= %s\n", n, reference)
+						} else if at.Sensitive {
+							templateBlock += fmt.Sprintf("\t%s		= %s\n", n, secretReference(n))
+						} else {
+							templateBlock += fmt.Sprintf("\t%s		= %s\n", n, templateValue(n, at))
+
+						}
+					} else if gen.isIdentityBlock(at) {
+						templateBlock += templateComment(at.Description)
+						templateBlock += fmt.Sprintf("\t%s		= %s\n", identityVariableName, templateIdentityValue(at))
+					} else {
+
+						for n1, at1 := range at.Attributes {
+							if !at1.IsBlock {
+								if n1 == "name" && gen.isGeneratedName(at1) {
+									continue
+								}
+
+								templateBlock += templateComment(at1.Description)
+
+								if n1 == "name" {
+									vn := genVariableNameFromResourcePath(at1.ResourcePath)
+
+									templateBlock += fmt.Sprintf("\t%s		= ${%s}\n", vn, vn)
+								} else if reference, ok := gen.referenceExpression(n1, at1); ok {
+									templateBlock += fmt.Sprintf("\t%s		= %s\n", n1, reference)
+								} else if at1.Sensitive {
+									templateBlock += fmt.Sprintf("\t%s		= %s\n", n1, secretReference(n1))
+								} else {
+									// the data source module declares the arguments of its blocks by path
+									vn := n1
+									if gen.isDataSource {
+										vn = genVariableNameFromResourcePath(at1.ResourcePath)
+									}
+
+									templateBlock += fmt.Sprintf("\t%s		= %s\n", vn, templateValue(vn, at1))
+								}
+							} else {
+								for n2, at2 := range at1.Attributes {
+									if n2 == "name" && gen.isGeneratedName(at2) {
+										continue
+									} else {
+										templateBlock += templateComment(at2.Description)
+
+										if n2 == "name" {
+
+											vn := genVariableNameFromResourcePath(at2.ResourcePath)
+
+											templateBlock += fmt.Sprintf("\t%s		= ${%s}\n", vn, vn)
+										} else if reference, ok := gen.referenceExpression(n2, at2); ok {
+											templateBlock += fmt.Sprintf("\t%s		= %s\n", n2, reference)
+										} else if at2.Sensitive {
+											templateBlock += fmt.Sprintf("\t%s		= %s\n", n2, secretReference(n2))
+										} else {
+											templateBlock += fmt.Sprintf("\t%s		= %s\n", n2, templateValue(n2, at2))
+										}
+
+									}
+								}
+
+							}
+						}
+					}
+				}
+			}
+
+		}
+
+		templateBlock += "}\n"
+	} else if gen.resourceName == "terraform_azurerm" {
+		templateBlock += "terraform {\n"
+		templateBlock += "	required_providers {\n"
+		templateBlock += "		azurerm = {\n"
+		templateBlock += fmt.Sprintf("			source =  \"${%s}\"\n", "terraform_azurerm_azurerm_source")
+		templateBlock += fmt.Sprintf("			version = \"${%s}\"\n", "terraform_azurerm_azurerm_version")
+		templateBlock += "		}\n"
+		templateBlock += "		azapi = {\n"
+		templateBlock += fmt.Sprintf("			source =  \"${%s}\"\n", "terraform_azurerm_azapi_source")
+		templateBlock += fmt.Sprintf("			version = \"${%s}\"\n", "terraform_azurerm_azapi_version")
+		templateBlock += "		}\n"
+		templateBlock += "	}\n"
+		templateBlock += "	# partial configuration, supplied per environment with -backend-config=backend-<environment>.hcl\n"
+		templateBlock += "	backend \"azurerm\" {\n"
+		templateBlock += "	}\n"
+		templateBlock += "}\n"
+
+		templateBlock += gen.terraformProviderBlock()
+	} else if gen.resourceName == "devops_pipeline" {
+		templateBlock += "name: $(connection)-$(Date:yyyyMMdd)$(Rev:.r)\n"
+		templateBlock += "variables:\n"
+		templateBlock += "  connection: 'sub-ret-d-001'\n"
+		templateBlock += "trigger: none\n"
+		templateBlock += "resources:\n"
+		templateBlock += "  repositories:\n"
+		templateBlock += "	- repository: Repo.Pipelines\n"
+		templateBlock += "	  type: git\n"
+		templateBlock += "	  name: Repo.Pipelines\n"
+		templateBlock += "	  ref: refs/heads/main\n"
+		templateBlock += "stages:\n"
+		templateBlock += "- template: TerraformStages.yml@Repo.Pipelines\n"
+		templateBlock += "  parameters:\n"
+		templateBlock += "	ServiceShort      : storage_policy_test\n"
+		templateBlock += "	serviceConnection : 'ServiceConnection.sub-ret-d-001'\n"
+		templateBlock += "	EnvironmentShort  : dev\n"
+	}
+
+	return templateBlock
+}
+
+// environmentGuard returns the value of an attribute in the module, one published for only some environments takes
+// its default (or is left to the provider) when the module is applied to another
+func environmentGuard(n string, at attribute) string {
+
+	if len(at.Environments) == 0 {
+		return "var." + n
+	}
+
+	quoted := make([]string, 0, len(at.Environments))
+	for _, e := range at.Environments {
+		quoted = append(quoted, strconv.Quote(e))
+	}
+	fallback := "null"
+	if at.hasStaticDefault() {
+		fallback = hclLiteral(at, at.Default)
+	}
+
+	return fmt.Sprintf("contains([%s], var.dlta_environment_char) ? var.%s : %s", strings.Join(quoted, ", "), n, fallback)
+}
+
+func (gen documentationGenerator) terraformModuleBlock() string {
+
+	if gen.azapiType != "" {
+		return gen.terraformAzapiModuleBlock()
+	}
+
+	attributes := gen.injectAttributes()
+
+	var moduleBlock string
+	var appendBlock string
+
+	moduleBlock += fmt.Sprintf("resource \"%s\" \"this\" {\n", gen.resourceName)
+	if gen.hasName() {
+		moduleBlock += "\tname = local.name\n"
+	}
+	for n, at := range attributes {
+		if at.Computed {
+			continue //Ignore computed values for time being
+		}
+		if n == "name" {
+			fmt.Printf("terraformModuleBlock name at.DataTypeString: %v\n", len(at.PossibleValues))
+			continue
+		}
+		if strings.Contains(n, "dlta_") { //Ignore any parameters that are for dlta, these are used elsewhere
+			continue
+		}
+		if strings.HasPrefix(n, timeoutsPrefix) { // rendered into the timeouts block below
+			continue
+		}
+		if gen.isIdentityBlock(at) {
+			appendBlock += terraformIdentityBlock(at)
+		} else if !at.IsBlock {
+			if at.DataTypeString == schema.TypeList.String() {
+				appendBlock += fmt.Sprintf("\t%s = %s\n", n, environmentGuard(n, at))
+			} else {
+				moduleBlock += fmt.Sprintf("\t%s = %s\n", n, environmentGuard(n, at))
+			}
+		} else {
+			moduleBlock += fmt.Sprintf("\t%s {\n", n)
+
+			//TODO multi level
+			for k, a := range at.Attributes {
+
+				if !a.IsBlock {
+					if k == "name" {
+						moduleBlock += fmt.Sprintf("\t\tname = %s\n", gen.nameExpression(a))
+					} else {
+						moduleBlock += fmt.Sprintf("\t\t%s = %s\n", k, environmentGuard(k, a))
+					}
+				} else {
+
+					moduleBlock += fmt.Sprintf("\t\t%s {\n", k)
+					for k2, a2 := range a.Attributes {
+						if k2 == "name" {
+							moduleBlock += fmt.Sprintf("\t\t\tname = %s\n", gen.nameExpression(a2))
+						} else {
+							moduleBlock += fmt.Sprintf("\t\t\t%s = %s\n", k2, environmentGuard(k2, a2))
+						}
+
+					}
+					moduleBlock += "\t\t}\n"
+
+				}
+
+			}
+
+			moduleBlock += "\t}\n"
+
+		}
+
+	}
+	moduleBlock += appendBlock
+	moduleBlock += gen.terraformTimeoutsBlock()
+
+	if preconditions := append(constraintPreconditions(attributes), gen.namePreconditions()...); len(preconditions) > 0 {
+		moduleBlock += "\tlifecycle {\n"
+		for _, p := range preconditions {
+			moduleBlock += p
+		}
+		moduleBlock += "\t}\n"
+	}
+
+	moduleBlock += "}\n"
+
+	return moduleBlock
+}
+
+var (
+	// moduleTagRegex matches a released module version e.g. `v1.2.0` or `1.2.0-beta.1`
+	moduleTagRegex = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`)
+
+	// moduleCommitRegex matches an abbreviated or full git commit sha
+	moduleCommitRegex = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// validateModuleRef checks the module version is valid for the ref type passed via `-module-ref-type`
+func validateModuleRef(refType string, version string) error {
+	switch refType {
+	case "tag":
+		if !moduleTagRegex.MatchString(version) {
+			return fmt.Errorf("`-module-version` must be a semantic version e.g. `v1.2.0` when `-module-ref-type` is `tag`, got %q", version)
+		}
+	case "branch":
+		if version == "" || strings.ContainsAny(version, " ?&~^:\\") {
+			return fmt.Errorf("`-module-version` must be a valid branch name when `-module-ref-type` is `branch`, got %q", version)
+		}
+	case "commit":
+		if !moduleCommitRegex.MatchString(version) {
+			return fmt.Errorf("`-module-version` must be a git commit sha when `-module-ref-type` is `commit`, got %q", version)
+		}
+	default:
+		return fmt.Errorf("`-module-ref-type` must be either `tag`, `branch` or `commit`, got %q", refType)
+	}
+
+	return nil
+}
+
+// moduleRef returns the git ref the module source is pinned to, the version is used exactly as configured as a tag
+// may or may not be released with a `v` prefix, the default is the main branch
+func (gen documentationGenerator) moduleRef() string {
+	if gen.moduleVersion == "" {
+		return "main"
+	}
+
+	return gen.moduleVersion
+}
+
+// azapiTypeRegex matches an ARM resource type and API version e.g. `Microsoft.App/containerApps@2023-05-01-preview`
+var azapiTypeRegex = regexp.MustCompile(`^[A-Za-z0-9]+\.[A-Za-z0-9.]+(/[A-Za-z0-9]+)+@[0-9]{4}-[0-9]{2}-[0-9]{2}(-preview)?$`)
+
+// azapiBodyTypeConstraint is the type of the body variable when there's no `-rest-spec`, the ARM properties aren't
+// known so are left as any
+const azapiBodyTypeConstraint = "object({ properties = any })"
+
+// azapiBodyTypeConstraint returns the type of the body variable, typed from the REST API spec when there is one. The
+// body stays a single variable (and palette control) as it's jsonencoded whole, so every property of the spec is typed
+// rather than just those published
+func (gen documentationGenerator) azapiBodyTypeConstraint() string {
+	if gen.azapiBody == nil {
+		return azapiBodyTypeConstraint
+	}
+
+	body := attribute{IsBlock: true, MaxItems: 1}
+	body.Attributes = gen.getAllInputAttributes(gen.azapiBody.Schema, body, true, gen.resourceName+".body")
+
+	return variableTypeConstraint(body)
+}
+
+// azapiResourceSchema is the schema scaffolded for an azapi_resource, there's no provider schema for the ARM type so
+// its properties are supplied through a single body variable
+func azapiResourceSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the resource.",
+			},
+			"location": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The Azure Region where the resource should exist.",
+			},
+			"parent_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The ID of the parent of the resource, usually a Resource Group ID.",
+			},
+			"body": {
+				Type:        schema.TypeMap,
+				Required:    true,
+				Description: "The ARM properties of the resource.",
+			},
+			"tags": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "A mapping of tags which should be assigned to the resource.",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the resource.",
+			},
+		},
+	}
+}
+
+const (
+	// awsProviderSource is the source of the AWS provider, whose resources are scaffolded with `-provider-schema`
+	awsProviderSource = "hashicorp/aws"
+
+	// awsServiceName is the service of AWS resources, which aren't part of an azurerm service package
+	awsServiceName = "AWS"
+
+	// gcpProviderSource is the source of the Google Cloud provider, whose resources are scaffolded with `-provider-schema`
+	gcpProviderSource = "hashicorp/google"
+
+	// gcpServiceName is the service of Google Cloud resources
+	gcpServiceName = "GCP"
+)
+
+// providerServiceNames are the services of the resources of the other clouds' providers, which group them on the palette
+var providerServiceNames = map[string]string{
+	awsProviderSource: awsServiceName,
+	gcpProviderSource: gcpServiceName,
+}
+
+// utilityServiceName is the service of the helper resources, which aren't part of an azurerm service package
+const utilityServiceName = "Utility"
+
+// utilityProviders are the sources of the helper providers, keyed by the prefix of their resources
+var utilityProviders = map[string]string{
+	"null":   "hashicorp/null",
+	"random": "hashicorp/random",
+	"time":   "hashicorp/time",
+	"tls":    "hashicorp/tls",
+}
+
+// utilityResourceSchemas are the helper resources modules commonly need for suffixes, secrets, keys and rotation. The
+// tool isn't compiled against their providers, so these are the arguments and attributes worth scaffolding
+var utilityResourceSchemas = map[string]func() *schema.Resource{
+	"null_resource": func() *schema.Resource {
+		return &schema.Resource{Schema: map[string]*schema.Schema{
+			"triggers": {Type: schema.TypeMap, Optional: true, ForceNew: true, Elem: &schema.Schema{Type: schema.TypeString}, Description: "Arbitrary values which cause the resource to be replaced when they change."},
+		}}
+	},
+	"random_string": func() *schema.Resource {
+		return randomStringSchema(false)
+	},
+	"random_password": func() *schema.Resource {
+		return randomStringSchema(true)
+	},
+	"time_rotating": func() *schema.Resource {
+		resource := &schema.Resource{Schema: map[string]*schema.Schema{
+			"rfc3339":          {Type: schema.TypeString, Optional: true, Computed: true, ForceNew: true, Description: "The base timestamp in RFC3339 format, defaults to the time the resource is created."},
+			"triggers":         {Type: schema.TypeMap, Optional: true, ForceNew: true, Elem: &schema.Schema{Type: schema.TypeString}, Description: "Arbitrary values which cause the resource to be replaced when they change."},
+			"rotation_rfc3339": {Type: schema.TypeString, Computed: true, Description: "The timestamp the resource rotates at in RFC3339 format."},
+			"unix":             {Type: schema.TypeInt, Computed: true, Description: "The base timestamp as a Unix timestamp."},
+		}}
+		for _, unit := range []string{"minutes", "hours", "days", "months", "years"} {
+			resource.Schema["rotation_"+unit] = &schema.Schema{Type: schema.TypeInt, Optional: true, ValidateFunc: help.IntAtLeast(1), Description: fmt.Sprintf("The number of %s after which the resource rotates.", unit)}
+		}
+		return resource
+	},
+	"tls_private_key": func() *schema.Resource {
+		return &schema.Resource{Schema: map[string]*schema.Schema{
+			"algorithm":                     {Type: schema.TypeString, Required: true, ForceNew: true, ValidateFunc: help.StringInSlice([]string{"RSA", "ECDSA", "ED25519"}, false), Description: "The algorithm of the key."},
+			"rsa_bits":                      {Type: schema.TypeInt, Optional: true, ForceNew: true, Default: 2048, Description: "The size of an RSA key in bits."},
+			"ecdsa_curve":                   {Type: schema.TypeString, Optional: true, ForceNew: true, Default: "P224", ValidateFunc: help.StringInSlice([]string{"P224", "P256", "P384", "P521"}, false), Description: "The elliptic curve of an ECDSA key."},
+			"private_key_pem":               {Type: schema.TypeString, Computed: true, Sensitive: true, Description: "The private key in PEM format."},
+			"private_key_openssh":           {Type: schema.TypeString, Computed: true, Sensitive: true, Description: "The private key in OpenSSH format."},
+			"public_key_pem":                {Type: schema.TypeString, Computed: true, Description: "The public key in PEM format."},
+			"public_key_openssh":            {Type: schema.TypeString, Computed: true, Description: "The public key in OpenSSH authorized_keys format."},
+			"public_key_fingerprint_sha256": {Type: schema.TypeString, Computed: true, Description: "The SHA256 fingerprint of the public key."},
+		}}
+	},
+}
+
+// randomStringSchema is the schema of random_string, and of random_password whose result is sensitive
+func randomStringSchema(isPassword bool) *schema.Resource {
+
+	resource := &schema.Resource{Schema: map[string]*schema.Schema{
+		"length":           {Type: schema.TypeInt, Required: true, ForceNew: true, ValidateFunc: help.IntAtLeast(1), Description: "The length of the string."},
+		"override_special": {Type: schema.TypeString, Optional: true, ForceNew: true, Description: "The special characters to use rather than the default `!@#$%&*()-_=+[]{}<>:?`."},
+		"keepers":          {Type: schema.TypeMap, Optional: true, ForceNew: true, Elem: &schema.Schema{Type: schema.TypeString}, Description: "Arbitrary values which cause the string to be regenerated when they change."},
+		"result":           {Type: schema.TypeString, Computed: true, Sensitive: isPassword, Description: "The generated string."},
+	}}
+	for _, class := range []string{"upper", "lower", "numeric", "special"} {
+		resource.Schema[class] = &schema.Schema{Type: schema.TypeBool, Optional: true, ForceNew: true, Default: true, Description: fmt.Sprintf("Whether %s characters are included.", class)}
+		resource.Schema["min_"+class] = &schema.Schema{Type: schema.TypeInt, Optional: true, ForceNew: true, Default: 0, Description: fmt.Sprintf("The minimum number of %s characters.", class)}
+	}
+
+	return resource
+}
+
+// providerSchemas is the output of `terraform providers schema -json`, keyed by the provider's address e.g.
+// `registry.terraform.io/hashicorp/azurerm`
+type providerSchemas struct {
+	FormatVersion   string                         `json:"format_version"`
+	ProviderSchemas map[string]providerSchemaBlock `json:"provider_schemas"`
+}
+
+type providerSchemaBlock struct {
+	ResourceSchemas   map[string]providerSchemaResource `json:"resource_schemas"`
+	DataSourceSchemas map[string]providerSchemaResource `json:"data_source_schemas"`
+}
+
+type providerSchemaResource struct {
+	Block providerBlock `json:"block"`
+}
+
+type providerBlock struct {
+	Attributes map[string]providerAttribute `json:"attributes"`
+	BlockTypes map[string]providerBlockType `json:"block_types"`
+}
+
+type providerAttribute struct {
+	Type        json.RawMessage `json:"type"`
+	Description string          `json:"description"`
+	Required    bool            `json:"required"`
+	Optional    bool            `json:"optional"`
+	Computed    bool            `json:"computed"`
+	Sensitive   bool            `json:"sensitive"`
+	Deprecated  bool            `json:"deprecated"`
+}
+
+type providerBlockType struct {
+	NestingMode string        `json:"nesting_mode"`
+	Block       providerBlock `json:"block"`
+	MinItems    int           `json:"min_items"`
+	MaxItems    int           `json:"max_items"`
+}
+
+// providerDeprecatedMessage is the deprecation of an attribute read from `-provider-schema`, which only flags it
+const providerDeprecatedMessage = "This attribute is deprecated."
+
+// readProviderSchema reads the schema of the resource or data source from the output of
+// `terraform providers schema -json`, returning it with the source of the provider which has it
+func readProviderSchema(path string, resourceName string, isResource bool) (*schema.Resource, string, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %+v", path, err)
+	}
+
+	var schemas providerSchemas
+	if err := json.Unmarshal(content, &schemas); err != nil {
+		return nil, "", fmt.Errorf("parsing %s: %+v", path, err)
+	}
+
+	for _, address := range sortedKeys(schemas.ProviderSchemas) {
+		provider := schemas.ProviderSchemas[address]
+		resources := provider.ResourceSchemas
+		if !isResource {
+			resources = provider.DataSourceSchemas
+		}
+
+		r, ok := resources[resourceName]
+		if !ok {
+			continue
+		}
+
+		resource, err := r.Block.toResource()
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %s: %+v", path, resourceName, err)
+		}
+		resource.Timeouts = providerTimeouts(resource)
+		return resource, strings.TrimPrefix(address, "registry.terraform.io/"), nil
+	}
+
+	if !isResource {
+		return nil, "", fmt.Errorf("Data Source %q isn't in %s", resourceName, path)
+	}
+	return nil, "", fmt.Errorf("Resource %q isn't in %s", resourceName, path)
+}
+
+// providerTimeouts moves the timeouts block of a resource read from `-provider-schema` into its Timeouts, as the SDK
+// would have it. The json doesn't hold the provider's default durations, so they're zero
+func providerTimeouts(resource *schema.Resource) *schema.ResourceTimeout {
+
+	s, ok := resource.Schema["timeouts"]
+	if !ok {
+		return nil
+	}
+	block, ok := s.Elem.(*schema.Resource)
+	if !ok {
+		return nil
+	}
+	delete(resource.Schema, "timeouts")
+
+	timeouts := &schema.ResourceTimeout{}
+	for operation, d := range map[string]**time.Duration{"create": &timeouts.Create, "read": &timeouts.Read, "update": &timeouts.Update, "delete": &timeouts.Delete} {
+		if _, ok := block.Schema[operation]; ok {
+			*d = new(time.Duration)
+		}
+	}
+
+	return timeouts
+}
+
+// toResource converts a block of the provider schema, nested blocks become lists (single blocks with at most one
+// item) and sets of objects
+func (b providerBlock) toResource() (*schema.Resource, error) {
+
+	resource := &schema.Resource{Schema: make(map[string]*schema.Schema)}
+
+	for n, a := range b.Attributes {
+		s, err := ctyTypeSchema(a.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %+v", n, err)
+		}
+		s.Description = a.Description
+		s.Required = a.Required
+		s.Optional = a.Optional
+		s.Computed = a.Computed
+		s.Sensitive = a.Sensitive
+		if a.Deprecated {
+			s.Deprecated = providerDeprecatedMessage
+		}
+		resource.Schema[n] = s
+	}
+
+	for n, bt := range b.BlockTypes {
+		nested, err := bt.Block.toResource()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %+v", n, err)
+		}
+
+		s := &schema.Schema{Type: schema.TypeList, Elem: nested, MinItems: bt.MinItems, MaxItems: bt.MaxItems}
+		switch bt.NestingMode {
+		case "set":
+			s.Type = schema.TypeSet
+		case "single", "group":
+			s.MaxItems = 1
+		}
+		s.Required = bt.MinItems > 0
+		s.Optional = !s.Required
+		resource.Schema[n] = s
+	}
+
+	return resource, nil
+}
+
+// ctyTypeSchema returns the schema of an attribute's type, as cty writes it to json e.g. `"string"`,
+// `["list","string"]` or `["object",{"name":"string"}]`. Numbers are floats as the json doesn't tell integers apart.
+// An object (or a collection of them) is set with attribute syntax, so it's marked SchemaConfigModeAttr rather than
+// being a block, see isObjectAttribute
+func ctyTypeSchema(raw json.RawMessage) (*schema.Schema, error) {
+
+	var primitive string
+	if err := json.Unmarshal(raw, &primitive); err == nil {
+		switch primitive {
+		case "string", "dynamic":
+			return &schema.Schema{Type: schema.TypeString}, nil
+		case "number":
+			return &schema.Schema{Type: schema.TypeFloat}, nil
+		case "bool":
+			return &schema.Schema{Type: schema.TypeBool}, nil
+		}
+		return nil, fmt.Errorf("unsupported type %q", primitive)
+	}
+
+	var complex []json.RawMessage
+	if err := json.Unmarshal(raw, &complex); err != nil || len(complex) != 2 {
+		return nil, fmt.Errorf("unsupported type %s", raw)
+	}
+	var kind string
+	if err := json.Unmarshal(complex[0], &kind); err != nil {
+		return nil, fmt.Errorf("unsupported type %s", raw)
+	}
+
+	if kind == "object" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(complex[1], &fields); err != nil {
+			return nil, fmt.Errorf("unsupported type %s", raw)
+		}
+		object := &schema.Resource{Schema: make(map[string]*schema.Schema)}
+		for n, field := range fields {
+			s, err := ctyTypeSchema(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %+v", n, err)
+			}
+			s.Optional = true
+			object.Schema[n] = s
+		}
+		return &schema.Schema{Type: schema.TypeList, MaxItems: 1, Elem: object, ConfigMode: schema.SchemaConfigModeAttr}, nil
+	}
+
+	elem, err := ctyTypeSchema(complex[1])
+	if err != nil {
+		return nil, err
+	}
+
+	s := &schema.Schema{}
+	switch kind {
+	case "list":
+		s.Type = schema.TypeList
+	case "set":
+		s.Type = schema.TypeSet
+	case "map":
+		s.Type = schema.TypeMap
+	default:
+		return nil, fmt.Errorf("unsupported type %s", raw)
+	}
+
+	// a collection of objects holds the object's fields
+	if object, ok := elem.Elem.(*schema.Resource); ok && elem.MaxItems == 1 && kind != "map" {
+		s.Elem = object
+		s.ConfigMode = schema.SchemaConfigModeAttr
+	} else {
+		s.Elem = elem
+	}
+
+	return s, nil
+}
+
+// isObjectAttribute returns whether the schema is an object attribute of `-provider-schema` (see ctyTypeSchema), which
+// Terraform only accepts with attribute syntax. The SDK's own SchemaConfigModeAttr blocks also accept block syntax so
+// they stay blocks
+func (gen documentationGenerator) isObjectAttribute(s *schema.Schema) bool {
+	_, isObject := s.Elem.(*schema.Resource)
+	return isObject && s.ConfigMode == schema.SchemaConfigModeAttr && gen.providerSchema != ""
+}
+
+// objectTypeConstraint returns the type of an object attribute e.g. `list(object({ name = optional(string) }))`, its
+// fields are optional as the json doesn't say which can be left out
+func objectTypeConstraint(s *schema.Schema) string {
+
+	switch elem := s.Elem.(type) {
+	case *schema.Resource:
+		fields := make([]string, 0)
+		for _, n := range sortedKeys(elem.Schema) {
+			fields = append(fields, fmt.Sprintf("%s = optional(%s)", n, objectTypeConstraint(elem.Schema[n])))
+		}
+		object := fmt.Sprintf("object({ %s })", strings.Join(fields, ", "))
+		if s.Type == schema.TypeSet {
+			return fmt.Sprintf("set(%s)", object)
+		}
+		if s.MaxItems == 1 {
+			return object
+		}
+		return fmt.Sprintf("list(%s)", object)
+	case *schema.Schema:
+		elemType := objectTypeConstraint(elem)
+		switch s.Type {
+		case schema.TypeSet:
+			return fmt.Sprintf("set(%s)", elemType)
+		case schema.TypeMap:
+			return fmt.Sprintf("map(%s)", elemType)
+		}
+		return fmt.Sprintf("list(%s)", elemType)
+	}
+
+	if s.Type == schema.TypeMap {
+		return "map(string)"
+	}
+	return translateDataType(s.Type.String())
+}
+
+// restSpec is the subset of an Azure REST API spec (swagger 2.0) needed to type the body of a resource
+type restSpec struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths       map[string]map[string]restSpecOperation `json:"paths"`
+	Definitions map[string]*restSpecSchema              `json:"definitions"`
+}
+
+type restSpecOperation struct {
+	Parameters []restSpecParameter `json:"parameters"`
+}
+
+type restSpecParameter struct {
+	Name   string          `json:"name"`
+	In     string          `json:"in"`
+	Schema *restSpecSchema `json:"schema"`
+}
+
+type restSpecSchema struct {
+	Ref                  string                     `json:"$ref"`
+	Type                 string                     `json:"type"`
+	Format               string                     `json:"format"`
+	Description          string                     `json:"description"`
+	Enum                 []interface{}              `json:"enum"`
+	Properties           map[string]*restSpecSchema `json:"properties"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                *restSpecSchema            `json:"items"`
+	AllOf                []*restSpecSchema          `json:"allOf"`
+	Required             []string                   `json:"required"`
+	ReadOnly             bool                       `json:"readOnly"`
+	Secret               bool                       `json:"x-ms-secret"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              string                     `json:"pattern"`
+}
+
+// restSpecDefinitionPrefix prefixes references to definitions within the same spec, references to other files (e.g.
+// common-types) aren't followed
+const restSpecDefinitionPrefix = "#/definitions/"
+
+// azapiArguments are the properties of an ARM resource azapi_resource takes as arguments rather than in the body
+var azapiArguments = map[string]bool{"id": true, "name": true, "type": true, "location": true, "tags": true, "systemData": true}
+
+// readRestSpecBody reads the body the ARM type's PUT takes from its REST API spec, the properties azapi_resource takes
+// as arguments and those which are read only are left out
+func readRestSpecBody(path string, azapiType string) (*schema.Resource, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %+v", path, err)
+	}
+
+	var spec restSpec
+	if err := json.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("parsing %s: %+v", path, err)
+	}
+
+	resourceType, apiVersion, _ := strings.Cut(azapiType, "@")
+	if spec.Info.Version != apiVersion {
+		return nil, fmt.Errorf("%s is API version %q not %q", path, spec.Info.Version, apiVersion)
+	}
+
+	pathRegex := restSpecPathRegex(resourceType)
+	for _, p := range sortedKeys(spec.Paths) {
+		if !pathRegex.MatchString(p) {
+			continue
+		}
+		put, ok := spec.Paths[p]["put"]
+		if !ok {
+			continue
+		}
+		for _, parameter := range put.Parameters {
+			if parameter.In != "body" || parameter.Schema == nil {
+				continue
+			}
+
+			body := spec.toResource(parameter.Schema, map[string]bool{}, azapiArguments)
+			if len(body.Schema) == 0 {
+				return nil, fmt.Errorf("%s: the body of %s has no properties", path, resourceType)
+			}
+			return body, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s has no PUT for %s", path, resourceType)
+}
+
+// restSpecPathRegex matches the path of an ARM type e.g. `Microsoft.Network/virtualNetworks/subnets` matches
+// `.../providers/Microsoft.Network/virtualNetworks/{virtualNetworkName}/subnets/{subnetName}`
+func restSpecPathRegex(resourceType string) *regexp.Regexp {
+	parts := strings.Split(resourceType, "/")
+
+	pattern := "(?i)/providers/" + regexp.QuoteMeta(parts[0])
+	for _, part := range parts[1:] {
+		pattern += "/" + regexp.QuoteMeta(part) + "/\\{[^}/]+\\}"
+	}
+
+	return regexp.MustCompile(pattern + "$")
+}
+
+// resolve follows a reference to a definition, seen holds the definitions already being converted so recursive
+// definitions (e.g. errors with nested details) end rather than loop
+func (spec restSpec) resolve(s *restSpecSchema, seen map[string]bool) (*restSpecSchema, map[string]bool, bool) {
+	if s.Ref == "" {
+		return s, seen, true
+	}
+
+	name, ok := strings.CutPrefix(s.Ref, restSpecDefinitionPrefix)
+	if !ok || seen[name] || spec.Definitions[name] == nil {
+		return nil, nil, false
+	}
+
+	nested := make(map[string]bool, len(seen)+1)
+	for k := range seen {
+		nested[k] = true
+	}
+	nested[name] = true
+
+	return spec.Definitions[name], nested, true
+}
+
+// properties returns the properties of an object and those it inherits through allOf, with those which are required
+func (spec restSpec) properties(s *restSpecSchema, seen map[string]bool) (map[string]*restSpecSchema, map[string]bool) {
+
+	properties := make(map[string]*restSpecSchema)
+	required := make(map[string]bool)
+
+	for _, parent := range s.AllOf {
+		if resolved, nested, ok := spec.resolve(parent, seen); ok {
+			inherited, inheritedRequired := spec.properties(resolved, nested)
+			for k, v := range inherited {
+				properties[k] = v
+			}
+			for k := range inheritedRequired {
+				required[k] = true
+			}
+		}
+	}
+	for k, v := range s.Properties {
+		properties[k] = v
+	}
+	for _, k := range s.Required {
+		required[k] = true
+	}
+
+	return properties, required
+}
+
+// toResource converts an object of the spec, properties which can't be converted (references to other files,
+// recursive definitions or objects without properties) are skipped
+func (spec restSpec) toResource(s *restSpecSchema, seen map[string]bool, skip map[string]bool) *schema.Resource {
+
+	resource := &schema.Resource{Schema: make(map[string]*schema.Schema)}
+
+	s, seen, ok := spec.resolve(s, seen)
+	if !ok {
+		return resource
+	}
+
+	properties, required := spec.properties(s, seen)
+	for _, n := range sortedKeys(properties) {
+		if skip[n] || properties[n].ReadOnly {
+			continue
+		}
+
+		p, err := spec.toSchema(properties[n], seen)
+		if err != nil {
+			fmt.Printf("readRestSpecBody %q: skipping, %+v\n", n, err)
+			continue
+		}
+		p.Required = required[n]
+		p.Optional = !p.Required
+		resource.Schema[n] = p
+	}
+
+	return resource
+}
+
+// toSchema converts a property of the spec, objects become single blocks, arrays of objects blocks and objects with
+// only additionalProperties maps. The spec's enums, lengths and bounds are kept as validation
+func (spec restSpec) toSchema(s *restSpecSchema, seen map[string]bool) (*schema.Schema, error) {
+
+	ref := s.Ref
+	description := s.Description
+	s, seen, ok := spec.resolve(s, seen)
+	if !ok {
+		return nil, fmt.Errorf("%s can't be followed", ref)
+	}
+	if description == "" {
+		description = s.Description
+	}
+
+	var c constraints
+	for _, v := range s.Enum {
+		c.OneOf = append(c.OneOf, fmt.Sprint(v))
+	}
+	c.MinLength, c.MaxLength = s.MinLength, s.MaxLength
+	if c.MaxLength != nil && c.MinLength == nil {
+		c.MinLength = new(int)
+	}
+	c.Min, c.Max = s.Minimum, s.Maximum
+	if s.Pattern != "" {
+		c.Patterns = []string{s.Pattern}
+	}
+
+	ps := &schema.Schema{Description: description, Sensitive: s.Secret || s.Format == "password"}
+	switch s.Type {
+	case "string":
+		ps.Type = schema.TypeString
+	case "integer":
+		ps.Type = schema.TypeInt
+	case "number":
+		ps.Type = schema.TypeFloat
+	case "boolean":
+		ps.Type = schema.TypeBool
+	case "array":
+		if s.Items == nil {
+			return nil, fmt.Errorf("the array has no items")
+		}
+		elem, err := spec.toSchema(s.Items, seen)
+		if err != nil {
+			return nil, err
+		}
+		ps.Type = schema.TypeList
+		if object, ok := elem.Elem.(*schema.Resource); ok && elem.MaxItems == 1 {
+			ps.Elem = object
+		} else {
+			ps.Elem = elem
+		}
+		return ps, nil
+	case "object", "":
+		properties, _ := spec.properties(s, seen)
+		if len(properties) == 0 {
+			if len(s.AdditionalProperties) == 0 || string(s.AdditionalProperties) == "false" {
+				return nil, fmt.Errorf("the object has no properties")
+			}
+			ps.Type = schema.TypeMap
+			ps.Elem = &schema.Schema{Type: schema.TypeString}
+			return ps, nil
+		}
+		object := spec.toResource(s, seen, nil)
+		if len(object.Schema) == 0 {
+			return nil, fmt.Errorf("the object has no properties which can be set")
+		}
+		ps.Type = schema.TypeList
+		ps.MaxItems = 1
+		ps.Elem = object
+		return ps, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %q", s.Type)
+	}
+
+	if ps.Type == schema.TypeBool {
+		return ps, nil
+	}
+	if fn := c.validateFunc(ps.Type); fn != nil {
+		ps.ValidateFunc = fn
+	}
+
+	return ps, nil
+}
+
+// schemaProvider returns the local name and source of the provider read from `-provider-schema` when it's not a
+// hashicorp provider, which terraform would otherwise assume
+func (gen documentationGenerator) schemaProvider() (string, string, bool) {
+	if gen.providerSource == "" || strings.HasPrefix(gen.providerSource, "hashicorp/") {
+		return "", "", false
+	}
+
+	return strings.SplitN(gen.resourceName, "_", 2)[0], gen.providerSource, true
+}
+
+// hasName reports whether the resource takes a name, helper resources such as random_string don't so the module
+// neither sets nor checks one
+func (gen documentationGenerator) hasName() bool {
+	if gen.resource == nil {
+		return true
+	}
+	_, ok := gen.resource.Schema["name"]
+
+	return ok
+}
+
+// moduleResourceAddress returns the address of the resource within the generated module
+func (gen documentationGenerator) moduleResourceAddress() string {
+	if gen.azapiType != "" {
+		return "azapi_resource.this"
+	}
+
+	return gen.resourceName + ".this"
+}
+
+// isAzapiBody reports whether the attribute is the body of an azapi_resource
+func (gen documentationGenerator) isAzapiBody(at attribute) bool {
+	return gen.azapiType != "" && at.ResourcePath == gen.resourceName+".body"
+}
+
+// terraformAzapiModuleBlock renders the module for an ARM type scaffolded with `-azapi`, azapi isn't a hashicorp
+// provider so the module has to declare where it is sourced from
+func (gen documentationGenerator) terraformAzapiModuleBlock() string {
+
+	attributes := gen.injectAttributes()
+
+	var moduleBlock string
+
+	providers := map[string]string{"azapi": terraform_azurerm_azapi_source_options[0].Value}
+	for name, source := range gen.getNamingProvider().requiredProviders() {
+		providers[name] = source
+	}
+	moduleBlock += requiredProvidersBlock(providers)
+
+	moduleBlock += "resource \"azapi_resource\" \"this\" {\n"
+	moduleBlock += fmt.Sprintf("\ttype = \"%s\"\n", gen.azapiType)
+	moduleBlock += "\tname = local.name\n"
+	for _, n := range sortAttributeNames(attributes) {
+		switch {
+		case n == "name" || strings.Contains(n, "dlta_"):
+			continue
+		case n == "body":
+			moduleBlock += "\tbody = jsonencode(var.body)\n"
+		default:
+			moduleBlock += fmt.Sprintf("\t%s = var.%s\n", n, n)
+		}
+	}
+	moduleBlock += "}\n"
+
+	return moduleBlock
+}
+
+// constraintPreconditions builds lifecycle preconditions enforcing the schema's ConflictsWith, ExactlyOneOf,
+// AtLeastOneOf and RequiredWith rules across the module variables, rules referencing anything which isn't a
+// top level module variable (e.g. nested `block.0.field` keys) are skipped
+func constraintPreconditions(attributes map[string]attribute) []string {
+
+	isVariable := func(key string) bool {
+		at, ok := attributes[key]
+		return ok && !at.IsBlock && !at.Computed && key != "name" && !strings.Contains(key, "dlta_")
+	}
+	allVariables := func(keys []string) bool {
+		for _, key := range keys {
+			if !isVariable(key) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var preconditions []string
+	seen := make(map[string]bool)
+	add := func(condition string, message string) {
+		if seen[condition] {
+			return
+		}
+		seen[condition] = true
+		preconditions = append(preconditions, fmt.Sprintf("\t\tprecondition {\n\t\t\tcondition     = %s\n\t\t\terror_message = \"%s\"\n\t\t}\n", condition, escapeHclString(message)))
+	}
+	countSet := func(keys []string) string {
+		return fmt.Sprintf("length([for v in [var.%s] : v if v != null])", strings.Join(keys, ", var."))
+	}
+
+	for _, n := range sortAttributeNames(attributes) {
+		at := attributes[n]
+		if !isVariable(n) {
+			continue
+		}
+
+		for _, c := range at.ConflictsWith {
+			if !isVariable(c) {
+				continue
+			}
+			pair := []string{n, c}
+			sort.Strings(pair)
+			add(fmt.Sprintf("var.%s == null || var.%s == null", pair[0], pair[1]), fmt.Sprintf("Only one of `%s` or `%s` can be specified.", pair[0], pair[1]))
+		}
+
+		if len(at.ExactlyOneOf) > 0 && allVariables(at.ExactlyOneOf) {
+			keys := sortedCopy(at.ExactlyOneOf)
+			add(countSet(keys)+" == 1", fmt.Sprintf("Exactly one of `%s` must be specified.", strings.Join(keys, "`, `")))
+		}
+
+		if len(at.AtLeastOneOf) > 0 && allVariables(at.AtLeastOneOf) {
+			keys := sortedCopy(at.AtLeastOneOf)
+			add(countSet(keys)+" >= 1", fmt.Sprintf("At least one of `%s` must be specified.", strings.Join(keys, "`, `")))
+		}
+
+		if len(at.RequiredWith) > 0 && allVariables(at.RequiredWith) {
+			keys := make([]string, 0)
+			for _, key := range sortedCopy(at.RequiredWith) {
+				if key != n {
+					keys = append(keys, key)
+				}
+			}
+			if len(keys) > 0 {
+				add(fmt.Sprintf("var.%s == null || %s == %d", n, countSet(keys), len(keys)), fmt.Sprintf("`%s` requires `%s` to be specified.", n, strings.Join(keys, "`, `")))
+			}
+		}
+	}
+
+	return preconditions
+}
+
+// constraintValidators returns the palette validators describing which controls are mutually exclusive or
+// dependent on each other, keys are the control ids i.e. the last element of the schema key
+func constraintValidators(at attribute) NameValue {
+
+	validators := make(NameValue)
+
+	controlIds := func(keys []string) []string {
+		ids := make([]string, 0)
+		for _, key := range keys {
+			parts := strings.Split(key, ".")
+			ids = append(ids, parts[len(parts)-1])
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	if len(at.ConflictsWith) > 0 {
+		validators["conflictsWith"] = controlIds(at.ConflictsWith)
+	}
+	if len(at.ExactlyOneOf) > 0 {
+		validators["exactlyOneOf"] = controlIds(at.ExactlyOneOf)
+	}
+	if len(at.AtLeastOneOf) > 0 {
+		validators["atLeastOneOf"] = controlIds(at.AtLeastOneOf)
+	}
+	if len(at.RequiredWith) > 0 {
+		validators["requiredWith"] = controlIds(at.RequiredWith)
+	}
+
+	return validators
+}
+
+// TODO.....
+func (gen documentationGenerator) terraformVariableBlock() string {
+
+	var variableBlock string
+
+	for _, v := range gen.getModuleVariables() {
+		variableBlock += variableDeclaration(v.Name, v.Attribute)
+	}
+
+	return variableBlock
+}
+
+// moduleVariable is a variable declared by the generated module along with the attribute it was derived from
+type moduleVariable struct {
+	Name      string
+	Attribute attribute
+}
+
+// getModuleVariables returns the variables declared by the generated resource module, sorted by attribute name
+func (gen documentationGenerator) getModuleVariables() []moduleVariable {
+
+	attributes := gen.injectAttributes()
+
+	variables := make([]moduleVariable, 0)
+
+	for _, n := range sortAttributeNames(attributes) {
+		at := attributes[n]
+
+		if n == "name" { // TODO  We need to work out the scenarios for this
+			continue
+		}
+
+		if !templateOnlyAttributes[n] {
+
+			if !at.Computed { // Computed fields are never variables
+				if gen.isIdentityBlock(at) {
+					variables = append(variables, moduleVariable{Name: identityVariableName, Attribute: identityVariable(at)})
+				} else if !at.IsBlock {
+
+					if isNamingField(n, gen.naming.Tokens) {
+						at.Validations = append(at.Validations, gen.namingFieldValidations(n)...)
+					}
+
+					variables = append(variables, moduleVariable{Name: n, Attribute: at})
+				} else {
+
+					//TODO multi level
+					for _, n1 := range sortAttributeNames(at.Attributes) {
+						at1 := at.Attributes[n1]
+
+						if !at1.IsBlock {
+							if n1 == "name" {
+								if gen.isGeneratedName(at1) {
+									continue
+								}
+								n1 = genVariableNameFromResourcePath(at1.ResourcePath)
+							}
+
+							variables = append(variables, moduleVariable{Name: n1, Attribute: at1})
+						} else {
+							for _, n2 := range sortAttributeNames(at1.Attributes) {
+								at2 := at1.Attributes[n2]
+								if n2 == "name" {
+									if gen.isGeneratedName(at2) {
+										continue
+									}
+
+									variables = append(variables, moduleVariable{Name: genVariableNameFromResourcePath(at2.ResourcePath), Attribute: at2})
+								} else {
+									variables = append(variables, moduleVariable{Name: n2, Attribute: at2})
+								}
+
+							}
+
+						}
+
+					}
+
+				}
+			}
+		}
+
+	}
+
+	for i, v := range variables {
+		variables[i].Attribute.Validations = append(variables[i].Attribute.Validations, numericValidations(v.Name, v.Attribute)...)
+		variables[i].Attribute.Validations = append(variables[i].Attribute.Validations, optionValidations(v.Name, v.Attribute)...)
+	}
+
+	return variables
+}
+
+// numericValidations checks a number variable is within the bounds of the attribute's IntBetween or FloatBetween (or
+// AtLeast/AtMost) validation, the comparison is skipped when an optional variable is null
+func numericValidations(n string, at attribute) []variableValidation {
+
+	if at.DataTypeString != "TypeInt" && at.DataTypeString != "TypeFloat" {
+		return nil
+	}
+
+	var min, max string
+	if at.Constraints.Min != nil {
+		min = strconv.FormatFloat(*at.Constraints.Min, 'f', -1, 64)
+	}
+	if at.Constraints.Max != nil {
+		max = strconv.FormatFloat(*at.Constraints.Max, 'f', -1, 64)
+	}
+
+	var condition string
+	var errorMessage string
+	switch {
+	case min != "" && max != "":
+		condition = fmt.Sprintf("var.%s >= %s && var.%s <= %s", n, min, n, max)
+		errorMessage = fmt.Sprintf("The %s must be between %s and %s.", n, min, max)
+	case min != "":
+		condition = fmt.Sprintf("var.%s >= %s", n, min)
+		errorMessage = fmt.Sprintf("The %s must be at least %s.", n, min)
+	case max != "":
+		condition = fmt.Sprintf("var.%s <= %s", n, max)
+		errorMessage = fmt.Sprintf("The %s must be at most %s.", n, max)
+	default:
+		return nil
+	}
+
+	if !at.Required {
+		condition = fmt.Sprintf("var.%s == null ? true : %s", n, condition)
+	}
+
+	return []variableValidation{{Condition: condition, ErrorMessage: errorMessage}}
+}
+
+// optionValidations checks a variable (or each of its elements) is one of the options the summary restricts it to,
+// the check is skipped when an optional variable is null
+func optionValidations(n string, at attribute) []variableValidation {
+
+	if len(at.OptionsOverride) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, 0, len(at.OptionsOverride))
+	for _, o := range at.OptionsOverride {
+		quoted = append(quoted, strconv.Quote(o))
+	}
+	options := fmt.Sprintf("[%s]", strings.Join(quoted, ", "))
+
+	condition := fmt.Sprintf("contains(%s, var.%s)", options, n)
+	if isCollectionType(at.DataTypeString) {
+		condition = fmt.Sprintf("alltrue([for v in var.%s : contains(%s, v)])", n, options)
+	}
+	if !at.Required {
+		condition = fmt.Sprintf("var.%s == null ? true : %s", n, condition)
+	}
+
+	return []variableValidation{{Condition: condition, ErrorMessage: fmt.Sprintf("The %s must be one of %s.", n, strings.Join(at.OptionsOverride, ", "))}}
+}
+
+// forceNewNote is appended to the description of a variable whose change destroys and recreates the resource
+const forceNewNote = "(forces recreation)"
+
+// variableDeclaration renders a module variable, Optional attributes default to null (unless the schema has a
+// default) so callers can omit them rather than having to supply every optional argument
+func variableDeclaration(n string, at attribute) string {
+
+	var variableBlock string
+
+	description := at.Description
+	if at.ForceNew && !at.Computed {
+		description = strings.TrimSpace(fmt.Sprintf("%s %s", description, forceNewNote))
+	}
+
+	variableBlock += fmt.Sprintf("variable \"%s\" {\n", n)
+	variableBlock += fmt.Sprintf("\tdescription = \"%s\"\n", escapeHclString(description))
+	variableBlock += fmt.Sprintf("\ttype = %s\n", variableTypeConstraint(at))
+	if at.Sensitive {
+		variableBlock += "\tsensitive = true\n"
+	}
+	if at.hasStaticDefault() {
+		variableBlock += fmt.Sprintf("\tdefault = %s\n", hclLiteral(at, at.Default))
+	} else if at.Optional && !at.Required && at.DataTypeString == schema.TypeMap.String() {
+		variableBlock += "\tdefault = {}\n"
+	} else if at.Optional && !at.Required {
+		variableBlock += "\tdefault = null\n"
+	}
+	for _, v := range at.Validations {
+		variableBlock += "\tvalidation {\n"
+		variableBlock += fmt.Sprintf("\t\tcondition     = %s\n", v.Condition)
+		variableBlock += fmt.Sprintf("\t\terror_message = \"%s\"\n", escapeHclString(v.ErrorMessage))
+		variableBlock += "\t}\n"
+	}
+	variableBlock += "}\n"
+
+	return variableBlock
+}
+
+// templateValue returns the placeholder of an attribute in the template, a collection (or an object attribute) is
+// substituted as json so isn't quoted
+func templateValue(n string, at attribute) string {
+	if isPrimitiveCollection(at) || (!at.IsBlock && (at.Shape == shapeListOfObject || at.Shape == shapeSetOfObject)) {
+		return fmt.Sprintf("${%s}", n)
+	}
+	return fmt.Sprintf("\"${%s}\"", n)
+}
+
+// variableTypeConstraint returns the type of a variable, a block is typed as an object (or a list of objects) with
+// optional() wrapping each Optional attribute so it can be omitted from the object
+func variableTypeConstraint(at attribute) string {
+
+	if at.TypeConstraint != "" {
+		return at.TypeConstraint
+	}
+
+	// the key value pairs entered on the canvas are strings
+	if at.DataTypeString == schema.TypeMap.String() && (at.ElemType == "" || at.ElemType == schema.TypeString.String()) {
+		return "map(string)"
+	}
+
+	switch at.Shape {
+	case shapeListOfPrimitive:
+		return fmt.Sprintf("list(%s)", translateDataType(at.ElemType))
+	case shapeSetOfPrimitive:
+		return fmt.Sprintf("set(%s)", translateDataType(at.ElemType))
+	case shapeMapOfPrimitive:
+		return fmt.Sprintf("map(%s)", translateDataType(at.ElemType))
+	}
+
+	if !at.IsBlock {
+		// Terraform rejects a bare `list` or `map`, a collection of an unknown element type holds the strings entered on
+		// the canvas
+		elemType := "string"
+		if at.ElemType != "" {
+			elemType = translateDataType(at.ElemType)
+		}
+		switch at.DataTypeString {
+		case schema.TypeList.String():
+			return fmt.Sprintf("list(%s)", elemType)
+		case schema.TypeSet.String():
+			return fmt.Sprintf("set(%s)", elemType)
+		case schema.TypeMap.String():
+			return fmt.Sprintf("map(%s)", elemType)
+		}
+
+		return translateDataType(at.DataTypeString)
+	}
+
+	fields := make([]string, 0)
+	for _, k := range sortAttributeNames(at.Attributes) {
+		a := at.Attributes[k]
+		fieldType := variableTypeConstraint(a)
+		if a.Optional && !a.Required {
+			fieldType = fmt.Sprintf("optional(%s)", fieldType)
+		}
+		fields = append(fields, fmt.Sprintf("%s = %s", k, fieldType))
+	}
+
+	objectType := fmt.Sprintf("object({ %s })", strings.Join(fields, ", "))
+	if at.MaxItems == 1 {
+		return objectType
+	}
+	if at.Shape == shapeSetOfObject {
+		return fmt.Sprintf("set(%s)", objectType)
+	}
+
+	return fmt.Sprintf("list(%s)", objectType)
+}
+
+func (gen documentationGenerator) terraformLocalBlock() string {
+
+	var localBlock string
+
+	attributes := flattenAttributes(gen.injectAttributes())
+
+	naming := gen.getNamingProvider()
+
+	// the azapi module declares its required providers in main.tf, a module can only declare each provider once
+	providers := make(map[string]string)
+	for name, source := range naming.requiredProviders() {
+		providers[name] = source
+	}
+	if name, source, ok := gen.schemaProvider(); ok {
+		providers[name] = source
+	}
+	if len(providers) > 0 && gen.azapiType == "" {
+		localBlock += requiredProvidersBlock(providers)
+	}
+	localBlock += naming.supportingBlocks()
+	if gen.usesUniqueSuffix() {
+		localBlock += gen.uniqueSuffixBlock()
+	}
+
+	localBlock += "locals {\n"
+	if gen.usesUniqueSuffix() {
+		localBlock += fmt.Sprintf("\t%s = %s\n", uniqueSuffixField, gen.uniqueSuffixExpression())
+	}
+	for _, rp := range sortAttributeNames(attributes) {
+		a := attributes[rp]
+		parts := strings.Split(rp, ".")
+
+		if a.IsBlock || parts[len(parts)-1] != "name" {
+			continue
+		}
+
+		if len(parts) == 2 {
+			localBlock += fmt.Sprintf("\tname = %s\n", naming.nameExpression(""))
+		} else if gen.isGeneratedName(a) {
+			// Nested names take the short code of the block they belong to e.g. `delegation` => `d`
+			localBlock += fmt.Sprintf("\t%s = %s\n", nameLocalName(rp), naming.nameExpression(gen.resourceShortCode(parts[1])))
+		}
+	}
+	localBlock += "}\n"
+
+	return localBlock
+}
+
+// namingExpression renders the resource's naming convention as a Terraform `format()` call over the dlta variables,
+// when shortCode is set it replaces the vendor asset short code so nested names can be distinguished from the parent
+func (gen documentationGenerator) namingExpression(shortCode string) string {
+
+	expression := formatNamingExpression(gen.getNamingStruct(gen.resourceName, gen.isDataSource), shortCode)
+
+	environments := gen.getEnvironmentNaming(gen.resourceName, gen.isDataSource)
+	if len(environments) == 0 {
+		return expression
+	}
+
+	// environment overrides are selected by the value of dlta_environment_char when the module is applied
+	keys := make([]string, 0, len(environments))
+	for k := range environments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	overrides := make([]string, 0, len(keys))
+	for _, k := range keys {
+		overrides = append(overrides, fmt.Sprintf("%s = %s", k, formatNamingExpression(environments[k], shortCode)))
+	}
+
+	return fmt.Sprintf("lookup({ %s }, var.dlta_environment_char, %s)", strings.Join(overrides, ", "), expression)
+}
+
+// formatNamingExpression renders a single naming convention as a Terraform `format()` call
+func formatNamingExpression(naming namingStruct, shortCode string) string {
+	return naming.Transforms.apply(naming.Delimiter, untransformedNamingExpression(naming, shortCode))
+}
+
+// apply wraps a name expression in the Terraform functions implementing the transforms
+func (t namingTransforms) apply(delimiter string, expression string) string {
+	if t.StripDelimiters && delimiter != "" {
+		expression = fmt.Sprintf("replace(%s, \"%s\", \"\")", expression, escapeHclString(delimiter))
+	}
+	if t.Alphanumeric {
+		expression = fmt.Sprintf("replace(%s, \"/[^a-zA-Z0-9]/\", \"\")", expression)
+	}
+	if t.Lowercase {
+		expression = fmt.Sprintf("lower(%s)", expression)
+	}
+	if t.MaxLength > 0 {
+		expression = fmt.Sprintf("substr(%s, 0, %d)", expression, t.MaxLength)
+	}
+
+	return expression
+}
+
+// untransformedNamingExpression renders the naming convention before any transforms are applied
+func untransformedNamingExpression(naming namingStruct, shortCode string) string {
+
+	if naming.StaticName != "" {
+		return fmt.Sprintf("\"%s\"", escapeHclString(naming.StaticName))
+	}
+
+	formats := make([]string, 0)
+	args := make([]string, 0)
+	if naming.Prefix != "" {
+		formats = append(formats, "%s")
+		args = append(args, fmt.Sprintf("\"%s\"", escapeHclString(naming.Prefix)))
+	}
+	for _, field := range naming.Fields {
+		formats = append(formats, "%s")
+		if field == "dlta_vendor_asset_short_code" && shortCode != "" {
+			args = append(args, fmt.Sprintf("\"%s\"", escapeHclString(shortCode)))
+		} else {
+			args = append(args, namingFieldReference(field))
+		}
+	}
+
+	return fmt.Sprintf("format(\"%s\",%s)", escapeHclString(strings.Join(formats, naming.Delimiter)), strings.Join(args, ","))
+}
+
+// namingProvider builds the names used within a generated module, selected with `provider` in `config/naming.json`
+type namingProvider interface {
+	// convention describes the naming convention, shown on the palette
+	convention() string
+
+	// nameExpression returns the expression for a name, shortCode is set for names nested within the resource
+	nameExpression(shortCode string) string
+
+	// supportingBlocks returns any blocks the name expression depends on
+	supportingBlocks() string
+
+	// requiredProviders returns the providers (name => source) needed by the supporting blocks
+	requiredProviders() map[string]string
+}
+
+// getNamingProvider returns the naming provider selected in `config/naming.json`, data sources look up existing names
+// so always use the token convention
+func (gen documentationGenerator) getNamingProvider() namingProvider {
+	if gen.isDataSource {
+		return tokenNamingProvider{gen: gen}
+	}
+
+	switch gen.naming.Provider {
+	case "azurecaf":
+		return azurecafNamingProvider{gen: gen}
+	case "webhook":
+		return webhookNamingProvider{gen: gen, url: gen.naming.WebhookUrl}
+	}
+
+	return tokenNamingProvider{gen: gen}
+}
+
+// tokenNamingProvider joins the dlta naming variables with the delimiter of the naming convention
+type tokenNamingProvider struct {
+	gen documentationGenerator
+}
+
+func (p tokenNamingProvider) convention() string {
+	return p.gen.getResourceNamingConvention(p.gen.resourceName, p.gen.isDataSource)
+}
+
+func (p tokenNamingProvider) nameExpression(shortCode string) string {
+	return p.gen.namingExpression(shortCode)
+}
+
+func (p tokenNamingProvider) supportingBlocks() string {
+	return ""
+}
+
+func (p tokenNamingProvider) requiredProviders() map[string]string {
+	return nil
+}
+
+// azurecafNamingProvider builds the name with the azurecaf_name resource, which adds the CAF slug for the resource type
+// in place of the vendor asset short code, nested names aren't resource types so use the token convention
+type azurecafNamingProvider struct {
+	gen documentationGenerator
+}
+
+func (p azurecafNamingProvider) convention() string {
+	return fmt.Sprintf("azurecaf_name(%s)", p.gen.getResourceNamingConvention(p.gen.resourceName, p.gen.isDataSource))
+}
+
+func (p azurecafNamingProvider) nameExpression(shortCode string) string {
+	if shortCode != "" {
+		return p.gen.namingExpression(shortCode)
+	}
+
+	return "azurecaf_name.this.result"
+}
+
+func (p azurecafNamingProvider) supportingBlocks() string {
+	naming := p.gen.getNamingStruct(p.gen.resourceName, p.gen.isDataSource)
+
+	suffixes := make([]string, 0)
+	for _, field := range naming.Fields {
+		if field == "dlta_vendor_asset_short_code" {
+			continue
+		}
+		suffixes = append(suffixes, namingFieldReference(field))
+	}
+
+	var block string
+	block += "resource \"azurecaf_name\" \"this\" {\n"
+	block += fmt.Sprintf("\tresource_type = \"%s\"\n", p.gen.resourceName)
+	if naming.Prefix != "" {
+		block += fmt.Sprintf("\tprefixes = [\"%s\"]\n", escapeHclString(naming.Prefix))
+	}
+	block += fmt.Sprintf("\tsuffixes = [%s]\n", strings.Join(suffixes, ", "))
+	block += fmt.Sprintf("\tseparator = \"%s\"\n", escapeHclString(naming.Delimiter))
+	block += "\tclean_input = true\n"
+	block += "}\n"
+
+	return block
+}
+
+func (p azurecafNamingProvider) requiredProviders() map[string]string {
+	return map[string]string{"azurecaf": "aztfmod/azurecaf"}
+}
+
+// webhookNamingProvider asks a naming service for the name when the module is planned, the naming fields are sent as
+// query parameters so the service decides the convention
+type webhookNamingProvider struct {
+	gen documentationGenerator
+	url string
+}
+
+func (p webhookNamingProvider) convention() string {
+	return fmt.Sprintf("webhook(%s)", p.url)
+}
+
+func (p webhookNamingProvider) nameExpression(shortCode string) string {
+	if shortCode != "" {
+		return p.gen.namingExpression(shortCode)
+	}
+
+	return "jsondecode(data.http.name.response_body).name"
+}
+
+func (p webhookNamingProvider) supportingBlocks() string {
+	naming := p.gen.getNamingStruct(p.gen.resourceName, p.gen.isDataSource)
+
+	separator := "?"
+	if strings.Contains(p.url, "?") {
+		separator = "&"
+	}
+
+	query := []string{fmt.Sprintf("resource_type=%s", url.QueryEscape(p.gen.resourceName))}
+	for _, field := range naming.Fields {
+		if field == "dlta_vendor_asset_short_code" {
+			query = append(query, fmt.Sprintf("%s=%s", field, url.QueryEscape(p.gen.ShortCode)))
+			continue
+		}
+		query = append(query, fmt.Sprintf("%s=${urlencode(%s)}", field, namingFieldReference(field)))
+	}
+
+	var block string
+	block += "data \"http\" \"name\" {\n"
+	block += fmt.Sprintf("\turl = \"%s%s%s\"\n", escapeHclString(p.url), separator, strings.Join(query, "&"))
+	block += "\trequest_headers = {\n"
+	block += "\t\tAccept = \"application/json\"\n"
+	block += "\t}\n"
+	block += "\tlifecycle {\n"
+	block += "\t\tpostcondition {\n"
+	block += "\t\t\tcondition     = self.status_code == 200\n"
+	block += "\t\t\terror_message = \"The naming webhook did not return a name.\"\n"
+	block += "\t\t}\n"
+	block += "\t}\n"
+	block += "}\n"
+
+	return block
+}
+
+func (p webhookNamingProvider) requiredProviders() map[string]string {
+	return map[string]string{"http": "hashicorp/http"}
+}
+
+// requiredProvidersBlock renders a terraform block declaring the providers (name => source) a module needs
+func requiredProvidersBlock(providers map[string]string) string {
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var block string
+	block += "terraform {\n"
+	block += "\trequired_providers {\n"
+	for _, name := range names {
+		block += fmt.Sprintf("\t\t%s = {\n", name)
+		block += fmt.Sprintf("\t\t\tsource = \"%s\"\n", providers[name])
+		block += "\t\t}\n"
+	}
+	block += "\t}\n"
+	block += "}\n"
+
+	return block
+}
+
+// isGeneratedName returns whether a name attribute is generated from the naming convention
+func (gen documentationGenerator) isGeneratedName(a attribute) bool {
+	return gen.nestedNameMode(a) == nestedNameGenerated
+}
+
+const (
+	// nestedNameGenerated names are generated from the naming convention
+	nestedNameGenerated = "generated"
+
+	// nestedNameUser names are free text supplied by the user
+	nestedNameUser = "user"
+
+	// nestedNameEnum names are one of the values defined by the provider e.g. service delegations
+	nestedNameEnum = "enum"
+)
+
+// publishRule sets whether the attributes matching Path are published, Path is a ResourcePath where `*` matches a
+// single element e.g. `azurerm_*.sku*` (any attribute when empty). When limits the rule to `required` or `optional`
+// attributes, and a rule with UnlessDependent doesn't match attributes supplied by a reference control (those with a
+// DependentResourcePath) e.g. `{"path": "*.*_id", "publish": false, "unless_dependent": true}`
+type publishRule struct {
+	Path            string `json:"path"`
+	When            string `json:"when"`
+	Publish         bool   `json:"publish"`
+	UnlessDependent bool   `json:"unless_dependent"`
+}
+
+const (
+	publishWhenRequired = "required"
+	publishWhenOptional = "optional"
+)
+
+// readPublishRules reads the publish rules from `config/publish_rules.json`
+func (gen documentationGenerator) readPublishRules() ([]publishRule, error) {
+
+	rules := make([]publishRule, 0)
+	if _, err := gen.readDltaConfig("publish_rules.json", &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if rule.When != "" && rule.When != publishWhenRequired && rule.When != publishWhenOptional {
+			return nil, fmt.Errorf("publish_rules.json: [%d]: `when` must be either `%s` or `%s`, got %q", i, publishWhenRequired, publishWhenOptional, rule.When)
+		}
+		if _, err := path.Match(strings.ReplaceAll(rule.Path, ".", "/"), ""); err != nil {
+			return nil, fmt.Errorf("publish_rules.json: [%d]: `path` %q is not a valid pattern: %+v", i, rule.Path, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// publishRuleDecision returns whether the first publish rule matching the attribute publishes it, and whether any did
+func (gen documentationGenerator) publishRuleDecision(rp string, a summaryAttribute) (bool, bool) {
+	for _, rule := range gen.publishRules {
+		if rule.Path != "" && !matchResourcePath(rule.Path, rp) {
+			continue
+		}
+		if (rule.When == publishWhenRequired && !a.Required) || (rule.When == publishWhenOptional && !a.Optional) {
+			continue
+		}
+		if rule.UnlessDependent && a.DependentResourcePath != "" {
+			continue
+		}
+		return rule.Publish, true
+	}
+
+	return false, false
+}
+
+// applyPublishRules sets Published for the attributes of the summary a publish rule matches, the rest keep theirs.
+// Deprecated attributes aren't published unless they're included, see `-include-deprecated`. It returns the resource
+// paths the rules published and unpublished
+func (gen documentationGenerator) applyPublishRules(summary map[string]summaryAttribute) ([]string, []string) {
+
+	published, unpublished := make([]string, 0), make([]string, 0)
+	for _, rp := range sortedKeys(summary) {
+		a := summary[rp]
+		publish, matched := gen.publishRuleDecision(rp, a)
+		if !matched || (publish && a.Deprecated != "" && !gen.includeDeprecated) || publish == a.Published {
+			continue
+		}
+
+		a.Published = publish
+		summary[rp] = a
+		if publish {
+			published = append(published, rp)
+		} else {
+			unpublished = append(unpublished, rp)
+		}
+	}
+
+	return published, unpublished
+}
+
+// nestedNameRule decides how a `name` matching Path is supplied, Path is a ResourcePath where `*` matches a single
+// element e.g. `azurerm_*.ip_configuration.name`
+type nestedNameRule struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+// nestedNameMode returns how a name attribute is supplied, the first rule in `config/nested_names.json` matching its
+// ResourcePath wins and otherwise names constrained to provider values are enums with the rest generated
+func (gen documentationGenerator) nestedNameMode(a attribute) string {
+	for _, rule := range gen.nestedNames {
+		if matchResourcePath(rule.Path, a.ResourcePath) {
+			return rule.Mode
+		}
+	}
+
+	if len(a.PossibleValues) > 0 || len(a.PossibleOptions) > 0 {
+		return nestedNameEnum
+	}
+
+	return nestedNameGenerated
+}
+
+// matchResourcePath matches a ResourcePath against a pattern where `*` matches a single element
+func matchResourcePath(pattern string, resourcePath string) bool {
+	matched, err := path.Match(strings.ReplaceAll(pattern, ".", "/"), strings.ReplaceAll(resourcePath, ".", "/"))
+	return err == nil && matched
+}
+
+// readNestedNames reads the nested name rules from `config/nested_names.json`
+// e.g. `[{"path": "azurerm_subnet.delegation.service_delegation.name", "mode": "enum"}]`
+func (gen documentationGenerator) readNestedNames() ([]nestedNameRule, error) {
+
+	rules := make([]nestedNameRule, 0)
+	if _, err := gen.readDltaConfig("nested_names.json", &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if rule.Mode != nestedNameGenerated && rule.Mode != nestedNameUser && rule.Mode != nestedNameEnum {
+			return nil, fmt.Errorf("nested_names.json: [%d]: `mode` must be either `%s`, `%s` or `%s`, got %q", i, nestedNameGenerated, nestedNameUser, nestedNameEnum, rule.Mode)
+		}
+		if !strings.HasSuffix(rule.Path, ".name") {
+			return nil, fmt.Errorf("nested_names.json: [%d]: `path` must end with `.name`, got %q", i, rule.Path)
+		}
+		if _, err := path.Match(strings.ReplaceAll(rule.Path, ".", "/"), ""); err != nil {
+			return nil, fmt.Errorf("nested_names.json: [%d]: `path` %q is not a valid pattern: %+v", i, rule.Path, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// attributeRules are the paths of the attributes within the resource excluded from scaffolding (e.g. legacy fields or
+// features the organisation doesn't allow) and those included which would otherwise be skipped as they're computed,
+// `*` matches a single element e.g. `network_rules.*`
+type attributeRules struct {
+	Exclude []string `json:"exclude"`
+	Include []string `json:"include"`
+}
+
+// attributeRuleConfig is read from `config/attribute_rules.json` e.g. `{"global": {"exclude":
+// ["public_network_access_enabled"]}, "resources": {"azurerm_storage_account": {"include": ["access_tier"]}}}`, the rules
+// of a resource take precedence over the global ones
+type attributeRuleConfig struct {
+	Global    attributeRules            `json:"global"`
+	Resources map[string]attributeRules `json:"resources"`
+}
+
+// readAttributeRules reads the attribute include and exclude rules from `config/attribute_rules.json`
+func (gen documentationGenerator) readAttributeRules() (attributeRuleConfig, error) {
+
+	var config attributeRuleConfig
+	if _, err := gen.readDltaConfig("attribute_rules.json", &config); err != nil {
+		return config, err
+	}
+
+	validate := func(key string, rules attributeRules) error {
+		for field, patterns := range map[string][]string{"exclude": rules.Exclude, "include": rules.Include} {
+			for i, p := range patterns {
+				if strings.TrimSpace(p) == "" {
+					return fmt.Errorf("attribute_rules.json: %s: %s: [%d]: the path can't be empty", key, field, i)
+				}
+				if _, err := path.Match(strings.ReplaceAll(p, ".", "/"), ""); err != nil {
+					return fmt.Errorf("attribute_rules.json: %s: %s: [%d]: %q is not a valid pattern: %+v", key, field, i, p, err)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := validate("global", config.Global); err != nil {
+		return config, err
+	}
+	for resourceName, rules := range config.Resources {
+		if err := validate(resourceName, rules); err != nil {
+			return config, err
+		}
+	}
+
+	return config, nil
+}
+
+// matchesAttributeRule reports whether the path of an attribute within the resource matches one of the patterns
+func matchesAttributeRule(patterns []string, attributePath string) bool {
+	for _, p := range patterns {
+		if matchResourcePath(p, attributePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attributePath returns the path of an attribute within the resource e.g. `azurerm_storage_account.network_rules.bypass`
+// => `network_rules.bypass`
+func (gen documentationGenerator) attributePath(resourcePath string) string {
+	return strings.TrimPrefix(resourcePath, gen.resourceName+".")
+}
+
+// isExcludedAttribute reports whether the attribute is excluded from scaffolding, a global exclusion is overridden by
+// including the attribute for the resource
+func (gen documentationGenerator) isExcludedAttribute(resourcePath string) bool {
+	ap := gen.attributePath(resourcePath)
+	resourceRules := gen.attributeRules.Resources[gen.resourceName]
+
+	if matchesAttributeRule(resourceRules.Exclude, ap) {
+		return true
+	}
+
+	return matchesAttributeRule(gen.attributeRules.Global.Exclude, ap) && !matchesAttributeRule(resourceRules.Include, ap)
+}
+
+// isIncludedAttribute reports whether the attribute is forced into scaffolding, it's published by default and
+// scaffolded as an input even though the provider computes it when it isn't set
+func (gen documentationGenerator) isIncludedAttribute(resourcePath string) bool {
+	if gen.isExcludedAttribute(resourcePath) {
+		return false
+	}
+
+	ap := gen.attributePath(resourcePath)
+	return matchesAttributeRule(gen.attributeRules.Resources[gen.resourceName].Include, ap) || matchesAttributeRule(gen.attributeRules.Global.Include, ap)
+}
+
+// nameLocalName returns the local which holds a generated name e.g. `azurerm_subnet.delegation.name` => `delegation_name`
+func nameLocalName(resourcePath string) string {
+	parts := strings.Split(resourcePath, ".")
+	if len(parts) <= 2 {
+		return "name"
+	}
+
+	return strings.Join(parts[1:], "_")
+}
+
+// nameExpression returns the expression a name attribute is set to within the module
+func (gen documentationGenerator) nameExpression(a attribute) string {
+	if gen.isGeneratedName(a) {
+		return "local." + nameLocalName(a.ResourcePath)
+	}
+
+	return "var." + genVariableNameFromResourcePath(a.ResourcePath)
+}
+
+func (gen documentationGenerator) getPalletProp(at attribute, name string) PaletteProp {
+
+	var pp PaletteProp
+	var flattenName string
+
+	flattenName = ""
+	pp.ID = name
+	pp.Type = translateDataType(at.DataTypeString)
+	pp.Name = convertNameToLabel(name)
+	pp.Disabled = false
+	pp.FlattenName = &flattenName
+	pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+
+	// published deprecated attributes already have the note appended to their description
+	description := sanitizeDescription(at.Description)
+	if at.Deprecated != "" && !strings.Contains(description, "Deprecated: ") {
+		description = strings.TrimSpace(fmt.Sprintf("%s Deprecated: %s", description, sanitizeDescription(at.Deprecated)))
+	}
+	if description != "" {
+		pp.Description = &description
+	}
+	if at.Deprecated != "" {
+		deprecated := sanitizeDescription(at.Deprecated)
+		pp.Deprecated = &deprecated
+	}
+	pp.ForceNew = at.ForceNew && !at.Computed
+	if len(at.Environments) > 0 {
+		filter := paletteRule{Control: name, When: "dlta_environment_char", In: at.Environments}.filter()
+		pp.Filter = &filter
+	}
+
+	switch name {
+	case "name":
+		if at.ResourcePath != "" && !gen.isGeneratedName(at) { // This is not a generated name
+
+			vn := genVariableNameFromResourcePath(at.ResourcePath)
+			flattenName = ""
+			pp.ID = vn
+			pp.Type = translateDataType(at.DataTypeString)
+			pp.Name = convertNameToLabel(vn)
+			pp.Disabled = false
+			pp.FlattenName = &flattenName
+			pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+
+			if len(at.PossibleValues) > 0 && gen.nestedNameMode(at) == nestedNameEnum {
+
+				var keyVal KeyValue
+
+				for i := 0; i < len(at.PossibleValues); i++ {
+					keyVal.Key = at.PossibleValues[i]
+					keyVal.Value = at.PossibleValues[i]
+
+					pp.Options = append(pp.Options, keyVal)
+				}
+
+				if isCollectionType(at.DataTypeString) {
+					pp.Type = "checkboxes"
+					pp.CurrentValue = []string{}
+				} else {
+					pp.Type = "select"
+					pp.CurrentValue = ""
+				}
+
+			}
+
+			if at.DataTypeString == "TypeBool" {
+				pp.Type = "checkbox"
+			}
+		}
+	case "AssetType":
+		flattenName = "AssetType"
+		pp.ID = "AssetType"
+		pp.Type = "string"
+		pp.Name = "Asset Type:"
+		pp.Disabled = true
+		pp.FlattenName = &flattenName
+		pp.CurrentValue = gen.assetType()
+	case "Name": // This is the name of the asset as dropped onto the canvas
+		validators := make(NameValue)
+		validators["required"] = true
+		validators["minLength"] = 3
+		if check, ok := nameAvailabilityChecks[gen.resourceName]; ok && gen.isResource {
+			validators["nameAvailability"] = check.validator()
+		}
+		if pattern, ok := gen.nameRegex(); ok {
+			validators["pattern"] = pattern
+		}
+		flattenName = "Name"
+		pp.ID = "name"
+		pp.Type = "string"
+		pp.Name = "Name:"
+		pp.Disabled = true
+		pp.FlattenName = &flattenName
+		pp.CurrentValue = initiaiseAttribute("TypeString")
+		pp.Validators = validators
+	case "location":
+		flattenName = ""
+		pp.ID = name
+		pp.Type = translateDataType(at.DataTypeString)
+		pp.Name = convertNameToLabel(name)
+		pp.Disabled = false
+		pp.FlattenName = &flattenName
+		pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+
+		for i := 0; i < len(dlta_location_options); i++ {
+			pp.Options = append(pp.Options, dlta_location_options[i])
+		}
+
+		if len(dlta_location_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = dlta_location_options[0].Value
+	case "dlta_application_short_code":
+		flattenName = ""
+		pp.ID = name
+		pp.Type = translateDataType(at.DataTypeString)
+		pp.Name = convertNameToLabel(name)
+		pp.Disabled = false
+		pp.FlattenName = &flattenName
+		pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+		for i := 0; i < len(dlta_application_short_code_options); i++ {
+			pp.Options = append(pp.Options, dlta_application_short_code_options[i])
+		}
+
+		if len(dlta_application_short_code_options) > 0 {
+			pp.Type = "select"
+		}
+		pp.CurrentValue = dlta_application_short_code_options[0].Value
+	case "dlta_business_short_code":
+		flattenName = ""
+		pp.ID = name
+		pp.Type = translateDataType(at.DataTypeString)
+		pp.Name = convertNameToLabel(name)
+		pp.Disabled = false
+		pp.FlattenName = &flattenName
+		pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+		for i := 0; i < len(dlta_business_short_code_options); i++ {
+			pp.Options = append(pp.Options, dlta_business_short_code_options[i])
+		}
+
+		if len(dlta_business_short_code_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = dlta_business_short_code_options[0].Value
+	case "dlta_environment_char":
+		for i := 0; i < len(dlta_environment_char_options); i++ {
+			pp.Options = append(pp.Options, dlta_environment_char_options[i])
+		}
+
+		if len(dlta_environment_char_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = dlta_environment_char_options[0].Value
+
+	case "dlta_instance_id":
+		if mode := gen.getInstanceIdConfig().Mode; mode != instanceIdStatic {
+			pp.Type = "text"
+			pp.ReadOnly = true
+			if mode == instanceIdQuery {
+				lookup := gen.instanceIdLookup()
+				pp.Lookup = &lookup
+				pp.CurrentValue = ""
+			} else {
+				pp.CurrentValue = nextInstanceId(gen.usedInstanceIds)
+			}
+			break
+		}
+
+		for i := 0; i < len(dlta_instance_id_options); i++ {
+			pp.Options = append(pp.Options, dlta_instance_id_options[i])
+		}
+
+		if len(dlta_instance_id_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = dlta_instance_id_options[0].Value
+	case "dlta_location_short_code":
+		for i := 0; i < len(dlta_location_short_code_options); i++ {
+			pp.Options = append(pp.Options, dlta_location_short_code_options[i])
+		}
+
+		if len(dlta_location_short_code_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = dlta_location_short_code_options[0].Value
+	case "dlta_vendor_asset_short_code":
+		pp.CurrentValue = gen.ShortCode
+		pp.Disabled = true
+	case "dlta_terraform_template":
+		pp.CurrentValue = gen.terraformTemplateBlock()
+		pp.Disabled = true
+		pp.Type = "textarea"
+
+	case "dlta_terraform_data_source_name":
+		pp.CurrentValue = gen.dataSourceNameConvention()
+		pp.Disabled = true
+	case "dlta_terraform_is_data_source":
+		if gen.isDataSource {
+			pp.CurrentValue = "data"
+		} else {
+			pp.CurrentValue = "resource"
+		}
+
+		pp.Disabled = true
+
+	case "resource_group_name":
+		if gen.isDataSource {
+			pp = PaletteProp{}
+			pp.ID = dataResourceGroupControl
+			pp.Type = "string"
+			pp.Name = "Resource Group:"
+			pp.Disabled = false
+			pp.FlattenName = &flattenName
+			pp.CurrentValue = nil
+		} else {
+			// set by the canvas from the group the asset is placed in, see defaultReferenceRules
+			flattenName = "ResourceGroups"
+			rule, _ := gen.referenceRuleFor(name)
+
+			pp = PaletteProp{}
+			pp.ID = rule.Control
+			pp.Type = "string"
+			pp.Name = "Resource Group:"
+			pp.Disabled = true
+			pp.FlattenName = &flattenName
+			pp.CurrentValue = nil
+			if rule.AssetType != "" {
+				assetType := rule.AssetType
+				pp.AssetType = &assetType
+			}
+		}
+
+		// creation.Props = append(creation.Props, palletItem)
+	case "terraform_azurerm_azapi_source":
+		for i := 0; i < len(terraform_azurerm_azapi_source_options); i++ {
+			pp.Options = append(pp.Options, terraform_azurerm_azapi_source_options[i])
+		}
+
+		if len(terraform_azurerm_azapi_source_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = terraform_azurerm_azapi_source_options[0].Value
+	case "terraform_azurerm_azapi_version":
+		for i := 0; i < len(terraform_azurerm_azapi_version_options); i++ {
+			pp.Options = append(pp.Options, terraform_azurerm_azapi_version_options[i])
+		}
+
+		if len(terraform_azurerm_azapi_version_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = terraform_azurerm_azapi_version_options[0].Value
+	case "terraform_azurerm_azurerm_source":
+		for i := 0; i < len(terraform_azurerm_azurerm_source_options); i++ {
+			pp.Options = append(pp.Options, terraform_azurerm_azurerm_source_options[i])
+		}
+
+		if len(terraform_azurerm_azurerm_source_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = terraform_azurerm_azurerm_source_options[0].Value
+	case "terraform_azurerm_azurerm_version":
+		for i := 0; i < len(terraform_azurerm_azurerm_version_options); i++ {
+			pp.Options = append(pp.Options, terraform_azurerm_azurerm_version_options[i])
+		}
+
+		if len(terraform_azurerm_azurerm_version_options) > 0 {
+			pp.Type = "select"
+		}
+
+		pp.CurrentValue = terraform_azurerm_azurerm_version_options[0].Value
+	case "dlta_naming_convention":
+		pp.CurrentValue = gen.NamingConvention
+		pp.Disabled = true
+	default:
+		if len(at.PossibleValues) > 0 {
+
+			var keyVal KeyValue
+
+			for i := 0; i < len(at.PossibleValues); i++ {
+				keyVal.Key = at.PossibleValues[i]
+				keyVal.Value = at.PossibleValues[i]
+
+				pp.Options = append(pp.Options, keyVal)
+			}
+
+			if isCollectionType(at.DataTypeString) {
+				pp.Type = "checkboxes"
+				pp.CurrentValue = []string{}
+			} else {
+				pp.Type = "select"
+				// pp.CurrentValue = at.PossibleValues[0]
+			}
+
+		}
+
+		if at.DataTypeString == "TypeBool" {
+			pp.Type = "checkbox"
+		}
+
+		// a map is entered as key value pairs which can be added and removed
+		if at.DataTypeString == "TypeMap" {
+			pp.Type = paletteKeyValueType
+		}
+
+		// the canvas stores a sensitive value as the secret named by secret_ref, the template only references it
+		if at.Sensitive {
+			secretRef := secretName(name)
+			pp.Type = "password"
+			pp.SecretRef = &secretRef
+			pp.CurrentValue = ""
+		}
+
+		// the canvas shows a value which is still the schema's default differently to one which has been set, a
+		// DefaultFunc's is left empty for the provider to call where the module is applied
+		if at.hasStaticDefault() {
+			pp.CurrentValue = defaultValue(at)
+			pp.IsDefault = true
+		}
+		if at.DefaultSource != "" {
+			defaultSource := at.DefaultSource
+			pp.DefaultSource = &defaultSource
+			pp.DefaultEnv = at.DefaultEnv
+		}
+	}
+
+	for k, v := range constraintValidators(at) {
+		if pp.Validators == nil {
+			pp.Validators = make(NameValue)
+		}
+		pp.Validators[k] = v
+	}
+	for k, v := range at.Constraints.paletteValidators(at.DataTypeString) {
+		if pp.Validators == nil {
+			pp.Validators = make(NameValue)
+		}
+		pp.Validators[k] = v
+	}
+
+	// a number control steps by whole numbers for an integer, any step for a float
+	if pp.Type == "number" && len(pp.Options) == 0 {
+		if pp.Validators == nil {
+			pp.Validators = make(NameValue)
+		}
+		pp.Validators["step"] = 1
+		if at.DataTypeString == "TypeFloat" {
+			pp.Validators["step"] = "any"
+		}
+	}
+
+	liveOptionKey := at.ResourcePath
+	if name == "location" {
+		liveOptionKey = "location"
+	}
+	if options, ok := gen.liveOptions[liveOptionKey]; ok && len(options) > 0 {
+		pp.Options = nil
+		for _, o := range options {
+			pp.Options = append(pp.Options, KeyValue{Key: o, Value: o})
+		}
+		pp.Type = "select"
+		if isCollectionType(at.DataTypeString) {
+			pp.Type = "checkboxes"
+			pp.CurrentValue = []string{}
+		}
+		if name == "location" {
+			pp.CurrentValue = options[0]
+		}
+	}
+
+	// a reference picks the asset it's wired to (see referenceExpression) so the value's validation doesn't apply, the
+	// canvas sets the resource group's itself
+	if rule, ok := gen.referenceFor(name, at); ok && name != "resource_group_name" {
+		assetType := rule.AssetType
+		if assetType == "" {
+			assetType = anyAssetType
+		}
+		pp.ID = rule.Control
+		pp.Type = paletteAssetType
+		pp.AssetType = &assetType
+		pp.CurrentValue = ""
+		pp.Options = nil
+		pp.Validators = nil
+		pp.IsDefault = false
+	}
+
+	if at.Required {
+		if pp.Validators == nil {
+			pp.Validators = make(NameValue)
+		}
+		pp.Validators["required"] = true
+		pp.Group = paletteGroupRequired
+	} else if at.Optional {
+		pp.Group = paletteGroupOptional
+	}
+
+	return pp
+}
+
+// paletteCreator returns the controls document the canvas renders the asset's form from
+func (gen documentationGenerator) paletteCreator() Creator {
+
+	attributes := gen.injectAttributes()
+	var palletItem PaletteProp
+	var creation Creator
+
+	//TODO Move to init area
+	//Enum for attribute type e.g. autoGenName or inputProperty
+
+	creation.CreateFunction = gen.resourceName
+	creation.FormSchemaVersion = paletteFormSchemaVersion
+
+	//getPalletItem()
+	palletItem = gen.getPalletProp(attribute{}, "AssetType")
+
+	// var flattenName string
+
+	creation.Props = append(creation.Props, palletItem)
+
+	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" && !gen.isDataSource {
+
+		palletItem = gen.getPalletProp(attribute{}, "Name")
+		creation.Props = append(creation.Props, palletItem)
+	}
+
+	// iterated in order so the controls of the asset (which aren't sorted by id) are generated the same each time
+	for _, n := range sortAttributeNames(attributes) {
+		fs := attributes[n]
+
+		// the name of a resource is generated from the naming convention, a data source looks it up by name
+		if n == "name" && !gen.isDataSource {
+			continue
+		}
+		// palletItem = PaletteProp{}
+
+		if n == "location" {
+			palletItem = gen.getPalletProp(fs, "location")
+		} else if n == "dlta_application_short_code" {
+			palletItem = gen.getPalletProp(fs, "dlta_application_short_code")
+		} else if n == "dlta_business_short_code" {
+			palletItem = gen.getPalletProp(fs, "dlta_business_short_code")
+		} else if n == "dlta_environment_char" {
+			palletItem = gen.getPalletProp(fs, "dlta_environment_char")
+		} else if n == "dlta_instance_id" {
+			palletItem = gen.getPalletProp(fs, "dlta_instance_id")
+		} else if n == "dlta_location_short_code" {
+			palletItem = gen.getPalletProp(fs, "dlta_location_short_code")
+		} else if n == "dlta_vendor_asset_short_code" {
+			palletItem = gen.getPalletProp(fs, "dlta_vendor_asset_short_code")
+		} else if n == "dlta_terraform_template" {
+			palletItem = gen.getPalletProp(fs, "dlta_terraform_template")
+		} else if n == "dlta_terraform_is_data_source" {
+			palletItem = gen.getPalletProp(fs, "dlta_terraform_is_data_source")
+		} else if n == "resource_group_name" {
+			palletItem = gen.getPalletProp(fs, "resource_group_name")
+		} else if n == "terraform_azurerm_azapi_source" {
+			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azapi_source")
+		} else if n == "terraform_azurerm_azapi_version" {
+			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azapi_version")
+		} else if n == "terraform_azurerm_azurerm_source" {
+			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azurerm_source")
+		} else if n == "terraform_azurerm_azurerm_version" {
+			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azurerm_version")
+		} else if n == "dlta_naming_convention" {
+			palletItem = gen.getPalletProp(fs, "dlta_naming_convention")
+		} else {
+			palletItem = gen.getPalletProp(fs, n)
+		}
+
+		if gen.isIdentityBlock(fs) {
+			identityType := fs.Attributes["type"]
+			identityType.Required = identityType.Required && fs.Required
+			identityType.PossibleValues = identityTypeOptions(fs)
+			creation.Props = append(creation.Props, gen.getPalletProp(identityType, identityTypeControl))
+			if identityIds, ok := fs.Attributes["identity_ids"]; ok {
+				identityIds.Required = identityIds.Required && fs.Required
+				creation.Props = append(creation.Props, gen.getPalletProp(identityIds, "identity_ids"))
+			}
+			if rule, ok := gen.identityPaletteRule(fs); ok {
+				gen.paletteRules = append(gen.paletteRules, rule)
+			}
+		} else if fs.IsBlock {
+			for _, n1 := range sortAttributeNames(fs.Attributes) {
+				at := fs.Attributes[n1]
+				// an attribute is only required when the blocks containing it are
+				at.Required = at.Required && fs.Required
+				if !at.IsBlock {
+					// a data source's template passes the arguments of its blocks by path
+					name := n1
+					if gen.isDataSource {
+						name = genVariableNameFromResourcePath(at.ResourcePath)
+					}
+					palletItem = gen.getPalletProp(at, name)
+					creation.Props = append(creation.Props, palletItem)
+				} else {
+					for _, n2 := range sortAttributeNames(at.Attributes) {
+						at2 := at.Attributes[n2]
+						at2.Required = at2.Required && at.Required
+						palletItem = gen.getPalletProp(at2, n2)
+						creation.Props = append(creation.Props, palletItem)
+					}
+				}
+
+			}
+		} else {
+			creation.Props = append(creation.Props, palletItem)
+		}
+	}
+
+	outputs := gen.getPaletteOutputAttributes()
+	for _, n := range sortAttributeNames(outputs) {
+		creation.Props = append(creation.Props, gen.paletteOutputProp(outputs[n], n))
+	}
+
+	// a reference can be to an existing resource the provider looks up, rather than an asset on the canvas
+	for _, pp := range creation.Props {
+		if props, ok := gen.existingReferenceProps(pp); ok {
+			creation.Props = append(creation.Props, props...)
+			gen.paletteRules = append(gen.paletteRules, paletteRule{Control: pp.ID, When: pp.ID + referenceModeSuffix, In: []string{referenceModeManaged}})
+		}
+	}
+
+	sortPaletteProps(creation.Props)
+	gen.applyPaletteRules(creation.Props)
+
+	return creation
+}
+
+// the modes of a reference, chosen with its `<control>_mode` control
+const (
+	// referenceModeManaged wires the reference to the module of an asset on the canvas
+	referenceModeManaged = "managed"
+
+	// referenceModeExisting wires the reference to a data source looking up an existing resource, with the values of
+	// the `<control>_existing_<argument>` controls
+	referenceModeExisting = "existing"
+
+	referenceModeSuffix   = "_mode"
+	existingArgumentInfix = "_existing_"
+)
+
+// existingReferenceProps returns the controls choosing whether the reference control pp is to an asset on the canvas
+// or an existing resource, and those of the arguments looking the existing resource up which are shown when it is. A
+// reference to any type, or to one without a data source, is always to an asset
+func (gen documentationGenerator) existingReferenceProps(pp PaletteProp) ([]PaletteProp, bool) {
+
+	if pp.AssetType == nil || *pp.AssetType == anyAssetType {
+		return nil, false
+	}
+	arguments, ok := gen.providerRegistry().DataSources[*pp.AssetType]
+	if !ok || len(arguments) == 0 {
+		return nil, false
+	}
+
+	mode := pp.ID + referenceModeSuffix
+	flattenName := ""
+	description := fmt.Sprintf("Whether %s is an asset on the canvas (managed) or an existing %s looked up with a data source (existing)", pp.ID, *pp.AssetType)
+	props := []PaletteProp{{
+		ID:           mode,
+		Name:         convertNameToLabel(mode),
+		Description:  &description,
+		Type:         "select",
+		CurrentValue: referenceModeManaged,
+		FlattenName:  &flattenName,
+		IsDefault:    true,
+		Options:      []KeyValue{{Key: referenceModeManaged, Value: referenceModeManaged}, {Key: referenceModeExisting, Value: referenceModeExisting}},
+		Group:        pp.Group,
+	}}
+
+	filter := paletteRule{When: mode, In: []string{referenceModeExisting}}.filter()
+	for _, argument := range arguments {
+		id := pp.ID + existingArgumentInfix + argument
+		description := fmt.Sprintf("The %s of the existing %s", argument, *pp.AssetType)
+		props = append(props, PaletteProp{
+			ID:           id,
+			Name:         convertNameToLabel(id),
+			Description:  &description,
+			Type:         "string",
+			CurrentValue: "",
+			FlattenName:  &flattenName,
+			Filter:       &filter,
+			Validators:   NameValue{"required": true},
+			Group:        pp.Group,
+		})
+	}
+
+	return props, true
+}
+
+// paletteRule shows a control only when another control has (or doesn't have) one of the values e.g.
+// `{"control": "zone_redundant", "when": "sku_name", "in": ["Premium"]}`
+type paletteRule struct {
+	Control string   `json:"control"`
+	When    string   `json:"when"`
+	In      []string `json:"in"`
+	NotIn   []string `json:"not_in"`
+}
+
+var paletteControlRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// paletteOutputRegex matches the computed attributes shown on the palette by default, along with the id
+var paletteOutputRegex = regexp.MustCompile(`(^|_)(endpoint|fqdn|uri|url)s?$`)
+
+// readPaletteOutputs reads the computed attributes shown on the palette for the resource from
+// `config/palette_outputs.json` e.g. `{"azurerm_key_vault": ["id", "vault_uri"]}`
+func (gen documentationGenerator) readPaletteOutputs() ([]string, error) {
+
+	outputs := make(map[string][]string)
+	if _, err := gen.readDltaConfig("palette_outputs.json", &outputs); err != nil {
+		return nil, err
+	}
+
+	for i, output := range outputs[gen.resourceName] {
+		if !paletteControlRegex.MatchString(output) {
+			return nil, fmt.Errorf("palette_outputs.json: %s: [%d]: must be an attribute name, got %q", gen.resourceName, i, output)
+		}
+	}
+
+	return outputs[gen.resourceName], nil
+}
+
+// getPaletteOutputAttributes returns the computed attributes of the resource shown on the palette with
+// `-palette-outputs`, those in `config/palette_outputs.json` or otherwise the id, endpoints and FQDNs
+func (gen documentationGenerator) getPaletteOutputAttributes() map[string]attribute {
+
+	attributes := make(map[string]attribute)
+	if !gen.showOutputs || !gen.isResource || gen.resource == nil {
+		return attributes
+	}
+
+	computed := map[string]attribute{
+		"id": {DataTypeString: "TypeString", Description: "The resource id", ResourcePath: gen.resourceName + ".id"},
+	}
+	for n, a := range gen.getAllOutputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName) {
+		if !a.IsBlock {
+			computed[n] = a
+		}
+	}
+
+	if len(gen.paletteOutputs) > 0 {
+		for _, n := range gen.paletteOutputs {
+			if a, ok := computed[n]; ok {
+				attributes[n] = a
+			} else {
+				fmt.Printf("getPaletteOutputAttributes \"unknown output\": %s isn't a computed attribute of %s\n", n, gen.resourceName)
+			}
+		}
+		return attributes
+	}
+
+	for n, a := range computed {
+		if n == "id" || (a.DataTypeString == "TypeString" && paletteOutputRegex.MatchString(n)) {
+			attributes[n] = a
+		}
+	}
+
+	return attributes
+}
+
+// paletteOutputProp returns the read only control of a computed attribute, `output` references the module output
+// setting it so the canvas can wire it into downstream assets
+func (gen documentationGenerator) paletteOutputProp(at attribute, name string) PaletteProp {
+	flattenName := ""
+	output := fmt.Sprintf("module.${dlta_terraform_module_name}.%s", name)
+
+	pp := PaletteProp{
+		ID:           name,
+		Name:         convertNameToLabel(name),
+		Type:         translateDataType(at.DataTypeString),
+		CurrentValue: initiaiseAttribute(at.DataTypeString),
+		FlattenName:  &flattenName,
+		Disabled:     true,
+		ReadOnly:     true,
+		Group:        paletteGroupOutput,
+		Output:       &output,
+	}
+	if description := sanitizeDescription(at.Description); description != "" {
+		pp.Description = &description
+	}
+	if at.Sensitive {
+		pp.Type = "password"
+	}
+
+	return pp
+}
+
+// readPaletteRules returns the resource's rules from `config/palette_rules.json`, which is keyed by resource type
+func (gen documentationGenerator) readPaletteRules() ([]paletteRule, error) {
+
+	rules := make(map[string][]paletteRule)
+	if _, err := gen.readDltaConfig("palette_rules.json", &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules[gen.resourceName] {
+		if !paletteControlRegex.MatchString(rule.Control) || !paletteControlRegex.MatchString(rule.When) {
+			return nil, fmt.Errorf("palette_rules.json: %s: [%d]: `control` and `when` must be control ids, got %q and %q", gen.resourceName, i, rule.Control, rule.When)
+		}
+		if (len(rule.In) == 0) == (len(rule.NotIn) == 0) {
+			return nil, fmt.Errorf("palette_rules.json: %s: [%d]: exactly one of `in` or `not_in` must be given", gen.resourceName, i)
+		}
+	}
+
+	return rules[gen.resourceName], nil
+}
+
+// filter returns the filter expression the canvas evaluates to decide whether the control is shown
+func (r paletteRule) filter() string {
+	values := r.In
+	operator, listOperator := "==", "in"
+	if len(r.NotIn) > 0 {
+		values = r.NotIn
+		operator, listOperator = "!=", "not in"
+	}
+
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "\\'")))
+	}
+
+	if len(quoted) == 1 {
+		return fmt.Sprintf("%s %s %s", r.When, operator, quoted[0])
+	}
+
+	return fmt.Sprintf("%s %s [%s]", r.When, listOperator, strings.Join(quoted, ", "))
+}
+
+// applyPaletteRules sets the filter of each control with rules, a control with several rules is shown when they all hold
+func (gen documentationGenerator) applyPaletteRules(props []PaletteProp) {
+
+	ids := make(map[string]int, len(props))
+	for i, pp := range props {
+		ids[pp.ID] = i
+	}
+
+	filters := make(map[string][]string)
+	for _, rule := range gen.paletteRules {
+		_, hasControl := ids[rule.Control]
+		_, hasWhen := ids[rule.When]
+		if !hasControl || !hasWhen {
+			fmt.Printf("applyPaletteRules \"unknown control\": ignoring the rule for %s when %s as the control isn't on the palette\n", rule.Control, rule.When)
+			continue
+		}
+		filters[rule.Control] = append(filters[rule.Control], rule.filter())
+	}
+
+	for control, expressions := range filters {
+		// the environments a control is published for (see getPalletProp) hold alongside its rules
+		if existing := props[ids[control]].Filter; existing != nil {
+			expressions = append([]string{*existing}, expressions...)
+		}
+		filter := strings.Join(expressions, " && ")
+		props[ids[control]].Filter = &filter
+	}
+}
+
+func (gen documentationGenerator) dltaPalletteCodeBlock() string {
+
+	var dltaPalletteCodeBlock string
+	creation := gen.paletteCreator()
+	design := gen.paletteDesign()
+
+	// upsert on asset_type (which needs a unique constraint) so the SQL can be re-run, an existing row keeps its guid
+	dltaPalletteCodeBlock += fmt.Sprintf("insert into %s (\n", gen.getPaletteTable())
+	dltaPalletteCodeBlock += "				id, 		guid, infra_id, name,	label,	type,	active, 	addable,	asset_type,	reflect_type, 	palette_design, form_fields, 	attributes, created_at, updated_at, deleted_at, updated_by,	rank, 	has_cost, svg_icon) values (\n"
+	dltaPalletteCodeBlock += fmt.Sprintf("	DEFAULT, 	'%s', 1, 		'%s', 	'%s', 	'', 	true, 		true, 		'%s',		'none', 		'%s', 			'%s', 			null, 		now(), 		now(), 		null, 		1,			%d, 	%s, 		'%s'	\n", uuid.New().String(), escapeSqlLiteral(gen.assetType()), escapeSqlLiteral(gen.assetType()), escapeSqlLiteral(gen.assetType()), escapeSqlLiteral(writeJson(design)), escapeSqlLiteral(writeJson(creation)), gen.getPaletteRank(), strconv.FormatBool(gen.hasCost()), escapeSqlLiteral(design.SVG))
+	dltaPalletteCodeBlock += ")\n"
+	dltaPalletteCodeBlock += "on conflict (asset_type) do update set\n"
+	dltaPalletteCodeBlock += "	name = excluded.name,\n"
+	dltaPalletteCodeBlock += "	label = excluded.label,\n"
+	dltaPalletteCodeBlock += "	palette_design = excluded.palette_design,\n"
+	dltaPalletteCodeBlock += "	svg_icon = excluded.svg_icon,\n"
+	dltaPalletteCodeBlock += "	rank = excluded.rank,\n"
+	dltaPalletteCodeBlock += "	form_fields = excluded.form_fields,\n"
+	dltaPalletteCodeBlock += "	has_cost = excluded.has_cost,\n"
+	dltaPalletteCodeBlock += "	active = excluded.active,\n"
+	dltaPalletteCodeBlock += "	addable = excluded.addable,\n"
+	dltaPalletteCodeBlock += "	updated_at = now(),\n"
+	dltaPalletteCodeBlock += "	deleted_at = null;"
+
+	return dltaPalletteCodeBlock
+}
+
+// paletteIconConfig is read from `config/palette_icons.json`, an icon set for a resource type takes precedence over
+// the one for its service e.g. `{"resources": {"azurerm_storage_account": {"svg": "storage-account.svg"}}, "services":
+// {"Storage": {"color": "#3999c6"}}}`. An svg ending `.svg` is read from `config/icons`, anything else is inline markup
+type paletteIconConfig struct {
+	Resources map[string]PaletteObj `json:"resources"`
+	Services  map[string]PaletteObj `json:"services"`
+}
+
+// defaultPaletteColor is used for services without a colour of their own
+const defaultPaletteColor = "#0078d4"
+
+// bundledPaletteColors are the default colours of the services, taken from the Azure icon set
+var bundledPaletteColors = map[string]string{
+	"AWS":                "#ff9900",
+	"AppService":         "#0072c6",
+	"Compute":            "#0078d4",
+	"Container Apps":     "#7a4f9e",
+	"Container Services": "#326ce5",
+	"CosmosDB":           "#5ea0ef",
+	"EventHub":           "#3999c6",
+	"GCP":                "#4285f4",
+	"KeyVault":           "#ffb900",
+	"Monitor":            "#e8661d",
+	"Network":            "#5ea0ef",
+	"PostgreSQL":         "#336791",
+	"Resources":          "#a0a1a2",
+	"SQL":                "#e52a2a",
+	"ServiceBus":         "#0072c6",
+	"Storage":            "#3999c6",
+	"Utility":            "#6b6b6b",
+	"Web":                "#0072c6",
+}
+
+// bundledPaletteIcon is the default icon, a badge of the short code in the colour of the service
+const bundledPaletteIcon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 18 18"><rect width="18" height="18" rx="3" fill="%s"/><text x="9" y="12" font-family="Segoe UI, sans-serif" font-size="6" text-anchor="middle" fill="#fff">%s</text></svg>`
+
+// readPaletteIcons reads `config/palette_icons.json`, replacing any svg file names with the contents of the file
+func (gen documentationGenerator) readPaletteIcons() (paletteIconConfig, error) {
+
+	var config paletteIconConfig
+	if _, err := gen.readDltaConfig("palette_icons.json", &config); err != nil {
+		return config, err
+	}
+
+	for _, icons := range []map[string]PaletteObj{config.Resources, config.Services} {
+		for k, icon := range icons {
+			if !strings.HasSuffix(icon.SVG, ".svg") {
+				continue
+			}
+
+			iconPath := filepath.Join(gen.dltaPath, "config", "icons", icon.SVG)
+			content, err := os.ReadFile(iconPath)
+			if err != nil {
+				return config, fmt.Errorf("palette_icons.json: %s: reading %s: %+v", k, iconPath, err)
+			}
+			icon.SVG = string(content)
+			icons[k] = icon
+		}
+	}
+
+	return config, nil
+}
+
+// paletteDesign returns the icon and colour the asset is drawn with, each taken from the resource type's icon set, then
+// the service's and finally the bundled defaults
+func (gen documentationGenerator) paletteDesign() PaletteObj {
+
+	design := gen.paletteIcons.Resources[gen.resourceName]
+	service := gen.paletteIcons.Services[gen.serviceName]
+
+	if design.Color == "" {
+		design.Color = service.Color
+	}
+	if design.Color == "" {
+		design.Color = bundledPaletteColors[gen.serviceName]
+	}
+	if design.Color == "" {
+		design.Color = defaultPaletteColor
+	}
+
+	if design.SVG == "" {
+		design.SVG = service.SVG
+	}
+	if design.SVG == "" {
+		design.SVG = fmt.Sprintf(bundledPaletteIcon, design.Color, html.EscapeString(gen.ShortCode))
+	}
+
+	design.Category = gen.paletteCategory()
+	design.Service = gen.serviceName
+
+	return design
+}
+
+// paletteCategoryConfig is read from `config/palette_categories.json` e.g. `{"resources": {"azurerm_key_vault":
+// "Security"}, "services": {"Databricks": "Analytics"}}`, a category for a resource type takes precedence
+type paletteCategoryConfig struct {
+	Resources map[string]string `json:"resources"`
+	Services  map[string]string `json:"services"`
+}
+
+// defaultPaletteCategory groups assets whose service has no category
+const defaultPaletteCategory = "Other"
+
+// bundledPaletteCategories group the services onto the palette, services not listed fall back to their first
+// website category
+var bundledPaletteCategories = map[string]string{
+	"AWS":                              "AWS",
+	"AppService":                       "Web",
+	"Authorization":                    "Security",
+	"Compute":                          "Compute",
+	"Container Apps":                   "Containers",
+	"Container Services":               "Containers",
+	"CosmosDB":                         "Databases",
+	"DNS":                              "Networking",
+	"EventHub":                         "Messaging",
+	"GCP":                              "GCP",
+	"KeyVault":                         "Security",
+	"ManagedIdentity":                  "Security",
+	"Microsoft SQL Server / Azure SQL": "Databases",
+	"Monitor":                          "Monitoring",
+	"MySQL":                            "Databases",
+	"Network":                          "Networking",
+	"PostgreSQL":                       "Databases",
+	"Private DNS":                      "Networking",
+	"Resources":                        "Management",
+	"SQL":                              "Databases",
+	"ServiceBus":                       "Messaging",
+	"Storage":                          "Storage",
+	"Utility":                          "Utility",
+	"Web":                              "Web",
+}
+
+// readPaletteCategories reads the palette category overrides from `config/palette_categories.json`
+func (gen documentationGenerator) readPaletteCategories() (paletteCategoryConfig, error) {
+
+	var config paletteCategoryConfig
+	if _, err := gen.readDltaConfig("palette_categories.json", &config); err != nil {
+		return config, err
+	}
+
+	for _, categories := range []map[string]string{config.Resources, config.Services} {
+		for k, category := range categories {
+			if strings.TrimSpace(category) == "" {
+				return config, fmt.Errorf("palette_categories.json: %s: the category can't be empty", k)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// paletteCategory returns the group the asset is presented in on the palette
+func (gen documentationGenerator) paletteCategory() string {
+	if category, ok := gen.paletteCategories.Resources[gen.resourceName]; ok {
+		return category
+	}
+	if category, ok := gen.paletteCategories.Services[gen.serviceName]; ok {
+		return category
+	}
+	if category, ok := bundledPaletteCategories[gen.serviceName]; ok {
+		return category
+	}
+	if len(gen.websiteCategories) > 0 {
+		return gen.websiteCategories[0]
+	}
+
+	return defaultPaletteCategory
+}
+
+// paletteRankConfig is read from `config/palette_ranks.json` e.g. `{"resources": {"azurerm_resource_group": 1},
+// "categories": {"Networking": 5}}`, a rank for a resource type takes precedence over the rank for its category
+type paletteRankConfig struct {
+	Resources  map[string]int `json:"resources"`
+	Categories map[string]int `json:"categories"`
+}
+
+// defaultPaletteRank is the rank of assets without a configured rank
+const defaultPaletteRank = 14
+
+// readPaletteRank returns the rank of the asset, with `-auto-rank` the asset is ranked by its position in the usage
+// counts of `config/asset_usage.json` e.g. `{"azurerm_resource_group": 120}` with unused assets ranked as configured
+func (gen documentationGenerator) readPaletteRank() (int, error) {
+
+	var config paletteRankConfig
+	if _, err := gen.readDltaConfig("palette_ranks.json", &config); err != nil {
+		return 0, err
+	}
+	for k, rank := range config.Resources {
+		if rank < 1 {
+			return 0, fmt.Errorf("palette_ranks.json: resources: %s: the rank must be at least 1, got %d", k, rank)
+		}
+	}
+	for k, rank := range config.Categories {
+		if rank < 1 {
+			return 0, fmt.Errorf("palette_ranks.json: categories: %s: the rank must be at least 1, got %d", k, rank)
+		}
+	}
+
+	if gen.autoRank {
+		usage := make(map[string]int)
+		exists, err := gen.readDltaConfig("asset_usage.json", &usage)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, fmt.Errorf("`-auto-rank` needs the usage counts of each asset type in config/asset_usage.json")
+		}
+		if rank, ok := usageRank(usage, gen.resourceName); ok {
+			return rank, nil
+		}
+	}
+
+	if rank, ok := config.Resources[gen.resourceName]; ok {
+		return rank, nil
+	}
+	if rank, ok := config.Categories[gen.paletteCategory()]; ok {
+		return rank, nil
+	}
+
+	return defaultPaletteRank, nil
+}
+
+// usageRank returns the 1 based position of the asset type when ordered by usage, most used first
+func usageRank(usage map[string]int, resourceName string) (int, bool) {
+	if usage[resourceName] <= 0 {
+		return 0, false
+	}
+
+	resourceNames := make([]string, 0, len(usage))
+	for k, count := range usage {
+		if count > 0 {
+			resourceNames = append(resourceNames, k)
+		}
+	}
+	sort.Slice(resourceNames, func(i, j int) bool {
+		if usage[resourceNames[i]] != usage[resourceNames[j]] {
+			return usage[resourceNames[i]] > usage[resourceNames[j]]
+		}
+		return resourceNames[i] < resourceNames[j]
+	})
+
+	for i, k := range resourceNames {
+		if k == resourceName {
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// getPaletteRank returns the rank of the asset, falling back to defaultPaletteRank
+func (gen documentationGenerator) getPaletteRank() int {
+	if gen.paletteRank == 0 {
+		return defaultPaletteRank
+	}
+
+	return gen.paletteRank
+}
+
+// sortPaletteProps orders the controls of the asset first, followed by the required, the optional and then the
+// output controls
+func sortPaletteProps(props []PaletteProp) {
+	groupOrder := map[string]int{paletteGroupRequired: 1, paletteGroupOptional: 2, paletteGroupOutput: 3}
+
+	sort.SliceStable(props, func(i, j int) bool {
+		gi, gj := groupOrder[props[i].Group], groupOrder[props[j].Group]
+		if gi != gj {
+			return gi < gj
+		}
+		return gi > 0 && props[i].ID < props[j].ID
+	})
+}
+
+const (
+	// paletteFormatSql writes the palette as SQL upserting the asset into the palette table
+	paletteFormatSql = "sql"
+
+	// paletteFormatJson writes just the controls document, for frontends and APIs which don't read the database
+	paletteFormatJson = "json"
+
+	// paletteFormatBoth writes the palette as both SQL and JSON
+	paletteFormatBoth = "both"
+)
+
+// writePalette renders the palette for each frontend chosen with `-palette-target`, defaulting to the palette table
+func (gen documentationGenerator) writePalette() {
+	for _, target := range gen.getPaletteTargets() {
+		artefacts, err := paletteRenderers[target].render(gen)
+		if err != nil {
+			fmt.Printf("writePalette \"%s\": %v\n", target, err)
+			continue
+		}
+
+		keys := make([]Artefact, 0, len(artefacts))
+		for a := range artefacts {
+			keys = append(keys, a)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		for _, a := range keys {
+			gen.writeResource(artefacts[a], a)
+		}
+	}
+	gen.writeResource(writeJson(gen.costMeta()), CostMeta)
+}
+
+// assetType returns the asset type of the palette asset, the resource or `<resource>:<profile>` for a profile of it
+func (gen documentationGenerator) assetType() string {
+	if gen.profile == "" {
+		return gen.resourceName
+	}
+	return gen.resourceName + ":" + gen.profile
+}
+
+// paletteCatalogEntry returns the asset's entry in the json palette catalog, the same row as dltaPalletteCodeBlock
+func (gen documentationGenerator) paletteCatalogEntry() paletteCatalogEntry {
+	design := gen.paletteDesign()
+
+	return paletteCatalogEntry{
+		AssetType:     gen.assetType(),
+		Name:          gen.assetType(),
+		Label:         gen.assetType(),
+		PaletteDesign: design,
+		FormFields:    gen.paletteCreator(),
+		Rank:          gen.getPaletteRank(),
+		HasCost:       gen.hasCost(),
+		SvgIcon:       design.SVG,
+	}
+}
+
+// paletteRenderer renders the palette of an asset for a frontend, all of them are rendered from the same controls
+// (paletteCreator) so each frontend offers the same form
+type paletteRenderer interface {
+	render(gen documentationGenerator) (map[Artefact]string, error)
+}
+
+const (
+	// paletteTargetInfraAsset is the palette table read by the canvas, written in the formats of `-palette-format`
+	paletteTargetInfraAsset = "infra-asset"
+
+	// paletteTargetJsonSchema is a JSON schema and ui schema for react-jsonschema-form
+	paletteTargetJsonSchema = "jsonschema"
+
+	// paletteTargetBackstage is a Backstage software template rendering the module call
+	paletteTargetBackstage = "backstage"
+)
+
+// paletteRenderers are the renderers selected with `-palette-target`
+var paletteRenderers = map[string]paletteRenderer{
+	paletteTargetInfraAsset: infraAssetRenderer{},
+	paletteTargetJsonSchema: jsonSchemaRenderer{},
+	paletteTargetBackstage:  backstageRenderer{},
+}
+
+// parsePaletteTargets parses the comma separated `-palette-target`
+func parsePaletteTargets(value string) ([]string, error) {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, target := range strings.Split(value, ",") {
+		target = strings.TrimSpace(target)
+		if _, ok := paletteRenderers[target]; !ok {
+			return nil, fmt.Errorf("`-palette-target` must be a comma separated list of %s, got %q", strings.Join(sortedKeys(paletteRenderers), ", "), target)
+		}
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+// getPaletteTargets returns the frontends the palette is rendered for
+func (gen documentationGenerator) getPaletteTargets() []string {
+	if len(gen.paletteTargets) == 0 {
+		return []string{paletteTargetInfraAsset}
+	}
+
+	return gen.paletteTargets
+}
+
+// infraAssetRenderer renders the SQL upserting the asset into the palette table and/or the controls json
+type infraAssetRenderer struct{}
+
+func (infraAssetRenderer) render(gen documentationGenerator) (map[Artefact]string, error) {
+	artefacts := make(map[Artefact]string)
+	if gen.paletteFormat != paletteFormatJson {
+		artefacts[PalletteBlock] = gen.dltaPalletteCodeBlock()
+	}
+	if gen.paletteFormat == paletteFormatJson || gen.paletteFormat == paletteFormatBoth {
+		artefacts[PaletteJson] = writeJson(gen.paletteCreator())
+	}
+
+	return artefacts, nil
+}
+
+// jsonSchemaRenderer renders `{"schema": ..., "uiSchema": ...}` for react-jsonschema-form
+type jsonSchemaRenderer struct{}
+
+func (jsonSchemaRenderer) render(gen documentationGenerator) (map[Artefact]string, error) {
+	properties := make(map[string]interface{})
+	uiSchema := make(map[string]interface{})
+	required := make([]string, 0)
+	order := make([]string, 0)
+
+	for _, pp := range gen.paletteCreator().Props {
+		property, ui := jsonSchemaProperty(pp)
+		properties[pp.ID] = property
+		if len(ui) > 0 {
+			uiSchema[pp.ID] = ui
+		}
+		if pp.Validators["required"] == true {
+			required = append(required, pp.ID)
+		}
+		order = append(order, pp.ID)
+	}
+	uiSchema["ui:order"] = order
+
+	schema := map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"title":      gen.resourceName,
+		"type":       "object",
+		"required":   required,
+		"properties": properties,
+	}
+
+	return map[Artefact]string{PaletteJsonSchema: writeJson(map[string]interface{}{"schema": schema, "uiSchema": uiSchema})}, nil
+}
+
+// jsonSchemaProperty returns the JSON schema of a control along with its ui schema, the `ui:` options of
+// react-jsonschema-form (which Backstage reads from the property itself)
+func jsonSchemaProperty(pp PaletteProp) (map[string]interface{}, map[string]interface{}) {
+
+	property := map[string]interface{}{"title": strings.TrimSuffix(pp.Name, ":")}
+	ui := make(map[string]interface{})
+
+	if pp.Description != nil {
+		property["description"] = *pp.Description
+	}
+
+	enum := make([]string, 0, len(pp.Options))
+	for _, o := range pp.Options {
+		enum = append(enum, o.Value)
+	}
+
+	switch pp.Type {
+	case "number":
+		property["type"] = "number"
+		if pp.Validators["step"] == 1 {
+			property["type"] = "integer"
+		}
+	case "bool", "checkbox":
+		property["type"] = "boolean"
+	case "checkboxes", "list":
+		items := map[string]interface{}{"type": "string"}
+		if len(enum) > 0 {
+			items["enum"] = enum
+			ui["ui:widget"] = "checkboxes"
+		}
+		property["type"] = "array"
+		property["items"] = items
+		property["uniqueItems"] = pp.Type == "checkboxes"
+	case paletteKeyValueType:
+		property["type"] = "object"
+		property["additionalProperties"] = map[string]interface{}{"type": "string"}
+	default:
+		property["type"] = "string"
+		if len(enum) > 0 {
+			property["enum"] = enum
+		}
+	}
+
+	switch pp.Type {
+	case "password", "textarea":
+		ui["ui:widget"] = pp.Type
+	case paletteAssetType:
+		ui["ui:widget"] = pp.Type
+		if pp.AssetType != nil {
+			ui["ui:options"] = map[string]interface{}{"asset_type": *pp.AssetType}
+		}
+	}
+
+	jsonSchemaKeywords := map[string]string{"min": "minimum", "max": "maximum", "minLength": "minLength", "maxLength": "maxLength", "pattern": "pattern"}
+	for validator, keyword := range jsonSchemaKeywords {
+		if v, ok := pp.Validators[validator]; ok {
+			property[keyword] = v
+		}
+	}
+
+	if pp.IsDefault || pp.Disabled {
+		if value := pp.CurrentValue; value != nil && value != "" {
+			property["default"] = value
+		}
+	}
+	if pp.ReadOnly || pp.Group == paletteGroupOutput {
+		property["readOnly"] = true
+	}
+	if pp.Disabled {
+		ui["ui:disabled"] = true
+	}
+
+	return property, ui
+}
+
+// backstageRenderer renders a Backstage software template (scaffolder.backstage.io/v1beta3), with a page of
+// parameters for the asset, required and optional controls, whose values are passed to the template skeleton
+type backstageRenderer struct{}
+
+const (
+	// backstageSkeletonDir is the directory, next to template.yaml, of the files the template's fetch:template renders
+	backstageSkeletonDir = "skeleton"
+
+	// backstageSkeletonFileName is the module call of the skeleton, only `.njk` files are rendered (see
+	// templateFileExtension) and the extension is dropped, so the module call isn't validated as HCL
+	backstageSkeletonFileName = "main.tf.njk"
+)
+
+// backstageSkeleton returns the module call of the template with its placeholders the values of fetch:template e.g.
+// `${sku_name}` becomes `${{ values.sku_name }}`
+func (gen documentationGenerator) backstageSkeleton() string {
+	return templatePlaceholderRegex.ReplaceAllString(formatHcl(gen.terraformTemplateBlock()), "$${{ values.$1 }}")
+}
+
+func (backstageRenderer) render(gen documentationGenerator) (map[Artefact]string, error) {
+
+	type page struct {
+		Title      string                 `yaml:"title"`
+		Required   []string               `yaml:"required,omitempty"`
+		Properties map[string]interface{} `yaml:"properties"`
+	}
+
+	pages := []*page{
+		{Title: "Asset", Properties: map[string]interface{}{}},
+		{Title: "Required", Properties: map[string]interface{}{}},
+		{Title: "Optional", Properties: map[string]interface{}{}},
+	}
+	values := make(map[string]string)
+
+	for _, pp := range gen.paletteCreator().Props {
+		// outputs are only known once the module is applied
+		if pp.Group == paletteGroupOutput {
+			continue
+		}
+
+		p := pages[0]
+		if pp.Group == paletteGroupRequired {
+			p = pages[1]
+		} else if pp.Group == paletteGroupOptional {
+			p = pages[2]
+		}
+
+		property, ui := jsonSchemaProperty(pp)
+		for k, v := range ui {
+			property[k] = v
+		}
+		p.Properties[pp.ID] = property
+		if pp.Validators["required"] == true {
+			p.Required = append(p.Required, pp.ID)
+		}
+		values[pp.ID] = fmt.Sprintf("${{ parameters.%s }}", pp.ID)
+	}
+
+	parameters := make([]*page, 0)
+	for _, p := range pages {
+		if len(p.Properties) > 0 {
+			parameters = append(parameters, p)
+		}
+	}
+
+	template := map[string]interface{}{
+		"apiVersion": "scaffolder.backstage.io/v1beta3",
+		"kind":       "Template",
+		"metadata": map[string]interface{}{
+			"name":        strings.ReplaceAll(gen.resourceName, "_", "-"),
+			"title":       gen.resourceName,
+			"description": fmt.Sprintf("Adds %s to the infrastructure with the dlta module", gen.resourceName),
+			"tags":        []string{"terraform", strings.ToLower(strings.ReplaceAll(gen.paletteCategory(), " ", "-"))},
+		},
+		"spec": map[string]interface{}{
+			"owner":      "platform",
+			"type":       "infrastructure",
+			"parameters": parameters,
+			"steps": []map[string]interface{}{
+				{
+					"id":     "template",
+					"name":   "Render the module call",
+					"action": "fetch:template",
+					"input":  map[string]interface{}{"url": "./" + backstageSkeletonDir, "templateFileExtension": true, "values": values},
+				},
+			},
+		},
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(template); err != nil {
+		return nil, fmt.Errorf("writing the Backstage template: %+v", err)
+	}
+
+	return map[Artefact]string{PaletteBackstage: b.String(), BackstageSkeleton: gen.backstageSkeleton()}, nil
+}
+
+// costConfig is read from `config/costs.json`, it classifies resource types as billable or free (overriding
+// freeResourceTypes) and gives cost hints for the SKUs of a resource e.g. `{"billable": ["azurerm_public_ip"],
+// "skus": {"azurerm_service_plan": {"attribute": "sku_name", "hints": {"B1": "~$13/month"}}}}`
+type costConfig struct {
+	Billable []string                `json:"billable"`
+	Free     []string                `json:"free"`
+	Skus     map[string]skuCostHints `json:"skus"`
+}
+
+// skuCostHints are the cost hints for each value of the attribute selecting the SKU
+type skuCostHints struct {
+	Attribute string            `json:"attribute"`
+	Hints     map[string]string `json:"hints"`
+}
+
+// costMeta is written to `cost.json` alongside the palette, so the canvas can show what an asset costs
+type costMeta struct {
+	AssetType    string            `json:"asset_type"`
+	HasCost      bool              `json:"has_cost"`
+	SkuAttribute string            `json:"sku_attribute,omitempty"`
+	SkuHints     map[string]string `json:"sku_hints,omitempty"`
+}
+
+// freeResourceTypes are resource types Azure doesn't bill for, anything else is assumed to be billable
+var freeResourceTypes = map[string]bool{
+	"azurerm_application_security_group":                true,
+	"azurerm_management_group":                          true,
+	"azurerm_network_interface":                         true,
+	"azurerm_network_security_group":                    true,
+	"azurerm_network_security_rule":                     true,
+	"azurerm_policy_assignment":                         true,
+	"azurerm_policy_definition":                         true,
+	"azurerm_resource_group":                            true,
+	"azurerm_role_assignment":                           true,
+	"azurerm_role_definition":                           true,
+	"azurerm_route_table":                               true,
+	"azurerm_subnet":                                    true,
+	"azurerm_subnet_network_security_group_association": true,
+	"azurerm_subnet_route_table_association":            true,
+	"azurerm_user_assigned_identity":                    true,
+	"azurerm_virtual_network":                           true,
+}
+
+// readCostConfig reads the cost classification from `config/costs.json`
+func (gen documentationGenerator) readCostConfig() (costConfig, error) {
+
+	var config costConfig
+	if _, err := gen.readDltaConfig("costs.json", &config); err != nil {
+		return config, err
+	}
+
+	billable := make(map[string]bool)
+	for _, resourceName := range config.Billable {
+		billable[resourceName] = true
+	}
+	for _, resourceName := range config.Free {
+		if billable[resourceName] {
+			return config, fmt.Errorf("costs.json: %s is listed as both billable and free", resourceName)
+		}
+	}
+	for resourceName, hints := range config.Skus {
+		if hints.Attribute == "" {
+			return config, fmt.Errorf("costs.json: skus: %s: `attribute` must name the attribute selecting the SKU", resourceName)
+		}
+	}
+
+	return config, nil
+}
+
+// hasCost returns whether deploying the asset is billed, data sources and the provider configuration never are
+func (gen documentationGenerator) hasCost() bool {
+	if gen.isDataSource || gen.resourceName == "terraform_azurerm" || gen.resourceName == "devops_pipeline" {
+		return false
+	}
+
+	for _, resourceName := range gen.costs.Billable {
+		if resourceName == gen.resourceName {
+			return true
+		}
+	}
+	for _, resourceName := range gen.costs.Free {
+		if resourceName == gen.resourceName {
+			return false
+		}
+	}
+
+	if _, ok := utilityResourceSchemas[gen.resourceName]; ok {
+		return false
+	}
+
+	return !freeResourceTypes[gen.resourceName]
+}
+
+// costMeta returns the cost metadata of the asset
+func (gen documentationGenerator) costMeta() costMeta {
+	meta := costMeta{AssetType: gen.assetType(), HasCost: gen.hasCost()}
+	if hints, ok := gen.costs.Skus[gen.resourceName]; ok && meta.HasCost {
+		meta.SkuAttribute = hints.Attribute
+		meta.SkuHints = hints.Hints
+	}
+
+	return meta
+}
+
+// retireFileName is the palette SQL written by `-output-type retire`
+const retireFileName = "retire.sql"
+
+// infracostConfigBlock returns an Infracost config file for the module with a project per SKU hint in
+// `config/costs.json`, run `infracost breakdown --config-file infracost.yml` in the module directory to estimate the
+// monthly cost of each SKU selection, without hints there is a single project using the example values
+func (gen documentationGenerator) infracostConfigBlock() string {
+
+	type infracostProject struct {
+		name string
+		vars map[string]string
+	}
+
+	projects := []infracostProject{{name: gen.resourceName}}
+	if hints, ok := gen.costs.Skus[gen.resourceName]; ok && len(hints.Hints) > 0 {
+		variable, found := gen.skuVariable(hints.Attribute)
+		if !found {
+			fmt.Printf("infracostConfigBlock \"sku attribute\" %s isn't a variable of the module, generating a single project\n", hints.Attribute)
+		} else {
+			projects = nil
+			for _, sku := range sortedKeys(hints.Hints) {
+				projects = append(projects, infracostProject{name: fmt.Sprintf("%s-%s", gen.resourceName, sku), vars: map[string]string{variable: sku}})
+			}
+		}
+	}
+
+	config := "version: 0.1\n\nprojects:\n"
+	for _, project := range projects {
+		config += "  - path: .\n"
+		config += fmt.Sprintf("    name: %s\n", strconv.Quote(project.name))
+		config += "    terraform_var_files:\n"
+		config += "      - terraform.tfvars.example\n"
+		if len(project.vars) > 0 {
+			config += "    terraform_vars:\n"
+			for _, variable := range sortedKeys(project.vars) {
+				config += fmt.Sprintf("      %s: %s\n", variable, strconv.Quote(project.vars[variable]))
+			}
+		}
+	}
+
+	return config
+}
+
+// skuVariable returns the module variable set from the attribute selecting the SKU, the attribute is its path
+// within the resource e.g. `sku_name` or `sku.tier`
+func (gen documentationGenerator) skuVariable(skuAttribute string) (string, bool) {
+	for _, v := range gen.getModuleVariables() {
+		if v.Attribute.ResourcePath == gen.resourceName+"."+skuAttribute {
+			return v.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// paletteRetireBlock returns the SQL removing the asset from the palette for a resource which is no longer supported,
+// the row is soft deleted so existing assets keep their type unless `-hard-delete` is given. Scaffolding the resource
+// again restores the row
+func (gen documentationGenerator) paletteRetireBlock() string {
+
+	if gen.hardDelete {
+		return fmt.Sprintf("delete from %s\nwhere asset_type = '%s';", gen.getPaletteTable(), escapeSqlLiteral(gen.assetType()))
+	}
+
+	var retireBlock string
+	retireBlock += fmt.Sprintf("update %s set\n", gen.getPaletteTable())
+	retireBlock += "	active = false,\n"
+	retireBlock += "	addable = false,\n"
+	retireBlock += "	updated_at = now(),\n"
+	retireBlock += "	deleted_at = now()\n"
+	retireBlock += fmt.Sprintf("where asset_type = '%s' and deleted_at is null;", escapeSqlLiteral(gen.assetType()))
+
+	return retireBlock
+}
+
+// paletteFormSchemaVersion is stamped into the controls json as `form_schema_version`, bump it and add a migration
+// to paletteMigrations whenever the format of the controls changes
+const paletteFormSchemaVersion = 11
+
+// paletteKeyValueType is the type of the control for a map, a list of key value pairs which can be added and removed
+const paletteKeyValueType = "keyvalue"
+
+// paletteAssetType is the type of a reference's control, which picks an asset of the control's AssetType on the canvas
+// (anyAssetType for any) and is set to its module's name
+const paletteAssetType = "asset"
+
+// migrateFileName is the palette SQL written by `-output-type migrate`
+const migrateFileName = "migrate.sql"
+
+// paletteMigrations upgrade controls json from the version they're keyed by to the next one, controls generated
+// before the version was stamped are version 1
+var paletteMigrations = map[int]func(creator map[string]interface{}) error{
+	// version 2 groups the controls into required and optional
+	1: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["group"]; ok {
+				return
+			}
+			control["group"] = paletteGroupOptional
+			if validators, ok := control["validators"].(map[string]interface{}); ok && validators["required"] == true {
+				control["group"] = paletteGroupRequired
+			}
+			if _, ok := control["lookup"]; !ok {
+				control["lookup"] = nil
+			}
+		})
+	},
+	// version 3 flags controls still holding the schema's default, which can't be told apart in earlier versions
+	2: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["is_default"]; !ok {
+				control["is_default"] = false
+			}
+		})
+	},
+	// version 4 enters maps as key value pairs, starting empty rather than null
+	3: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if control["type"] != "map" {
+				return
+			}
+			control["type"] = paletteKeyValueType
+			if control["value"] == nil {
+				control["value"] = map[string]interface{}{}
+			}
+		})
+	},
+	// version 5 adds read only controls set from the module's outputs, which the controls before it aren't
+	4: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["output"]; !ok {
+				control["output"] = nil
+			}
+		})
+	},
+	// version 6 masks sensitive controls, those before it held their values in the template so must be regenerated
+	5: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["secret_ref"]; !ok {
+				control["secret_ref"] = nil
+			}
+		})
+	},
+	// version 7 records where a default comes from, the defaults before it were all the schema's
+	6: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["default_source"]; !ok {
+				control["default_source"] = nil
+				if control["is_default"] == true {
+					control["default_source"] = defaultSourceSchema
+				}
+			}
+			if _, ok := control["default_env"]; !ok {
+				control["default_env"] = nil
+			}
+		})
+	},
+	// version 8 flags deprecated controls, those before it only noted the deprecation in the description
+	7: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["deprecated"]; !ok {
+				control["deprecated"] = nil
+			}
+		})
+	},
+	// version 9 flags controls whose change recreates the resource, those before it don't say
+	8: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["force_new"]; !ok {
+				control["force_new"] = false
+			}
+		})
+	},
+	// version 10 picks the asset a reference is wired to, those before it entered its name as a string so keep it as
+	// the asset picked
+	9: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if _, ok := control["asset_type"]; ok {
+				return
+			}
+			control["asset_type"] = nil
+			for _, rule := range defaultReferenceRules {
+				if rule.Control != "" || rule.Attribute != control["id"] {
+					continue
+				}
+				assetType := rule.AssetType
+				if assetType == "" {
+					assetType = anyAssetType
+				}
+				control["type"] = paletteAssetType
+				control["asset_type"] = assetType
+				control["options"] = nil
+				control["is_default"] = false
+				// the canvas wires a reference whatever its value would have been validated as
+				required := false
+				if validators, ok := control["validators"].(map[string]interface{}); ok {
+					required = validators["required"] == true
+				}
+				control["validators"] = nil
+				if required {
+					control["validators"] = map[string]interface{}{"required": true}
+				}
+			}
+		})
+	},
+	// version 11 chooses whether a reference is to an asset on the canvas or an existing resource, a reference without
+	// a `<control>_mode` control is to an asset so those before it are unchanged. Scaffolding the asset again adds the
+	// controls looking an existing resource up, the resource group's picks from the resource groups since
+	10: func(creator map[string]interface{}) error {
+		return eachPaletteControl(creator, func(control map[string]interface{}) {
+			if control["id"] == "ResourceGroup" && control["asset_type"] == nil {
+				control["asset_type"] = "azurerm_resource_group"
+			}
+		})
+	},
+}
+
+// eachPaletteControl calls fn with each control of the controls json
+func eachPaletteControl(creator map[string]interface{}, fn func(control map[string]interface{})) error {
+	controls, ok := creator["controls"].([]interface{})
+	if !ok {
+		return fmt.Errorf("`controls` must be a list")
+	}
+	for i, c := range controls {
+		control, ok := c.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("controls: %d: must be an object", i)
+		}
+		fn(control)
+	}
+
+	return nil
+}
+
+// migratePaletteControls upgrades controls json to paletteFormSchemaVersion, returning the version it was at
+func migratePaletteControls(creator map[string]interface{}) (int, error) {
+
+	version := 1
+	if v, ok := creator["form_schema_version"]; ok {
+		number, ok := v.(float64)
+		if !ok || number != float64(int(number)) || number < 1 {
+			return 0, fmt.Errorf("`form_schema_version` must be a positive whole number, got %v", v)
+		}
+		version = int(number)
+	}
+	if version > paletteFormSchemaVersion {
+		return 0, fmt.Errorf("`form_schema_version` %d is newer than this version of dlta-scaffold supports (%d)", version, paletteFormSchemaVersion)
+	}
+
+	for v := version; v < paletteFormSchemaVersion; v++ {
+		if err := paletteMigrations[v](creator); err != nil {
+			return 0, fmt.Errorf("migrating from version %d: %+v", v, err)
+		}
+	}
+	creator["form_schema_version"] = paletteFormSchemaVersion
+
+	return version, nil
+}
+
+// paletteMigrateBlock returns the SQL replacing the form_fields of the asset with the controls from `-migrate-input`
+// upgraded to the current format, nothing is returned when they're already current
+func (gen documentationGenerator) paletteMigrateBlock() (string, error) {
+
+	input := gen.migrateInput
+	if input == "" {
+		input = filepath.Join(gen.resourceDir("resource"), "palette.json")
+	}
+
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return "", fmt.Errorf("reading the controls to migrate (export form_fields to a file and pass it with `-migrate-input`): %+v", err)
+	}
+
+	var creator map[string]interface{}
+	if err := json.Unmarshal(content, &creator); err != nil {
+		return "", fmt.Errorf("%s: %+v", input, err)
+	}
+
+	version, err := migratePaletteControls(creator)
+	if err != nil {
+		return "", fmt.Errorf("%s: %+v", input, err)
+	}
+	if version == paletteFormSchemaVersion {
+		fmt.Printf("paletteMigrateBlock \"up to date\" %s is already version %d\n", input, version)
+		return "", nil
+	}
+
+	var migrateBlock string
+	migrateBlock += fmt.Sprintf("update %s set\n", gen.getPaletteTable())
+	migrateBlock += fmt.Sprintf("	form_fields = '%s',\n", escapeSqlLiteral(writeJson(creator)))
+	migrateBlock += "	updated_at = now()\n"
+	migrateBlock += fmt.Sprintf("where asset_type = '%s';", escapeSqlLiteral(gen.assetType()))
+
+	return migrateBlock, nil
+}
+
+// printPaletteDiff prints the difference between the controls of the asset in the palette and those generated now, so
+// changes to the canvas forms can be reviewed before the palette SQL is applied
+func (gen documentationGenerator) printPaletteDiff(ctx context.Context, query func(ctx context.Context, sql string) (string, error)) error {
+
+	diff, err := gen.paletteDiff(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	if len(diff) == 0 {
+		color.Green("The palette controls of %s are up to date", gen.resourceName)
+		return nil
+	}
+
+	fmt.Printf("Palette controls of %s (live -> generated):\n", gen.resourceName)
+	for _, line := range diff {
+		switch line[0] {
+		case '+':
+			color.Green(line)
+		case '-':
+			color.Red(line)
+		default:
+			color.Yellow(line)
+		}
+	}
+
+	return nil
+}
+
+// paletteDiff returns the changes from the form_fields of the asset in the palette to the controls generated now,
+// the live controls are migrated to the current form_schema_version first so only changes to the forms are listed
+func (gen documentationGenerator) paletteDiff(ctx context.Context, query func(ctx context.Context, sql string) (string, error)) ([]string, error) {
+
+	sql := fmt.Sprintf("select form_fields from %s where asset_type = '%s' and deleted_at is null", gen.getPaletteTable(), escapeSqlLiteral(gen.assetType()))
+	formFields, err := query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("reading the palette of %s: %+v", gen.resourceName, err)
+	}
+
+	live := map[string]interface{}{"controls": []interface{}{}}
+	if strings.TrimSpace(formFields) != "" {
+		live = nil
+		if err := json.Unmarshal([]byte(formFields), &live); err != nil {
+			return nil, fmt.Errorf("parsing the form_fields of %s: %+v", gen.resourceName, err)
+		}
+		if _, err := migratePaletteControls(live); err != nil {
+			return nil, fmt.Errorf("migrating the form_fields of %s: %+v", gen.resourceName, err)
+		}
+	} else {
+		fmt.Printf("paletteDiff \"not in palette\" %s has no row in %s\n", gen.resourceName, gen.getPaletteTable())
+	}
+
+	// round trip the generated controls so both sides are compared as plain json
+	var generated map[string]interface{}
+	if err := json.Unmarshal([]byte(writeJson(gen.paletteCreator())), &generated); err != nil {
+		return nil, fmt.Errorf("reading the generated controls: %+v", err)
+	}
+
+	return diffPaletteControls(live, generated), nil
+}
+
+// diffPaletteControls lists the controls added (`+`) and removed (`-`) by id and each changed field of the controls
+// in both (`~`), followed by a change to their order
+func diffPaletteControls(live map[string]interface{}, generated map[string]interface{}) []string {
+
+	byId := func(creator map[string]interface{}) ([]string, map[string]map[string]interface{}) {
+		ids := make([]string, 0)
+		controls := make(map[string]map[string]interface{})
+		_ = eachPaletteControl(creator, func(control map[string]interface{}) {
+			id := fmt.Sprint(control["id"])
+			ids = append(ids, id)
+			controls[id] = control
+		})
+		return ids, controls
+	}
+	compact := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+
+	liveIds, liveControls := byId(live)
+	generatedIds, generatedControls := byId(generated)
+
+	diff := make([]string, 0)
+	for _, id := range generatedIds {
+		if _, ok := liveControls[id]; !ok {
+			diff = append(diff, fmt.Sprintf("+ %s (%s)", id, generatedControls[id]["type"]))
+		}
+	}
+	for _, id := range liveIds {
+		if _, ok := generatedControls[id]; !ok {
+			diff = append(diff, fmt.Sprintf("- %s (%s)", id, liveControls[id]["type"]))
+		}
+	}
+
+	common := make([]string, 0)
+	for _, id := range generatedIds {
+		liveControl, ok := liveControls[id]
+		if !ok {
+			continue
+		}
+		common = append(common, id)
+
+		fields := make(map[string]bool)
+		for field := range liveControl {
+			fields[field] = true
+		}
+		for field := range generatedControls[id] {
+			fields[field] = true
+		}
+		for _, field := range sortedKeys(fields) {
+			if !reflect.DeepEqual(liveControl[field], generatedControls[id][field]) {
+				diff = append(diff, fmt.Sprintf("~ %s.%s: %s -> %s", id, field, compact(liveControl[field]), compact(generatedControls[id][field])))
+			}
+		}
+	}
+
+	liveOrder := make([]string, 0)
+	for _, id := range liveIds {
+		if _, ok := generatedControls[id]; ok {
+			liveOrder = append(liveOrder, id)
+		}
+	}
+	if !reflect.DeepEqual(liveOrder, common) {
+		diff = append(diff, fmt.Sprintf("~ order: %s -> %s", strings.Join(liveOrder, ", "), strings.Join(common, ", ")))
+	}
+
+	return diff
+}
+
+// psqlQuery runs a query returning a single value against `-dsn` with psql, so no database driver is needed
+func (gen documentationGenerator) psqlQuery(ctx context.Context, sql string) (string, error) {
+	cmd := exec.CommandContext(ctx, "psql", gen.dsn, "--no-psqlrc", "--tuples-only", "--no-align", "--set", "ON_ERROR_STOP=1", "--command", sql)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running psql: %+v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(out), nil
+}
+
+// defaultPaletteTable is the table the palette SQL upserts into unless `-palette-table` is given
+const defaultPaletteTable = "core.infra_asset"
+
+// paletteTableRegex matches an unquoted `schema.table`, so it can be written into the SQL as is
+var paletteTableRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*\.[a-z_][a-z0-9_]*$`)
+
+// getPaletteTable returns the `schema.table` the palette SQL upserts into
+func (gen documentationGenerator) getPaletteTable() string {
+	if gen.paletteTable == "" {
+		return defaultPaletteTable
+	}
+
+	return gen.paletteTable
+}
+
+func (gen documentationGenerator) terraformOutputBlock() string {
+
+	var outputBlock string
+
+	if gen.resource != nil {
+
+		attributes := gen.getModuleOutputAttributes()
+
+		for _, k := range sortAttributeNames(attributes) {
+			outputBlock += "output \"" + k + "\" {\n"
+			if description := attributes[k].Description; description != "" {
+				outputBlock += "\tdescription = \"" + escapeHclString(description) + "\"\n"
+			}
+			outputBlock += "\tvalue = " + gen.moduleResourceAddress() + "." + k + "\n"
+			// terraform refuses an output of a sensitive value which isn't itself sensitive
+			if attributes[k].Sensitive {
+				outputBlock += "\tsensitive = true\n"
+			}
+			outputBlock += "}\n"
+		}
+
+	}
+
+	return outputBlock
+}
+
+// moduleMetaBlock builds the module-meta.json manifest, the content hash covers every module file in write order
+// (see moduleContentHash) so each must be passed as it's written
+func (gen documentationGenerator) moduleMetaBlock(moduleFiles ...string) string {
+
+	meta := moduleMeta{
+		GeneratorVersion:    generatorVersion,
+		ProviderVersion:     version.ProviderVersion,
+		ResourceType:        gen.resourceName,
+		NamingConvention:    gen.NamingConvention,
+		PublishedAttributes: gen.getPublishedResourcePaths(),
+		ContentHash:         moduleContentHash(moduleFiles...),
+	}
+
+	return writeJson(meta)
+}
+
+// moduleContentHash returns the content hash of module-meta.json, over the module's files as written less the header
+// (see withoutFileHeader) which says when they were generated, so a module regenerated unchanged hashes the same
+func moduleContentHash(moduleFiles ...string) string {
+
+	hash := sha256.New()
+	for _, content := range moduleFiles {
+		hash.Write([]byte(withoutFileHeader(content)))
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// withoutFileHeader returns a generated file's content less the header fileHeader writes, which ends at the first
+// blank line
+func withoutFileHeader(content string) string {
+	if !strings.HasPrefix(content, "# Code generated by dlta-scaffold ") {
+		return content
+	}
+	if _, body, ok := strings.Cut(content, "\n\n"); ok {
+		return body
+	}
+	return content
+}
+
+// getPublishedResourcePaths returns the sorted resource paths marked as Published in the summary file
+func (gen documentationGenerator) getPublishedResourcePaths() []string {
+
+	paths := make([]string, 0)
+	for rp, sa := range gen.readResourceProperties() {
+		if sa.Published {
+			paths = append(paths, rp)
+		}
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// terraformTemplateNamingArguments passes the dlta naming tokens selected on the canvas through to the module
+func (gen documentationGenerator) terraformTemplateNamingArguments() string {
+
+	var templateBlock string
+
+	templateBlock += templateComment(dlta_location_short_code.Description)
+	templateBlock += fmt.Sprintf("\tdlta_location_short_code    = ${%s}\n", "dlta_location_short_code")
+	templateBlock += templateComment(dlta_environment_char.Description)
+	templateBlock += fmt.Sprintf("\tdlta_environment_char       = ${%s}\n", "dlta_environment_char")
+	templateBlock += templateComment(dlta_business_short_code.Description)
+	templateBlock += fmt.Sprintf("\tdlta_business_short_code    = ${%s}\n", "dlta_business_short_code")
+	templateBlock += templateComment(dlta_application_short_code.Description)
+	templateBlock += fmt.Sprintf("\tdlta_application_short_code = ${%s}\n", "dlta_application_short_code")
+	templateBlock += templateComment(dlta_instance_id.Description)
+	templateBlock += fmt.Sprintf("\tdlta_instance_id            = ${%s}\n", "dlta_instance_id")
+	templateBlock += templateComment(dlta_vendor_asset_short_code.Description)
+	templateBlock += fmt.Sprintf("\tdlta_vendor_asset_short_code	= ${%s}\n", "dlta_vendor_asset_short_code")
+
+	tokens := gen.getNamingTokens()
+	for _, n := range sortAttributeNames(tokens) {
+		templateBlock += templateComment(tokens[n].Description)
+		templateBlock += fmt.Sprintf("\t%s = ${%s}\n", n, n)
+	}
+
+	return templateBlock
+}
+
+// canImport returns whether import blocks can be generated, only managed resources can be adopted
+func (gen documentationGenerator) canImport() bool {
+	return gen.isImport && gen.isResource && gen.resource != nil
+}
+
+// terraformImportBlock templates a Terraform 1.5 import block which adopts an existing resource into the module,
+// the block is only rendered onto the canvas when a resource id has been supplied
+func (gen documentationGenerator) terraformImportBlock() string {
+
+	var importBlock string
+
+	importBlock += "import {\n"
+	importBlock += fmt.Sprintf("\tto = module.${%s}.%s\n", "dlta_terraform_module_name", gen.moduleResourceAddress())
+	importBlock += fmt.Sprintf("\tid = \"${%s}\"\n", "dlta_import_resource_id")
+	importBlock += "}\n"
+
+	return importBlock
+}
+
+// canMove returns whether a moved block is generated, only when the asset was renamed as Terraform rejects a move
+// from nowhere or onto itself. Data sources hold no state so are never moved
+func (gen documentationGenerator) canMove() bool {
+	return gen.isResource && gen.resource != nil && gen.moduleName != "" && gen.previousModuleName != "" && gen.previousModuleName != gen.moduleName
+}
+
+// terraformMovedBlock renders a moved block mapping the previous module address onto the current one, so renaming
+// an asset on the canvas results in a move rather than a destroy/recreate plan
+func (gen documentationGenerator) terraformMovedBlock() string {
+
+	var movedBlock string
+
+	movedBlock += "moved {\n"
+	movedBlock += fmt.Sprintf("\tfrom = module.%s\n", gen.previousModuleName)
+	movedBlock += fmt.Sprintf("\tto   = module.%s\n", gen.moduleName)
+	movedBlock += "}\n"
+
+	return movedBlock
+}
+
+func (gen documentationGenerator) getResourceNamingConvention(resourceName string, isDataSource bool) string {
+
+	// menu := make(map[string][]string)
+
+	//	Angular
+
+	naming := gen.getNamingStruct(resourceName, isDataSource)
+
+	static := naming.StaticName
+	delim := naming.Delimiter
+	fields := naming.Fields
+	prefix := naming.Prefix
+
+	returnString := ""
+
+	if static != "" {
+		returnString = static
+	} else {
+		if prefix != "" {
+			returnString += prefix + delim
+		}
+		for i := 0; i < len(fields); i++ {
+			returnString += fmt.Sprintf("${%v}", fields[i])
+			if i < (len(fields) - 1) {
+				returnString += delim
+			}
+		}
+	}
+
+	return returnString
+}
+
+// getNamingStruct returns the naming convention for the resource, `config/naming.json` takes precedence over the
+// built in conventions with anything not listed in either falling back to the default
+func (gen documentationGenerator) getNamingStruct(resourceName string, isDataSource bool) namingStruct {
+
+	resourceSpecificNaming := map[string]namingStruct{
+		"terraform_azurerm":       {Delimiter: "-", StaticName: "terraform_azurerm"},
+		"azurerm_storage_account": {Delimiter: "", Fields: defaultNaming.Fields},
+	}
+
+	fallback := defaultNaming
+	if gen.naming.Default != nil {
+		fallback = gen.naming.Default.toNamingStruct(defaultNaming)
+	}
+
+	if isDataSource {
+		dataSourceSpecificNaming := map[string]namingStruct{
+			"azurerm_subnet":                {Prefix: "ds"},
+			"azurerm_key_vault_certificate": {Prefix: "ds"},
+		}
+
+		naming := fallback
+		configured, isConfigured := gen.naming.DataSources[resourceName]
+		if isConfigured {
+			naming = configured.toNamingStruct(fallback)
+		} else if specific, ok := dataSourceSpecificNaming[resourceName]; ok {
+			naming.Prefix = specific.Prefix
+		}
+		if naming.Prefix == "" {
+			naming.Prefix = gen.dataSourcePrefix()
+		}
+		if naming.Lookup == "" {
+			naming.Lookup = gen.dataSourceLookup()
+		}
+		naming.IsDataSource = true
+
+		return naming
+	}
+
+	naming, ok := resourceSpecificNaming[resourceName]
+	configured, isConfigured := gen.naming.Resources[resourceName]
+	if isConfigured {
+		naming = configured.toNamingStruct(fallback)
+	} else if !ok {
+		naming = fallback
+	}
+
+	if isConfigured && configured.Transforms != nil {
+		naming.Transforms = *configured.Transforms
+	} else if rule, ok := lookupNameRule(resourceName); ok {
+		naming.Transforms = rule.transforms()
+	}
+
+	return naming
+}
+
+// dataSourcePrefix returns the prefix for data source names, none unless configured
+func (gen documentationGenerator) dataSourcePrefix() string {
+	if gen.naming.DataSourcePrefix != nil {
+		return *gen.naming.DataSourcePrefix
+	}
+
+	return ""
+}
+
+// dataSourceLookup works out how the data source finds what it reads from its required arguments
+func (gen documentationGenerator) dataSourceLookup() string {
+	if gen.resource == nil {
+		return dataSourceLookupName
+	}
+
+	if s, ok := gen.resource.Schema["resource_group_name"]; ok && s.Required {
+		return dataSourceLookupResourceGroup
+	}
+
+	for k, s := range gen.resource.Schema {
+		if s.Required && strings.HasSuffix(k, "_id") {
+			return dataSourceLookupId
+		}
+	}
+
+	return dataSourceLookupName
+}
+
+// dataSourceNameConvention returns the convention for `dlta_terraform_data_source_name`, data sources within a
+// resource group are named from the naming fields whereas those found by a parent id or by name alone take the name
+// they look up as the naming fields don't identify them
+func (gen documentationGenerator) dataSourceNameConvention() string {
+	naming := gen.getNamingStruct(gen.resourceName, true)
+
+	if naming.StaticName != "" || naming.Lookup == dataSourceLookupResourceGroup {
+		return gen.getResourceNamingConvention(gen.resourceName, true)
+	}
+
+	if naming.Prefix == "" {
+		return "${name}"
+	}
+
+	return naming.Prefix + naming.Delimiter + "${name}"
+}
+
+// toNamingStruct fills in anything not set on the entry from fallback
+func (e namingConfigEntry) toNamingStruct(fallback namingStruct) namingStruct {
+	naming := namingStruct{
+		Delimiter:  fallback.Delimiter,
+		StaticName: e.StaticName,
+		Prefix:     e.Prefix,
+		Fields:     fallback.Fields,
+		Lookup:     e.Lookup,
+	}
+
+	if e.Delimiter != nil {
+		naming.Delimiter = *e.Delimiter
+	}
+	if len(e.Fields) > 0 {
+		naming.Fields = e.Fields
+	}
+
+	return naming
+}
+
+// applyOverride returns naming with anything set on the entry replacing it
+func (e namingConfigEntry) applyOverride(naming namingStruct) namingStruct {
+	if e.Transforms != nil {
+		naming.Transforms = *e.Transforms
+	}
+	if e.Delimiter != nil {
+		naming.Delimiter = *e.Delimiter
+	}
+	if e.StaticName != "" {
+		naming.StaticName = e.StaticName
+	}
+	if e.Prefix != "" {
+		naming.Prefix = e.Prefix
+	}
+	if len(e.Fields) > 0 {
+		naming.Fields = e.Fields
+	}
+
+	return naming
+}
+
+// getEnvironmentNaming returns the naming conventions which replace the resource's for a `dlta_environment_char`,
+// the default environment overrides apply first so a resource can override them again
+func (gen documentationGenerator) getEnvironmentNaming(resourceName string, isDataSource bool) map[string]namingStruct {
+
+	environments := make(map[string]namingStruct)
+	base := gen.getNamingStruct(resourceName, isDataSource)
+
+	configured := gen.naming.Resources[resourceName]
+	if isDataSource {
+		configured = gen.naming.DataSources[resourceName]
+	}
+
+	var defaults map[string]namingConfigEntry
+	if gen.naming.Default != nil {
+		defaults = gen.naming.Default.Environments
+	}
+
+	for _, o := range dlta_environment_char_options {
+		defaultOverride, hasDefault := defaults[o.Value]
+		override, hasOverride := configured.Environments[o.Value]
+		if !hasDefault && !hasOverride {
+			continue
+		}
+
+		naming := defaultOverride.applyOverride(base)
+		environments[o.Value] = override.applyOverride(naming)
+	}
+
+	return environments
+}
+
+// readNamingConfig reads `config/naming.json`, the fields of each convention must be dlta naming variables as these
+// are the only variables the generated modules declare for naming
+func (gen documentationGenerator) readNamingConfig() (namingConfig, error) {
+
+	var config namingConfig
+	if _, err := gen.readDltaConfig("naming.json", &config); err != nil {
+		return config, err
+	}
+
+	entries := make(map[string]namingConfigEntry)
+	if config.Default != nil {
+		entries["default"] = *config.Default
+	}
+	for k, e := range config.Resources {
+		entries["resources."+k] = e
+	}
+	for k, e := range config.DataSources {
+		entries["data_sources."+k] = e
+	}
+
+	switch config.Provider {
+	case "", "token", "azurecaf":
+	case "webhook":
+		u, err := url.Parse(config.WebhookUrl)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return config, fmt.Errorf("naming.json: `webhook_url` must be an http(s) url when the provider is `webhook`, got %q", config.WebhookUrl)
+		}
+	default:
+		return config, fmt.Errorf("naming.json: `provider` must be either `token`, `azurecaf` or `webhook`, got %q", config.Provider)
+	}
+
+	overrides := make(map[string]namingConfigEntry)
+	for k, e := range entries {
+		for environment, override := range e.Environments {
+			if !isEnvironmentChar(environment) {
+				return config, fmt.Errorf("naming.json: %s: environments: %q is not a `dlta_environment_char`", k, environment)
+			}
+			overrides[fmt.Sprintf("%s.environments.%s", k, environment)] = override
+		}
+	}
+	for k, e := range overrides {
+		entries[k] = e
+	}
+
+	if config.InstanceId != nil {
+		switch config.InstanceId.Mode {
+		case "", instanceIdStatic, instanceIdRegistry:
+		case instanceIdQuery:
+			if config.InstanceId.Query == "" {
+				return config, fmt.Errorf("naming.json: instance_id: `query` is required when the mode is `%s`", instanceIdQuery)
+			}
+		default:
+			return config, fmt.Errorf("naming.json: instance_id: `mode` must be either `%s`, `%s` or `%s`, got %q", instanceIdStatic, instanceIdRegistry, instanceIdQuery, config.InstanceId.Mode)
+		}
+	}
+
+	if config.UniqueSuffix != nil {
+		if mode := config.UniqueSuffix.Mode; mode != "" && mode != "hash" && mode != "random" {
+			return config, fmt.Errorf("naming.json: unique_suffix: `mode` must be either `hash` or `random`, got %q", mode)
+		}
+		if length := config.UniqueSuffix.Length; length < 0 || length > 16 {
+			return config, fmt.Errorf("naming.json: unique_suffix: `length` must be between 1 and 16, got %d", length)
+		}
+	}
+
+	for name := range config.Tokens {
+		if !namingTokenRegex.MatchString(name) {
+			return config, fmt.Errorf("naming.json: tokens: %q must start with `dlta_` and contain only lowercase letters, numbers and underscores", name)
+		}
+		if isNamingField(name, nil) || templateOnlyAttributes[name] {
+			return config, fmt.Errorf("naming.json: tokens: %q is a built in field", name)
+		}
+	}
+
+	for k, e := range entries {
+		if e.Transforms != nil && e.Transforms.MaxLength < 0 {
+			return config, fmt.Errorf("naming.json: %s: transforms: `max_length` can't be negative, got %d", k, e.Transforms.MaxLength)
+		}
+		if e.Lookup != "" && e.Lookup != dataSourceLookupResourceGroup && e.Lookup != dataSourceLookupId && e.Lookup != dataSourceLookupName {
+			return config, fmt.Errorf("naming.json: %s: `lookup` must be either `%s`, `%s` or `%s`, got %q", k, dataSourceLookupResourceGroup, dataSourceLookupId, dataSourceLookupName, e.Lookup)
+		}
+		for _, field := range e.Fields {
+			if !isNamingField(field, config.Tokens) {
+				return config, fmt.Errorf("naming.json: %s: %q is not a naming field, expected one of %s or a token", k, field, strings.Join(defaultNaming.Fields, ", "))
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// nameAvailabilityCheck describes how to check a globally unique name is free, either with the ARM
+// checkNameAvailability API of the resource provider or by resolving the DNS name the resource would be given
+type nameAvailabilityCheck struct {
+	ResourceType string
+	Provider     string
+	ApiVersion   string
+	DnsSuffix    string
+}
+
+// nameAvailabilityChecks are the globally unique resource types which names can be checked for
+var nameAvailabilityChecks = map[string]nameAvailabilityCheck{
+	"azurerm_container_registry":   {ResourceType: "Microsoft.ContainerRegistry/registries", Provider: "Microsoft.ContainerRegistry", ApiVersion: "2023-07-01", DnsSuffix: "azurecr.io"},
+	"azurerm_key_vault":            {ResourceType: "Microsoft.KeyVault/vaults", Provider: "Microsoft.KeyVault", ApiVersion: "2022-07-01", DnsSuffix: "vault.azure.net"},
+	"azurerm_linux_function_app":   {ResourceType: "Microsoft.Web/sites", Provider: "Microsoft.Web", ApiVersion: "2022-09-01", DnsSuffix: "azurewebsites.net"},
+	"azurerm_linux_web_app":        {ResourceType: "Microsoft.Web/sites", Provider: "Microsoft.Web", ApiVersion: "2022-09-01", DnsSuffix: "azurewebsites.net"},
+	"azurerm_storage_account":      {ResourceType: "Microsoft.Storage/storageAccounts", Provider: "Microsoft.Storage", ApiVersion: "2023-01-01", DnsSuffix: "blob.core.windows.net"},
+	"azurerm_windows_function_app": {ResourceType: "Microsoft.Web/sites", Provider: "Microsoft.Web", ApiVersion: "2022-09-01", DnsSuffix: "azurewebsites.net"},
+	"azurerm_windows_web_app":      {ResourceType: "Microsoft.Web/sites", Provider: "Microsoft.Web", ApiVersion: "2022-09-01", DnsSuffix: "azurewebsites.net"},
+}
+
+// path returns the ARM checkNameAvailability path for the subscription
+func (c nameAvailabilityCheck) path(subscriptionId string) string {
+	return fmt.Sprintf("/subscriptions/%s/providers/%s/checkNameAvailability?api-version=%s", url.PathEscape(subscriptionId), c.Provider, c.ApiVersion)
+}
+
+// validator returns the palette validator the canvas uses to check the name, `{subscriptionId}` is filled in by the canvas
+func (c nameAvailabilityCheck) validator() NameValue {
+	return NameValue{
+		"resourceType": c.ResourceType,
+		"path":         fmt.Sprintf("/subscriptions/{subscriptionId}/providers/%s/checkNameAvailability?api-version=%s", c.Provider, c.ApiVersion),
+		"dnsSuffix":    c.DnsSuffix,
+	}
+}
+
+// nameAvailability is the response of the ARM checkNameAvailability APIs
+type nameAvailability struct {
+	NameAvailable bool   `json:"nameAvailable"`
+	Reason        string `json:"reason"`
+	Message       string `json:"message"`
+}
+
+// checkNameAvailability checks `-check-name` is free for the resource type, with ARM when a subscription is given
+// and otherwise by resolving its DNS name
+func (gen documentationGenerator) checkNameAvailability(ctx context.Context) error {
+
+	check, ok := nameAvailabilityChecks[gen.resourceName]
+	if !ok {
+		return fmt.Errorf("names of %s are not globally unique, name availability can only be checked for %s", gen.resourceName, strings.Join(sortedKeys(nameAvailabilityChecks), ", "))
+	}
+	if gen.checkName == "" {
+		return fmt.Errorf("the name to check must be specified via `-check-name`")
+	}
+
+	availability, err := gen.lookupNameAvailability(ctx, check)
+	if err != nil {
+		return fmt.Errorf("checking the availability of %q: %+v", gen.checkName, err)
+	}
+
+	if !availability.NameAvailable {
+		return fmt.Errorf("%q is not available for %s: %s %s", gen.checkName, gen.resourceName, availability.Reason, availability.Message)
+	}
+
+	color.Green("%q is available for %s", gen.checkName, gen.resourceName)
+	return nil
+}
+
+// lookupNameAvailability checks with ARM when a subscription is given, authenticating with the Azure CLI
+func (gen documentationGenerator) lookupNameAvailability(ctx context.Context, check nameAvailabilityCheck) (*nameAvailability, error) {
+
+	if gen.subscriptionId == "" {
+		return dnsNameAvailability(net.LookupHost, check, gen.checkName)
+	}
+
+	environment := environments.AzurePublic()
+	endpoint, ok := environment.ResourceManager.Endpoint()
+	if !ok {
+		return nil, fmt.Errorf("the Resource Manager endpoint isn't defined")
+	}
+
+	authorizer, err := auth.NewAzureCliAuthorizer(ctx, auth.AzureCliAuthorizerOptions{Api: environment.ResourceManager})
+	if err != nil {
+		return nil, fmt.Errorf("authenticating with the Azure CLI: %+v", err)
+	}
+
+	return armNameAvailability(ctx, http.DefaultClient, authorizer, *endpoint, check, gen.subscriptionId, gen.checkName)
+}
+
+// armNameAvailability calls the resource provider's checkNameAvailability API on the Resource Manager endpoint
+func armNameAvailability(ctx context.Context, client *http.Client, authorizer auth.Authorizer, endpoint string, check nameAvailabilityCheck, subscriptionId string, name string) (*nameAvailability, error) {
+
+	body, err := json.Marshal(map[string]string{"name": name, "type": check.ResourceType})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+check.path(subscriptionId), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	token, err := authorizer.Token(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("obtaining a token: %+v", err)
+	}
+	token.SetAuthHeader(request)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", response.StatusCode, request.URL.Path)
+	}
+
+	var availability nameAvailability
+	if err := json.NewDecoder(response.Body).Decode(&availability); err != nil {
+		return nil, fmt.Errorf("parsing the response: %+v", err)
+	}
+
+	return &availability, nil
+}
+
+// liveOptionSource lists the options of a palette control with a Resource Manager API, `%s` in the path is replaced
+// with the subscription
+type liveOptionSource struct {
+	Path    string
+	extract func(body []byte) ([]string, error)
+}
+
+// liveOptionSources are the palette controls whose options can be fetched from Azure with `-refresh-options`, keyed
+// by resource path or `location` for the location of any resource
+var liveOptionSources = map[string]liveOptionSource{
+	"location":                             {Path: "/subscriptions/%s/locations?api-version=2022-12-01", extract: extractLocations},
+	"azurerm_linux_virtual_machine.size":   {Path: computeSkusPath, extract: extractVirtualMachineSizes},
+	"azurerm_service_plan.sku_name":        {Path: "/subscriptions/%s/providers/Microsoft.Web/skus?api-version=2022-03-01", extract: extractWebSkus},
+	"azurerm_windows_virtual_machine.size": {Path: computeSkusPath, extract: extractVirtualMachineSizes},
+}
+
+const computeSkusPath = "/subscriptions/%s/providers/Microsoft.Compute/skus?api-version=2021-07-01"
+
+// optionsCacheTTL is how long fetched options are used before `-refresh-options` fetches them again
+const optionsCacheTTL = 24 * time.Hour
+
+// cachedOptions are the options of a live option source, cached in `cache/options.json` within the dlta path
+type cachedOptions struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Options   []string  `json:"options"`
+}
+
+// loadLiveOptions returns the cached options of the sources used by the resource, with `-refresh-options` any stale
+// sources are fetched and the cache updated. Controls without cached options keep their built in options
+func (gen documentationGenerator) loadLiveOptions(ctx context.Context, fetch func(ctx context.Context, source liveOptionSource) ([]string, error)) (map[string][]string, error) {
+
+	cachePath := filepath.Join(gen.dltaPath, "cache", "options.json")
+	cache := make(map[string]cachedOptions)
+	if content, err := os.ReadFile(cachePath); err == nil {
+		if err := json.Unmarshal(content, &cache); err != nil {
+			return nil, fmt.Errorf("parsing %s: %+v", cachePath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s: %+v", cachePath, err)
+	}
+
+	liveOptions := make(map[string][]string)
+	isChanged := false
+	for _, key := range sortedKeys(liveOptionSources) {
+		if key != "location" && !strings.HasPrefix(key, gen.resourceName+".") {
+			continue
+		}
+
+		cached, ok := cache[key]
+		if gen.refreshOptions && (!ok || time.Since(cached.FetchedAt) > optionsCacheTTL) {
+			options, err := fetch(ctx, liveOptionSources[key])
+			if err != nil {
+				return nil, fmt.Errorf("fetching the options of %s: %+v", key, err)
+			}
+			cached = cachedOptions{FetchedAt: time.Now().UTC(), Options: options}
+			cache[key] = cached
+			ok = true
+			isChanged = true
+		}
+
+		if ok {
+			liveOptions[key] = cached.Options
+		}
+	}
+
+	if isChanged {
+		if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("creating %s: %+v", filepath.Dir(cachePath), err)
+		}
+		if err := writeFileAtomically(cachePath, []byte(writeJson(cache))); err != nil {
+			return nil, fmt.Errorf("writing %s: %+v", cachePath, err)
+		}
+	}
+
+	return liveOptions, nil
+}
+
+// schemaCacheFormat is bumped when what's cached changes, so caches written before are read from the provider again
+// rather than missing it e.g. format 2 added the resource's timeouts, format 3 the azurerm ID an attribute is parsed as
+const schemaCacheFormat = 3
+
+// schemaCacheEntry is the schema of a resource or data source as cached in `cache/schema/<provider version>`
+type schemaCacheEntry struct {
+	Format            int                      `json:"format"`
+	ProviderVersion   string                   `json:"provider_version"`
+	ServiceName       string                   `json:"service_name"`
+	WebsiteCategories []string                 `json:"website_categories"`
+	Schema            map[string]*cachedSchema `json:"schema"`
+	Timeouts          map[string]string        `json:"timeouts,omitempty"`
+}
+
+// cachedSchema is the serialisable part of a *schema.Schema. The validation functions and DefaultFunc can't be
+// serialised so what was read from them is cached instead and they're rebuilt from it, giving the same attributes
+type cachedSchema struct {
+	Type          string                   `json:"type"`
+	Description   string                   `json:"description,omitempty"`
+	Required      bool                     `json:"required,omitempty"`
+	Optional      bool                     `json:"optional,omitempty"`
+	Computed      bool                     `json:"computed,omitempty"`
+	ForceNew      bool                     `json:"force_new,omitempty"`
+	Sensitive     bool                     `json:"sensitive,omitempty"`
+	Deprecated    string                   `json:"deprecated,omitempty"`
+	MaxItems      int                      `json:"max_items,omitempty"`
+	MinItems      int                      `json:"min_items,omitempty"`
+	ConflictsWith []string                 `json:"conflicts_with,omitempty"`
+	ExactlyOneOf  []string                 `json:"exactly_one_of,omitempty"`
+	AtLeastOneOf  []string                 `json:"at_least_one_of,omitempty"`
+	RequiredWith  []string                 `json:"required_with,omitempty"`
+	Default       string                   `json:"default,omitempty"`
+	DefaultSource string                   `json:"default_source,omitempty"`
+	DefaultEnv    []string                 `json:"default_env,omitempty"`
+	Constraints   constraints              `json:"constraints"`
+	ElemSchema    *cachedSchema            `json:"elem_schema,omitempty"`
+	ElemResource  map[string]*cachedSchema `json:"elem_resource,omitempty"`
+}
+
+// schemaCachePath returns the path the schema of the resource is cached at
+func (gen documentationGenerator) schemaCachePath() string {
+	return gen.schemaCachePathFor(version.ProviderVersion)
+}
+
+// schemaCachePathFor returns where the schema read from a version of the provider is cached
+func (gen documentationGenerator) schemaCachePathFor(providerVersion string) string {
+	kind := "resource"
+	if !gen.isResource {
+		kind = "data"
+	}
+	return filepath.Join(gen.dltaPath, "cache", "schema", providerVersion, kind, gen.resourceName+".json")
+}
+
+// readSchemaCache sets the resource's schema from the cache, returning false when it must be read from the provider
+// (a cache which can't be read is replaced)
+func (gen *documentationGenerator) readSchemaCache() bool {
+
+	if gen.refreshSchema {
+		return false
+	}
+
+	cachePath := gen.schemaCachePath()
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("readSchemaCache \"read error\": %s: %+v\n", cachePath, err)
+		}
+		return false
+	}
+
+	var entry schemaCacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil || entry.ProviderVersion != version.ProviderVersion || entry.Format != schemaCacheFormat {
+		fmt.Printf("readSchemaCache \"invalid cache\": %s\n", cachePath)
+		return false
+	}
+
+	gen.resource = &schema.Resource{Schema: make(map[string]*schema.Schema), Timeouts: entry.resourceTimeouts()}
+	for n, c := range entry.Schema {
+		gen.resource.Schema[n] = c.toSchema()
+	}
+	gen.serviceName = entry.ServiceName
+	gen.websiteCategories = entry.WebsiteCategories
+
+	return true
+}
+
+// cachedTimeouts returns the resource's timeouts keyed by operation e.g. `{"create": "30m0s"}`
+func cachedTimeouts(t *schema.ResourceTimeout) map[string]string {
+	if t == nil {
+		return nil
+	}
+
+	timeouts := make(map[string]string)
+	for operation, d := range map[string]*time.Duration{"create": t.Create, "read": t.Read, "update": t.Update, "delete": t.Delete} {
+		if d != nil {
+			timeouts[operation] = d.String()
+		}
+	}
+
+	return timeouts
+}
 
-	switch name {
-	case "name":
-		if len(at.PossibleOptions) > 0 || len(at.PossibleValues) > 0 { // This is not a generated name
+// resourceTimeouts returns the resource's timeouts read from the cache
+func (entry schemaCacheEntry) resourceTimeouts() *schema.ResourceTimeout {
+	if entry.Timeouts == nil {
+		return nil
+	}
 
-			vn := genVariableNameFromResourcePath(at.ResourcePath)
-			flattenName = ""
-			pp.ID = vn
-			pp.Type = translateDataType(at.DataTypeString)
-			pp.Name = convertNameToLabel(vn)
-			pp.Disabled = false
-			pp.FlattenName = &flattenName
-			pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
+	parse := func(operation string) *time.Duration {
+		if v, ok := entry.Timeouts[operation]; ok {
+			if d, err := time.ParseDuration(v); err == nil {
+				return &d
+			}
+		}
+		return nil
+	}
 
-			if len(at.PossibleValues) > 0 {
+	return &schema.ResourceTimeout{Create: parse("create"), Read: parse("read"), Update: parse("update"), Delete: parse("delete")}
+}
 
-				var keyVal KeyValue
+// writeSchemaCache caches the schema read from the provider
+func (gen documentationGenerator) writeSchemaCache() error {
 
-				for i := 0; i < len(at.PossibleValues); i++ {
-					keyVal.Key = at.PossibleValues[i]
-					keyVal.Value = at.PossibleValues[i]
+	entry := schemaCacheEntry{
+		Format:            schemaCacheFormat,
+		ProviderVersion:   version.ProviderVersion,
+		ServiceName:       gen.serviceName,
+		WebsiteCategories: gen.websiteCategories,
+		Schema:            make(map[string]*cachedSchema),
+		Timeouts:          cachedTimeouts(gen.resource.Timeouts),
+	}
+	for n, s := range gen.resource.Schema {
+		entry.Schema[n] = newCachedSchema(s)
+	}
 
-					pp.Options = append(pp.Options, keyVal)
-				}
+	cachePath := gen.schemaCachePath()
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return fmt.Errorf("creating %s: %+v", filepath.Dir(cachePath), err)
+	}
+	if err := writeFileAtomically(cachePath, []byte(writeJson(entry))); err != nil {
+		return fmt.Errorf("writing %s: %+v", cachePath, err)
+	}
 
-				if at.DataTypeString == schema.TypeList.String() {
-					pp.Type = "checkboxes"
-					var a []string
-					pp.CurrentValue = a
-				} else {
-					pp.Type = "select"
-					pp.CurrentValue = ""
-				}
+	return nil
+}
 
-			} else {
-				fmt.Printf("FSNAME: %v\n", name)
+// schemaChanges are the differences in the input attributes of a resource between two versions of the provider
+type schemaChanges struct {
+	Added           []string
+	Removed         []string
+	NewlyRequired   []string
+	NewlyDeprecated []string
+}
+
+// readSchemaVersion returns the schema of the resource in a version of the provider, from the schema cache written
+// when that version scaffolded the resource or from a `terraform providers schema -json` output
+func (gen documentationGenerator) readSchemaVersion(from string) (*schema.Resource, error) {
+
+	if info, err := os.Stat(from); err == nil && !info.IsDir() {
+		resource, _, err := readProviderSchema(from, gen.resourceName, gen.isResource)
+		return resource, err
+	}
+
+	cachePath := gen.schemaCachePathFor(from)
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("there's no schema cached for provider %s at %s, scaffold %s with that version or use the output of `terraform providers schema -json`", from, cachePath, gen.resourceName)
+		}
+		return nil, fmt.Errorf("reading %s: %+v", cachePath, err)
+	}
+
+	var entry schemaCacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("parsing %s: %+v", cachePath, err)
+	}
+
+	resource := &schema.Resource{Schema: make(map[string]*schema.Schema)}
+	for n, c := range entry.Schema {
+		resource.Schema[n] = c.toSchema()
+	}
+
+	return resource, nil
+}
+
+// schemaDiff compares the input attributes of two schemas of the resource, nested attributes are compared by path
+func (gen documentationGenerator) schemaDiff(from *schema.Resource, to *schema.Resource) schemaChanges {
+
+	before := flattenAttributes(gen.getAllInputAttributes(from.Schema, attribute{}, false, gen.resourceName))
+	after := flattenAttributes(gen.getAllInputAttributes(to.Schema, attribute{}, false, gen.resourceName))
+
+	changes := schemaChanges{}
+	for _, rp := range sortedKeys(after) {
+		a := after[rp]
+		b, ok := before[rp]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, rp)
+		case a.Required && !b.Required:
+			changes.NewlyRequired = append(changes.NewlyRequired, rp)
+		}
+		if ok && a.Deprecated != "" && b.Deprecated == "" {
+			changes.NewlyDeprecated = append(changes.NewlyDeprecated, rp)
+		}
+	}
+	for _, rp := range sortedKeys(before) {
+		if _, ok := after[rp]; !ok {
+			changes.Removed = append(changes.Removed, rp)
+		}
+	}
+
+	return changes
+}
+
+// printSchemaDiff reports the attributes added, removed, newly required and newly deprecated between `-from` and
+// `-to` (or the loaded provider), which decide whether the modules of the resource need upgrading
+func (gen *documentationGenerator) printSchemaDiff() error {
+
+	from, err := gen.readSchemaVersion(gen.schemaFrom)
+	if err != nil {
+		return err
+	}
+
+	toVersion := gen.schemaTo
+	var to *schema.Resource
+	if toVersion == "" {
+		if err := gen.lookupResource(); err != nil {
+			return err
+		}
+		to = gen.resource
+		toVersion = version.ProviderVersion
+	} else if to, err = gen.readSchemaVersion(toVersion); err != nil {
+		return err
+	}
+
+	changes := gen.schemaDiff(from, to)
+	if len(changes.Added)+len(changes.Removed)+len(changes.NewlyRequired)+len(changes.NewlyDeprecated) == 0 {
+		color.Green("The attributes of %s are unchanged from %s to %s", gen.resourceName, gen.schemaFrom, toVersion)
+		return nil
+	}
+
+	fmt.Printf("Attributes of %s (%s -> %s):\n", gen.resourceName, gen.schemaFrom, toVersion)
+	for _, section := range []struct {
+		title string
+		paths []string
+		print func(format string, a ...interface{})
+	}{
+		{"Added", changes.Added, color.Green},
+		{"Removed", changes.Removed, color.Red},
+		{"Newly required", changes.NewlyRequired, color.Yellow},
+		{"Newly deprecated", changes.NewlyDeprecated, color.Yellow},
+	} {
+		if len(section.paths) == 0 {
+			continue
+		}
+		fmt.Printf("%s:\n", section.title)
+		for _, rp := range section.paths {
+			section.print("  %s", rp)
+		}
+	}
+
+	return nil
+}
+
+// newCachedSchema returns the cache entry of a schema, a block which contains one of the blocks it's within is left out
+// as it can't be written out
+func newCachedSchema(s *schema.Schema, ancestors ...*schema.Resource) *cachedSchema {
+
+	var a attribute
+	cloneSchemaToAttributes(&a, s, false, "", "")
+
+	c := &cachedSchema{
+		Type:          s.Type.String(),
+		Description:   s.Description,
+		Required:      s.Required,
+		Optional:      s.Optional,
+		Computed:      s.Computed,
+		ForceNew:      s.ForceNew,
+		Sensitive:     s.Sensitive,
+		Deprecated:    s.Deprecated,
+		MaxItems:      s.MaxItems,
+		MinItems:      s.MinItems,
+		ConflictsWith: s.ConflictsWith,
+		ExactlyOneOf:  s.ExactlyOneOf,
+		AtLeastOneOf:  s.AtLeastOneOf,
+		RequiredWith:  s.RequiredWith,
+		Default:       a.Default,
+		DefaultSource: a.DefaultSource,
+		DefaultEnv:    a.DefaultEnv,
+		Constraints:   getSchemaConstraints(s),
+	}
+
+	switch elem := s.Elem.(type) {
+	case *schema.Schema:
+		c.ElemSchema = newCachedSchema(elem)
+	case *schema.Resource:
+		for _, ancestor := range ancestors {
+			if ancestor == elem {
+				fmt.Printf("newCachedSchema \"cycle\": a block contains itself, it's left out of the cache\n")
+				return c
 			}
+		}
+		c.ElemResource = make(map[string]*cachedSchema)
+		for n, nested := range elem.Schema {
+			c.ElemResource[n] = newCachedSchema(nested, append(ancestors, elem)...)
+		}
+	}
 
-			if at.DataTypeString == "TypeBool" {
-				pp.Type = "checkbox"
+	return c
+}
+
+// toSchema rebuilds the schema, with validation functions and a default giving back what was cached
+func (c *cachedSchema) toSchema() *schema.Schema {
+
+	s := &schema.Schema{
+		Description:   c.Description,
+		Required:      c.Required,
+		Optional:      c.Optional,
+		Computed:      c.Computed,
+		ForceNew:      c.ForceNew,
+		Sensitive:     c.Sensitive,
+		Deprecated:    c.Deprecated,
+		MaxItems:      c.MaxItems,
+		MinItems:      c.MinItems,
+		ConflictsWith: c.ConflictsWith,
+		ExactlyOneOf:  c.ExactlyOneOf,
+		AtLeastOneOf:  c.AtLeastOneOf,
+		RequiredWith:  c.RequiredWith,
+	}
+	for _, t := range []schema.ValueType{schema.TypeBool, schema.TypeInt, schema.TypeFloat, schema.TypeString, schema.TypeList, schema.TypeMap, schema.TypeSet} {
+		if t.String() == c.Type {
+			s.Type = t
+		}
+	}
+	s.ValidateFunc = c.Constraints.validateFunc(s.Type)
+
+	defaultValue := typedValue(s.Type, c.Default)
+	switch c.DefaultSource {
+	case defaultSourceSchema:
+		s.Default = defaultValue
+	case defaultSourceFunc:
+		s.DefaultFunc = func() (interface{}, error) {
+			return defaultValue, nil
+		}
+	case defaultSourceEnvironment:
+		s.DefaultFunc = schema.MultiEnvDefaultFunc(c.DefaultEnv, defaultValue)
+	}
+
+	if c.ElemSchema != nil {
+		s.Elem = c.ElemSchema.toSchema()
+	} else if c.ElemResource != nil {
+		elem := &schema.Resource{Schema: make(map[string]*schema.Schema)}
+		for n, nested := range c.ElemResource {
+			elem.Schema[n] = nested.toSchema()
+		}
+		s.Elem = elem
+	}
+
+	return s
+}
+
+// typedValue parses a value formatted with fmt.Sprint back into the type of the schema
+func typedValue(t schema.ValueType, value string) interface{} {
+	switch t {
+	case schema.TypeBool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case schema.TypeInt:
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	case schema.TypeFloat:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// armOptionsFetcher returns a fetcher which authenticates with the Azure CLI the first time it's used
+func (gen documentationGenerator) armOptionsFetcher() func(ctx context.Context, source liveOptionSource) ([]string, error) {
+
+	var authorizer auth.Authorizer
+	return func(ctx context.Context, source liveOptionSource) ([]string, error) {
+		environment := environments.AzurePublic()
+		endpoint, ok := environment.ResourceManager.Endpoint()
+		if !ok {
+			return nil, fmt.Errorf("the Resource Manager endpoint isn't defined")
+		}
+
+		if authorizer == nil {
+			a, err := auth.NewAzureCliAuthorizer(ctx, auth.AzureCliAuthorizerOptions{Api: environment.ResourceManager})
+			if err != nil {
+				return nil, fmt.Errorf("authenticating with the Azure CLI: %+v", err)
 			}
+			authorizer = a
 		}
-	case "AssetType":
-		flattenName = "AssetType"
-		pp.ID = "AssetType"
-		pp.Type = "string"
-		pp.Name = "Asset Type:"
-		pp.Disabled = true
-		pp.FlattenName = &flattenName
-		pp.CurrentValue = gen.resourceName
-	case "Name": // This is the name of the asset as dropped onto the canvas
-		validators := make(NameValue)
-		validators["required"] = true
-		validators["minLength"] = 3
-		flattenName = "Name"
-		pp.ID = "name"
-		pp.Type = "string"
-		pp.Name = "Name:"
-		pp.Disabled = true
-		pp.FlattenName = &flattenName
-		pp.CurrentValue = initiaiseAttribute("TypeString")
-		pp.Validators = validators
-	case "location":
-		flattenName = ""
-		pp.ID = name
-		pp.Type = translateDataType(at.DataTypeString)
-		pp.Name = convertNameToLabel(name)
-		pp.Disabled = false
-		pp.FlattenName = &flattenName
-		pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
 
-		for i := 0; i < len(dlta_location_options); i++ {
-			pp.Options = append(pp.Options, dlta_location_options[i])
+		return armListOptions(ctx, http.DefaultClient, authorizer, *endpoint, source, gen.subscriptionId)
+	}
+}
+
+// armListOptions calls the source's API on the Resource Manager endpoint, following `nextLink` through the pages
+func armListOptions(ctx context.Context, client *http.Client, authorizer auth.Authorizer, endpoint string, source liveOptionSource, subscriptionId string) ([]string, error) {
+
+	options := make([]string, 0)
+	seen := make(map[string]bool)
+	url := strings.TrimSuffix(endpoint, "/") + fmt.Sprintf(source.Path, subscriptionId)
+	for url != "" {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(dlta_location_options) > 0 {
-			pp.Type = "select"
+		token, err := authorizer.Token(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("obtaining a token: %+v", err)
 		}
+		token.SetAuthHeader(request)
 
-		pp.CurrentValue = dlta_location_options[0].Value
-	case "dlta_application_short_code":
-		flattenName = ""
-		pp.ID = name
-		pp.Type = translateDataType(at.DataTypeString)
-		pp.Name = convertNameToLabel(name)
-		pp.Disabled = false
-		pp.FlattenName = &flattenName
-		pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
-		for i := 0; i < len(dlta_application_short_code_options); i++ {
-			pp.Options = append(pp.Options, dlta_application_short_code_options[i])
+		response, err := client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d from %s", response.StatusCode, request.URL.Path)
+		}
+
+		page, err := source.extract(body)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the response: %+v", err)
+		}
+		for _, o := range page {
+			if !seen[o] {
+				seen[o] = true
+				options = append(options, o)
+			}
+		}
+
+		var next struct {
+			NextLink string `json:"nextLink"`
+		}
+		_ = json.Unmarshal(body, &next)
+		url = next.NextLink
+	}
+
+	sort.Strings(options)
+	return options, nil
+}
+
+// extractLocations returns the physical regions of the subscription
+func extractLocations(body []byte) ([]string, error) {
+	var response struct {
+		Value []struct {
+			Name     string `json:"name"`
+			Metadata struct {
+				RegionType string `json:"regionType"`
+			} `json:"metadata"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	locations := make([]string, 0)
+	for _, l := range response.Value {
+		if l.Metadata.RegionType == "" || l.Metadata.RegionType == "Physical" {
+			locations = append(locations, l.Name)
+		}
+	}
+
+	return locations, nil
+}
+
+// extractWebSkus returns the App Service plan SKUs
+func extractWebSkus(body []byte) ([]string, error) {
+	var response struct {
+		Skus []struct {
+			Name string `json:"name"`
+		} `json:"skus"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	skus := make([]string, 0)
+	for _, sku := range response.Skus {
+		skus = append(skus, sku.Name)
+	}
+
+	return skus, nil
+}
+
+// extractVirtualMachineSizes returns the virtual machine sizes from the compute resource SKUs
+func extractVirtualMachineSizes(body []byte) ([]string, error) {
+	var response struct {
+		Value []struct {
+			ResourceType string `json:"resourceType"`
+			Name         string `json:"name"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	sizes := make([]string, 0)
+	for _, sku := range response.Value {
+		if sku.ResourceType == "virtualMachines" {
+			sizes = append(sizes, sku.Name)
+		}
+	}
+
+	return sizes, nil
+}
+
+// dnsNameAvailability treats a name as taken when the DNS name the resource would be given resolves
+func dnsNameAvailability(lookupHost func(host string) ([]string, error), check nameAvailabilityCheck, name string) (*nameAvailability, error) {
+
+	host := fmt.Sprintf("%s.%s", name, check.DnsSuffix)
+	addresses, err := lookupHost(host)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return &nameAvailability{NameAvailable: true}, nil
+		}
+		return nil, err
+	}
+
+	if len(addresses) == 0 {
+		return &nameAvailability{NameAvailable: true}, nil
+	}
+
+	return &nameAvailability{NameAvailable: false, Reason: "AlreadyExists", Message: fmt.Sprintf("%s resolves", host)}, nil
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// nameRule is the constraint the cloud places on the name of a resource type, Characters is a regex character class
+type nameRule struct {
+	MinLength   int
+	MaxLength   int
+	Characters  string
+	Description string
+}
+
+// nameRules are the naming constraints for resource types, see
+// https://learn.microsoft.com/azure/azure-resource-manager/management/resource-name-rules
+var nameRules = map[string]nameRule{
+	"azurerm_container_registry":     {MinLength: 5, MaxLength: 50, Characters: "a-zA-Z0-9", Description: "letters and numbers"},
+	"azurerm_cosmosdb_account":       {MinLength: 3, MaxLength: 44, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"azurerm_key_vault":              {MinLength: 3, MaxLength: 24, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"azurerm_kubernetes_cluster":     {MinLength: 1, MaxLength: 63, Characters: "a-zA-Z0-9_-", Description: "letters, numbers, underscores and hyphens"},
+	"azurerm_linux_function_app":     {MinLength: 2, MaxLength: 60, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"azurerm_linux_web_app":          {MinLength: 2, MaxLength: 60, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"azurerm_mssql_server":           {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"azurerm_private_endpoint":       {MinLength: 2, MaxLength: 64, Characters: "a-zA-Z0-9._-", Description: "letters, numbers, periods, underscores and hyphens"},
+	"azurerm_resource_group":         {MinLength: 1, MaxLength: 90, Characters: "a-zA-Z0-9._()-", Description: "letters, numbers, periods, underscores, parentheses and hyphens"},
+	"azurerm_service_plan":           {MinLength: 1, MaxLength: 60, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"azurerm_storage_account":        {MinLength: 3, MaxLength: 24, Characters: "a-z0-9", Description: "lowercase letters and numbers"},
+	"azurerm_subnet":                 {MinLength: 1, MaxLength: 80, Characters: "a-zA-Z0-9._-", Description: "letters, numbers, periods, underscores and hyphens"},
+	"azurerm_virtual_network":        {MinLength: 2, MaxLength: 64, Characters: "a-zA-Z0-9._-", Description: "letters, numbers, periods, underscores and hyphens"},
+	"azurerm_windows_function_app":   {MinLength: 2, MaxLength: 60, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"azurerm_windows_web_app":        {MinLength: 2, MaxLength: 60, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"azurerm_cdn_frontdoor_endpoint": {MinLength: 1, MaxLength: 46, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+}
+
+// awsNameRules are the naming constraints for AWS resource types whose name is their `name` argument, resources
+// named by another argument such as aws_s3_bucket's `bucket` aren't named by the generator so have no rule
+var awsNameRules = map[string]nameRule{
+	"aws_cloudwatch_log_group": {MinLength: 1, MaxLength: 512, Characters: "a-zA-Z0-9_./#-", Description: "letters, numbers, underscores, periods, slashes, hashes and hyphens"},
+	"aws_dynamodb_table":       {MinLength: 3, MaxLength: 255, Characters: "a-zA-Z0-9_.-", Description: "letters, numbers, underscores, periods and hyphens"},
+	"aws_ecr_repository":       {MinLength: 2, MaxLength: 256, Characters: "a-z0-9_./-", Description: "lowercase letters, numbers, underscores, periods, slashes and hyphens"},
+	"aws_ecs_cluster":          {MinLength: 1, MaxLength: 255, Characters: "a-zA-Z0-9_-", Description: "letters, numbers, underscores and hyphens"},
+	"aws_eks_cluster":          {MinLength: 1, MaxLength: 100, Characters: "a-zA-Z0-9_-", Description: "letters, numbers, underscores and hyphens"},
+	"aws_iam_policy":           {MinLength: 1, MaxLength: 128, Characters: "a-zA-Z0-9+=,.@_-", Description: "letters, numbers and +=,.@_-"},
+	"aws_iam_role":             {MinLength: 1, MaxLength: 64, Characters: "a-zA-Z0-9+=,.@_-", Description: "letters, numbers and +=,.@_-"},
+	"aws_lb":                   {MinLength: 1, MaxLength: 32, Characters: "a-zA-Z0-9-", Description: "letters, numbers and hyphens"},
+	"aws_sns_topic":            {MinLength: 1, MaxLength: 256, Characters: "a-zA-Z0-9_-", Description: "letters, numbers, underscores and hyphens"},
+	"aws_sqs_queue":            {MinLength: 1, MaxLength: 80, Characters: "a-zA-Z0-9_-", Description: "letters, numbers, underscores and hyphens"},
+}
+
+// gcpNameRules are the naming constraints for Google Cloud resource types whose name is their `name` argument, most
+// follow RFC 1035 so are lowercase letters, numbers and hyphens
+var gcpNameRules = map[string]nameRule{
+	"google_cloud_run_service":       {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_cloudfunctions_function": {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_compute_firewall":        {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_compute_instance":        {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_compute_network":         {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_compute_subnetwork":      {MinLength: 1, MaxLength: 63, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_container_cluster":       {MinLength: 1, MaxLength: 40, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_kms_key_ring":            {MinLength: 1, MaxLength: 63, Characters: "a-zA-Z0-9_-", Description: "letters, numbers, underscores and hyphens"},
+	"google_pubsub_topic":            {MinLength: 3, MaxLength: 255, Characters: "a-zA-Z0-9_.~+-", Description: "letters, numbers and _.~+-"},
+	"google_sql_database_instance":   {MinLength: 1, MaxLength: 98, Characters: "a-z0-9-", Description: "lowercase letters, numbers and hyphens"},
+	"google_storage_bucket":          {MinLength: 3, MaxLength: 63, Characters: "a-z0-9_.-", Description: "lowercase letters, numbers, underscores, periods and hyphens"},
+}
+
+// lookupNameRule returns the naming constraint for the resource type from the rules of whichever cloud it belongs to
+func lookupNameRule(resourceName string) (nameRule, bool) {
+	for _, rules := range []map[string]nameRule{nameRules, awsNameRules, gcpNameRules} {
+		if rule, ok := rules[resourceName]; ok {
+			return rule, true
 		}
+	}
+
+	return nameRule{}, false
+}
+
+// namingFieldOptions are the values the palette offers for each naming field, used to work out how long a name can be
+var namingFieldOptions = map[string][]KeyValue{
+	"dlta_application_short_code": dlta_application_short_code_options,
+	"dlta_business_short_code":    dlta_business_short_code_options,
+	"dlta_environment_char":       dlta_environment_char_options,
+	"dlta_instance_id":            dlta_instance_id_options,
+	"dlta_location_short_code":    dlta_location_short_code_options,
+}
+
+// transforms returns the transforms which make a generated name satisfy the rule
+func (r nameRule) transforms() namingTransforms {
+	return namingTransforms{
+		Lowercase:    !strings.Contains(r.Characters, "A-Z"),
+		Alphanumeric: r.Characters == "a-z0-9" || r.Characters == "a-zA-Z0-9",
+		MaxLength:    r.MaxLength,
+	}
+}
+
+// getNameRule returns the name constraint for the resource, data sources look up existing names so have none
+func (gen documentationGenerator) getNameRule() (nameRule, bool) {
+	if gen.isDataSource || gen.azapiType != "" {
+		return nameRule{}, false
+	}
+
+	return lookupNameRule(gen.resourceName)
+}
+
+// namingFieldValidations validates a naming field only holds characters allowed in the resource's name
+func (gen documentationGenerator) namingFieldValidations(field string) []variableValidation {
+	validations := make([]variableValidation, 0)
+	if rule, ok := gen.getNameRule(); ok {
+		validations = append(validations, variableValidation{
+			Condition:    fmt.Sprintf("can(regex(\"^[%s]*$\", var.%s))", rule.Characters, field),
+			ErrorMessage: fmt.Sprintf("The %s name can only contain %s.", gen.resourceName, rule.Description),
+		})
+	}
+
+	if pattern, ok := gen.namingFieldPattern(field); ok {
+		validations = append(validations, variableValidation{
+			Condition:    fmt.Sprintf("can(regex(\"%s\", var.%s))", escapeHclString(pattern), field),
+			ErrorMessage: fmt.Sprintf("The %s must match %s to fit the %s naming convention.", field, pattern, gen.resourceName),
+		})
+	}
+
+	if len(validations) == 0 {
+		return nil
+	}
+
+	return validations
+}
+
+// namingFieldPattern returns the anchored regex a naming field's value must match, fields the palette offers as free
+// text aren't constrained beyond the name rule so have no pattern
+func (gen documentationGenerator) namingFieldPattern(field string) (string, bool) {
+	if field == "dlta_vendor_asset_short_code" || field == uniqueSuffixField {
+		return "", false
+	}
+
+	pattern := gen.namingFieldRegex(field, namingTransforms{})
+	if pattern == freeTextNamingFieldRegex {
+		return "", false
+	}
+
+	return fmt.Sprintf("^%s$", pattern), true
+}
+
+// freeTextNamingFieldRegex matches a naming field without a fixed set of options
+const freeTextNamingFieldRegex = "[a-zA-Z0-9]+"
+
+// namingFieldRegex returns the regex matching the values of a naming field once the transforms have been applied
+func (gen documentationGenerator) namingFieldRegex(field string, transforms namingTransforms) string {
+	switch field {
+	case "dlta_vendor_asset_short_code":
+		return transforms.literalRegex(gen.ShortCode)
+	case "dlta_instance_id":
+		return "[0-9]{3}"
+	case uniqueSuffixField:
+		return fmt.Sprintf("[a-z0-9]{%d}", gen.getUniqueSuffixConfig().Length)
+	}
+
+	options := make([]string, 0)
+	for _, o := range namingFieldOptions[field] {
+		options = append(options, o.Value)
+	}
+	options = append(options, gen.naming.Tokens[field].Options...)
+	if len(options) == 0 {
+		return freeTextNamingFieldRegex
+	}
+
+	alternatives := make([]string, 0, len(options))
+	for _, o := range options {
+		alternatives = append(alternatives, transforms.literalRegex(o))
+	}
+
+	return fmt.Sprintf("(?:%s)", strings.Join(alternatives, "|"))
+}
+
+// literalRegex returns the regex matching a literal part of the name once the transforms have been applied
+func (t namingTransforms) literalRegex(literal string) string {
+	if t.Alphanumeric {
+		literal = nonAlphanumericRegex.ReplaceAllString(literal, "")
+	}
+	if t.Lowercase {
+		literal = strings.ToLower(literal)
+	}
 
-		if len(dlta_application_short_code_options) > 0 {
-			pp.Type = "select"
-		}
-		pp.CurrentValue = dlta_application_short_code_options[0].Value
-	case "dlta_business_short_code":
-		flattenName = ""
-		pp.ID = name
-		pp.Type = translateDataType(at.DataTypeString)
-		pp.Name = convertNameToLabel(name)
-		pp.Disabled = false
-		pp.FlattenName = &flattenName
-		pp.CurrentValue = initiaiseAttribute(at.DataTypeString)
-		for i := 0; i < len(dlta_business_short_code_options); i++ {
-			pp.Options = append(pp.Options, dlta_business_short_code_options[i])
-		}
+	return regexp.QuoteMeta(literal)
+}
 
-		if len(dlta_business_short_code_options) > 0 {
-			pp.Type = "select"
-		}
+var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
 
-		pp.CurrentValue = dlta_business_short_code_options[0].Value
-	case "dlta_environment_char":
-		for i := 0; i < len(dlta_environment_char_options); i++ {
-			pp.Options = append(pp.Options, dlta_environment_char_options[i])
+// nameRegex returns the anchored regex describing the shape of the generated name, which the palette and the module
+// both enforce. A name which can be truncated has no regex as RE2 can't match a prefix of the shape
+func (gen documentationGenerator) nameRegex() (string, bool) {
+	if gen.isDataSource {
+		return "", false
+	}
+	if _, ok := gen.getNamingProvider().(tokenNamingProvider); !ok {
+		return "", false
+	}
+
+	conventions := []namingStruct{gen.getNamingStruct(gen.resourceName, false)}
+	environmentNaming := gen.getEnvironmentNaming(gen.resourceName, false)
+	environments := make([]string, 0, len(environmentNaming))
+	for environment := range environmentNaming {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+	for _, environment := range environments {
+		conventions = append(conventions, environmentNaming[environment])
+	}
+
+	alternatives := make([]string, 0, len(conventions))
+	seen := make(map[string]bool)
+	for _, naming := range conventions {
+		if naming.Transforms.MaxLength > 0 && gen.untransformedNamingMaxLength(naming) > naming.Transforms.MaxLength {
+			return "", false
 		}
 
-		if len(dlta_environment_char_options) > 0 {
-			pp.Type = "select"
+		pattern := gen.namingStructRegex(naming)
+		if !seen[pattern] {
+			seen[pattern] = true
+			alternatives = append(alternatives, pattern)
 		}
+	}
 
-		pp.CurrentValue = dlta_environment_char_options[0].Value
+	if len(alternatives) == 1 {
+		return fmt.Sprintf("^%s$", alternatives[0]), true
+	}
 
-	case "dlta_instance_id":
-		for i := 0; i < len(dlta_instance_id_options); i++ {
-			pp.Options = append(pp.Options, dlta_instance_id_options[i])
-		}
+	return fmt.Sprintf("^(?:%s)$", strings.Join(alternatives, "|")), true
+}
 
-		if len(dlta_instance_id_options) > 0 {
-			pp.Type = "select"
-		}
+// namingStructRegex returns the unanchored regex matching the names a single naming convention generates
+func (gen documentationGenerator) namingStructRegex(naming namingStruct) string {
+	t := naming.Transforms
+	if naming.StaticName != "" {
+		return t.literalRegex(naming.StaticName)
+	}
 
-		pp.CurrentValue = dlta_instance_id_options[0].Value
-	case "dlta_location_short_code":
-		for i := 0; i < len(dlta_location_short_code_options); i++ {
-			pp.Options = append(pp.Options, dlta_location_short_code_options[i])
-		}
+	parts := make([]string, 0)
+	if naming.Prefix != "" {
+		parts = append(parts, t.literalRegex(naming.Prefix))
+	}
+	for _, field := range naming.Fields {
+		parts = append(parts, gen.namingFieldRegex(field, t))
+	}
 
-		if len(dlta_location_short_code_options) > 0 {
-			pp.Type = "select"
-		}
+	delimiter := naming.Delimiter
+	if t.StripDelimiters {
+		delimiter = ""
+	}
 
-		pp.CurrentValue = dlta_location_short_code_options[0].Value
-	case "dlta_vendor_asset_short_code":
-		pp.CurrentValue = gen.ShortCode
-		pp.Disabled = true
-	case "dlta_terraform_template":
-		pp.CurrentValue = gen.terraformTemplateBlock()
-		pp.Disabled = true
-		pp.Type = "textarea"
+	return strings.Join(parts, t.literalRegex(delimiter))
+}
 
-	case "dlta_terraform_is_data_source":
-		if gen.isDataSource {
-			pp.CurrentValue = "data"
-		} else {
-			pp.CurrentValue = "resource"
-		}
+// namePreconditions checks the generated name against the resource's name constraint when the module is applied
+func (gen documentationGenerator) namePreconditions() []string {
+	if !gen.hasName() {
+		return nil
+	}
 
-		pp.Disabled = true
+	preconditions := make([]string, 0)
 
-	case "resource_group_name":
-		if gen.isDataSource {
+	if rule, ok := gen.getNameRule(); ok {
+		condition := fmt.Sprintf("length(local.name) >= %d && length(local.name) <= %d && can(regex(\"^[%s]+$\", local.name))", rule.MinLength, rule.MaxLength, rule.Characters)
+		message := fmt.Sprintf("The %s name must be %d-%d characters of %s.", gen.resourceName, rule.MinLength, rule.MaxLength, rule.Description)
+		preconditions = append(preconditions, fmt.Sprintf("\t\tprecondition {\n\t\t\tcondition     = %s\n\t\t\terror_message = \"%s\"\n\t\t}\n", condition, escapeHclString(message)))
+	}
 
-			pp = PaletteProp{}
-			pp.ID = "DataResourceGroup"
-			pp.Type = "string"
-			pp.Name = "Resource Group:"
-			pp.Disabled = false
-			pp.FlattenName = &flattenName
-			pp.CurrentValue = nil
-		} else {
-			flattenName = "ResourceGroups"
+	if pattern, ok := gen.nameRegex(); ok {
+		condition := fmt.Sprintf("can(regex(\"%s\", local.name))", escapeHclString(pattern))
+		message := fmt.Sprintf("The %s name must match the naming convention %s.", gen.resourceName, pattern)
+		preconditions = append(preconditions, fmt.Sprintf("\t\tprecondition {\n\t\t\tcondition     = %s\n\t\t\terror_message = \"%s\"\n\t\t}\n", condition, escapeHclString(message)))
+	}
 
-			pp = PaletteProp{}
-			pp.ID = "ResourceGroup"
-			pp.Type = "string"
-			pp.Name = "Resource Group:"
-			pp.Disabled = true
-			pp.FlattenName = &flattenName
-			pp.CurrentValue = nil
-		}
+	if len(preconditions) == 0 {
+		return nil
+	}
 
-		// creation.Props = append(creation.Props, palletItem)
-	case "terraform_azurerm_azapi_source":
-		for i := 0; i < len(terraform_azurerm_azapi_source_options); i++ {
-			pp.Options = append(pp.Options, terraform_azurerm_azapi_source_options[i])
-		}
+	return preconditions
+}
 
-		if len(terraform_azurerm_azapi_source_options) > 0 {
-			pp.Type = "select"
-		}
+// maxNameLength returns the longest name the naming convention, or any of its environment overrides, can produce
+func (gen documentationGenerator) maxNameLength() int {
+	length := gen.namingMaxLength(gen.getNamingStruct(gen.resourceName, gen.isDataSource))
 
-		pp.CurrentValue = terraform_azurerm_azapi_source_options[0].Value
-	case "terraform_azurerm_azapi_version":
-		for i := 0; i < len(terraform_azurerm_azapi_version_options); i++ {
-			pp.Options = append(pp.Options, terraform_azurerm_azapi_version_options[i])
+	for _, naming := range gen.getEnvironmentNaming(gen.resourceName, gen.isDataSource) {
+		if l := gen.namingMaxLength(naming); l > length {
+			length = l
 		}
+	}
 
-		if len(terraform_azurerm_azapi_version_options) > 0 {
-			pp.Type = "select"
-		}
+	return length
+}
 
-		pp.CurrentValue = terraform_azurerm_azapi_version_options[0].Value
-	case "terraform_azurerm_azurerm_source":
-		for i := 0; i < len(terraform_azurerm_azurerm_source_options); i++ {
-			pp.Options = append(pp.Options, terraform_azurerm_azurerm_source_options[i])
-		}
+// namingMaxLength returns the longest name a single naming convention can produce from the palette options
+func (gen documentationGenerator) namingMaxLength(naming namingStruct) int {
+	length := gen.untransformedNamingMaxLength(naming)
+	if naming.Transforms.MaxLength > 0 && length > naming.Transforms.MaxLength {
+		return naming.Transforms.MaxLength
+	}
 
-		if len(terraform_azurerm_azurerm_source_options) > 0 {
-			pp.Type = "select"
-		}
+	return length
+}
 
-		pp.CurrentValue = terraform_azurerm_azurerm_source_options[0].Value
-	case "terraform_azurerm_azurerm_version":
-		for i := 0; i < len(terraform_azurerm_azurerm_version_options); i++ {
-			pp.Options = append(pp.Options, terraform_azurerm_azurerm_version_options[i])
-		}
+// untransformedNamingMaxLength returns the longest name before truncation, stripped delimiters aren't counted
+func (gen documentationGenerator) untransformedNamingMaxLength(naming namingStruct) int {
+	if naming.StaticName != "" {
+		return len(naming.StaticName)
+	}
 
-		if len(terraform_azurerm_azurerm_version_options) > 0 {
-			pp.Type = "select"
-		}
+	parts := make([]string, 0)
+	if naming.Prefix != "" {
+		parts = append(parts, naming.Prefix)
+	}
 
-		pp.CurrentValue = terraform_azurerm_azurerm_version_options[0].Value
-	case "dlta_naming_convention":
-		pp.CurrentValue = gen.NamingConvention
-		pp.Disabled = true
-	default:
-		if len(at.PossibleValues) > 0 {
+	length := 0
+	for _, field := range naming.Fields {
+		length += gen.namingFieldMaxLength(field)
+		parts = append(parts, field)
+	}
 
-			var keyVal KeyValue
+	if len(parts) > 1 && !naming.Transforms.StripDelimiters && !naming.Transforms.Alphanumeric {
+		length += len(naming.Delimiter) * (len(parts) - 1)
+	}
 
-			for i := 0; i < len(at.PossibleValues); i++ {
-				keyVal.Key = at.PossibleValues[i]
-				keyVal.Value = at.PossibleValues[i]
+	return length + len(naming.Prefix)
+}
 
-				pp.Options = append(pp.Options, keyVal)
-			}
+// namingConventionWarnings returns the ways the naming convention can break the resource's name constraint
+func (gen documentationGenerator) namingConventionWarnings() []string {
+	rule, ok := gen.getNameRule()
+	if !ok {
+		return nil
+	}
 
-			if at.DataTypeString == schema.TypeList.String() {
-				pp.Type = "checkboxes"
-				var a []string
-				pp.CurrentValue = a
-			} else {
-				pp.Type = "select"
-				// pp.CurrentValue = at.PossibleValues[0]
-			}
+	warnings := make([]string, 0)
+	if length := gen.maxNameLength(); length > rule.MaxLength {
+		warnings = append(warnings, fmt.Sprintf("names can be up to %d characters but the limit is %d", length, rule.MaxLength))
+	}
 
-		} else {
-			fmt.Printf("FSNAME: %v\n", name)
-		}
+	conventions := []namingStruct{gen.getNamingStruct(gen.resourceName, gen.isDataSource)}
+	for _, naming := range gen.getEnvironmentNaming(gen.resourceName, gen.isDataSource) {
+		conventions = append(conventions, naming)
+	}
 
-		if at.DataTypeString == "TypeBool" {
-			pp.Type = "checkbox"
+	literals := regexp.MustCompile(fmt.Sprintf("^[%s]*$", rule.Characters))
+	for _, naming := range conventions {
+		if naming.Transforms.Alphanumeric {
+			continue
+		}
+		literal := naming.StaticName + naming.Prefix
+		if !naming.Transforms.StripDelimiters {
+			literal += naming.Delimiter
+		}
+		if naming.Transforms.Lowercase {
+			literal = strings.ToLower(literal)
+		}
+		if !literals.MatchString(literal) {
+			warnings = append(warnings, fmt.Sprintf("the prefix, delimiter or static name contain characters other than %s", rule.Description))
+			break
 		}
 	}
 
-	return pp
+	return warnings
 }
 
-func (gen documentationGenerator) dltaPalletteCodeBlock() string {
+// namingFieldReference returns the expression for a naming field within the generated module
+func namingFieldReference(field string) string {
+	if field == uniqueSuffixField {
+		return "local." + uniqueSuffixField
+	}
 
-	attributes := gen.injectAttributes()
-	var dltaPalletteCodeBlock string
-	var palletItem PaletteProp
-	var creation Creator
+	return "var." + field
+}
 
-	//generateInsertString
-	dltaPalletteCodeBlock += "insert into core.infra_asset (\n"
-	dltaPalletteCodeBlock += "				id, 		guid, infra_id, name,	label,	type,	active, 	addable,	asset_type,	reflect_type, 	palette_design, form_fields, 	attributes, created_at, updated_at, deleted_at, updated_by,	rank, 	has_cost, svg_icon) values (\n"
-	dltaPalletteCodeBlock += fmt.Sprintf("	DEFAULT, 	'%s', 1, 		'%s', 	'%s', 	'', 	true, 		true, 		'%s',		'none', 		null, 			'{}', 			null, 		now(), 		now(), 		null, 		1,			14, 	false, 		''	\n", uuid.New().String(), gen.resourceName, gen.resourceName, gen.resourceName)
-	dltaPalletteCodeBlock += ");\n"
-	//Start insert
+const (
+	// instanceIdStatic offers the fixed `dlta_instance_id` options on the palette
+	instanceIdStatic = "static"
 
-	//TODO Move to init area
-	//Enum for attribute type e.g. autoGenName or inputProperty
+	// instanceIdRegistry fills in the next instance id not used by the resource in the instance registry
+	instanceIdRegistry = "registry"
 
-	creation.CreateFunction = gen.resourceName
+	// instanceIdQuery has the canvas run a lookup for the next free instance id when the asset is added
+	instanceIdQuery = "query"
+)
 
-	//getPalletItem()
-	palletItem = gen.getPalletProp(attribute{}, "AssetType")
+// defaultInstanceRegistry is the file within `config` listing the instance ids used by each resource
+// e.g. `{"azurerm_storage_account": ["001", "002"]}`
+const defaultInstanceRegistry = "instance_registry.json"
 
-	// var flattenName string
+// maxInstanceId is the largest instance id which fits in the 3 digits of `dlta_instance_id`
+const maxInstanceId = 999
 
-	creation.Props = append(creation.Props, palletItem)
+// instanceIdConfig configures `dlta_instance_id` within `config/naming.json`, `query` is a SQL select returning the
+// instance ids already used by an asset type, `{asset_type}` is replaced with the resource name
+type instanceIdConfig struct {
+	Mode     string `json:"mode"`
+	Registry string `json:"registry"`
+	Query    string `json:"query"`
+}
 
-	if gen.resourceName != "terraform_azurerm" && gen.resourceName != "devops_pipeline" && !gen.isDataSource {
+// getInstanceIdConfig returns the configured instance id mode, falling back to the static options
+func (gen documentationGenerator) getInstanceIdConfig() instanceIdConfig {
+	config := instanceIdConfig{Mode: instanceIdStatic, Registry: defaultInstanceRegistry}
+	if gen.naming.InstanceId != nil {
+		if gen.naming.InstanceId.Mode != "" {
+			config.Mode = gen.naming.InstanceId.Mode
+		}
+		if gen.naming.InstanceId.Registry != "" {
+			config.Registry = gen.naming.InstanceId.Registry
+		}
+		config.Query = gen.naming.InstanceId.Query
+	}
 
-		palletItem = gen.getPalletProp(attribute{}, "Name")
-		creation.Props = append(creation.Props, palletItem)
+	return config
+}
+
+// readInstanceRegistry returns the instance ids the registry records as used by the resource, the registry is only
+// read in `registry` mode
+func (gen documentationGenerator) readInstanceRegistry() ([]string, error) {
+
+	config := gen.getInstanceIdConfig()
+	if config.Mode != instanceIdRegistry {
+		return nil, nil
 	}
 
-	for n, fs := range attributes {
+	registry := make(map[string][]string)
+	if _, err := gen.readDltaConfig(config.Registry, &registry); err != nil {
+		return nil, err
+	}
 
-		if n == "name" {
-			continue
+	used := registry[gen.resourceName]
+	for _, id := range used {
+		if n, err := strconv.Atoi(id); err != nil || len(id) != 3 || n < 1 {
+			return nil, fmt.Errorf("%s: %s: %q is not a 3 digit instance id", config.Registry, gen.resourceName, id)
 		}
-		// palletItem = PaletteProp{}
+	}
+	if nextInstanceId(used) == "" {
+		return nil, fmt.Errorf("%s: %s: all %d instance ids are used", config.Registry, gen.resourceName, maxInstanceId)
+	}
 
-		if n == "location" {
-			palletItem = gen.getPalletProp(fs, "location")
-		} else if n == "dlta_application_short_code" {
-			palletItem = gen.getPalletProp(fs, "dlta_application_short_code")
-		} else if n == "dlta_business_short_code" {
-			palletItem = gen.getPalletProp(fs, "dlta_business_short_code")
-		} else if n == "dlta_environment_char" {
-			palletItem = gen.getPalletProp(fs, "dlta_environment_char")
-		} else if n == "dlta_instance_id" {
-			palletItem = gen.getPalletProp(fs, "dlta_instance_id")
-		} else if n == "dlta_location_short_code" {
-			palletItem = gen.getPalletProp(fs, "dlta_location_short_code")
-		} else if n == "dlta_vendor_asset_short_code" {
-			palletItem = gen.getPalletProp(fs, "dlta_vendor_asset_short_code")
-		} else if n == "dlta_terraform_template" {
-			palletItem = gen.getPalletProp(fs, "dlta_terraform_template")
-		} else if n == "dlta_terraform_is_data_source" {
-			palletItem = gen.getPalletProp(fs, "dlta_terraform_is_data_source")
-		} else if n == "resource_group_name" {
-			palletItem = gen.getPalletProp(fs, "resource_group_name")
-		} else if n == "terraform_azurerm_azapi_source" {
-			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azapi_source")
-		} else if n == "terraform_azurerm_azapi_version" {
-			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azapi_version")
-		} else if n == "terraform_azurerm_azurerm_source" {
-			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azurerm_source")
-		} else if n == "terraform_azurerm_azurerm_version" {
-			palletItem = gen.getPalletProp(fs, "terraform_azurerm_azurerm_version")
-		} else if n == "dlta_naming_convention" {
-			palletItem = gen.getPalletProp(fs, "dlta_naming_convention")
-		} else {
-			palletItem = gen.getPalletProp(fs, n)
+	return used, nil
+}
+
+// nextInstanceId returns the lowest instance id which isn't used, or an empty string when they're all used
+func nextInstanceId(used []string) string {
+	isUsed := make(map[string]bool, len(used))
+	for _, id := range used {
+		isUsed[id] = true
+	}
+
+	for n := 1; n <= maxInstanceId; n++ {
+		if id := fmt.Sprintf("%03d", n); !isUsed[id] {
+			return id
 		}
+	}
 
-		if fs.IsBlock {
-			for n1, at := range fs.Attributes {
-				if !at.IsBlock {
-					palletItem = gen.getPalletProp(at, n1)
-					creation.Props = append(creation.Props, palletItem)
-				} else {
-					for n2, at2 := range at.Attributes {
-						palletItem = gen.getPalletProp(at2, n2)
-						creation.Props = append(creation.Props, palletItem)
-					}
-				}
+	return ""
+}
 
-			}
-		} else {
-			creation.Props = append(creation.Props, palletItem)
+// instanceIdLookup returns the SQL the canvas runs to find the lowest instance id not returned by the configured query
+func (gen documentationGenerator) instanceIdLookup() string {
+	used := strings.ReplaceAll(gen.getInstanceIdConfig().Query, "{asset_type}", fmt.Sprintf("'%s'", escapeSqlLiteral(gen.resourceName)))
+
+	return fmt.Sprintf("select min(id) from (select lpad(n::text, 3, '0') as id from generate_series(1, %d) as n except %s) as free", maxInstanceId, used)
+}
+
+// uniqueSuffixField is a naming field generated within the module, so globally unique names don't collide across teams
+const uniqueSuffixField = "dlta_unique_suffix"
+
+// uniqueSuffixConfig configures `dlta_unique_suffix` within `config/naming.json`, `hash` derives the suffix from the
+// subscription and the resource so it's stable across applies, `random` generates it with a random_string resource
+type uniqueSuffixConfig struct {
+	Mode   string `json:"mode"`
+	Length int    `json:"length"`
+}
+
+// defaultUniqueSuffix is used when `unique_suffix` isn't configured
+var defaultUniqueSuffix = uniqueSuffixConfig{Mode: "hash", Length: 4}
+
+// getUniqueSuffixConfig returns the configured unique suffix, falling back to defaultUniqueSuffix
+func (gen documentationGenerator) getUniqueSuffixConfig() uniqueSuffixConfig {
+	config := defaultUniqueSuffix
+	if gen.naming.UniqueSuffix != nil {
+		if gen.naming.UniqueSuffix.Mode != "" {
+			config.Mode = gen.naming.UniqueSuffix.Mode
+		}
+		if gen.naming.UniqueSuffix.Length > 0 {
+			config.Length = gen.naming.UniqueSuffix.Length
 		}
 	}
 
-	dltaPalletteCodeBlock += fmt.Sprintf("UPDATE core.infra_asset SET has_cost= %s,\nform_fields = '", strconv.FormatBool(false))
+	return config
+}
 
-	dltaPalletteCodeBlock += writeJson(creation)
+// usesUniqueSuffix returns whether the naming convention, or any of its environment overrides, uses the unique suffix
+func (gen documentationGenerator) usesUniqueSuffix() bool {
+	conventions := []namingStruct{gen.getNamingStruct(gen.resourceName, gen.isDataSource)}
+	for _, naming := range gen.getEnvironmentNaming(gen.resourceName, gen.isDataSource) {
+		conventions = append(conventions, naming)
+	}
 
-	dltaPalletteCodeBlock += fmt.Sprintf("'\nwhere asset_type = '%s'", gen.resourceName)
-	//Finish insert
-	dltaPalletteCodeBlock += ";"
+	for _, naming := range conventions {
+		for _, field := range naming.Fields {
+			if field == uniqueSuffixField {
+				return true
+			}
+		}
+	}
 
-	return dltaPalletteCodeBlock
+	return false
 }
 
-func (gen documentationGenerator) terraformOutputBlock() string {
+// uniqueSuffixBlock returns the block the unique suffix is derived from
+func (gen documentationGenerator) uniqueSuffixBlock() string {
+	config := gen.getUniqueSuffixConfig()
+
+	var block string
+	if config.Mode == "random" {
+		block += fmt.Sprintf("resource \"random_string\" \"%s\" {\n", uniqueSuffixField)
+		block += fmt.Sprintf("\tlength = %d\n", config.Length)
+		block += "\tspecial = false\n"
+		block += "\tupper = false\n"
+		block += "}\n"
+	} else {
+		block += "data \"azurerm_client_config\" \"current\" {}\n"
+	}
 
-	var outputBlock string
+	return block
+}
 
-	if gen.resource != nil {
+// uniqueSuffixExpression returns the expression for the unique suffix, the hash covers the subscription, the resource
+// type and the other naming fields so the same resource always gets the same suffix
+func (gen documentationGenerator) uniqueSuffixExpression() string {
+	config := gen.getUniqueSuffixConfig()
 
-		attributes := gen.getAllOutputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if config.Mode == "random" {
+		return fmt.Sprintf("random_string.%s.result", uniqueSuffixField)
+	}
 
-		for k, _ := range attributes {
-			outputBlock += "output \"" + k + "\" {\n"
-			outputBlock += "\tvalue = " + gen.resourceName + ".this." + k + "\n"
-			outputBlock += "}\n"
+	parts := []string{"data.azurerm_client_config.current.subscription_id", fmt.Sprintf("\"%s\"", gen.resourceName)}
+	for _, field := range gen.getNamingStruct(gen.resourceName, gen.isDataSource).Fields {
+		if field == uniqueSuffixField || field == "dlta_vendor_asset_short_code" {
+			continue
 		}
+		parts = append(parts, namingFieldReference(field))
+	}
+
+	return fmt.Sprintf("substr(sha256(join(\"/\", [%s])), 0, %d)", strings.Join(parts, ", "), config.Length)
+}
 
+// isEnvironmentChar returns whether the value is one of the `dlta_environment_char` options
+func isEnvironmentChar(value string) bool {
+	for _, o := range dlta_environment_char_options {
+		if o.Value == value {
+			return true
+		}
 	}
 
-	return outputBlock
+	return false
 }
 
-func (gen documentationGenerator) getResourceNamingConvention(resourceName string, isDataSource bool) string {
+// isNamingField returns whether the field is one of the dlta naming variables or a user defined token
+func isNamingField(field string, tokens map[string]namingToken) bool {
+	if field == uniqueSuffixField {
+		return true
+	}
 
-	// menu := make(map[string][]string)
+	for _, f := range defaultNaming.Fields {
+		if f == field {
+			return true
+		}
+	}
 
-	//	Angular
+	_, ok := tokens[field]
+	return ok
+}
 
-	var static string
-	var delim string
-	var fields []string
-	var prefix string
+// getNamingTokens returns the user defined tokens used by the resource's naming convention as attributes
+func (gen documentationGenerator) getNamingTokens() map[string]attribute {
 
-	resourceSpecificNaming := map[string]namingStruct{
-		"terraform_azurerm":                  {Delimiter: "-", StaticName: "terraform_azurerm"},
-		"azurerm_subscription":               {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_resource_group":             {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_windows_web_app":            {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_windows_function_app":       {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_service_plan":               {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_storage_account":            {Delimiter: "", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_cdn_frontdoor_profile":      {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_cdn_frontdoor_endpoint":     {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_cdn_frontdoor_origin_group": {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_cdn_frontdoor_origin":       {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_key_vault_access_policy":    {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_key_vault":                  {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_private_endpoint":           {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_virtual_network":            {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_subnet":                     {Delimiter: "-", StaticName: "", Prefix: "", IsDataSource: false, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-	}
-
-	dataSourceSpecificNaming := map[string]namingStruct{
-		"azurerm_subnet":                {Delimiter: "-", StaticName: "", Prefix: "ds", IsDataSource: true, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
-		"azurerm_key_vault_certificate": {Delimiter: "-", StaticName: "", Prefix: "ds", IsDataSource: true, Fields: []string{"dlta_vendor_asset_short_code", "dlta_business_short_code", "dlta_application_short_code", "dlta_environment_char", "dlta_location_short_code", "dlta_instance_id"}},
+	tokens := make(map[string]attribute)
+
+	fields := gen.getNamingStruct(gen.resourceName, gen.isDataSource).Fields
+	for _, naming := range gen.getEnvironmentNaming(gen.resourceName, gen.isDataSource) {
+		fields = append(fields, naming.Fields...)
 	}
 
-	if isDataSource {
-		static = dataSourceSpecificNaming[resourceName].StaticName
-		delim = dataSourceSpecificNaming[resourceName].Delimiter
-		fields = dataSourceSpecificNaming[resourceName].Fields
-		prefix = dataSourceSpecificNaming[resourceName].Prefix
-	} else {
-		static = resourceSpecificNaming[resourceName].StaticName
-		delim = resourceSpecificNaming[resourceName].Delimiter
-		fields = resourceSpecificNaming[resourceName].Fields
-		prefix = resourceSpecificNaming[resourceName].Prefix
+	for _, field := range fields {
+		token, ok := gen.naming.Tokens[field]
+		if !ok {
+			continue
+		}
+
+		description := token.Description
+		if description == "" {
+			description = fmt.Sprintf("Naming token %s", field)
+		}
+		tokens[field] = attribute{
+			DataTypeString: schema.TypeString.String(),
+			Description:    description,
+			PossibleValues: token.Options,
+			ResourcePath:   field,
+		}
 	}
 
-	returnString := ""
+	return tokens
+}
 
-	if static != "" {
-		returnString = static
-	} else {
-		if prefix != "" {
-			returnString += prefix + delim
+// namingFieldMaxLength returns the longest value the palette offers for a naming field
+func (gen documentationGenerator) namingFieldMaxLength(field string) int {
+
+	length := 0
+	if field == "dlta_vendor_asset_short_code" {
+		length = len(gen.ShortCode)
+	}
+	if field == uniqueSuffixField {
+		length = gen.getUniqueSuffixConfig().Length
+	}
+
+	for _, o := range namingFieldOptions[field] {
+		if len(o.Value) > length {
+			length = len(o.Value)
 		}
-		for i := 0; i < len(fields); i++ {
-			returnString += fmt.Sprintf("${%v}", fields[i])
-			if i < (len(fields) - 1) {
-				returnString += delim
-			}
+	}
+	for _, o := range gen.naming.Tokens[field].Options {
+		if len(o) > length {
+			length = len(o)
 		}
 	}
 
-	return returnString
+	return length
 }
 
 func genVariableNameFromResourcePath(rp string) string {
@@ -1787,6 +11629,193 @@ func genVariableNameFromResourcePath(rp string) string {
 	return cs
 }
 
+// cafAbbreviations are the Azure Cloud Adoption Framework abbreviations for resource types, see
+// https://learn.microsoft.com/azure/cloud-adoption-framework/ready/azure-best-practices/resource-abbreviations
+var cafAbbreviations = map[string]string{
+	"azurerm_api_management":                  "apim",
+	"azurerm_app_configuration":               "appcs",
+	"azurerm_application_gateway":             "agw",
+	"azurerm_application_insights":            "appi",
+	"azurerm_automation_account":              "aa",
+	"azurerm_bastion_host":                    "bas",
+	"azurerm_cdn_frontdoor_endpoint":          "fde",
+	"azurerm_cdn_frontdoor_profile":           "afd",
+	"azurerm_cognitive_account":               "cog",
+	"azurerm_container_app":                   "ca",
+	"azurerm_container_app_environment":       "cae",
+	"azurerm_container_registry":              "cr",
+	"azurerm_cosmosdb_account":                "cosmos",
+	"azurerm_dashboard_grafana":               "amg",
+	"azurerm_data_factory":                    "adf",
+	"azurerm_eventhub":                        "evh",
+	"azurerm_eventhub_namespace":              "evhns",
+	"azurerm_firewall":                        "afw",
+	"azurerm_frontdoor_firewall_policy":       "fdfp",
+	"azurerm_key_vault":                       "kv",
+	"azurerm_kubernetes_cluster":              "aks",
+	"azurerm_linux_function_app":              "func",
+	"azurerm_linux_virtual_machine":           "vm",
+	"azurerm_linux_web_app":                   "app",
+	"azurerm_log_analytics_workspace":         "log",
+	"azurerm_logic_app_workflow":              "logic",
+	"azurerm_machine_learning_workspace":      "mlw",
+	"azurerm_management_group":                "mg",
+	"azurerm_monitor_action_group":            "ag",
+	"azurerm_mssql_database":                  "sqldb",
+	"azurerm_mssql_server":                    "sql",
+	"azurerm_mysql_flexible_server":           "mysql",
+	"azurerm_network_interface":               "nic",
+	"azurerm_network_security_group":          "nsg",
+	"azurerm_network_watcher":                 "nw",
+	"azurerm_postgresql_flexible_server":      "psql",
+	"azurerm_private_dns_resolver":            "dnspr",
+	"azurerm_private_endpoint":                "pep",
+	"azurerm_public_ip":                       "pip",
+	"azurerm_recovery_services_vault":         "rsv",
+	"azurerm_redis_cache":                     "redis",
+	"azurerm_resource_group":                  "rg",
+	"azurerm_route_table":                     "rt",
+	"azurerm_search_service":                  "srch",
+	"azurerm_service_plan":                    "plan",
+	"azurerm_servicebus_namespace":            "sbns",
+	"azurerm_signalr_service":                 "sigr",
+	"azurerm_static_web_app":                  "stapp",
+	"azurerm_storage_account":                 "st",
+	"azurerm_subnet":                          "snet",
+	"azurerm_user_assigned_identity":          "id",
+	"azurerm_virtual_network":                 "vnet",
+	"azurerm_virtual_network_gateway":         "vgw",
+	"azurerm_virtual_wan":                     "vwan",
+	"azurerm_web_application_firewall_policy": "waf",
+	"azurerm_windows_function_app":            "func",
+	"azurerm_windows_virtual_machine":         "vm",
+	"azurerm_windows_web_app":                 "app",
+}
+
+// awsAbbreviations are the short codes of common AWS resource types, AWS has no equivalent of the CAF abbreviations
+// so these follow the service names teams already use in resource names
+var awsAbbreviations = map[string]string{
+	"aws_cloudwatch_log_group": "cwl",
+	"aws_db_instance":          "rds",
+	"aws_dynamodb_table":       "ddb",
+	"aws_ecr_repository":       "ecr",
+	"aws_ecs_cluster":          "ecs",
+	"aws_eks_cluster":          "eks",
+	"aws_iam_policy":           "pol",
+	"aws_iam_role":             "role",
+	"aws_instance":             "ec2",
+	"aws_kms_key":              "kms",
+	"aws_lambda_function":      "lmb",
+	"aws_lb":                   "alb",
+	"aws_s3_bucket":            "s3",
+	"aws_security_group":       "sg",
+	"aws_sns_topic":            "sns",
+	"aws_sqs_queue":            "sqs",
+	"aws_subnet":               "sn",
+	"aws_vpc":                  "vpc",
+}
+
+// gcpAbbreviations are the short codes of common Google Cloud resource types, chosen not to collide with the CAF or
+// AWS abbreviations so a canvas spanning clouds keeps its short codes unique
+var gcpAbbreviations = map[string]string{
+	"google_bigquery_dataset":        "bq",
+	"google_cloud_run_service":       "run",
+	"google_cloudfunctions_function": "gcf",
+	"google_compute_firewall":        "fw",
+	"google_compute_instance":        "gce",
+	"google_compute_network":         "net",
+	"google_compute_subnetwork":      "subnet",
+	"google_container_cluster":       "gke",
+	"google_kms_key_ring":            "kr",
+	"google_pubsub_topic":            "ps",
+	"google_service_account":         "sa",
+	"google_sql_database_instance":   "csql",
+	"google_storage_bucket":          "gcs",
+}
+
+// shortCodeRegex matches a short code which is safe in any resource name
+var shortCodeRegex = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// resourceShortCode returns the short code for the resource type, an override from `config/short_codes.json` takes
+// precedence over the CAF abbreviation with anything else falling back to getResourceShortCode
+func (gen documentationGenerator) resourceShortCode(resourceName string) string {
+	if shortCode, ok := gen.shortCodes[resourceName]; ok {
+		return shortCode
+	}
+
+	if shortCode, ok := cafAbbreviations[resourceName]; ok {
+		return shortCode
+	}
+
+	if shortCode, ok := awsAbbreviations[resourceName]; ok {
+		return shortCode
+	}
+
+	if shortCode, ok := gcpAbbreviations[resourceName]; ok {
+		return shortCode
+	}
+
+	return getResourceShortCode(resourceName)
+}
+
+// readShortCodes reads the short code overrides from `config/short_codes.json` e.g. `{"azurerm_storage_account": "sa"}`
+func (gen documentationGenerator) readShortCodes() (map[string]string, error) {
+
+	shortCodes := make(map[string]string)
+	if _, err := gen.readDltaConfig("short_codes.json", &shortCodes); err != nil {
+		return nil, err
+	}
+
+	for resourceName, shortCode := range shortCodes {
+		if !shortCodeRegex.MatchString(shortCode) {
+			return nil, fmt.Errorf("short_codes.json: %s: %q must be lowercase letters and numbers only", resourceName, shortCode)
+		}
+	}
+
+	return shortCodes, nil
+}
+
+// checkShortCodeCollisions returns an error when another resource type resolves to the same short code as this one,
+// the resource types checked are those already scaffolded within the dlta path and those listed in
+// `config/short_codes.json`. Collisions between the others are only warned of, they're for scaffolding them to fail
+func (gen documentationGenerator) checkShortCodeCollisions() error {
+
+	resourceNames := map[string]bool{gen.resourceName: true}
+	for resourceName := range gen.shortCodes {
+		resourceNames[resourceName] = true
+	}
+	for _, resourceKind := range []string{"r", "d"} {
+		dirs, err := scaffoldedDirs(gen.dltaPath, resourceKind)
+		if err != nil {
+			return fmt.Errorf("listing scaffolded resources: %+v", err)
+		}
+		for name := range dirs {
+			resourceNames[name] = true
+		}
+	}
+
+	byShortCode := make(map[string][]string)
+	for resourceName := range resourceNames {
+		shortCode := gen.resourceShortCode(resourceName)
+		byShortCode[shortCode] = append(byShortCode[shortCode], resourceName)
+	}
+
+	for _, shortCode := range sortedKeys(byShortCode) {
+		names := byShortCode[shortCode]
+		if len(names) < 2 {
+			continue
+		}
+		sort.Strings(names)
+		collision := fmt.Sprintf("%q is used by %s", shortCode, strings.Join(names, ", "))
+		if slices.Contains(names, gen.resourceName) {
+			return fmt.Errorf("short code collision: %s, add an override to config/short_codes.json", collision)
+		}
+		fmt.Printf("checkShortCodeCollisions \"collision\": %s, add an override to config/short_codes.json\n", collision)
+	}
+
+	return nil
+}
+
 func getResourceShortCode(resourceName string) string {
 	resourceNames := strings.Split(resourceName, "_")
 
@@ -1805,6 +11834,25 @@ func getResourceShortCode(resourceName string) string {
 	return resourceShortCode
 }
 
+// knownSetValues are the values of set attributes whose elements the schema doesn't validate against a list, keyed by
+// resource path as a field name alone can mean different things on different resources e.g. availability zones are
+// only validated as non-empty strings
+var knownSetValues = map[string][]string{
+	"azurerm_kubernetes_cluster.default_node_pool.zones": {"1", "2", "3"},
+	"azurerm_kubernetes_cluster_node_pool.zones":         {"1", "2", "3"},
+	"azurerm_linux_virtual_machine_scale_set.zones":      {"1", "2", "3"},
+	"azurerm_nat_gateway.zones":                          {"1", "2", "3"},
+	"azurerm_public_ip.zones":                            {"1", "2", "3"},
+	"azurerm_public_ip_prefix.zones":                     {"1", "2", "3"},
+	"azurerm_windows_virtual_machine_scale_set.zones":    {"1", "2", "3"},
+}
+
+// isCollectionType returns whether an attribute of the type holds a list of values, which the palette shows as a
+// checkbox group when the values are enumerated
+func isCollectionType(terraType string) bool {
+	return terraType == schema.TypeList.String() || terraType == schema.TypeSet.String()
+}
+
 func translateDataType(terraType string) string {
 
 	switch terraType {
@@ -1818,6 +11866,8 @@ func translateDataType(terraType string) string {
 		return "number"
 	case "TypeList":
 		return "list"
+	case "TypeSet":
+		return "list"
 	case "TypeMap":
 		return "map"
 	default:
@@ -1846,18 +11896,103 @@ func writeJson(v any) string {
 	return string(b)
 }
 
+// templatePlaceholderRegex matches the `${...}` placeholders substituted when a template is rendered onto the canvas
+var templatePlaceholderRegex = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// validateHcl parses the generated configuration and returns an error containing each diagnostic and the offending
+// snippet, template placeholders are substituted for identifiers first so resource templates can be validated too
+func validateHcl(fileName string, input string) error {
+	rendered := templatePlaceholderRegex.ReplaceAllString(input, "$1")
+
+	_, diags := hclsyntax.ParseConfig([]byte(rendered), fileName, hcl.Pos{Line: 1, Column: 1})
+	if !diags.HasErrors() {
+		return nil
+	}
+
+	lines := strings.Split(input, "\n")
+	var message string
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+
+		message += fmt.Sprintf("\n%s: %s", diag.Summary, diag.Detail)
+		if diag.Subject == nil {
+			continue
+		}
+
+		message += fmt.Sprintf("\n  on %s line %d:", fileName, diag.Subject.Start.Line)
+		for line := diag.Subject.Start.Line - 1; line <= diag.Subject.Start.Line+1; line++ {
+			if line < 1 || line > len(lines) {
+				continue
+			}
+			message += fmt.Sprintf("\n  %4d: %s", line, lines[line-1])
+		}
+	}
+
+	return fmt.Errorf("%s is not valid HCL:%s", fileName, message)
+}
+
+// escapeHclString escapes a value for use within a quoted HCL string, schema descriptions regularly contain quotes,
+// newlines and literal `${`/`%{` sequences which would otherwise be parsed as template interpolations
+func escapeHclString(input string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"${", "$${",
+		"%{", "%%{",
+	).Replace(input)
+}
+
+// escapeHclTemplateString escapes a value for use within a quoted HCL string whilst leaving its `${...}` tokens
+// intact, for values which the canvas renders as a template
+func escapeHclTemplateString(input string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+	).Replace(input)
+}
+
+// templateComment renders a description as a comment above an argument in the module call template, descriptions
+// are collapsed onto a single line so they can't break out of the comment
+func templateComment(description string) string {
+	description = strings.Join(strings.Fields(description), " ")
+	if description == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("\t# %s\n", description)
+}
+
+// escapeSqlLiteral escapes a value for use within a single quoted SQL string literal
+func escapeSqlLiteral(input string) string {
+	return strings.ReplaceAll(input, "'", "''")
+}
+
+// formatHcl applies the canonical Terraform formatting (as `terraform fmt` would) to the generated configuration,
+// only module files are formatted as the resource templates contain `${...}` placeholders which aren't valid HCL
+func formatHcl(input string) string {
+	return string(hclwrite.Format([]byte(input)))
+}
+
 func writeDebug(input string) {
 	color.Red(input)
 }
 
 func isBlock(element interface{}) bool {
 	attribute, isSchema := element.(*schema.Schema)
-	var isResource bool
-	if isSchema {
-		_, isResource = attribute.Elem.(*schema.Resource)
+	if !isSchema {
+		return false
 	}
 
-	return isResource
+	shape, _ := schemaShape(attribute)
+	return shape == shapeListOfObject || shape == shapeSetOfObject
 }
 
 func convertNameToLabel(name string) string {
@@ -1881,6 +12016,22 @@ func convertNameToLabel(name string) string {
 	return label
 }
 
+// flattenAttributes returns every attribute in the tree keyed by its ResourcePath
+func flattenAttributes(input map[string]attribute) map[string]attribute {
+	retAttributes := make(map[string]attribute)
+
+	for _, a := range input {
+		retAttributes[a.ResourcePath] = a
+		if a.IsBlock {
+			for rp, a2 := range flattenAttributes(a.Attributes) {
+				retAttributes[rp] = a2
+			}
+		}
+	}
+
+	return retAttributes
+}
+
 func cloneSchemaToAttributes(a *attribute, s *schema.Schema, isBlock bool, parentPath string, fieldName string) {
 
 	a.Description = s.Description
@@ -1894,16 +12045,60 @@ func cloneSchemaToAttributes(a *attribute, s *schema.Schema, isBlock bool, paren
 	//a.PossibleValues  = s.PossibleValues
 	//a.PossibleOptions = s.PossibleOptions
 	a.DataTypeString = s.Type.String()
-	//a.Default         = s.Default //TODO Find out how this works  SchemaDefaultFunc
+	a.Shape, a.ElemType = schemaShape(s)
+	if s.Default != nil {
+		a.Default = fmt.Sprint(s.Default)
+		a.DefaultSource = defaultSourceSchema
+	} else if s.DefaultFunc != nil {
+		a.DefaultSource, a.DefaultEnv = defaultFuncSource(s)
+	}
 	a.ConflictsWith = s.ConflictsWith
+	a.ExactlyOneOf = s.ExactlyOneOf
+	a.AtLeastOneOf = s.AtLeastOneOf
+	a.RequiredWith = s.RequiredWith
+	a.Deprecated = s.Deprecated
+	a.Sensitive = s.Sensitive
 	a.ResourcePath = parentPath + "." + fieldName
 }
 
+// defaultFuncSource returns where the schema's DefaultFunc takes its default from, it isn't called as its value depends
+// on the environment of the machine it's called on. Which variables an EnvDefaultFunc reads can't be read from the
+// closure so are taken from the description e.g. "can also be sourced from the `ARM_ENVIRONMENT` Environment Variable"
+func defaultFuncSource(s *schema.Schema) (string, []string) {
+
+	var envVars []string
+	for _, m := range envVarRegex.FindAllStringSubmatch(s.Description, -1) {
+		envVars = appendUnique(envVars, m[1])
+	}
+	if len(envVars) > 0 {
+		return defaultSourceEnvironment, envVars
+	}
+
+	return defaultSourceFunc, nil
+}
+
 func cloneSchemaToAttributesSummary(a *attributeSummary, s *schema.Schema, isBlock bool, parentPath string, fieldName string) {
 
 	a.IsBlock = isBlock
 	a.DataTypeString = s.Type.String()
 	a.ResourcePath = parentPath + "." + fieldName
+	a.Deprecated = s.Deprecated
+}
+
+func sortAttributeNames(input map[string]attribute) []string {
+	names := make([]string, 0)
+	for name := range input {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedCopy(input []string) []string {
+	output := make([]string, len(input))
+	copy(output, input)
+	sort.Strings(output)
+	return output
 }
 
 func (gen documentationGenerator) sortFields(input map[string]*schema.Schema) []string {
@@ -1915,49 +12110,351 @@ func (gen documentationGenerator) sortFields(input map[string]*schema.Schema) []
 	return fieldNames
 }
 
-func patchPossibleValuesFn() {
-	gomonkey.ApplyFunc(help.StringInSlice,
-		func(valid []string, ignoreCase bool) schema.SchemaValidateFunc { //nolint:staticcheck
-			return func(i interface{}, k string) (warnings []string, errors []error) {
-				var res []string // must have a copy
-				res = append(res, valid...)
-				return res, nil
+// the palette patterns of the IsCIDR, IsIPAddress (IPv4/IPv6) and IsUUID validation functions
+const (
+	cidrPattern = `^(([0-9]{1,3}\.){3}[0-9]{1,3}/[0-9]{1,2}|[0-9a-fA-F:]+/[0-9]{1,3})$`
+	ipPattern   = `^(([0-9]{1,3}\.){3}[0-9]{1,3}|[0-9a-fA-F:]+)$`
+	ipv4Pattern = `^([0-9]{1,3}\.){3}[0-9]{1,3}$`
+	ipv6Pattern = `^[0-9a-fA-F:]+$`
+	uuidPattern = `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`
+)
+
+var (
+	stringLenBetweenErrorRegex = regexp.MustCompile(`^expected length of .* to be in the range \((\d+) - (\d+)\)`)
+	numberBetweenErrorRegex    = regexp.MustCompile(`^expected .* to be in the range \((-?\d+(?:\.\d+)?) - (-?\d+(?:\.\d+)?)\)`)
+	numberAtLeastErrorRegex    = regexp.MustCompile(`^expected .* to be at least \((-?\d+(?:\.\d+)?)\)`)
+	numberAtMostErrorRegex     = regexp.MustCompile(`^expected .* to be at most \((-?\d+(?:\.\d+)?)\)`)
+	stringMatchErrorRegex      = regexp.MustCompile(`^expected value of .* to match regular expression ("(?:[^"\\]|\\.)*")`)
+	isCIDRErrorRegex           = regexp.MustCompile(`^expected .* to be a valid CIDR Value`)
+	isUUIDErrorRegex           = regexp.MustCompile(`^expected .* to be a valid UUID`)
+	isIPErrorRegex             = regexp.MustCompile(`^expected .* to contain a valid (IP|IPv4 address|IPv6 address), got`)
+	urlSchemeErrorRegex        = regexp.MustCompile(`^expected .* to have a url with schema of: ("(?:[^"\\]|\\.)*")`)
+	oneOfErrorRegex            = regexp.MustCompile(`^expected .* to be one of \[(.*)\], got `)
+	envVarRegex                = regexp.MustCompile("`([A-Z][A-Z0-9]*_[A-Z0-9_]+)`")
+	quotedStringRegex          = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+	resourceIDErrorRegex       = regexp.MustCompile(`(?:^parsing .* as an?|^Expected an?) ([A-Z][A-Za-z0-9]*) ID\b`)
+	anyResourceIDErrorRegex    = regexp.MustCompile(`(?i)^can not parse .* as a resource id`)
+)
+
+// validationMessages returns the errors of the schema's validation functions for each of the probe values, a
+// validation function which panics (custom ones may assume more of the value than its type) returns nothing
+func validationMessages(item *schema.Schema, probes []interface{}) []string {
+
+	messages := make([]string, 0)
+	for _, probe := range probes {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+
+			if item.ValidateFunc != nil {
+				_, errs := item.ValidateFunc(probe, "")
+				for _, err := range errs {
+					messages = append(messages, err.Error())
+				}
 			}
-		},
-	)
+			if item.ValidateDiagFunc != nil {
+				for _, d := range item.ValidateDiagFunc(probe, nil) {
+					messages = append(messages, d.Summary)
+				}
+			}
+		}()
+	}
+
+	return messages
 }
 
-func StringInSlice() {
-	gomonkey.ApplyFunc(validation.StringInSlice,
-		func(valid []string, ignoreCase bool) func(interface{}, string) ([]string, []error) { //nolint:staticcheck
-			return func(i interface{}, k string) (warnings []string, errors []error) {
-				var res []string // must have a copy
-				res = append(res, valid...)
-				return res, nil
+// getSchemaConstraints returns the constraints of the schema's validation functions. The validation functions are
+// closures so they're called with values which break the common constraints and the constraint read back from the
+// error, e.g. `expected length of name to be in the range (3 - 24)`. Within All/Any each function's error is returned
+func getSchemaConstraints(item *schema.Schema) constraints {
+
+	var probes []interface{}
+	switch item.Type {
+	case schema.TypeString:
+		// a url with a scheme nothing requires
+		probes = []interface{}{"", strings.Repeat("\x00", 65536), "ftp://example.com"}
+	case schema.TypeInt:
+		probes = []interface{}{math.MinInt32, math.MaxInt32}
+	case schema.TypeFloat:
+		probes = []interface{}{-math.MaxFloat64, math.MaxFloat64}
+	default:
+		return constraints{}
+	}
+
+	bound := func(s string) *float64 {
+		f, _ := strconv.ParseFloat(s, 64)
+		return &f
+	}
+	length := func(s string) *int {
+		i, _ := strconv.Atoi(s)
+		return &i
+	}
+	formats := map[string]string{"IP": formatIP, "IPv4 address": formatIPv4, "IPv6 address": formatIPv6}
+
+	var c constraints
+	for _, message := range validationMessages(item, probes) {
+		if m := stringLenBetweenErrorRegex.FindStringSubmatch(message); m != nil {
+			c.MinLength = length(m[1])
+			c.MaxLength = length(m[2])
+		} else if m := numberBetweenErrorRegex.FindStringSubmatch(message); m != nil {
+			c.Min = bound(m[1])
+			c.Max = bound(m[2])
+		} else if m := numberAtLeastErrorRegex.FindStringSubmatch(message); m != nil {
+			c.Min = bound(m[1])
+		} else if m := numberAtMostErrorRegex.FindStringSubmatch(message); m != nil {
+			c.Max = bound(m[1])
+		} else if m := oneOfErrorRegex.FindStringSubmatch(message); m != nil && c.OneOf == nil {
+			c.OneOf = oneOfValues(item.Type, m[1])
+		} else if m := stringMatchErrorRegex.FindStringSubmatch(message); m != nil {
+			if pattern, err := strconv.Unquote(m[1]); err == nil {
+				c.Patterns = appendUnique(c.Patterns, pattern)
 			}
-		},
-	)
+		} else if isCIDRErrorRegex.MatchString(message) {
+			c.Formats = appendUnique(c.Formats, formatCIDR)
+		} else if m := isIPErrorRegex.FindStringSubmatch(message); m != nil {
+			c.Formats = appendUnique(c.Formats, formats[m[1]])
+		} else if isUUIDErrorRegex.MatchString(message) {
+			c.Formats = appendUnique(c.Formats, formatUUID)
+		} else if m := urlSchemeErrorRegex.FindStringSubmatch(message); m != nil {
+			if schemes, err := strconv.Unquote(m[1]); err == nil {
+				c.Formats = appendUnique(c.Formats, formatURL)
+				c.URLSchemes = strings.Split(schemes, ",")
+			}
+		} else if m := resourceIDErrorRegex.FindStringSubmatch(message); m != nil {
+			c.ResourceID = m[1]
+		} else if anyResourceIDErrorRegex.MatchString(message) && c.ResourceID == "" {
+			c.ResourceID = anyAssetType
+		}
+	}
+
+	return c
 }
 
-func init() {
-	patchPossibleValuesFn()
-	StringInSlice()
+// oneOfValues splits the valid values of a StringInSlice error (formatted with %q, `["a" "b"]`) or an IntInSlice
+// error (formatted with %v, `[1 2]`)
+func oneOfValues(t schema.ValueType, values string) []string {
+
+	if t != schema.TypeString {
+		return strings.Fields(values)
+	}
+
+	oneOf := make([]string, 0)
+	for _, quoted := range quotedStringRegex.FindAllString(values, -1) {
+		if value, err := strconv.Unquote(quoted); err == nil {
+			oneOf = append(oneOf, value)
+		}
+	}
+	return oneOf
 }
 
-func getSchemaPossibleValues(item *schema.Schema) []string {
-	if item.ValidateFunc != nil {
-		// check if it is StringsInSlice
-		pc := reflect.ValueOf(item.ValidateFunc).Pointer()
-		fn := runtime.FuncForPC(pc)
-		fnName := fn.Name()
-		// seems different go version behaviors different
-		if strings.Contains(fnName, "StringInSlice") || strings.Contains(fnName, "patchPossibleValuesFn") {
-
-			values, _ := item.ValidateFunc(nil, "")
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
 			return values
 		}
 	}
-	return nil
+	return append(values, value)
+}
+
+// isEmpty reports whether none of the schema's validation functions were read back as constraints
+func (c constraints) isEmpty() bool {
+	return c.MinLength == nil && c.MaxLength == nil && c.Min == nil && c.Max == nil && len(c.OneOf) == 0 && len(c.Patterns) == 0 && len(c.Formats) == 0 && len(c.URLSchemes) == 0 && c.ResourceID == ""
+}
+
+// pattern returns the regular expression a string is checked with, none when the constraints have none or more than
+// one (which one applies isn't known)
+func (c constraints) pattern() (string, bool) {
+
+	patterns := append([]string{}, c.Patterns...)
+	for _, format := range c.Formats {
+		switch format {
+		case formatCIDR:
+			patterns = append(patterns, cidrPattern)
+		case formatIP:
+			patterns = append(patterns, ipPattern)
+		case formatIPv4:
+			patterns = append(patterns, ipv4Pattern)
+		case formatIPv6:
+			patterns = append(patterns, ipv6Pattern)
+		case formatUUID:
+			patterns = append(patterns, uuidPattern)
+		case formatURL:
+			schemes := make([]string, 0, len(c.URLSchemes))
+			for _, scheme := range c.URLSchemes {
+				schemes = append(schemes, regexp.QuoteMeta(scheme))
+			}
+			patterns = append(patterns, fmt.Sprintf("^(%s)://", strings.Join(schemes, "|")))
+		}
+	}
+
+	if len(patterns) != 1 {
+		return "", false
+	}
+	return patterns[0], true
+}
+
+// paletteValidators returns the palette validators of the constraints, the bounds of an integer are whole numbers
+func (c constraints) paletteValidators(dataType string) NameValue {
+
+	bound := func(f float64) interface{} {
+		if dataType == "TypeInt" {
+			return int(f)
+		}
+		return f
+	}
+
+	validators := make(NameValue)
+	if c.MinLength != nil {
+		validators["minLength"] = *c.MinLength
+	}
+	if c.MaxLength != nil {
+		validators["maxLength"] = *c.MaxLength
+	}
+	if c.Min != nil {
+		validators["min"] = bound(*c.Min)
+	}
+	if c.Max != nil {
+		validators["max"] = bound(*c.Max)
+	}
+	if pattern, ok := c.pattern(); ok {
+		validators["pattern"] = pattern
+	}
+
+	if len(validators) == 0 {
+		return nil
+	}
+
+	return validators
+}
+
+// validateFunc rebuilds a validation function the constraints are read back from, for a schema loaded from the cache
+func (c constraints) validateFunc(t schema.ValueType) schema.SchemaValidateFunc {
+
+	fns := make([]schema.SchemaValidateFunc, 0)
+
+	if c.MinLength != nil && c.MaxLength != nil {
+		fns = append(fns, help.StringLenBetween(*c.MinLength, *c.MaxLength))
+	}
+
+	if t == schema.TypeInt {
+		switch {
+		case c.Min != nil && c.Max != nil:
+			fns = append(fns, help.IntBetween(int(*c.Min), int(*c.Max)))
+		case c.Min != nil:
+			fns = append(fns, help.IntAtLeast(int(*c.Min)))
+		case c.Max != nil:
+			fns = append(fns, help.IntAtMost(int(*c.Max)))
+		}
+	} else if t == schema.TypeFloat {
+		switch {
+		case c.Min != nil && c.Max != nil:
+			fns = append(fns, help.FloatBetween(*c.Min, *c.Max))
+		case c.Min != nil:
+			fns = append(fns, help.FloatAtLeast(*c.Min))
+		case c.Max != nil:
+			fns = append(fns, help.FloatAtMost(*c.Max))
+		}
+	}
+
+	if len(c.OneOf) > 0 {
+		if t == schema.TypeInt {
+			values := make([]int, 0, len(c.OneOf))
+			for _, v := range c.OneOf {
+				if i, err := strconv.Atoi(v); err == nil {
+					values = append(values, i)
+				}
+			}
+			fns = append(fns, help.IntInSlice(values))
+		} else {
+			fns = append(fns, help.StringInSlice(c.OneOf, false))
+		}
+	}
+
+	for _, pattern := range c.Patterns {
+		if r, err := regexp.Compile(pattern); err == nil {
+			fns = append(fns, help.StringMatch(r, ""))
+		}
+	}
+
+	for _, format := range c.Formats {
+		switch format {
+		case formatCIDR:
+			fns = append(fns, help.IsCIDR)
+		case formatIP:
+			fns = append(fns, help.IsIPAddress)
+		case formatIPv4:
+			fns = append(fns, help.IsIPv4Address)
+		case formatIPv6:
+			fns = append(fns, help.IsIPv6Address)
+		case formatUUID:
+			fns = append(fns, help.IsUUID)
+		case formatURL:
+			fns = append(fns, help.IsURLWithScheme(c.URLSchemes))
+		}
+	}
+
+	if c.ResourceID != "" {
+		fns = append(fns, resourceIDValidateFunc(c.ResourceID))
+	}
+
+	if len(fns) == 0 {
+		return nil
+	}
+
+	// every failing function's error is returned, as each failed the probes of the original validation
+	return help.All(fns...)
+}
+
+// resourceIDValidateFunc rebuilds the validation of an azurerm ID, which is only checked to be a path as the parser's
+// format isn't cached. Its error reads back as the ID the original parsed the value as
+func resourceIDValidateFunc(resourceID string) schema.SchemaValidateFunc {
+	return func(i interface{}, k string) ([]string, []error) {
+		v, ok := i.(string)
+		if !ok {
+			return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+		}
+		if strings.HasPrefix(v, "/") {
+			return nil, nil
+		}
+		if resourceID == anyAssetType {
+			return nil, []error{fmt.Errorf("can not parse %q as a resource id", v)}
+		}
+		return nil, []error{fmt.Errorf("parsing %q as an %s ID: expected a resource ID starting with `/`", v, resourceID)}
+	}
+}
+
+// schemaPossibleValues returns the values an attribute can be set to, whether it's top level or nested in a block,
+// from a StringInSlice or IntInSlice validation of the attribute or (for a list, set or map) of its elements. A set
+// whose elements aren't validated against a list falls back to the knownSetValues of its resource path
+func schemaPossibleValues(resourcePath string, s *schema.Schema) []string {
+
+	var values []string
+	seen := make(map[string]bool)
+	add := func(possibleValues []string) {
+		for _, v := range possibleValues {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+
+	add(getSchemaPossibleValues(s))
+	if elem, ok := s.Elem.(*schema.Schema); ok {
+		add(getSchemaPossibleValues(elem))
+	}
+	if len(values) == 0 && s.Type == schema.TypeSet {
+		add(knownSetValues[resourcePath])
+	}
+
+	return values
+}
+
+// getSchemaPossibleValues returns the values allowed by a StringInSlice or IntInSlice validation (on its own or
+// within All/Any)
+func getSchemaPossibleValues(item *schema.Schema) []string {
+	return getSchemaConstraints(item).OneOf
 }
 
 func initiaiseAttribute(terraType string) interface{} {
@@ -1978,8 +12475,12 @@ func initiaiseAttribute(terraType string) interface{} {
 	case "TypeList":
 		var r []string = []string{""}
 		return r
+	case "TypeSet":
+		// a set can't hold the same value twice, so starts empty rather than with a blank entry
+		var r = []string{}
+		return r
 	case "TypeMap":
-		var r map[string]string
+		var r = map[string]string{}
 		return r
 	default:
 		return nil
@@ -0,0 +1,4755 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	help "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/terraform-provider-azurerm/internal/tf/validation"
+	"github.com/hashicorp/terraform-provider-azurerm/version"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+const resourceName = "azurerm_foobar"
+
+func testGenerator() documentationGenerator {
+	return documentationGenerator{
+		resourceName: resourceName,
+		isResource:   true,
+		resource: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"location": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+			},
+		},
+	}
+}
+
+// writeConfig writes a file of the config directory of the dlta path e.g. `naming.json`
+func writeConfig(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, "config", name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTerraformImportBlock(t *testing.T) {
+	gen := testGenerator()
+	gen.isImport = true
+
+	expected := `import {
+	to = module.${dlta_terraform_module_name}.azurerm_foobar.this
+	id = "${dlta_import_resource_id}"
+}
+`
+	if actual := gen.terraformImportBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	if !gen.canImport() {
+		t.Fatalf("expected a resource to be importable")
+	}
+
+	gen.isResource = false
+	gen.isDataSource = true
+	if gen.canImport() {
+		t.Fatalf("expected a data source not to be importable")
+	}
+}
+
+func TestTerraformMovedBlock(t *testing.T) {
+	gen := testGenerator()
+	gen.previousModuleName = "azurerm_foobar_1234"
+	gen.moduleName = "azurerm_foobar_5678"
+
+	expected := `moved {
+	from = module.azurerm_foobar_1234
+	to   = module.azurerm_foobar_5678
+}
+`
+	if !gen.canMove() {
+		t.Fatalf("expected a renamed asset to be moved")
+	}
+	if actual := gen.terraformMovedBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	for _, previous := range []string{"", "azurerm_foobar_5678"} {
+		gen.previousModuleName = previous
+		if gen.canMove() {
+			t.Fatalf("expected no moved block for the previous module name %q", previous)
+		}
+	}
+}
+
+func TestModuleMetaContentHash(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.isForced = true
+	gen.writeInitResourceProperties()
+
+	moduleDir := filepath.Join(gen.dltaPath, "r", resourceName, "module")
+	readHash := func() (string, string) {
+		var meta moduleMeta
+		content, err := os.ReadFile(filepath.Join(moduleDir, "module-meta.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(content, &meta); err != nil {
+			t.Fatal(err)
+		}
+
+		files := make([]string, 0)
+		for _, fileName := range []string{"main.tf", "variables.tf", "local.tf", "output.tf"} {
+			content, err := os.ReadFile(filepath.Join(moduleDir, fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, string(content))
+		}
+		return meta.ContentHash, moduleContentHash(files...)
+	}
+
+	gen.generatedAt = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	gen.scaffoldConfiguation()
+	recorded, written := readHash()
+	if recorded != written {
+		t.Fatalf("expected the hash of the files written %s, got %s", written, recorded)
+	}
+
+	// the header says when the files were generated, which isn't drift
+	gen.generatedAt = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	gen.scaffoldConfiguation()
+	if regenerated, _ := readHash(); regenerated != recorded {
+		t.Fatalf("expected the same hash regenerating the module unchanged, got %s and %s", recorded, regenerated)
+	}
+
+	main := filepath.Join(moduleDir, "main.tf")
+	content, err := os.ReadFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, append(content, []byte("locals {}\n")...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, edited := readHash(); edited == recorded {
+		t.Fatal("expected an edit to the module to change its hash")
+	}
+}
+
+func TestFormatHcl(t *testing.T) {
+	input := "resource \"azurerm_foobar\" \"this\" {\n\tname = local.name\n\tlocation		= var.location\n}\n"
+	expected := `resource "azurerm_foobar" "this" {
+  name     = local.name
+  location = var.location
+}
+`
+	if actual := formatHcl(input); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestValidateHcl(t *testing.T) {
+	gen := testGenerator()
+	gen.moduleName = "azurerm_foobar_5678"
+	gen.previousModuleName = "azurerm_foobar_1234"
+
+	if err := validateHcl("moved.tf", gen.terraformMovedBlock()); err != nil {
+		t.Fatalf("expected the moved template to be valid once rendered, got: %+v", err)
+	}
+
+	err := validateHcl("main.tf", "resource \"azurerm_foobar\" \"this\" {\n\tname = \n}\n")
+	if err == nil {
+		t.Fatalf("expected an error for an attribute missing its value")
+	}
+	if !strings.Contains(err.Error(), "main.tf line 2") {
+		t.Fatalf("expected the error to reference the offending line, got: %+v", err)
+	}
+}
+
+func TestGetAllPublishedAttributesDeprecated(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["legacy_enabled"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "Should legacy mode be enabled?",
+		Deprecated:  "`legacy_enabled` will be removed in favour of `mode`",
+	}
+
+	inputAttributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	sa := map[string]summaryAttribute{
+		"azurerm_foobar.name":           {Published: true},
+		"azurerm_foobar.legacy_enabled": {Published: true},
+	}
+
+	if _, ok := gen.getAllPublishedAttributes(inputAttributes, sa)["legacy_enabled"]; ok {
+		t.Fatalf("expected deprecated attributes to be excluded by default")
+	}
+
+	gen.includeDeprecated = true
+	published, ok := gen.getAllPublishedAttributes(inputAttributes, sa)["legacy_enabled"]
+	if !ok {
+		t.Fatalf("expected deprecated attributes to be included with `-include-deprecated`")
+	}
+	if !strings.Contains(published.Description, "(Deprecated: ") {
+		t.Fatalf("expected a deprecation note in the description, got %q", published.Description)
+	}
+}
+
+func TestDeprecationMetadata(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["legacy_sku"] = &schema.Schema{
+		Type:       schema.TypeString,
+		Required:   true,
+		Deprecated: "`legacy_sku` will be removed in favour of `sku_name`",
+	}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+
+	summary := gen.summariseAttributes(attributes, gen.resourceName, true)
+	legacy := summary["azurerm_foobar.legacy_sku"]
+	if legacy.Published || legacy.Deprecated != "`legacy_sku` will be removed in favour of `sku_name`" {
+		t.Fatalf("expected an unpublished attribute carrying the deprecation, got %+v", legacy)
+	}
+	if content := writeJson(summary); strings.Count(content, "\"Deprecated\"") != 1 {
+		t.Fatalf("expected only the deprecated attribute to have a deprecation in:\n%s", content)
+	}
+
+	gen.includeDeprecated = true
+	if summary := gen.summariseAttributes(attributes, gen.resourceName, true); !summary["azurerm_foobar.legacy_sku"].Published {
+		t.Fatalf("expected a required deprecated attribute to be published with `-include-deprecated`")
+	}
+
+	pp := gen.getPalletProp(attributes["legacy_sku"], "legacy_sku")
+	if pp.Deprecated == nil || *pp.Deprecated != "legacy_sku will be removed in favour of sku_name" {
+		t.Fatalf("expected the deprecation on the control, got %v", pp.Deprecated)
+	}
+	if pp := gen.getPalletProp(attributes["name"], "name"); pp.Deprecated != nil {
+		t.Fatalf("expected no deprecation on a current control, got %q", *pp.Deprecated)
+	}
+}
+
+func TestSummaryConstraints(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["key_vault_id"] = &schema.Schema{Type: schema.TypeString, Optional: true, ExactlyOneOf: []string{"key_vault_id", "secret"}}
+	gen.resource.Schema["secret"] = &schema.Schema{Type: schema.TypeString, Optional: true, ExactlyOneOf: []string{"key_vault_id", "secret"}}
+	gen.resource.Schema["identity"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"client_id": {Type: schema.TypeString, Optional: true, RequiredWith: []string{"identity.0.tenant_id"}},
+		"tenant_id": {Type: schema.TypeString, Optional: true},
+	}}}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	sa := gen.summariseAttributes(attributes, gen.resourceName, true)
+
+	if expected := []string{"azurerm_foobar.key_vault_id", "azurerm_foobar.secret"}; !reflect.DeepEqual(sa["azurerm_foobar.secret"].ExactlyOneOf, expected) {
+		t.Fatalf("expected %v, got %v", expected, sa["azurerm_foobar.secret"].ExactlyOneOf)
+	}
+	if expected := []string{"azurerm_foobar.identity.tenant_id"}; !reflect.DeepEqual(sa["azurerm_foobar.identity.client_id"].RequiredWith, expected) {
+		t.Fatalf("expected the nested key as a resource path %v, got %v", expected, sa["azurerm_foobar.identity.client_id"].RequiredWith)
+	}
+
+	expected := []string{"exactly one of azurerm_foobar.key_vault_id, azurerm_foobar.secret must be set but none are published"}
+	if actual := gen.publishConstraintWarnings(sa); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+
+	for _, rp := range []string{"azurerm_foobar.secret", "azurerm_foobar.identity", "azurerm_foobar.identity.client_id"} {
+		a := sa[rp]
+		a.Published = true
+		sa[rp] = a
+	}
+	expected = []string{"azurerm_foobar.identity.client_id requires azurerm_foobar.identity.tenant_id which isn't published"}
+	if actual := gen.publishConstraintWarnings(sa); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestOutputAttributeTree(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["vault_uri"] = &schema.Schema{Type: schema.TypeString, Computed: true}
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Computed: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"subnet_id": {Type: schema.TypeString, Computed: true},
+		"routes": {Type: schema.TypeList, Computed: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"prefix": {Type: schema.TypeString},
+		}}},
+	}}}
+	gen.resource.Schema["identity"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"type":         {Type: schema.TypeString, Required: true},
+		"principal_id": {Type: schema.TypeString, Computed: true},
+	}}}
+	gen.resource.Schema["sku"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"name": {Type: schema.TypeString, Required: true},
+	}}}
+
+	attributes := gen.getAllOutputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if actual := sortAttributeNames(attributes); !reflect.DeepEqual(actual, []string{"identity", "network", "vault_uri"}) {
+		t.Fatalf("expected the computed attributes and the blocks holding them, got %v", actual)
+	}
+	if actual := sortAttributeNames(attributes["identity"].Attributes); !reflect.DeepEqual(actual, []string{"principal_id"}) {
+		t.Fatalf("expected only the computed attributes of a block set by the user, got %v", actual)
+	}
+	routes := attributes["network"].Attributes["routes"]
+	if prefix, ok := routes.Attributes["prefix"]; !ok || prefix.ResourcePath != "azurerm_foobar.network.routes.prefix" {
+		t.Fatalf("expected the whole of a nested computed block, got %+v", routes)
+	}
+
+	gen.isResource = false
+	if actual := sortAttributeNames(gen.getDataSourceComputedAttributes()); !reflect.DeepEqual(actual, []string{"id", "network", "vault_uri"}) {
+		t.Fatalf("expected the data source to read the computed attributes, got %v", actual)
+	}
+	if output := gen.terraformDataSourceOutputBlock(); !strings.Contains(output, "value = data.azurerm_foobar.this.network\n") {
+		t.Fatalf("expected an output for the computed block, got:\n%s", output)
+	}
+}
+
+func TestSchemaShapes(t *testing.T) {
+	object := &schema.Resource{Schema: map[string]*schema.Schema{"name": {Type: schema.TypeString, Required: true}}}
+	cases := map[string]struct {
+		schema   *schema.Schema
+		shape    string
+		elemType string
+		block    bool
+	}{
+		"string":           {schema: &schema.Schema{Type: schema.TypeString}, shape: shapePrimitive},
+		"list of objects":  {schema: &schema.Schema{Type: schema.TypeList, Elem: object}, shape: shapeListOfObject, block: true},
+		"set of objects":   {schema: &schema.Schema{Type: schema.TypeSet, Elem: object}, shape: shapeSetOfObject, block: true},
+		"list of strings":  {schema: &schema.Schema{Type: schema.TypeList, Elem: &schema.Schema{Type: schema.TypeString}}, shape: shapeListOfPrimitive, elemType: "TypeString"},
+		"set of ints":      {schema: &schema.Schema{Type: schema.TypeSet, Elem: &schema.Schema{Type: schema.TypeInt}}, shape: shapeSetOfPrimitive, elemType: "TypeInt"},
+		"map of bools":     {schema: &schema.Schema{Type: schema.TypeMap, Elem: &schema.Schema{Type: schema.TypeBool}}, shape: shapeMapOfPrimitive, elemType: "TypeBool"},
+		"legacy map":       {schema: &schema.Schema{Type: schema.TypeMap, Elem: &schema.Resource{}}, shape: shapeMapOfPrimitive, elemType: "TypeString"},
+		"untyped elements": {schema: &schema.Schema{Type: schema.TypeSet}, shape: shapeSetOfPrimitive, elemType: "TypeString"},
+	}
+	for name, c := range cases {
+		if shape, elemType := schemaShape(c.schema); shape != c.shape || elemType != c.elemType {
+			t.Fatalf("%s: expected %s of %q, got %s of %q", name, c.shape, c.elemType, shape, elemType)
+		}
+		if isBlock(c.schema) != c.block {
+			t.Fatalf("%s: expected block %t", name, c.block)
+		}
+	}
+
+	gen := testGenerator()
+	gen.resource.Schema["zones"] = &schema.Schema{Type: schema.TypeSet, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.resource.Schema["ports"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Schema{Type: schema.TypeInt}}
+	gen.resource.Schema["rule"] = &schema.Schema{Type: schema.TypeSet, Optional: true, Elem: object}
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+
+	for field, expected := range map[string]string{"zones": "set(string)", "ports": "list(number)", "rule": "set(object({ name = string }))"} {
+		if actual := variableTypeConstraint(attributes[field]); actual != expected {
+			t.Fatalf("expected %s to be %s, got %s", field, expected, actual)
+		}
+	}
+	if actual := templateValue("zones", attributes["zones"]); actual != "${zones}" {
+		t.Fatalf("expected a set to be substituted unquoted, got %s", actual)
+	}
+	if actual := templateValue("tags", attribute{DataTypeString: "TypeMap"}); actual != "${tags}" {
+		t.Fatalf("expected an unshaped map to be substituted unquoted, got %s", actual)
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.serviceName = "Foobar"
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true, Default: "Standard", ValidateFunc: validation.StringInSlice([]string{"Standard", "Premium"}, false)}
+	gen.resource.Schema["capacity"] = &schema.Schema{Type: schema.TypeInt, Optional: true, ValidateFunc: validation.IntBetween(1, 10)}
+	gen.resource.Schema["ratio"] = &schema.Schema{Type: schema.TypeFloat, Optional: true, ValidateFunc: validation.FloatAtLeast(0.5)}
+	gen.resource.Schema["address"] = &schema.Schema{Type: schema.TypeString, Optional: true, ValidateFunc: validation.All(validation.StringLenBetween(7, 18), validation.Any(validation.IsIPv4Address, validation.IsCIDR))}
+	gen.resource.Schema["callback_url"] = &schema.Schema{Type: schema.TypeString, Optional: true, ValidateFunc: validation.IsURLWithHTTPS}
+	gen.resource.Schema["environment"] = &schema.Schema{Type: schema.TypeString, Optional: true, DefaultFunc: schema.EnvDefaultFunc("DLTA_TEST_ENVIRONMENT", "public"), Description: "Sourced from the `DLTA_TEST_ENVIRONMENT` Environment Variable."}
+	gen.resource.Schema["zones"] = &schema.Schema{Type: schema.TypeSet, Optional: true, Elem: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringInSlice([]string{"1", "2", "3"}, false)}}
+	gen.resource.Schema["rule"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"name":     {Type: schema.TypeString, Required: true, ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-z]+$`), "")},
+		"priority": {Type: schema.TypeInt, Optional: true, ValidateFunc: validation.IntInSlice([]int{100, 200})},
+	}}}
+
+	expected := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if err := gen.writeSchemaCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	cached := testGenerator()
+	cached.dltaPath = gen.dltaPath
+	cached.resource = nil
+	if !cached.readSchemaCache() {
+		t.Fatalf("expected the schema to be read from %s", cached.schemaCachePath())
+	}
+	if cached.serviceName != "Foobar" {
+		t.Fatalf("expected the cached service name, got %q", cached.serviceName)
+	}
+	if actual := cached.getAllInputAttributes(cached.resource.Schema, attribute{}, false, cached.resourceName); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the cached schema to give the same attributes\nexpected: %+v\nactual:   %+v", expected, actual)
+	}
+
+	cached.refreshSchema = true
+	if cached.readSchemaCache() {
+		t.Fatalf("expected `-refresh-schema` to ignore the cache")
+	}
+
+	cached.refreshSchema = false
+	if err := os.WriteFile(cached.schemaCachePath(), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cached.readSchemaCache() {
+		t.Fatalf("expected an unreadable cache to be refreshed")
+	}
+}
+
+func TestSchemaDiff(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	writeVersion := func(providerVersion string, resource *schema.Resource) {
+		entry := schemaCacheEntry{ProviderVersion: providerVersion, Schema: make(map[string]*cachedSchema)}
+		for n, s := range resource.Schema {
+			entry.Schema[n] = newCachedSchema(s)
+		}
+		cachePath := gen.schemaCachePathFor(providerVersion)
+		if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cachePath, []byte(writeJson(entry)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("3.59.0", &schema.Resource{Schema: map[string]*schema.Schema{
+		"name":       {Type: schema.TypeString, Required: true},
+		"sku_name":   {Type: schema.TypeString, Optional: true},
+		"access_key": {Type: schema.TypeString, Optional: true},
+		"network_acls": {Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"bypass": {Type: schema.TypeString, Optional: true},
+		}}},
+	}})
+	writeVersion("3.75.0", &schema.Resource{Schema: map[string]*schema.Schema{
+		"name":       {Type: schema.TypeString, Required: true},
+		"sku_name":   {Type: schema.TypeString, Required: true},
+		"access_key": {Type: schema.TypeString, Optional: true, Deprecated: "use access_keys instead"},
+		"network_acls": {Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"default_action": {Type: schema.TypeString, Optional: true},
+		}}},
+	}})
+
+	from, err := gen.readSchemaVersion("3.59.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := gen.readSchemaVersion("3.75.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := schemaChanges{
+		Added:           []string{"azurerm_foobar.network_acls.default_action"},
+		Removed:         []string{"azurerm_foobar.network_acls.bypass"},
+		NewlyRequired:   []string{"azurerm_foobar.sku_name"},
+		NewlyDeprecated: []string{"azurerm_foobar.access_key"},
+	}
+	if actual := gen.schemaDiff(from, to); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+	if actual := gen.schemaDiff(to, to); !reflect.DeepEqual(actual, schemaChanges{}) {
+		t.Fatalf("expected no changes between the same schemas, got %+v", actual)
+	}
+
+	if _, err := gen.readSchemaVersion("3.1.0"); err == nil || !strings.Contains(err.Error(), "no schema cached for provider 3.1.0") {
+		t.Fatalf("expected a version which isn't cached to error, got %+v", err)
+	}
+}
+
+func TestProviderSchema(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	content := `{
+  "format_version": "1.0",
+  "provider_schemas": {
+    "registry.terraform.io/example/widget": {
+      "resource_schemas": {
+        "widget_gadget": {
+          "version": 0,
+          "block": {
+            "attributes": {
+              "id": {"type": "string", "computed": true},
+              "name": {"type": "string", "required": true, "description": "The name."},
+              "size": {"type": "number", "optional": true},
+              "labels": {"type": ["map", "string"], "optional": true},
+              "zones": {"type": ["set", "string"], "optional": true},
+              "token": {"type": "string", "optional": true, "sensitive": true, "deprecated": true},
+              "endpoints": {"type": ["list", ["object", {"host": "string", "port": "number"}]], "computed": true},
+              "settings": {"type": ["object", {"enabled": "bool", "tags": ["map", "string"]}], "required": true},
+              "origins": {"type": ["list", ["object", {"host": "string"}]], "required": true}
+            },
+            "block_types": {
+              "rule": {"nesting_mode": "set", "min_items": 1, "block": {"attributes": {"action": {"type": "string", "required": true}}}},
+              "timeouts": {"nesting_mode": "single", "block": {"attributes": {"create": {"type": "string", "optional": true}}}}
+            }
+          }
+        }
+      },
+      "data_source_schemas": {}
+    }
+  }
+}`
+	if err := os.WriteFile(schemaPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, source, err := readProviderSchema(schemaPath, "widget_gadget", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "example/widget" {
+		t.Fatalf("expected the provider's source, got %q", source)
+	}
+
+	gen := testGenerator()
+	gen.resourceName = "widget_gadget"
+	gen.resource = resource
+	gen.providerSource = source
+
+	attributes := gen.getAllInputAttributes(resource.Schema, attribute{}, false, gen.resourceName)
+	for field, expected := range map[string]string{"name": "string", "size": "number", "labels": "map(string)", "zones": "set(string)", "rule": "set(object({ action = string }))"} {
+		if actual := variableTypeConstraint(attributes[field]); actual != expected {
+			t.Fatalf("expected %s to be %s, got %s", field, expected, actual)
+		}
+	}
+	if _, ok := resource.Schema["timeouts"]; ok || resource.Timeouts == nil || resource.Timeouts.Create == nil || resource.Timeouts.Delete != nil {
+		t.Fatalf("expected the timeouts block to be the resource's timeouts, got %+v", resource.Timeouts)
+	}
+	if !attributes["rule"].Required || !attributes["token"].Sensitive || attributes["token"].Deprecated == "" {
+		t.Fatalf("expected the block's min items and the attribute's flags to carry over, got %+v %+v", attributes["rule"], attributes["token"])
+	}
+	outputs := gen.getAllOutputAttributes(resource.Schema, attribute{}, false, gen.resourceName)
+	if actual := sortAttributeNames(outputs["endpoints"].Attributes); !reflect.DeepEqual(actual, []string{"host", "port"}) {
+		t.Fatalf("expected a list of objects to be a computed block, got %v", actual)
+	}
+
+	if local := gen.terraformLocalBlock(); !strings.Contains(local, `source = "example/widget"`) {
+		t.Fatalf("expected the provider to be required, got:\n%s", local)
+	}
+
+	// an object attribute is only set with attribute syntax
+	gen.providerSchema = schemaPath
+	attributes = gen.getAllInputAttributes(resource.Schema, attribute{}, false, gen.resourceName)
+	for field, expected := range map[string]string{"settings": "object({ enabled = optional(bool), tags = optional(map(string)) })", "origins": "list(object({ host = optional(string) }))", "rule": "set(object({ action = string }))"} {
+		if actual := variableTypeConstraint(attributes[field]); actual != expected {
+			t.Fatalf("expected %s to be %s, got %s", field, expected, actual)
+		}
+	}
+	if attributes["settings"].IsBlock || attributes["origins"].IsBlock || !attributes["rule"].IsBlock {
+		t.Fatalf("expected the object attributes not to be blocks, got %+v %+v", attributes["settings"], attributes["origins"])
+	}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+	module := gen.terraformModuleBlock()
+	for _, expected := range []string{"settings = var.settings", "origins = var.origins"} {
+		if !strings.Contains(strings.Join(strings.Fields(module), " "), expected) {
+			t.Fatalf("expected %q in the module, got:\n%s", expected, module)
+		}
+	}
+	if strings.Contains(module, `dynamic "settings"`) || strings.Contains(module, `dynamic "origins"`) {
+		t.Fatalf("expected no dynamic blocks for object attributes, got:\n%s", module)
+	}
+	if template := gen.terraformTemplateBlock(); !strings.Contains(template, "settings\t\t= ${settings}\n") {
+		t.Fatalf("expected the object to be substituted unquoted, got:\n%s", template)
+	}
+
+	if _, _, err := readProviderSchema(schemaPath, "widget_gadget", false); err == nil || !strings.Contains(err.Error(), "Data Source") {
+		t.Fatalf("expected a data source which isn't in the schema to error, got %+v", err)
+	}
+}
+
+func TestAwsResources(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	content := `{
+  "format_version": "1.0",
+  "provider_schemas": {
+    "registry.terraform.io/hashicorp/aws": {
+      "resource_schemas": {
+        "aws_sqs_queue": {
+          "version": 0,
+          "block": {
+            "attributes": {
+              "id": {"type": "string", "computed": true},
+              "name": {"type": "string", "optional": true, "computed": true},
+              "delay_seconds": {"type": "number", "optional": true},
+              "tags": {"type": ["map", "string"], "optional": true},
+              "arn": {"type": "string", "computed": true}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+	if err := os.WriteFile(schemaPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, source, err := readProviderSchema(schemaPath, "aws_sqs_queue", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != awsProviderSource {
+		t.Fatalf("expected the aws provider, got %q", source)
+	}
+
+	gen := testGenerator()
+	gen.resourceName = "aws_sqs_queue"
+	gen.resource = resource
+	gen.providerSource = source
+
+	if shortCode := gen.resourceShortCode(gen.resourceName); shortCode != "sqs" {
+		t.Fatalf("expected the aws abbreviation, got %q", shortCode)
+	}
+	if _, _, ok := gen.schemaProvider(); ok {
+		t.Fatal("expected the hashicorp aws provider not to need declaring")
+	}
+	naming := gen.getNamingStruct(gen.resourceName, false)
+	if naming.Transforms.MaxLength != 80 || naming.Transforms.Lowercase {
+		t.Fatalf("expected the name rule's transforms, got %+v", naming.Transforms)
+	}
+	preconditions := strings.Join(gen.namePreconditions(), "")
+	if !strings.Contains(preconditions, "length(local.name) <= 80") {
+		t.Fatalf("expected the name rule to be enforced, got:\n%s", preconditions)
+	}
+
+	for name, rule := range awsNameRules {
+		if _, err := regexp.Compile(fmt.Sprintf("^[%s]+$", rule.Characters)); err != nil {
+			t.Fatalf("expected %s's characters to be a character class, got %s", name, err)
+		}
+		if _, ok := awsAbbreviations[name]; !ok {
+			t.Fatalf("expected %s to have an abbreviation", name)
+		}
+	}
+	for name, shortCode := range awsAbbreviations {
+		if !shortCodeRegex.MatchString(shortCode) {
+			t.Fatalf("expected %s's short code to be safe in a name, got %q", name, shortCode)
+		}
+	}
+}
+
+func TestGcpResources(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	content := `{
+  "format_version": "1.0",
+  "provider_schemas": {
+    "registry.terraform.io/hashicorp/google": {
+      "resource_schemas": {
+        "google_storage_bucket": {
+          "version": 0,
+          "block": {
+            "attributes": {
+              "id": {"type": "string", "computed": true},
+              "name": {"type": "string", "required": true},
+              "location": {"type": "string", "required": true},
+              "labels": {"type": ["map", "string"], "optional": true},
+              "url": {"type": "string", "computed": true}
+            },
+            "block_types": {
+              "versioning": {"nesting_mode": "list", "max_items": 1, "block": {"attributes": {"enabled": {"type": "bool", "required": true}}}}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+	if err := os.WriteFile(schemaPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, source, err := readProviderSchema(schemaPath, "google_storage_bucket", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if providerServiceNames[source] != gcpServiceName {
+		t.Fatalf("expected the google provider to be a GCP service, got %q", source)
+	}
+
+	gen := testGenerator()
+	gen.resourceName = "google_storage_bucket"
+	gen.resource = resource
+	gen.providerSource = source
+
+	if shortCode := gen.resourceShortCode(gen.resourceName); shortCode != "gcs" {
+		t.Fatalf("expected the gcp abbreviation, got %q", shortCode)
+	}
+	naming := gen.getNamingStruct(gen.resourceName, false)
+	if naming.Transforms.MaxLength != 63 || !naming.Transforms.Lowercase {
+		t.Fatalf("expected the name rule's transforms, got %+v", naming.Transforms)
+	}
+	gen.providerSchema = schemaPath
+	if header := gen.fileHeader("main.tf"); !strings.Contains(header, "-provider-schema "+schemaPath) {
+		t.Fatalf("expected the regenerate command to read the same schema, got:\n%s", header)
+	}
+	attributes := gen.getAllInputAttributes(resource.Schema, attribute{}, false, gen.resourceName)
+	if actual := variableTypeConstraint(attributes["versioning"]); actual != "object({ enabled = bool })" {
+		t.Fatalf("expected a single nested block to be an object, got %s", actual)
+	}
+
+	shortCodes := make(map[string]string)
+	for _, abbreviations := range []map[string]string{cafAbbreviations, awsAbbreviations} {
+		for name, shortCode := range abbreviations {
+			shortCodes[shortCode] = name
+		}
+	}
+	for name, shortCode := range gcpAbbreviations {
+		if other, ok := shortCodes[shortCode]; ok {
+			t.Fatalf("expected %s's short code %q not to collide with %s", name, shortCode, other)
+		}
+	}
+	for name := range gcpNameRules {
+		if _, ok := gcpAbbreviations[name]; !ok {
+			t.Fatalf("expected %s to have an abbreviation", name)
+		}
+	}
+}
+
+func TestRestSpecBody(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "containerapps.json")
+	content := `{
+  "swagger": "2.0",
+  "info": {"version": "2024-03-01"},
+  "paths": {
+    "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.App/containerApps/{containerAppName}": {
+      "get": {"parameters": []},
+      "put": {"parameters": [
+        {"$ref": "../../../common-types/resource-management/v3/types.json#/parameters/SubscriptionIdParameter"},
+        {"name": "containerAppEnvelope", "in": "body", "required": true, "schema": {"$ref": "#/definitions/ContainerApp"}}
+      ]}
+    }
+  },
+  "definitions": {
+    "TrackedResource": {
+      "properties": {
+        "id": {"type": "string", "readOnly": true},
+        "location": {"type": "string"},
+        "tags": {"type": "object", "additionalProperties": {"type": "string"}}
+      },
+      "required": ["location"]
+    },
+    "ContainerApp": {
+      "allOf": [{"$ref": "#/definitions/TrackedResource"}],
+      "properties": {
+        "identity": {"$ref": "../../../common-types/resource-management/v5/managedidentity.json#/definitions/ManagedServiceIdentity"},
+        "properties": {"x-ms-client-flatten": true, "$ref": "#/definitions/ContainerAppProperties"}
+      }
+    },
+    "ContainerAppProperties": {
+      "properties": {
+        "provisioningState": {"type": "string", "readOnly": true},
+        "environmentId": {"type": "string", "description": "Resource ID of the environment."},
+        "workloadProfileName": {"type": "string", "maxLength": 64},
+        "configuration": {"$ref": "#/definitions/Configuration"}
+      },
+      "required": ["environmentId"]
+    },
+    "Configuration": {
+      "properties": {
+        "activeRevisionsMode": {"type": "string", "enum": ["Multiple", "Single"]},
+        "maxInactiveRevisions": {"type": "integer", "minimum": 0, "maximum": 100},
+        "secrets": {"type": "array", "items": {"$ref": "#/definitions/Secret"}},
+        "labels": {"type": "array", "items": {"type": "string"}},
+        "details": {"$ref": "#/definitions/Configuration"}
+      }
+    },
+    "Secret": {
+      "properties": {
+        "name": {"type": "string"},
+        "value": {"type": "string", "x-ms-secret": true}
+      }
+    }
+  }
+}`
+	if err := os.WriteFile(specPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := readRestSpecBody(specPath, "Microsoft.App/containerApps@2024-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := sortedKeys(body.Schema); !reflect.DeepEqual(actual, []string{"properties"}) {
+		t.Fatalf("expected the azapi arguments and external references to be left out of the body, got %v", actual)
+	}
+	gen := testGenerator()
+	gen.azapiType = "Microsoft.App/containerApps@2024-03-01"
+	gen.resource = azapiResourceSchema()
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if actual := variableTypeConstraint(attributes["body"]); actual != azapiBodyTypeConstraint {
+		t.Fatalf("expected the body to be untyped without a spec, got %s", actual)
+	}
+
+	gen.azapiBody = body
+	attributes = gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	expected := "object({ properties = optional(object({ configuration = optional(object({ activeRevisionsMode = optional(string), labels = optional(list(string)), maxInactiveRevisions = optional(number), secrets = optional(list(object({ name = optional(string), value = optional(string) }))) })), environmentId = string, workloadProfileName = optional(string) })) })"
+	if actual := variableTypeConstraint(attributes["body"]); actual != expected {
+		t.Fatalf("expected the body to be typed from the spec\nexpected: %s\nactual:   %s", expected, actual)
+	}
+	bodyAttributes := gen.getAllInputAttributes(body.Schema, attribute{}, true, gen.resourceName+".body")
+	configuration := bodyAttributes["properties"].Attributes["configuration"]
+	if actual := configuration.Attributes["activeRevisionsMode"].PossibleValues; !reflect.DeepEqual(actual, []string{"Multiple", "Single"}) {
+		t.Fatalf("expected the enum as possible values, got %v", actual)
+	}
+	if !configuration.Attributes["secrets"].Attributes["value"].Sensitive {
+		t.Fatal("expected a secret to be sensitive")
+	}
+
+	for _, c := range []struct {
+		azapiType string
+		expected  string
+	}{
+		// a spec of another API version
+		{azapiType: "Microsoft.App/containerApps@2023-05-01", expected: "API version"},
+		// a type which isn't in the spec
+		{azapiType: "Microsoft.App/managedEnvironments@2024-03-01", expected: "no PUT"},
+	} {
+		if _, err := readRestSpecBody(specPath, c.azapiType); err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("%s: expected an error containing %q, got %+v", c.azapiType, c.expected, err)
+		}
+	}
+}
+
+func TestUtilityResources(t *testing.T) {
+	for name, schemaFn := range utilityResourceSchemas {
+		if err := schemaFn().InternalValidate(nil, true); err != nil {
+			t.Fatalf("expected %s to have a valid schema, got %s", name, err)
+		}
+		if _, ok := utilityProviders[strings.SplitN(name, "_", 2)[0]]; !ok {
+			t.Fatalf("expected %s to have a provider", name)
+		}
+	}
+
+	gen := testGenerator()
+	gen.resourceName = "tls_private_key"
+	gen.resource = utilityResourceSchemas[gen.resourceName]()
+
+	if gen.hasName() || gen.hasCost() || len(gen.namePreconditions()) > 0 {
+		t.Fatal("expected a helper resource to have neither a name nor a cost")
+	}
+	if _, ok := gen.getModuleOutputAttributes()["name"]; ok {
+		t.Fatal("expected no name output for a resource without a name")
+	}
+	if strings.Contains(gen.terraformModuleBlock(), "local.name") {
+		t.Fatal("expected the module not to set a name")
+	}
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if actual := getSchemaPossibleValues(gen.resource.Schema["algorithm"]); !reflect.DeepEqual(actual, []string{"RSA", "ECDSA", "ED25519"}) {
+		t.Fatalf("expected the algorithms as possible values, got %v", actual)
+	}
+	if attributes["rsa_bits"].Default != "2048" {
+		t.Fatalf("expected the rsa_bits default, got %+v", attributes["rsa_bits"])
+	}
+	outputs := gen.getAllOutputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if !outputs["private_key_pem"].Sensitive {
+		t.Fatal("expected the private key to be sensitive")
+	}
+}
+
+func TestForceNewAnnotations(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["name"].ForceNew = true
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Required: true, Description: "The SKU."}
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Required: true, ForceNew: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"subnet_id": {Type: schema.TypeString, Required: true},
+	}}}
+	gen.resource.Schema["zone"] = &schema.Schema{Type: schema.TypeString, Optional: true, ForceNew: true, Description: "The zone."}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if declaration := variableDeclaration("zone", attributes["zone"]); !strings.Contains(declaration, `description = "The zone. (forces recreation)"`) {
+		t.Fatalf("expected the description to note the recreation, got:\n%s", declaration)
+	}
+	if declaration := variableDeclaration("sku_name", attributes["sku_name"]); strings.Contains(declaration, forceNewNote) {
+		t.Fatalf("expected no note for an attribute updated in place, got:\n%s", declaration)
+	}
+
+	if pp := gen.getPalletProp(attributes["zone"], "zone"); !pp.ForceNew {
+		t.Fatal("expected the control to be flagged force new")
+	}
+	if pp := gen.getPalletProp(attributes["sku_name"], "sku_name"); pp.ForceNew {
+		t.Fatal("expected the control not to be flagged force new")
+	}
+
+	// zone isn't published as it's optional, the subnet is destructive through its block
+	expected := []string{"azurerm_foobar.name", "azurerm_foobar.network.subnet_id"}
+	if actual := gen.destructiveInputs(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+
+	creator := map[string]interface{}{"controls": []interface{}{map[string]interface{}{"id": "zone"}}}
+	if err := paletteMigrations[8](creator); err != nil {
+		t.Fatal(err)
+	}
+	if control := creator["controls"].([]interface{})[0].(map[string]interface{}); control["force_new"] != false {
+		t.Fatalf("expected the migration to default force_new, got %+v", control)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	gen := testGenerator()
+	create, del := 30*time.Minute, 90*time.Minute
+	gen.resource.Timeouts = &schema.ResourceTimeout{Create: &create, Delete: &del}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	attributes := gen.injectAttributes()
+	// the provider's timeouts are the defaults
+	for name, expected := range map[string]string{"timeouts_create": "30m", "timeouts_delete": "1h30m"} {
+		if actual := attributes[name].Default; actual != expected {
+			t.Fatalf("expected the provider's timeout %q as the default of %s, got %q", expected, name, actual)
+		}
+	}
+	if _, ok := attributes["timeouts_update"]; ok {
+		t.Fatal("expected no update timeout when the resource has none")
+	}
+
+	module := gen.terraformModuleBlock()
+	if !strings.Contains(module, "\ttimeouts {\n\t\tcreate = var.timeouts_create == \"\" ? null : var.timeouts_create\n\t\tdelete = var.timeouts_delete == \"\" ? null : var.timeouts_delete\n\t}\n") {
+		t.Fatalf("expected a timeouts block, got:\n%s", module)
+	}
+	if err := validateHcl("main.tf", module); err != nil {
+		t.Fatalf("expected a valid module, got %+v:\n%s", err, module)
+	}
+	if variables := gen.terraformVariableBlock(); !strings.Contains(variables, "variable \"timeouts_create\" {\n\tdescription = \"How long to wait for the resource to create e.g. `1h30m`, defaults to 30m.\"\n\ttype = string\n\tdefault = \"30m\"") {
+		t.Fatalf("expected a variable for the create timeout, got:\n%s", variables)
+	}
+	if pp := gen.getPalletProp(attributes["timeouts_delete"], "timeouts_delete"); pp.CurrentValue != "1h30m" || pp.Validators["pattern"] != timeoutPattern {
+		t.Fatalf("expected a control defaulting to the provider's timeout, got %+v", pp)
+	}
+
+	if err := gen.writeSchemaCache(); err != nil {
+		t.Fatal(err)
+	}
+	cached := gen
+	cached.resource = nil
+	if !cached.readSchemaCache() || cached.resource.Timeouts == nil || *cached.resource.Timeouts.Delete != del || cached.resource.Timeouts.Update != nil {
+		t.Fatalf("expected the timeouts to be cached, got %+v", cached.resource)
+	}
+
+	gen.isDataSource = true
+	if len(gen.getTimeoutAttributes()) > 0 {
+		t.Fatal("expected no timeouts for a data source")
+	}
+}
+
+func TestIdentityBlock(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["identity"] = &schema.Schema{Type: schema.TypeList, Required: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"type":         {Type: schema.TypeString, Required: true, ValidateFunc: validation.StringInSlice([]string{"SystemAssigned", "UserAssigned", "SystemAssigned, UserAssigned"}, false)},
+		"identity_ids": {Type: schema.TypeSet, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+		"principal_id": {Type: schema.TypeString, Computed: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	// the identity ids are optional so are published by hand
+	summary := gen.readResourceProperties()
+	summary["azurerm_foobar.identity.identity_ids"] = summaryAttribute{Published: true}
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(summary)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "variable \"identity\" {\n\tdescription = \"\"\n\ttype = object({ type = string, identity_ids = optional(list(string)) })\n"
+	if variables := gen.terraformVariableBlock(); !strings.Contains(variables, expected) || strings.Contains(variables, "variable \"type\"") {
+		t.Fatalf("expected a single identity variable, got:\n%s", variables)
+	}
+
+	module := gen.terraformModuleBlock()
+	if !strings.Contains(module, "\tdynamic \"identity\" {\n\t\tfor_each = try(var.identity.type, \"\") == \"\" ? [] : [var.identity]\n\t\tcontent {\n\t\t\ttype = identity.value.type\n\t\t\tidentity_ids = identity.value.identity_ids\n") {
+		t.Fatalf("expected a dynamic identity block, got:\n%s", module)
+	}
+	if err := validateHcl("main.tf", module); err != nil {
+		t.Fatalf("expected a valid module, got %+v:\n%s", err, module)
+	}
+	if template := gen.terraformTemplateBlock(); !strings.Contains(template, "identity\t\t= { type = \"${identity_type}\", identity_ids = ${identity_ids} }") {
+		t.Fatalf("expected the identity object in the template, got:\n%s", template)
+	}
+
+	controls := make(map[string]PaletteProp)
+	for _, pp := range gen.paletteCreator().Props {
+		controls[pp.ID] = pp
+	}
+	if pp := controls[identityTypeControl]; pp.Type != "select" || len(pp.Options) != 3 || pp.Options[0].Value != "SystemAssigned" {
+		t.Fatalf("expected a select of the identity types, got %+v", pp)
+	}
+	if pp := controls["identity_ids"]; pp.Filter == nil || *pp.Filter != "identity_type in ['UserAssigned', 'SystemAssigned, UserAssigned']" {
+		t.Fatalf("expected the identity ids only for a user assigned identity, got %+v", pp)
+	}
+	if _, ok := controls["type"]; ok {
+		t.Fatal("expected no control for the type itself")
+	}
+
+	// a block with arguments besides the type and identity ids is left as it is
+	identity := gen.injectAttributes()["identity"]
+	identity.Attributes["tenant_id"] = attribute{DataTypeString: "TypeString", Optional: true}
+	if gen.isIdentityBlock(identity) {
+		t.Fatal("expected a block with other arguments not to be an identity block")
+	}
+}
+
+func TestSchemaDepth(t *testing.T) {
+	gen := testGenerator()
+
+	// a block which contains itself
+	recursive := &schema.Resource{Schema: map[string]*schema.Schema{
+		"value": {Type: schema.TypeString, Optional: true},
+	}}
+	recursive.Schema["child"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: recursive}
+	gen.resource.Schema["rule"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: recursive}
+
+	rule := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)["rule"]
+	if _, ok := rule.Attributes["value"]; !ok {
+		t.Fatalf("expected the block's attributes, got %+v", rule.Attributes)
+	}
+	if _, ok := rule.Attributes["child"]; ok {
+		t.Fatal("expected the block containing itself to be left out")
+	}
+	if cached := newCachedSchema(gen.resource.Schema["rule"]); cached.ElemResource["child"].ElemResource != nil {
+		t.Fatalf("expected the cycle to be left out of the cache, got %+v", cached.ElemResource["child"])
+	}
+
+	// blocks nested deeper than the max depth
+	nested := func(elem *schema.Resource) *schema.Schema {
+		return &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: elem}
+	}
+	leaf := &schema.Resource{Schema: map[string]*schema.Schema{"value": {Type: schema.TypeString, Optional: true}}}
+	middle := &schema.Resource{Schema: map[string]*schema.Schema{"inner": nested(leaf), "value": {Type: schema.TypeString, Optional: true}}}
+	gen.resource.Schema["rule"] = nested(middle)
+
+	if rule := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)["rule"]; len(rule.Attributes["inner"].Attributes) != 1 {
+		t.Fatalf("expected the blocks within the default depth, got %+v", rule)
+	}
+	gen.maxDepth = 1
+	rule = gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)["rule"]
+	if _, ok := rule.Attributes["inner"]; ok || len(rule.Attributes) != 1 {
+		t.Fatalf("expected the block past the max depth to be left out, got %+v", rule.Attributes)
+	}
+	if header := gen.fileHeader("main.tf"); !strings.Contains(header, "-max-depth 1") {
+		t.Fatalf("expected the regenerate command to keep the max depth, got:\n%s", header)
+	}
+}
+
+func TestServiceLayout(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.serviceName = "Key Vault"
+	gen.websiteCategories = []string{"Key Vault"}
+
+	if actual := gen.resourceDir("module"); actual != filepath.Join(gen.dltaPath, "r", "azurerm_foobar", "module") {
+		t.Fatalf("expected the flat layout by default, got %s", actual)
+	}
+
+	gen.layout = layoutService
+	if actual := gen.resourceDir("module"); actual != filepath.Join(gen.dltaPath, "r", "key-vault", "azurerm_foobar", "module") {
+		t.Fatalf("expected the resource within its service, got %s", actual)
+	}
+	if template := gen.terraformTemplateBlock(); !strings.Contains(template, `"__modules_path__//r//key-vault//azurerm_foobar//module?ref=main"`) {
+		t.Fatalf("expected the module source within the service, got:\n%s", template)
+	}
+	if design := gen.paletteDesign(); design.Service != "Key Vault" {
+		t.Fatalf("expected the service in the palette design, got %+v", design)
+	}
+
+	gen.writeInitResourceProperties()
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if service := string(raw[summaryServiceKey]); service != `"Key Vault"` {
+		t.Fatalf("expected the service in the summary, got %s", service)
+	}
+	if _, sa := parseResourceSummary(content); len(sa) != 2 {
+		t.Fatalf("expected the service not to be read as an attribute, got %+v", sa)
+	}
+
+	// the resource is found within its service when it's retired, as it isn't looked up
+	retiring := testGenerator()
+	retiring.dltaPath = gen.dltaPath
+	retiring.layout = layoutService
+	if actual := retiring.resourcePropertiesPath(); actual != gen.resourcePropertiesPath() {
+		t.Fatalf("expected %s, got %s", gen.resourcePropertiesPath(), actual)
+	}
+
+	dirs, err := scaffoldedDirs(gen.dltaPath, "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"azurerm_foobar": filepath.Join(gen.dltaPath, "r", "key-vault", "azurerm_foobar")}; !reflect.DeepEqual(dirs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, dirs)
+	}
+}
+
+func TestConstraintPreconditions(t *testing.T) {
+	attributes := map[string]attribute{
+		"name":       {DataTypeString: "TypeString"},
+		"public_key": {DataTypeString: "TypeString", ConflictsWith: []string{"secret"}, ExactlyOneOf: []string{"secret", "public_key"}},
+		"secret":     {DataTypeString: "TypeString", ConflictsWith: []string{"public_key"}, ExactlyOneOf: []string{"secret", "public_key"}},
+		"nested":     {DataTypeString: "TypeString", RequiredWith: []string{"block.0.field"}},
+	}
+
+	expected := []string{
+		"\t\tprecondition {\n\t\t\tcondition     = var.public_key == null || var.secret == null\n\t\t\terror_message = \"Only one of `public_key` or `secret` can be specified.\"\n\t\t}\n",
+		"\t\tprecondition {\n\t\t\tcondition     = length([for v in [var.public_key, var.secret] : v if v != null]) == 1\n\t\t\terror_message = \"Exactly one of `public_key`, `secret` must be specified.\"\n\t\t}\n",
+	}
+
+	actual := constraintPreconditions(attributes)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d preconditions, got %d: %+v", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected:\n%s\ngot:\n%s", expected[i], actual[i])
+		}
+	}
+
+	validators := constraintValidators(attributes["nested"])
+	if v, ok := validators["requiredWith"].([]string); !ok || len(v) != 1 || v[0] != "field" {
+		t.Fatalf("expected requiredWith to reference the `field` control, got %+v", validators)
+	}
+}
+
+func TestEscapeHclString(t *testing.T) {
+	input := "The \"name\" of the ${resource}\nsee C:\\docs or %{if}"
+	expected := `The \"name\" of the $${resource}\nsee C:\\docs or %%{if}`
+	if actual := escapeHclString(input); actual != expected {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+
+	if err := validateHcl("variables.tf", fmt.Sprintf("variable \"x\" {\n\tdescription = \"%s\"\n}\n", escapeHclString(input))); err != nil {
+		t.Fatalf("expected the escaped description to be valid HCL, got: %+v", err)
+	}
+}
+
+func TestEscapeSqlLiteral(t *testing.T) {
+	if actual := escapeSqlLiteral(`{"description": "the resource's name"}`); actual != `{"description": "the resource''s name"}` {
+		t.Fatalf("expected single quotes to be doubled, got %s", actual)
+	}
+}
+
+func TestTerraformOutputBlock(t *testing.T) {
+	gen := testGenerator()
+
+	expected := `output "id" {
+	description = "The resource id"
+	value = azurerm_foobar.this.id
+}
+output "name" {
+	description = "The resource name"
+	value = azurerm_foobar.this.name
+}
+`
+	if actual := gen.terraformOutputBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestTemplateComment(t *testing.T) {
+	cases := []struct {
+		description string
+		expected    string
+	}{
+		// the description is collapsed onto a single comment line
+		{description: "The ID of the Subnet.\n  Changing this forces a new resource to be created.", expected: "\t# The ID of the Subnet. Changing this forces a new resource to be created.\n"},
+		// an empty description has no comment
+		{description: "", expected: ""},
+	}
+
+	for _, c := range cases {
+		if actual := templateComment(c.description); actual != c.expected {
+			t.Fatalf("%q: expected %q, got %q", c.description, c.expected, actual)
+		}
+	}
+}
+
+func TestNamingExpression(t *testing.T) {
+	gen := testGenerator()
+	gen.resourceName = "azurerm_storage_account"
+
+	attributes := map[string]attribute{
+		"name": {DataTypeString: "TypeString", ResourcePath: "azurerm_storage_account.name"},
+		"delegation": {IsBlock: true, ResourcePath: "azurerm_storage_account.delegation", Attributes: map[string]attribute{
+			"name": {DataTypeString: "TypeString", ResourcePath: "azurerm_storage_account.delegation.name"},
+			"service_delegation": {IsBlock: true, ResourcePath: "azurerm_storage_account.delegation.service_delegation", Attributes: map[string]attribute{
+				"name": {DataTypeString: "TypeString", ResourcePath: "azurerm_storage_account.delegation.service_delegation.name", PossibleValues: []string{"Microsoft.Web/serverFarms"}},
+			}},
+		}},
+	}
+
+	if actual := gen.nameExpression(attributes["delegation"].Attributes["name"]); actual != "local.delegation_name" {
+		t.Fatalf("expected a generated nested name to reference its local, got %s", actual)
+	}
+	if actual := gen.nameExpression(attributes["delegation"].Attributes["service_delegation"].Attributes["name"]); actual != "var.azurerm_storage_account_delegation_service_delegation_name" {
+		t.Fatalf("expected a constrained nested name to reference a variable, got %s", actual)
+	}
+
+	expected := `substr(lower(replace(format("%s%s%s%s%s%s",var.dlta_vendor_asset_short_code,var.dlta_business_short_code,var.dlta_application_short_code,var.dlta_environment_char,var.dlta_location_short_code,var.dlta_instance_id), "/[^a-zA-Z0-9]/", "")), 0, 24)`
+	if actual := gen.namingExpression(""); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestBackendConfigBlocks(t *testing.T) {
+	gen := testGenerator()
+	gen.resourceName = "terraform_azurerm"
+	gen.dltaPath = t.TempDir()
+
+	config := `{
+		"d": {"resource_group_name": "rg-tfstate-d", "storage_account_name": "sttfstated", "container_name": "tfstate"},
+		"p": {"resource_group_name": "rg-tfstate-p", "storage_account_name": "sttfstatep", "container_name": "tfstate", "key": "${dlta_environment_char}\\\"quoted\".tfstate"}
+	}`
+	writeConfig(t, gen.dltaPath, "backend.json", config)
+
+	blocks, err := gen.backendConfigBlocks()
+	if err != nil {
+		t.Fatalf("reading backend config: %+v", err)
+	}
+
+	expected := `resource_group_name  = "rg-tfstate-d"
+storage_account_name = "sttfstated"
+container_name       = "tfstate"
+key                  = "${dlta_business_short_code}-${dlta_application_short_code}-${dlta_environment_char}-${dlta_location_short_code}.tfstate"
+`
+	if len(blocks) != 2 || blocks["d"] != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%+v", expected, blocks)
+	}
+
+	expectedKey := `key                  = "${dlta_environment_char}\\\"quoted\".tfstate"`
+	if !strings.Contains(blocks["p"], expectedKey) {
+		t.Fatalf("expected the key to be escaped with its tokens intact:\n%s\ngot:\n%s", expectedKey, blocks["p"])
+	}
+}
+
+func TestDataSourceBlockVariables(t *testing.T) {
+	gen := testGenerator()
+	gen.isResource = false
+	gen.isDataSource = true
+	gen.dltaPath = t.TempDir()
+	for _, n := range []string{"primary", "secondary"} {
+		gen.resource.Schema[n] = &schema.Schema{
+			Type:     schema.TypeList,
+			Required: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+				"key": {Type: schema.TypeString, Required: true},
+			}},
+		}
+	}
+	gen.writeInitResourceProperties()
+
+	module := gen.terraformDataBlock() + gen.terraformDataSourceVariableBlock()
+	if err := validateHcl("data.tf", module); err != nil {
+		t.Fatalf("expected a valid module, got %+v:\n%s", err, module)
+	}
+	for _, n := range []string{"primary", "secondary"} {
+		if !strings.Contains(module, fmt.Sprintf("key = var.azurerm_foobar_%s_key", n)) {
+			t.Fatalf("expected the %s key to be looked up by its own variable, got:\n%s", n, module)
+		}
+		if !strings.Contains(module, fmt.Sprintf("variable \"azurerm_foobar_%s_key\"", n)) {
+			t.Fatalf("expected the %s key to be declared by path, got:\n%s", n, module)
+		}
+	}
+
+	template := gen.terraformTemplateBlock()
+	palette := gen.dltaPalletteCodeBlock()
+	for _, n := range []string{"azurerm_foobar_primary_key", "azurerm_foobar_secondary_key"} {
+		if !strings.Contains(template, fmt.Sprintf("%s\t\t= \"${%s}\"", n, n)) || !strings.Contains(palette, fmt.Sprintf("\"id\": \"%s\"", n)) {
+			t.Fatalf("expected the template to pass %s from its control, got:\n%s", n, template)
+		}
+	}
+}
+
+func TestTerraformDataSourceOutputBlock(t *testing.T) {
+	gen := testGenerator()
+	gen.isResource = false
+	gen.isDataSource = true
+	gen.resource.Schema["location"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "The Azure Region where the Foobar exists.",
+	}
+
+	expected := `output "id" {
+	description = "The resource id"
+	value = data.azurerm_foobar.this.id
+}
+output "location" {
+	description = "The Azure Region where the Foobar exists."
+	value = data.azurerm_foobar.this.location
+}
+`
+	if actual := gen.terraformDataSourceOutputBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestVariableDeclaration(t *testing.T) {
+	expected := `variable "sku_name" {
+	description = "The SKU"
+	type = string
+	default = null
+}
+`
+	if actual := variableDeclaration("sku_name", attribute{DataTypeString: "TypeString", Optional: true, Description: "The SKU"}); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	block := attribute{IsBlock: true, MaxItems: 1, Attributes: map[string]attribute{
+		"type":         {DataTypeString: "TypeString", Required: true},
+		"identity_ids": {DataTypeString: "TypeList", Optional: true},
+		"ports":        {DataTypeString: "TypeSet", ElemType: "TypeInt", Required: true},
+		"tags":         {DataTypeString: "TypeMap", Optional: true},
+	}}
+	expected = "object({ identity_ids = optional(list(string)), ports = set(number), tags = optional(map(string)), type = string })"
+	if actual := variableTypeConstraint(block); actual != expected {
+		t.Fatalf("expected optional attributes to be wrapped in optional() and collections to have an element type, got %s", actual)
+	}
+}
+
+func TestTerraformTfvarsExample(t *testing.T) {
+	variables := []moduleVariable{
+		{Name: "dlta_environment_char", Attribute: dlta_environment_char},
+		{Name: "https_only", Attribute: attribute{DataTypeString: "TypeBool", Optional: true, Default: "true", Description: "Should HTTPS only be enabled?"}},
+		{Name: "sku_name", Attribute: attribute{DataTypeString: "TypeString", Required: true, PossibleValues: []string{"standard", "premium"}, Description: "The SKU name."}},
+		{Name: "tags", Attribute: attribute{DataTypeString: "TypeMap", Optional: true}},
+	}
+
+	expected := `# Single character variable
+dlta_environment_char = "d"
+
+# Should HTTPS only be enabled? (optional)
+https_only = true
+
+# The SKU name.
+sku_name = "standard"
+
+# (optional)
+# tags = {}
+`
+	if actual := terraformTfvarsExample(variables); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestRenderHclBody(t *testing.T) {
+	features := map[string]interface{}{
+		"key_vault": map[string]interface{}{
+			"purge_soft_delete_on_destroy": false,
+		},
+		"resource_group": map[string]interface{}{
+			"prevent_deletion_if_contains_resources": true,
+		},
+	}
+
+	expected := "\t\tkey_vault {\n\t\t\tpurge_soft_delete_on_destroy = false\n\t\t}\n\t\tresource_group {\n\t\t\tprevent_deletion_if_contains_resources = true\n\t\t}\n"
+	if actual := renderHclBody(features, 2); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	if err := validateFeatures(features); err != nil {
+		t.Fatalf("expected the features to be valid, got: %+v", err)
+	}
+
+	features["key_vualt"] = map[string]interface{}{}
+	if err := validateFeatures(features); err == nil || !strings.Contains(err.Error(), "features.key_vualt") {
+		t.Fatalf("expected an error for the misspelt feature, got: %+v", err)
+	}
+}
+
+func TestTerraformAzapiModuleBlock(t *testing.T) {
+	gen := testGenerator()
+	gen.resourceName = "container_app"
+	gen.azapiType = "Microsoft.App/containerApps@2023-05-01"
+	gen.resource = azapiResourceSchema()
+	gen.dltaPath = t.TempDir()
+
+	summaryDir := filepath.Join(gen.dltaPath, "r", gen.resourceName, "resource")
+	if err := os.MkdirAll(summaryDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	summary := `{
+	"container_app.name": {"Published": true},
+	"container_app.location": {"Published": true},
+	"container_app.parent_id": {"Published": true},
+	"container_app.body": {"Published": true},
+	"container_app.tags": {"Published": true}
+}`
+	if err := os.WriteFile(filepath.Join(summaryDir, "container_app.json"), []byte(summary), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `terraform {
+	required_providers {
+		azapi = {
+			source = "azure/azapi"
+		}
+	}
+}
+resource "azapi_resource" "this" {
+	type = "Microsoft.App/containerApps@2023-05-01"
+	name = local.name
+	body = jsonencode(var.body)
+	location = var.location
+	parent_id = var.parent_id
+	tags = var.tags
+}
+`
+	if actual := gen.terraformModuleBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	body := gen.getPublishedAttributes()["body"]
+	if actual := variableTypeConstraint(body); actual != azapiBodyTypeConstraint {
+		t.Fatalf("expected the body to be typed as %q, got %q", azapiBodyTypeConstraint, actual)
+	}
+
+	if actual := gen.moduleResourceAddress(); actual != "azapi_resource.this" {
+		t.Fatalf("expected the azapi_resource address, got %q", actual)
+	}
+
+	for _, v := range []string{"Microsoft.App/containerApps@2023-05-01", "Microsoft.Network/virtualNetworks/subnets@2023-04-01-preview"} {
+		if !azapiTypeRegex.MatchString(v) {
+			t.Fatalf("expected %q to be a valid azapi type", v)
+		}
+	}
+	if azapiTypeRegex.MatchString("Microsoft.App/containerApps") {
+		t.Fatalf("expected a type without an API version to be invalid")
+	}
+}
+
+func TestModuleRef(t *testing.T) {
+	cases := []struct {
+		refType  string
+		version  string
+		expected string
+		valid    bool
+	}{
+		{refType: "branch", version: "main", expected: "main", valid: true},
+		{refType: "branch", version: "release/2023", expected: "release/2023", valid: true},
+		{refType: "branch", version: "main?x", valid: false},
+		{refType: "tag", version: "v1.2.0", expected: "v1.2.0", valid: true},
+		{refType: "tag", version: "1.2.0", expected: "1.2.0", valid: true},
+		{refType: "tag", version: "latest", valid: false},
+		{refType: "commit", version: "4f2c9e1", expected: "4f2c9e1", valid: true},
+		{refType: "commit", version: "main", valid: false},
+		{refType: "release", version: "v1.2.0", valid: false},
+	}
+
+	for _, c := range cases {
+		err := validateModuleRef(c.refType, c.version)
+		if c.valid != (err == nil) {
+			t.Fatalf("%s %q: expected valid to be %t, got: %+v", c.refType, c.version, c.valid, err)
+		}
+		if !c.valid {
+			continue
+		}
+
+		gen := testGenerator()
+		gen.moduleRefType = c.refType
+		gen.moduleVersion = c.version
+		if actual := gen.moduleRef(); actual != c.expected {
+			t.Fatalf("%s %q: expected %q, got %q", c.refType, c.version, c.expected, actual)
+		}
+	}
+
+	if actual := testGenerator().moduleRef(); actual != "main" {
+		t.Fatalf("expected the default ref to be main, got %q", actual)
+	}
+}
+
+func TestResolveDltaPath(t *testing.T) {
+	dir := t.TempDir()
+
+	resolved, err := resolveDltaPath(dir + string(filepath.Separator) + "." + string(filepath.Separator))
+	if err != nil {
+		t.Fatalf("resolving %q: %+v", dir, err)
+	}
+	if resolved != dir {
+		t.Fatalf("expected %q, got %q", dir, resolved)
+	}
+
+	if resolved, err := resolveDltaPath("dlta"); err != nil || !filepath.IsAbs(resolved) {
+		t.Fatalf("expected a relative path to be made absolute, got %q: %+v", resolved, err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveDltaPath(file); err == nil {
+		t.Fatalf("expected an error when the dlta path is a file")
+	}
+
+	gen := testGenerator()
+	gen.dltaPath = dir
+	expected := filepath.Join(dir, "r", resourceName, "resource", resourceName+".json")
+	if actual := gen.resourcePropertiesPath(); actual != expected {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestFileHeader(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = "/home/user/tfarm/dlta"
+	gen.dltaPathFlag = "./dlta"
+	gen.generatedAt = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	header := gen.fileHeader("main.tf")
+	expected := []string{
+		"# Code generated by dlta-scaffold " + generatorVersion + "; DO NOT EDIT.\n",
+		"# Generated at: 2023-06-01T12:00:00Z\n",
+		"# Resource: azurerm_foobar\n",
+		"# Regenerate with: go run ./internal/tools/dlta-scaffold -name azurerm_foobar -type resource -dlta-path ./dlta -output-type scaffold -force y\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(header, e) {
+			t.Fatalf("expected the header to contain %q, got:\n%s", e, header)
+		}
+	}
+
+	if strings.Contains(header, gen.dltaPath) {
+		t.Fatalf("expected the header not to embed the resolved dlta path, got:\n%s", header)
+	}
+
+	if err := validateHcl("main.tf", header+"locals {}\n"); err != nil {
+		t.Fatalf("expected the header to be valid HCL: %+v", err)
+	}
+
+	if !strings.HasPrefix(gen.fileHeader("pallette.sql"), "-- ") {
+		t.Fatalf("expected sql to use `--` comments")
+	}
+	if gen.fileHeader("module-meta.json") != "" {
+		t.Fatalf("expected no header for json")
+	}
+
+	if isManuallyModified(header) {
+		t.Fatalf("expected the generated header not to count as manually modified")
+	}
+	if !isManuallyModified(header + "# " + manuallyModifiedMarker + "\n") {
+		t.Fatalf("expected the marker to be detected")
+	}
+}
+
+func TestWriteResourceFileManuallyModified(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.isForced = true
+
+	gen.writeResourceFile("locals {}\n", "module", "local.tf")
+	path := filepath.Join(gen.resourceDir("module"), "local.tf")
+
+	edited := "# " + manuallyModifiedMarker + "\nlocals {\n  edited = true\n}\n"
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gen.writeResourceFile("locals {}\n", "module", "local.tf")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != edited {
+		t.Fatalf("expected the manually modified file to be kept, got:\n%s", content)
+	}
+}
+
+func TestReadNamingConfig(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	config := `{
+	"default": {"fields": ["dlta_application_short_code", "dlta_environment_char"]},
+	"resources": {"azurerm_foobar": {"delimiter": "", "prefix": "fb"}},
+	"data_sources": {"azurerm_foobar": {"static_name": "shared"}}
+}`
+	writeConfig(t, gen.dltaPath, "naming.json", config)
+
+	naming, err := gen.readNamingConfig()
+	if err != nil {
+		t.Fatalf("reading naming config: %+v", err)
+	}
+	gen.naming = naming
+
+	cases := []struct {
+		resourceName string
+		isDataSource bool
+		expected     string
+	}{
+		{resourceName: resourceName, expected: "fb${dlta_application_short_code}${dlta_environment_char}"},
+		{resourceName: resourceName, isDataSource: true, expected: "shared"},
+		{resourceName: "azurerm_unlisted", expected: "${dlta_application_short_code}-${dlta_environment_char}"},
+	}
+
+	for _, c := range cases {
+		if actual := gen.getResourceNamingConvention(c.resourceName, c.isDataSource); actual != c.expected {
+			t.Fatalf("%s (data source: %t): expected %q, got %q", c.resourceName, c.isDataSource, c.expected, actual)
+		}
+	}
+
+	config = `{"resources": {"azurerm_foobar": {"fields": ["dlta_colour"]}}}`
+	writeConfig(t, gen.dltaPath, "naming.json", config)
+	if _, err := gen.readNamingConfig(); err == nil || !strings.Contains(err.Error(), "dlta_colour") {
+		t.Fatalf("expected an error for the unknown naming field, got: %+v", err)
+	}
+}
+
+func TestResourceShortCode(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	if actual := gen.resourceShortCode("azurerm_storage_account"); actual != "st" {
+		t.Fatalf("expected the CAF abbreviation `st`, got %q", actual)
+	}
+	if actual := gen.resourceShortCode("azurerm_foobar"); actual != getResourceShortCode("azurerm_foobar") {
+		t.Fatalf("expected a type without an abbreviation to fall back, got %q", actual)
+	}
+
+	writeConfig(t, gen.dltaPath, "short_codes.json", `{"azurerm_storage_account": "sa"}`)
+
+	shortCodes, err := gen.readShortCodes()
+	if err != nil {
+		t.Fatalf("reading short codes: %+v", err)
+	}
+	gen.shortCodes = shortCodes
+	if actual := gen.resourceShortCode("azurerm_storage_account"); actual != "sa" {
+		t.Fatalf("expected the override `sa`, got %q", actual)
+	}
+
+	writeConfig(t, gen.dltaPath, "short_codes.json", `{"azurerm_storage_account": "S-A"}`)
+	if _, err := gen.readShortCodes(); err == nil {
+		t.Fatalf("expected an error for an invalid short code")
+	}
+}
+
+func TestShortCodeCollisions(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	if err := gen.checkShortCodeCollisions(); err != nil {
+		t.Fatalf("expected no collisions, got %+v", err)
+	}
+
+	// azurerm_fizz and azurerm_foobar both resolve to `af`
+	if err := os.MkdirAll(filepath.Join(gen.dltaPath, "r", "azurerm_fizz"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	err := gen.checkShortCodeCollisions()
+	if err == nil || !strings.Contains(err.Error(), `"af" is used by azurerm_fizz, azurerm_foobar`) {
+		t.Fatalf("expected a collision between azurerm_fizz and azurerm_foobar, got %+v", err)
+	}
+
+	gen.shortCodes = map[string]string{"azurerm_fizz": "fz"}
+	if err := gen.checkShortCodeCollisions(); err != nil {
+		t.Fatalf("expected the override to resolve the collision, got %+v", err)
+	}
+
+	// a collision between other resources fails scaffolding them, not this one
+	gen.shortCodes = map[string]string{"azurerm_fizz": "fz", "azurerm_buzz": "fz"}
+	if err := gen.checkShortCodeCollisions(); err != nil {
+		t.Fatalf("expected a collision between other resources only to be warned of, got %+v", err)
+	}
+	gen.resourceName = "azurerm_buzz"
+	if err := gen.checkShortCodeCollisions(); err == nil || !strings.Contains(err.Error(), `"fz" is used by azurerm_buzz, azurerm_fizz`) {
+		t.Fatalf("expected a collision between overrides, got %+v", err)
+	}
+}
+
+func TestNameRules(t *testing.T) {
+	gen := testGenerator()
+	gen.resourceName = "azurerm_storage_account"
+	gen.ShortCode = "st"
+
+	validations := gen.namingFieldValidations("dlta_application_short_code")
+	if len(validations) != 2 || validations[0].Condition != `can(regex("^[a-z0-9]*$", var.dlta_application_short_code))` {
+		t.Fatalf("unexpected validations: %+v", validations)
+	}
+
+	declaration := variableDeclaration("dlta_application_short_code", attribute{DataTypeString: "TypeString", Validations: validations})
+	if err := validateHcl("variables.tf", declaration); err != nil {
+		t.Fatalf("expected the variable to be valid HCL: %+v", err)
+	}
+
+	preconditions := gen.namePreconditions()
+	if len(preconditions) != 2 || !strings.Contains(preconditions[0], "length(local.name) >= 3 && length(local.name) <= 24") {
+		t.Fatalf("unexpected preconditions: %+v", preconditions)
+	}
+
+	if actual := gen.maxNameLength(); actual != 18 {
+		t.Fatalf("expected a max name length of 18, got %d", actual)
+	}
+	if warnings := gen.namingConventionWarnings(); len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %+v", warnings)
+	}
+
+	delimiter := "-"
+	gen.naming = namingConfig{Resources: map[string]namingConfigEntry{
+		"azurerm_storage_account": {Delimiter: &delimiter, Prefix: "stor", Transforms: &namingTransforms{}},
+	}}
+	if warnings := gen.namingConventionWarnings(); len(warnings) != 2 {
+		t.Fatalf("expected the length and characters to be warned about, got %+v", warnings)
+	}
+
+	gen.resourceName = resourceName
+	if len(gen.namingFieldValidations("dlta_application_short_code")) != 1 || len(gen.namePreconditions()) != 1 {
+		t.Fatalf("expected only the naming convention to be validated for a resource without a name rule")
+	}
+}
+
+func TestNamingTransforms(t *testing.T) {
+	gen := testGenerator()
+	gen.resourceName = "azurerm_storage_account"
+	gen.ShortCode = "st"
+
+	naming := gen.getNamingStruct(gen.resourceName, false)
+	if naming.Transforms != (namingTransforms{Lowercase: true, Alphanumeric: true, MaxLength: 24}) {
+		t.Fatalf("expected transforms derived from the name rule, got %+v", naming.Transforms)
+	}
+
+	delimiter := "-"
+	gen.naming = namingConfig{Resources: map[string]namingConfigEntry{
+		"azurerm_storage_account": {Delimiter: &delimiter, Prefix: "Stor", Transforms: &namingTransforms{Lowercase: true, StripDelimiters: true, MaxLength: 10}},
+	}}
+	expected := `substr(lower(replace(format("%s-%s-%s-%s-%s-%s-%s","Stor",var.dlta_vendor_asset_short_code,var.dlta_business_short_code,var.dlta_application_short_code,var.dlta_environment_char,var.dlta_location_short_code,var.dlta_instance_id), "-", "")), 0, 10)`
+	if actual := gen.namingExpression(""); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	if actual := gen.maxNameLength(); actual != 10 {
+		t.Fatalf("expected the max name length to be capped at 10, got %d", actual)
+	}
+	if warnings := gen.namingConventionWarnings(); len(warnings) != 0 {
+		t.Fatalf("expected no warnings once the delimiter is stripped, got %+v", warnings)
+	}
+
+	gen.resourceName = resourceName
+	if actual := gen.getNamingStruct(gen.resourceName, false).Transforms; actual != (namingTransforms{}) {
+		t.Fatalf("expected no transforms for a resource without a name rule, got %+v", actual)
+	}
+}
+
+func TestInstanceId(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	if pp := gen.getPalletProp(dlta_instance_id, "dlta_instance_id"); pp.Type != "select" || pp.CurrentValue != "001" || pp.Lookup != nil {
+		t.Fatalf("expected the static options by default, got %+v", pp)
+	}
+
+	registry := fmt.Sprintf(`{%q: ["001", "002", "004"]}`, resourceName)
+	writeConfig(t, gen.dltaPath, defaultInstanceRegistry, registry)
+
+	gen.naming = namingConfig{InstanceId: &instanceIdConfig{Mode: instanceIdRegistry}}
+	used, err := gen.readInstanceRegistry()
+	if err != nil {
+		t.Fatalf("reading instance registry: %+v", err)
+	}
+	gen.usedInstanceIds = used
+	if pp := gen.getPalletProp(dlta_instance_id, "dlta_instance_id"); !pp.ReadOnly || pp.CurrentValue != "003" {
+		t.Fatalf("expected the next free instance id, got %+v", pp)
+	}
+
+	writeConfig(t, gen.dltaPath, defaultInstanceRegistry, fmt.Sprintf(`{%q: ["1"]}`, resourceName))
+	if _, err := gen.readInstanceRegistry(); err == nil {
+		t.Fatalf("expected an error for an instance id which isn't 3 digits")
+	}
+
+	gen.naming = namingConfig{InstanceId: &instanceIdConfig{Mode: instanceIdQuery, Query: "select instance_id from core.infra_instance where asset_type = {asset_type}"}}
+	pp := gen.getPalletProp(dlta_instance_id, "dlta_instance_id")
+	expected := "select min(id) from (select lpad(n::text, 3, '0') as id from generate_series(1, 999) as n except select instance_id from core.infra_instance where asset_type = 'azurerm_foobar') as free"
+	if pp.Lookup == nil || *pp.Lookup != expected {
+		t.Fatalf("expected the instance id lookup:\n%s\ngot: %+v", expected, pp)
+	}
+	if sql := gen.dltaPalletteCodeBlock(); !strings.Contains(sql, "generate_series(1, 999)") {
+		t.Fatalf("expected the lookup to be emitted into the palette SQL")
+	}
+
+	used = make([]string, 0, maxInstanceId)
+	for n := 1; n <= maxInstanceId; n++ {
+		used = append(used, fmt.Sprintf("%03d", n))
+	}
+	if actual := nextInstanceId(used); actual != "" {
+		t.Fatalf("expected no free instance id, got %q", actual)
+	}
+}
+
+func TestNameRegex(t *testing.T) {
+	gen := testGenerator()
+	gen.ShortCode = "fb"
+
+	expected := `^fb-(?:sec|idt|mgt|finc|gdmz)-(?:demo|bigd|ecom|erp|aldft)-(?:d|u|s|p)-(?:eun|euw)-[0-9]{3}$`
+	pattern, ok := gen.nameRegex()
+	if !ok || pattern != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, pattern)
+	}
+	if !regexp.MustCompile(pattern).MatchString("fb-sec-demo-d-eun-001") || regexp.MustCompile(pattern).MatchString("fb-sec-demo-x-eun-001") {
+		t.Fatalf("expected %s to match only names of the naming convention", pattern)
+	}
+	if pp := gen.getPalletProp(attribute{}, "Name"); pp.Validators["pattern"] != expected {
+		t.Fatalf("expected the palette to validate the name with the pattern, got %+v", pp.Validators)
+	}
+	if preconditions := gen.namePreconditions(); len(preconditions) != 1 || !strings.Contains(preconditions[0], `can(regex("^fb-`) {
+		t.Fatalf("expected the module to validate the name with the pattern, got %+v", preconditions)
+	}
+
+	validations := gen.namingFieldValidations("dlta_environment_char")
+	if len(validations) != 1 || validations[0].Condition != `can(regex("^(?:d|u|s|p)$", var.dlta_environment_char))` {
+		t.Fatalf("unexpected validations: %+v", validations)
+	}
+	if gen.namingFieldValidations("dlta_vendor_asset_short_code") != nil {
+		t.Fatalf("expected the vendor asset short code not to be a variable validation")
+	}
+
+	delimiter := "-"
+	gen.naming = namingConfig{
+		Resources: map[string]namingConfigEntry{
+			resourceName: {Delimiter: &delimiter, Fields: []string{"dlta_application_short_code", "dlta_cost_center"}, Environments: map[string]namingConfigEntry{
+				"p": {StaticName: "fb.prod"},
+			}},
+		},
+		Tokens: map[string]namingToken{"dlta_cost_center": {}},
+	}
+	if pattern, _ := gen.nameRegex(); pattern != `^(?:(?:demo|bigd|ecom|erp|aldft)-[a-zA-Z0-9]+|fb\.prod)$` {
+		t.Fatalf("unexpected pattern with an environment override %s", pattern)
+	}
+
+	gen.naming = namingConfig{Resources: map[string]namingConfigEntry{
+		resourceName: {Transforms: &namingTransforms{MaxLength: 5}},
+	}}
+	if _, ok := gen.nameRegex(); ok {
+		t.Fatalf("expected no pattern for a name which can be truncated")
+	}
+
+	gen.isDataSource = true
+	if _, ok := gen.nameRegex(); ok {
+		t.Fatalf("expected no pattern for a data source")
+	}
+}
+
+func TestPaletteUpsert(t *testing.T) {
+	gen := testGenerator()
+
+	sql := gen.dltaPalletteCodeBlock()
+	if !strings.HasPrefix(sql, "insert into core.infra_asset (") || !strings.Contains(sql, "on conflict (asset_type) do update set") {
+		t.Fatalf("expected an upsert into the default table, got:\n%s", sql)
+	}
+	if strings.Count(sql, ";") != 1 {
+		t.Fatalf("expected a single statement so re-runs can't partially apply, got:\n%s", sql)
+	}
+
+	gen.paletteTable = "canvas.assets"
+	if sql := gen.dltaPalletteCodeBlock(); !strings.HasPrefix(sql, "insert into canvas.assets (") {
+		t.Fatalf("expected the configured table, got:\n%s", sql)
+	}
+
+	for _, table := range []string{"infra_asset", "core.infra_asset; drop table x", "Core.Asset"} {
+		if paletteTableRegex.MatchString(table) {
+			t.Fatalf("expected %q to be rejected", table)
+		}
+	}
+}
+
+func TestPaletteDesign(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.ShortCode = "fb"
+	gen.serviceName = "Storage"
+
+	design := gen.paletteDesign()
+	if design.Color != "#3999c6" || !strings.Contains(design.SVG, `fill="#3999c6"`) || !strings.Contains(design.SVG, ">fb</text>") {
+		t.Fatalf("expected the bundled icon in the colour of the service, got %+v", design)
+	}
+
+	writeConfig(t, gen.dltaPath, "icons/foobar.svg", "<svg>foobar</svg>")
+	config := fmt.Sprintf(`{"resources": {%q: {"svg": "foobar.svg"}}, "services": {"Storage": {"color": "#123456"}}}`, resourceName)
+	writeConfig(t, gen.dltaPath, "palette_icons.json", config)
+
+	icons, err := gen.readPaletteIcons()
+	if err != nil {
+		t.Fatalf("reading palette icons: %+v", err)
+	}
+	gen.paletteIcons = icons
+	if design := gen.paletteDesign(); design.SVG != "<svg>foobar</svg>" || design.Color != "#123456" {
+		t.Fatalf("expected the configured icon and service colour, got %+v", design)
+	}
+	if sql := gen.dltaPalletteCodeBlock(); !strings.Contains(sql, "'<svg>foobar</svg>'") {
+		t.Fatalf("expected the svg_icon to be populated, got:\n%s", sql)
+	}
+
+	gen.serviceName = "Unknown"
+	gen.paletteIcons = paletteIconConfig{}
+	if design := gen.paletteDesign(); design.Color != defaultPaletteColor {
+		t.Fatalf("expected the default colour for an unknown service, got %+v", design)
+	}
+}
+
+func TestPaletteCategory(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	if actual := gen.paletteCategory(); actual != defaultPaletteCategory {
+		t.Fatalf("expected %q without a service, got %q", defaultPaletteCategory, actual)
+	}
+
+	gen.serviceName = "Databricks"
+	gen.websiteCategories = []string{"Databricks"}
+	if actual := gen.paletteCategory(); actual != "Databricks" {
+		t.Fatalf("expected the website category, got %q", actual)
+	}
+
+	gen.serviceName = "Network"
+	if actual := gen.paletteDesign().Category; actual != "Networking" {
+		t.Fatalf("expected the bundled category in the palette design, got %q", actual)
+	}
+
+	config := fmt.Sprintf(`{"resources": {%q: "Security"}, "services": {"Network": "Connectivity"}}`, resourceName)
+	writeConfig(t, gen.dltaPath, "palette_categories.json", config)
+	categories, err := gen.readPaletteCategories()
+	if err != nil {
+		t.Fatalf("reading palette categories: %+v", err)
+	}
+	gen.paletteCategories = categories
+	if actual := gen.paletteCategory(); actual != "Security" {
+		t.Fatalf("expected the resource override, got %q", actual)
+	}
+
+	gen.resourceName = "azurerm_virtual_network"
+	if actual := gen.paletteCategory(); actual != "Connectivity" {
+		t.Fatalf("expected the service override, got %q", actual)
+	}
+}
+
+func TestPaletteRank(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.serviceName = "Network"
+
+	if rank, err := gen.readPaletteRank(); err != nil || rank != defaultPaletteRank {
+		t.Fatalf("expected the default rank, got %d: %+v", rank, err)
+	}
+
+	writeConfig(t, gen.dltaPath, "palette_ranks.json", `{"categories": {"Networking": 5}}`)
+	if rank, err := gen.readPaletteRank(); err != nil || rank != 5 {
+		t.Fatalf("expected the category rank, got %d: %+v", rank, err)
+	}
+
+	gen.autoRank = true
+	if _, err := gen.readPaletteRank(); err == nil {
+		t.Fatalf("expected an error when auto ranking without usage counts")
+	}
+
+	usage := fmt.Sprintf(`{"azurerm_resource_group": 120, %q: 40, "azurerm_subnet": 40, "azurerm_unused": 0}`, resourceName)
+	writeConfig(t, gen.dltaPath, "asset_usage.json", usage)
+	rank, err := gen.readPaletteRank()
+	if err != nil || rank != 2 {
+		t.Fatalf("expected to be ranked second by usage, got %d: %+v", rank, err)
+	}
+	gen.paletteRank = rank
+	if sql := gen.dltaPalletteCodeBlock(); !strings.Contains(sql, "rank = excluded.rank") {
+		t.Fatalf("expected the rank to be upserted, got:\n%s", sql)
+	}
+
+	gen.resourceName = "azurerm_unused"
+	if rank, err := gen.readPaletteRank(); err != nil || rank != 5 {
+		t.Fatalf("expected an unused asset to fall back to the configured rank, got %d: %+v", rank, err)
+	}
+}
+
+func TestSchemaValidators(t *testing.T) {
+	cases := []struct {
+		schema   *schema.Schema
+		expected NameValue
+	}{
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringLenBetween(3, 24)}, expected: NameValue{"minLength": 3, "maxLength": 24}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsIPv4Address}, expected: NameValue{"pattern": ipv4Pattern}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsURLWithHTTPS}, expected: NameValue{"pattern": "^(https)://"}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.Any(validation.IsIPAddress, validation.IsCIDR)}, expected: nil},
+		{schema: &schema.Schema{Type: schema.TypeInt, ValidateDiagFunc: help.ToDiagFunc(help.IntBetween(1, 5))}, expected: NameValue{"min": 1, "max": 5}},
+		{schema: &schema.Schema{Type: schema.TypeInt, ValidateFunc: validation.IntAtLeast(-2)}, expected: NameValue{"min": -2}},
+		{schema: &schema.Schema{Type: schema.TypeFloat, ValidateFunc: validation.FloatBetween(0, 1.5)}, expected: NameValue{"min": 0.0, "max": 1.5}},
+		{schema: &schema.Schema{Type: schema.TypeFloat, ValidateFunc: validation.FloatAtLeast(0.25)}, expected: NameValue{"min": 0.25}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-z]+$`), "")}, expected: NameValue{"pattern": `^[a-z]+$`}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-z]+$`), "lowercase")}, expected: nil},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.All(validation.IsCIDR, validation.StringLenBetween(1, 18))}, expected: NameValue{"pattern": cidrPattern, "minLength": 1, "maxLength": 18}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsUUID}, expected: NameValue{"pattern": uuidPattern}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: func(i interface{}, k string) ([]string, []error) {
+			_ = i.(string)
+			return nil, nil
+		}}, expected: nil},
+	}
+
+	for i, c := range cases {
+		if actual := getSchemaConstraints(c.schema).paletteValidators(c.schema.Type.String()); !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("case %d: expected %+v, got %+v", i, c.expected, actual)
+		}
+	}
+
+	gen := testGenerator()
+	maxLength := 24
+	pp := gen.getPalletProp(attribute{DataTypeString: "TypeString", Constraints: constraints{MaxLength: &maxLength}}, "foo")
+	if pp.Validators["maxLength"] != 24 {
+		t.Fatalf("expected the schema validators on the control, got %+v", pp.Validators)
+	}
+}
+
+func TestSchemaConstraints(t *testing.T) {
+	bound := func(f float64) *float64 {
+		return &f
+	}
+	length := func(i int) *int {
+		return &i
+	}
+
+	cases := []struct {
+		schema   *schema.Schema
+		expected constraints
+	}{
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringLenBetween(1, 80)}, expected: constraints{MinLength: length(1), MaxLength: length(80)}},
+		{schema: &schema.Schema{Type: schema.TypeInt, ValidateFunc: validation.IntBetween(7, 90)}, expected: constraints{Min: bound(7), Max: bound(90)}},
+		{schema: &schema.Schema{Type: schema.TypeInt, ValidateFunc: validation.IntInSlice([]int{1, 2, 4})}, expected: constraints{OneOf: []string{"1", "2", "4"}}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsCIDR}, expected: constraints{Formats: []string{formatCIDR}}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsIPAddress}, expected: constraints{Formats: []string{formatIP}}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsURLWithHTTPS}, expected: constraints{Formats: []string{formatURL}, URLSchemes: []string{"https"}}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-z]+$`), "")}, expected: constraints{Patterns: []string{`^[a-z]+$`}}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.All(validation.StringLenBetween(3, 24), validation.StringMatch(regexp.MustCompile(`^[a-z0-9]+$`), ""))}, expected: constraints{MinLength: length(3), MaxLength: length(24), Patterns: []string{`^[a-z0-9]+$`}}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.Any(validation.IsIPv4Address, validation.IsCIDR)}, expected: constraints{Formats: []string{formatIPv4, formatCIDR}}},
+		{schema: &schema.Schema{Type: schema.TypeBool}, expected: constraints{}},
+	}
+
+	for i, c := range cases {
+		if actual := getSchemaConstraints(c.schema); !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("case %d: expected %+v, got %+v", i, c.expected, actual)
+		}
+	}
+}
+
+func TestSchemaPossibleValues(t *testing.T) {
+	cases := []struct {
+		schema   *schema.Schema
+		expected []string
+	}{
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringInSlice([]string{"Standard", "Premium"}, false)}, expected: []string{"Standard", "Premium"}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: help.StringInSlice([]string{"a \"quoted\" value", "b"}, true)}, expected: []string{"a \"quoted\" value", "b"}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateDiagFunc: help.ToDiagFunc(help.StringInSlice([]string{"1", "2", "3"}, false))}, expected: []string{"1", "2", "3"}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.All(validation.StringIsNotEmpty, validation.StringInSlice([]string{"Allow", "Deny"}, false))}, expected: []string{"Allow", "Deny"}},
+		{schema: &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringLenBetween(3, 24)}, expected: nil},
+		{schema: &schema.Schema{Type: schema.TypeInt, ValidateFunc: validation.IntInSlice([]int{1, 2})}, expected: []string{"1", "2"}},
+		{schema: &schema.Schema{Type: schema.TypeBool}, expected: nil},
+	}
+
+	for i, c := range cases {
+		if actual := getSchemaPossibleValues(c.schema); !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("case %d: expected %+v, got %+v", i, c.expected, actual)
+		}
+	}
+}
+
+func TestNestedPossibleValues(t *testing.T) {
+	inSlice := validation.StringInSlice([]string{"Logging", "Metrics"}, false)
+	gen := testGenerator()
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Required: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"bypass": {Type: schema.TypeSet, Required: true, Elem: &schema.Schema{Type: schema.TypeString, ValidateFunc: inSlice}},
+		"zones":  {Type: schema.TypeSet, Required: true, Elem: &schema.Schema{Type: schema.TypeString}},
+	}}}
+	knownSetValues["azurerm_foobar.network.zones"] = []string{"1", "2", "3"}
+	t.Cleanup(func() { delete(knownSetValues, "azurerm_foobar.network.zones") })
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	// the values of the list and its elements aren't repeated
+	both := &schema.Schema{Type: schema.TypeList, ValidateFunc: func(i interface{}, k string) ([]string, []error) { return nil, nil }, Elem: &schema.Schema{Type: schema.TypeString, ValidateFunc: inSlice}}
+	if actual := schemaPossibleValues("azurerm_foobar.network.bypass", both); !reflect.DeepEqual(actual, []string{"Logging", "Metrics"}) {
+		t.Fatalf("expected the values of the elements, got %v", actual)
+	}
+
+	network := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)["network"]
+	if actual := network.Attributes["bypass"].PossibleValues; !reflect.DeepEqual(actual, []string{"Logging", "Metrics"}) {
+		t.Fatalf("expected the nested attribute's element values, got %v", actual)
+	}
+	if actual := network.Attributes["zones"].PossibleValues; !reflect.DeepEqual(actual, []string{"1", "2", "3"}) {
+		t.Fatalf("expected the known values of a nested set, got %v", actual)
+	}
+
+	controls := make(map[string]PaletteProp)
+	for _, pp := range gen.paletteCreator().Props {
+		controls[pp.ID] = pp
+	}
+	if pp := controls["bypass"]; pp.Type != "checkboxes" || len(pp.Options) != 2 {
+		t.Fatalf("expected the nested set's values as checkboxes, got %+v", pp)
+	}
+
+	if actual := gen.readResourceProperties()["azurerm_foobar.network.bypass"].PossibleValues; !reflect.DeepEqual(actual, []string{"Logging", "Metrics"}) {
+		t.Fatalf("expected the element values in the summary, got %v", actual)
+	}
+}
+
+func TestPaletteRequiredGroup(t *testing.T) {
+	gen := testGenerator()
+
+	pp := gen.getPalletProp(attribute{DataTypeString: "TypeString", Required: true}, "sku_name")
+	if pp.Validators["required"] != true || pp.Group != paletteGroupRequired {
+		t.Fatalf("expected a required control, got %+v", pp)
+	}
+	pp = gen.getPalletProp(attribute{DataTypeString: "TypeString", Optional: true}, "tags")
+	if _, ok := pp.Validators["required"]; ok || pp.Group != paletteGroupOptional {
+		t.Fatalf("expected an optional control, got %+v", pp)
+	}
+
+	props := []PaletteProp{
+		{ID: "zone", Group: paletteGroupOptional},
+		{ID: "AssetType"},
+		{ID: "sku_name", Group: paletteGroupRequired},
+		{ID: "name"},
+		{ID: "access_tier", Group: paletteGroupOptional},
+		{ID: "kind", Group: paletteGroupRequired},
+	}
+	sortPaletteProps(props)
+
+	ids := make([]string, 0, len(props))
+	for _, p := range props {
+		ids = append(ids, p.ID)
+	}
+	if expected := "AssetType,name,kind,sku_name,access_tier,zone"; strings.Join(ids, ",") != expected {
+		t.Fatalf("expected %s, got %s", expected, strings.Join(ids, ","))
+	}
+}
+
+func TestPaletteRetire(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	expected := "update core.infra_asset set\n\tactive = false,\n\taddable = false,\n\tupdated_at = now(),\n\tdeleted_at = now()\nwhere asset_type = 'azurerm_foobar' and deleted_at is null;"
+	if actual := gen.paletteRetireBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	gen.hardDelete = true
+	gen.paletteTable = "canvas.assets"
+	if actual := gen.paletteRetireBlock(); actual != "delete from canvas.assets\nwhere asset_type = 'azurerm_foobar';" {
+		t.Fatalf("unexpected hard delete:\n%s", actual)
+	}
+
+	// a retired resource isn't registered with the provider
+	if _, err := getContent("azurerm_retired", true, gen.dltaPath, "retire", scaffoldOptions{}); err != nil {
+		t.Fatalf("retiring: %+v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(gen.dltaPath, "r", "azurerm_retired", "resource", retireFileName))
+	if err != nil {
+		t.Fatalf("reading %s: %+v", retireFileName, err)
+	}
+	if !strings.Contains(string(content), "-output-type retire") || !strings.Contains(string(content), "where asset_type = 'azurerm_retired' and deleted_at is null;") {
+		t.Fatalf("unexpected %s:\n%s", retireFileName, content)
+	}
+}
+
+func TestPaletteFormat(t *testing.T) {
+	for _, c := range []struct {
+		format string
+		sql    bool
+		json   bool
+	}{
+		{format: "", sql: true},
+		{format: paletteFormatSql, sql: true},
+		{format: paletteFormatJson, json: true},
+		{format: paletteFormatBoth, sql: true, json: true},
+	} {
+		gen := testGenerator()
+		gen.dltaPath = t.TempDir()
+		gen.paletteFormat = c.format
+		gen.writePalette()
+
+		_, err := os.Stat(filepath.Join(gen.resourceDir("resource"), "pallette.sql"))
+		if (err == nil) != c.sql {
+			t.Fatalf("format %q: expected pallette.sql to be written: %t", c.format, c.sql)
+		}
+
+		content, err := os.ReadFile(filepath.Join(gen.resourceDir("resource"), "palette.json"))
+		if (err == nil) != c.json {
+			t.Fatalf("format %q: expected palette.json to be written: %t", c.format, c.json)
+		}
+		if c.json {
+			var creation Creator
+			if err := json.Unmarshal(content, &creation); err != nil {
+				t.Fatalf("format %q: expected palette.json to be the controls document: %+v", c.format, err)
+			}
+			if creation.CreateFunction != resourceName || len(creation.Props) == 0 {
+				t.Fatalf("format %q: unexpected controls document %+v", c.format, creation)
+			}
+		}
+	}
+}
+
+func TestPaletteRules(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	rules := fmt.Sprintf(`{%q: [
+	{"control": "zone_redundant", "when": "sku_name", "in": ["Premium"]},
+	{"control": "zone_redundant", "when": "tier", "not_in": ["Basic", "Free"]},
+	{"control": "missing", "when": "sku_name", "in": ["Premium"]}
+]}`, resourceName)
+	writeConfig(t, gen.dltaPath, "palette_rules.json", rules)
+
+	paletteRules, err := gen.readPaletteRules()
+	if err != nil {
+		t.Fatalf("reading palette rules: %+v", err)
+	}
+	gen.paletteRules = paletteRules
+
+	props := []PaletteProp{{ID: "sku_name"}, {ID: "tier"}, {ID: "zone_redundant"}}
+	gen.applyPaletteRules(props)
+	if props[2].Filter == nil || *props[2].Filter != "sku_name == 'Premium' && tier not in ['Basic', 'Free']" {
+		t.Fatalf("unexpected filter %v", props[2].Filter)
+	}
+	if props[0].Filter != nil {
+		t.Fatalf("expected a control without rules to have no filter")
+	}
+
+	writeConfig(t, gen.dltaPath, "palette_rules.json", fmt.Sprintf(`{%q: [{"control": "zone_redundant", "when": "sku_name"}]}`, resourceName))
+	if _, err := gen.readPaletteRules(); err == nil {
+		t.Fatalf("expected an error for a rule without values")
+	}
+}
+
+func TestCostMeta(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	if !gen.hasCost() {
+		t.Fatalf("expected an unclassified resource to be billable")
+	}
+	gen.resourceName = "azurerm_resource_group"
+	if gen.hasCost() || strings.Contains(gen.dltaPalletteCodeBlock(), "has_cost = true") {
+		t.Fatalf("expected a resource group to be free")
+	}
+
+	config := `{"billable": ["azurerm_resource_group"], "free": ["azurerm_foobar"], "skus": {"azurerm_resource_group": {"attribute": "sku_name", "hints": {"B1": "~$13/month"}}}}`
+	writeConfig(t, gen.dltaPath, "costs.json", config)
+	costs, err := gen.readCostConfig()
+	if err != nil {
+		t.Fatalf("reading costs: %+v", err)
+	}
+	gen.costs = costs
+
+	meta := gen.costMeta()
+	if !meta.HasCost || meta.SkuAttribute != "sku_name" || meta.SkuHints["B1"] != "~$13/month" {
+		t.Fatalf("expected the configured classification and hints, got %+v", meta)
+	}
+	if sql := gen.dltaPalletteCodeBlock(); !strings.Contains(sql, "14, 	true,") {
+		t.Fatalf("expected has_cost to be true, got:\n%s", sql)
+	}
+
+	gen.resourceName = resourceName
+	if gen.hasCost() {
+		t.Fatalf("expected the configured free resource not to be billable")
+	}
+	gen.isDataSource = true
+	gen.resourceName = "azurerm_resource_group"
+	if gen.hasCost() {
+		t.Fatalf("expected a data source not to be billable")
+	}
+
+	writeConfig(t, gen.dltaPath, "costs.json", `{"billable": ["azurerm_foobar"], "free": ["azurerm_foobar"]}`)
+	if _, err := gen.readCostConfig(); err == nil {
+		t.Fatalf("expected an error for a resource which is both billable and free")
+	}
+}
+
+func TestInfracostConfig(t *testing.T) {
+	gen := testGenerator()
+
+	expected := `version: 0.1
+
+projects:
+  - path: .
+    name: "azurerm_foobar"
+    terraform_var_files:
+      - terraform.tfvars.example
+`
+	if actual := gen.infracostConfigBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+	gen.costs = costConfig{Skus: map[string]skuCostHints{resourceName: {Attribute: "sku_name", Hints: map[string]string{"P1v3": "~$120/month", "B1": "~$13/month"}}}}
+
+	expected = `version: 0.1
+
+projects:
+  - path: .
+    name: "azurerm_foobar-B1"
+    terraform_var_files:
+      - terraform.tfvars.example
+    terraform_vars:
+      sku_name: "B1"
+  - path: .
+    name: "azurerm_foobar-P1v3"
+    terraform_var_files:
+      - terraform.tfvars.example
+    terraform_vars:
+      sku_name: "P1v3"
+`
+	if actual := gen.infracostConfigBlock(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	gen.costs.Skus[resourceName] = skuCostHints{Attribute: "sku.tier", Hints: map[string]string{"Basic": "~$5/month"}}
+	if actual := gen.infracostConfigBlock(); strings.Contains(actual, "terraform_vars") {
+		t.Fatalf("expected a single project when the sku attribute isn't a variable, got:\n%s", actual)
+	}
+}
+
+func TestPaletteMigrate(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	if _, err := gen.paletteMigrateBlock(); err == nil {
+		t.Fatalf("expected an error without any controls to migrate")
+	}
+
+	input := filepath.Join(gen.dltaPath, "form_fields.json")
+	legacy := `{"create_function": "azurerm_foobar", "controls": [{"id": "location", "validators": {"required": true}}, {"id": "tags", "type": "map", "value": null, "validators": {}}]}`
+	if err := os.WriteFile(input, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gen.migrateInput = input
+
+	migrateBlock, err := gen.paletteMigrateBlock()
+	if err != nil {
+		t.Fatalf("migrating: %+v", err)
+	}
+	for _, expected := range []string{"update core.infra_asset set", fmt.Sprintf(`"form_schema_version": %d`, paletteFormSchemaVersion), `"output": null`, `"is_default": false`, `"force_new": false`, `"type": "keyvalue"`, `"group": "required"`, `"group": "optional"`, "where asset_type = 'azurerm_foobar';"} {
+		if !strings.Contains(migrateBlock, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, migrateBlock)
+		}
+	}
+
+	current := writeJson(Creator{CreateFunction: resourceName, FormSchemaVersion: paletteFormSchemaVersion, Props: []PaletteProp{}})
+	if err := os.WriteFile(input, []byte(current), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if migrateBlock, err := gen.paletteMigrateBlock(); err != nil || migrateBlock != "" {
+		t.Fatalf("expected nothing to migrate for current controls, got %q: %+v", migrateBlock, err)
+	}
+
+	if err := os.WriteFile(input, []byte(`{"form_schema_version": 99, "controls": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.paletteMigrateBlock(); err == nil {
+		t.Fatalf("expected an error for controls newer than supported")
+	}
+}
+
+func TestPaletteCheckboxGroups(t *testing.T) {
+	gen := testGenerator()
+	gen.resourceName = "azurerm_public_ip"
+	gen.resource.Schema["zones"] = &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem:     &schema.Schema{Type: schema.TypeString, ValidateFunc: help.StringIsNotEmpty},
+	}
+	gen.resource.Schema["tags_list"] = &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem:     &schema.Schema{Type: schema.TypeString},
+	}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	attributes["ip_rules"] = attribute{DataTypeString: "TypeSet", Optional: true, PossibleValues: []string{"AzureServices", "Logging", "Metrics"}}
+
+	for field, expected := range map[string][]KeyValue{
+		"ip_rules": {{Key: "AzureServices", Value: "AzureServices"}, {Key: "Logging", Value: "Logging"}, {Key: "Metrics", Value: "Metrics"}},
+		"zones":    {{Key: "1", Value: "1"}, {Key: "2", Value: "2"}, {Key: "3", Value: "3"}},
+	} {
+		pp := gen.getPalletProp(attributes[field], field)
+		if pp.Type != "checkboxes" {
+			t.Fatalf("expected %s to be a checkbox group, got %q", field, pp.Type)
+		}
+		if !reflect.DeepEqual(pp.Options, expected) {
+			t.Fatalf("expected %s options %+v, got %+v", field, expected, pp.Options)
+		}
+		if value, err := json.Marshal(pp.CurrentValue); err != nil || string(value) != "[]" {
+			t.Fatalf("expected %s to start as an empty list, got %s", field, value)
+		}
+	}
+
+	pp := gen.getPalletProp(attributes["tags_list"], "tags_list")
+	if pp.Type != "list" || len(pp.Options) != 0 {
+		t.Fatalf("expected a set without enumerated values to be a list, got %q with %+v", pp.Type, pp.Options)
+	}
+	if value, _ := json.Marshal(pp.CurrentValue); string(value) != "[]" {
+		t.Fatalf("expected a set to start as an empty list, got %s", value)
+	}
+
+	// the known values are those of the resource's zones, not any field of that name
+	gen.resourceName = resourceName
+	attributes = gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if pp := gen.getPalletProp(attributes["zones"], "zones"); pp.Type != "list" || len(pp.Options) != 0 {
+		t.Fatalf("expected zones without known values to be a list, got %q with %+v", pp.Type, pp.Options)
+	}
+}
+
+func TestNumericConstraints(t *testing.T) {
+	gen := testGenerator()
+	bound := func(f float64) *float64 {
+		return &f
+	}
+
+	pp := gen.getPalletProp(attribute{DataTypeString: "TypeInt", Constraints: constraints{Min: bound(7), Max: bound(90)}}, "retention_days")
+	if pp.Type != "number" || pp.Validators["min"] != 7 || pp.Validators["max"] != 90 || pp.Validators["step"] != 1 {
+		t.Fatalf("expected a number control stepping by 1 between 7 and 90, got %q %+v", pp.Type, pp.Validators)
+	}
+	pp = gen.getPalletProp(attribute{DataTypeString: "TypeFloat"}, "ratio")
+	if pp.Type != "number" || pp.Validators["step"] != "any" {
+		t.Fatalf("expected a number control with any step, got %q %+v", pp.Type, pp.Validators)
+	}
+	pp = gen.getPalletProp(attribute{DataTypeString: "TypeInt", PossibleValues: []string{"1", "2"}}, "count")
+	if _, ok := pp.Validators["step"]; ok {
+		t.Fatalf("expected no step for a select, got %+v", pp.Validators)
+	}
+
+	cases := []struct {
+		at       attribute
+		expected []variableValidation
+	}{
+		{at: attribute{DataTypeString: "TypeInt", Required: true, Constraints: constraints{Min: bound(7), Max: bound(90)}}, expected: []variableValidation{{Condition: "var.days >= 7 && var.days <= 90", ErrorMessage: "The days must be between 7 and 90."}}},
+		{at: attribute{DataTypeString: "TypeFloat", Optional: true, Constraints: constraints{Min: bound(0.5)}}, expected: []variableValidation{{Condition: "var.days == null ? true : var.days >= 0.5", ErrorMessage: "The days must be at least 0.5."}}},
+		{at: attribute{DataTypeString: "TypeInt", Required: true, Constraints: constraints{Max: bound(10)}}, expected: []variableValidation{{Condition: "var.days <= 10", ErrorMessage: "The days must be at most 10."}}},
+		{at: attribute{DataTypeString: "TypeString", Required: true, Constraints: constraints{Min: bound(1)}}, expected: nil},
+		{at: attribute{DataTypeString: "TypeInt", Required: true}, expected: nil},
+	}
+	for i, c := range cases {
+		if actual := numericValidations("days", c.at); !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("case %d: expected %+v, got %+v", i, c.expected, actual)
+		}
+	}
+
+	declaration := variableDeclaration("days", attribute{DataTypeString: "TypeInt", Optional: true, Validations: numericValidations("days", attribute{DataTypeString: "TypeInt", Optional: true, Constraints: constraints{Min: bound(7), Max: bound(90)}})})
+	if err := validateHcl("variables.tf", declaration); err != nil {
+		t.Fatalf("expected valid HCL, got %+v:\n%s", err, declaration)
+	}
+}
+
+func TestPaletteDefaults(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["enabled"] = &schema.Schema{Type: schema.TypeBool, Optional: true, Default: true}
+	gen.resource.Schema["retention_days"] = &schema.Schema{Type: schema.TypeInt, Optional: true, Default: 30}
+	gen.resource.Schema["sku"] = &schema.Schema{Type: schema.TypeString, Optional: true, Default: "Standard"}
+	gen.resource.Schema["tier"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+
+	for field, expected := range map[string]interface{}{"enabled": true, "retention_days": 30, "sku": "Standard"} {
+		pp := gen.getPalletProp(attributes[field], field)
+		if !pp.IsDefault || pp.CurrentValue != expected {
+			t.Fatalf("expected %s to default to %v, got %v (is_default %t)", field, expected, pp.CurrentValue, pp.IsDefault)
+		}
+	}
+	if pp := gen.getPalletProp(attributes["tier"], "tier"); pp.IsDefault {
+		t.Fatalf("expected an attribute without a default not to be marked as one")
+	}
+
+	for field, expected := range map[string]string{"enabled": "default = true", "retention_days": "default = 30", "sku": "default = \"Standard\"", "tier": "default = null"} {
+		if declaration := variableDeclaration(field, attributes[field]); !strings.Contains(declaration, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, declaration)
+		}
+	}
+}
+
+func TestDefaultFuncs(t *testing.T) {
+	t.Setenv("DLTA_TEST_ENVIRONMENT", "china")
+
+	called := false
+	gen := testGenerator()
+	gen.resource.Schema["environment"] = &schema.Schema{Type: schema.TypeString, Optional: true, DefaultFunc: schema.EnvDefaultFunc("DLTA_TEST_ENVIRONMENT", "public"), Description: "This can also be sourced from the `DLTA_TEST_ENVIRONMENT` Environment Variable."}
+	gen.resource.Schema["capacity"] = &schema.Schema{Type: schema.TypeInt, Optional: true, DefaultFunc: func() (interface{}, error) {
+		called = true
+		return 5, nil
+	}}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if called {
+		t.Fatalf("expected the DefaultFunc not to be called")
+	}
+
+	environment := attributes["environment"]
+	if environment.Default != "" || environment.DefaultSource != defaultSourceEnvironment || !reflect.DeepEqual(environment.DefaultEnv, []string{"DLTA_TEST_ENVIRONMENT"}) {
+		t.Fatalf("expected a default read from the environment the description names, got %q %q %v", environment.Default, environment.DefaultSource, environment.DefaultEnv)
+	}
+	if declaration := variableDeclaration("environment", environment); !strings.Contains(declaration, "default = null") {
+		t.Fatalf("expected a default read from the environment to be left to the provider:\n%s", declaration)
+	}
+	pp := gen.getPalletProp(environment, "environment")
+	if pp.IsDefault || pp.DefaultSource == nil || *pp.DefaultSource != defaultSourceEnvironment || len(pp.DefaultEnv) != 1 {
+		t.Fatalf("expected an environment default on the control, got %+v", pp)
+	}
+
+	capacity := attributes["capacity"]
+	if capacity.Default != "" || capacity.DefaultSource != defaultSourceFunc || capacity.DefaultEnv != nil {
+		t.Fatalf("expected a DefaultFunc default, got %q %q %v", capacity.Default, capacity.DefaultSource, capacity.DefaultEnv)
+	}
+	if declaration := variableDeclaration("capacity", capacity); !strings.Contains(declaration, "default = null") {
+		t.Fatalf("expected a DefaultFunc default to be left to the provider:\n%s", declaration)
+	}
+	if pp := gen.getPalletProp(capacity, "capacity"); pp.IsDefault || pp.DefaultSource == nil || *pp.DefaultSource != defaultSourceFunc {
+		t.Fatalf("expected a DefaultFunc default on the control, got %+v", pp)
+	}
+}
+
+func TestPaletteKeyValue(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["tags"] = &schema.Schema{Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.resource.Schema["labels"] = &schema.Schema{Type: schema.TypeMap, Required: true, Elem: &schema.Schema{Type: schema.TypeString}}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+
+	pp := gen.getPalletProp(attributes["tags"], "tags")
+	if pp.Type != paletteKeyValueType {
+		t.Fatalf("expected a %s control, got %q", paletteKeyValueType, pp.Type)
+	}
+	if value, _ := json.Marshal(pp.CurrentValue); string(value) != "{}" {
+		t.Fatalf("expected a map to start empty, got %s", value)
+	}
+
+	expected := `variable "tags" {
+	description = ""
+	type = map(string)
+	default = {}
+}
+`
+	if actual := variableDeclaration("tags", attributes["tags"]); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	if actual := variableDeclaration("labels", attributes["labels"]); strings.Contains(actual, "default") || !strings.Contains(actual, "map(string)") {
+		t.Fatalf("expected a required map(string) without a default, got:\n%s", actual)
+	}
+}
+
+func TestPaletteDiff(t *testing.T) {
+	live := map[string]interface{}{"controls": []interface{}{
+		map[string]interface{}{"id": "location", "type": "select", "group": "required"},
+		map[string]interface{}{"id": "legacy", "type": "string"},
+		map[string]interface{}{"id": "sku", "type": "string", "group": "optional"},
+	}}
+	generated := map[string]interface{}{"controls": []interface{}{
+		map[string]interface{}{"id": "sku", "type": "select", "group": "optional"},
+		map[string]interface{}{"id": "location", "type": "select", "group": "required"},
+		map[string]interface{}{"id": "tags", "type": "keyvalue"},
+	}}
+
+	expected := []string{
+		"+ tags (keyvalue)",
+		"- legacy (string)",
+		`~ sku.type: "string" -> "select"`,
+		"~ order: location, sku -> sku, location",
+	}
+	if actual := diffPaletteControls(live, generated); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+	if actual := diffPaletteControls(generated, generated); len(actual) != 0 {
+		t.Fatalf("expected no differences, got %q", actual)
+	}
+
+	gen := testGenerator()
+	var query string
+	current := writeJson(gen.paletteCreator())
+	diff, err := gen.paletteDiff(context.Background(), func(ctx context.Context, sql string) (string, error) {
+		query = sql
+		return current, nil
+	})
+	if err != nil || len(diff) != 0 {
+		t.Fatalf("expected no differences to the current controls, got %q: %+v", diff, err)
+	}
+	if query != "select form_fields from core.infra_asset where asset_type = 'azurerm_foobar' and deleted_at is null" {
+		t.Fatalf("unexpected query %q", query)
+	}
+
+	diff, err = gen.paletteDiff(context.Background(), func(ctx context.Context, sql string) (string, error) {
+		return "", nil
+	})
+	if err != nil || len(diff) == 0 || !strings.HasPrefix(diff[0], "+ AssetType") {
+		t.Fatalf("expected every control to be added for an asset not in the palette, got %q: %+v", diff, err)
+	}
+
+	if _, err := gen.paletteDiff(context.Background(), func(ctx context.Context, sql string) (string, error) {
+		return "", fmt.Errorf("connection refused")
+	}); err == nil {
+		t.Fatalf("expected the query error to be returned")
+	}
+}
+
+func TestPaletteOutputs(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["vault_uri"] = &schema.Schema{Type: schema.TypeString, Computed: true, Description: "The URI of the Key Vault"}
+	gen.resource.Schema["private_fqdn"] = &schema.Schema{Type: schema.TypeString, Computed: true}
+	gen.resource.Schema["tenant_id"] = &schema.Schema{Type: schema.TypeString, Computed: true}
+	gen.resource.Schema["endpoint"] = &schema.Schema{Type: schema.TypeString, Optional: true, Computed: true}
+
+	if outputs := gen.getPaletteOutputAttributes(); len(outputs) != 0 {
+		t.Fatalf("expected no outputs without `-palette-outputs`, got %+v", outputs)
+	}
+
+	gen.showOutputs = true
+	if actual := sortAttributeNames(gen.getPaletteOutputAttributes()); !reflect.DeepEqual(actual, []string{"id", "private_fqdn", "vault_uri"}) {
+		t.Fatalf("expected the id, endpoints and FQDNs by default, got %v", actual)
+	}
+
+	creator := gen.paletteCreator()
+	last := creator.Props[len(creator.Props)-1]
+	if last.ID != "vault_uri" || !last.ReadOnly || last.Group != paletteGroupOutput || last.Output == nil || *last.Output != "module.${dlta_terraform_module_name}.vault_uri" {
+		t.Fatalf("expected the outputs last as read only controls, got %+v", last)
+	}
+	if !strings.Contains(gen.terraformOutputBlock(), `output "vault_uri"`) {
+		t.Fatalf("expected a module output for vault_uri, got:\n%s", gen.terraformOutputBlock())
+	}
+
+	gen.paletteOutputs = []string{"tenant_id", "missing"}
+	if actual := sortAttributeNames(gen.getPaletteOutputAttributes()); !reflect.DeepEqual(actual, []string{"tenant_id"}) {
+		t.Fatalf("expected the configured outputs, got %v", actual)
+	}
+}
+
+func TestSummaryProviderDrift(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	initialisedWith, sa := parseResourceSummary(content)
+	if initialisedWith != version.ProviderVersion || !sa["azurerm_foobar.name"].Published {
+		t.Fatalf("expected the summary to record the provider version alongside the attributes, got %q %+v", initialisedWith, sa)
+	}
+	if _, ok := gen.summaryDriftWarning(); ok {
+		t.Fatal("expected no drift when the summary was initialised with the loaded provider")
+	}
+
+	// a summary from an older provider which had an attribute since removed, and not the one since added
+	delete(sa, "azurerm_foobar.location")
+	sa["azurerm_foobar.retired"] = summaryAttribute{Optional: true}
+	summary := map[string]any{summaryProviderVersionKey: "3.0.0"}
+	for rp, a := range sa {
+		summary[rp] = a
+	}
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(summary)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("azurerm_foobar.json was initialised with provider 3.0.0 but %s is loaded, added: azurerm_foobar.location, removed: azurerm_foobar.retired", version.ProviderVersion)
+	if warning, ok := gen.summaryDriftWarning(); !ok || warning != expected {
+		t.Fatalf("expected the drift to be described\nexpected: %s\nactual:   %s", expected, warning)
+	}
+	if _, ok := gen.readResourceProperties()[summaryProviderVersionKey]; ok {
+		t.Fatal("expected the provider version not to be read as an attribute")
+	}
+
+	// summaries written before the version was recorded can't tell
+	delete(summary, summaryProviderVersionKey)
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(summary)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gen.summaryDriftWarning(); ok {
+		t.Fatal("expected no drift for a summary without a provider version")
+	}
+}
+
+func TestSummaryReinit(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["tags"] = &schema.Schema{Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.resource.Schema["zone"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	// the user publishes the tags, unpublishes the location and marks the zone as a secret
+	sa := gen.readResourceProperties()
+	tags, location, zone := sa["azurerm_foobar.tags"], sa["azurerm_foobar.location"], sa["azurerm_foobar.zone"]
+	tags.Published, location.Published, zone.Sensitive = true, false, true
+	sa["azurerm_foobar.tags"], sa["azurerm_foobar.location"], sa["azurerm_foobar.zone"] = tags, location, zone
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the provider since removed the zone and added a required sku
+	delete(gen.resource.Schema, "zone")
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	gen.writeInitResourceProperties()
+
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	initialisedWith, merged := parseResourceSummary(content)
+	if initialisedWith != version.ProviderVersion {
+		t.Fatalf("expected the merged summary to record the provider loaded, got %q", initialisedWith)
+	}
+	if !merged["azurerm_foobar.tags"].Published || merged["azurerm_foobar.location"].Published || !merged["azurerm_foobar.name"].Published {
+		t.Fatalf("expected the publishing choices to be kept, got %+v", merged)
+	}
+	if a, ok := merged["azurerm_foobar.sku_name"]; !ok || !a.Published || !a.Required {
+		t.Fatalf("expected the added attribute to be summarised from the schema, got %+v", a)
+	}
+	if _, ok := merged["azurerm_foobar.zone"]; ok {
+		t.Fatal("expected the removed attribute to be dropped from the summary")
+	}
+
+	_, added, removed := mergeResourceSummary(sa, merged)
+	if len(added) != 1 || added[0] != "azurerm_foobar.sku_name" || len(removed) != 1 || removed[0] != "azurerm_foobar.zone" {
+		t.Fatalf("expected the changes to be reported, got added %v removed %v", added, removed)
+	}
+
+	// forcing starts the summary afresh
+	gen.isForced = true
+	gen.writeInitResourceProperties()
+	if gen.readResourceProperties()["azurerm_foobar.tags"].Published {
+		t.Fatal("expected a forced init to discard the publishing choices")
+	}
+}
+
+func TestSummaryValidation(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"subnet_id": {Type: schema.TypeString, Optional: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.validateResourceSummary(content); err != nil {
+		t.Fatalf("expected the summary written by init to be valid, got %v", err)
+	}
+
+	invalid := fmt.Sprintf(`{
+  "provider_version": %q,
+  "format": "2",
+  "azurerm_foobar.name": {"Published": true},
+  "azurerm_foobar.locaton": {"Published": true},
+  "azurerm_foobar.network": {"published": true, "Publshed": true, "Sensitive": "yes"},
+  "azurerm_foobar.network.subnet_id": {"Published": true, "ConflictsWith": [1], "Constraints": {"MinLenght": 1, "Max": "10"}}
+}`, version.ProviderVersion)
+	expected := `azurerm_foobar.json: azurerm_foobar.network: Publshed: unknown key, did you mean "Published"
+azurerm_foobar.json: azurerm_foobar.network: Sensitive: expected a boolean, got string
+azurerm_foobar.json: azurerm_foobar.network: published: unknown key, did you mean "Published"
+azurerm_foobar.json: azurerm_foobar.network.subnet_id: ConflictsWith: expected a list of strings, got [1]
+azurerm_foobar.json: azurerm_foobar.network.subnet_id: Constraints: Max: expected a number, got string
+azurerm_foobar.json: azurerm_foobar.network.subnet_id: Constraints: MinLenght: unknown key, did you mean "MinLength"
+azurerm_foobar.json: format: expected a number, got string`
+	if err := gen.validateResourceSummary([]byte(invalid)); err == nil || err.Error() != expected {
+		t.Fatalf("expected every problem to be located\nexpected: %s\nactual:   %v", expected, err)
+	}
+	// a path the schema doesn't know is drift, reported without failing
+	if unknown := gen.unknownResourceSummaryPaths([]byte(invalid)); !reflect.DeepEqual(unknown, []string{`azurerm_foobar.json: azurerm_foobar.locaton: unknown resource path, did you mean "azurerm_foobar.location"`}) {
+		t.Fatalf("expected the unknown path with the one most likely meant, got %v", unknown)
+	}
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(invalid), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if sa := gen.readResourceProperties(); len(sa) != 0 {
+		t.Fatalf("expected an invalid summary to be rejected, got %+v", sa)
+	}
+
+	// the paths of a summary initialised with another provider are drift, not typos
+	drifted := `{"provider_version": "3.0.0", "azurerm_foobar.retired": {"Published": true}}`
+	if err := gen.validateResourceSummary([]byte(drifted)); err != nil || len(gen.unknownResourceSummaryPaths([]byte(drifted))) > 0 {
+		t.Fatalf("expected the paths of an older provider's summary not to be checked, got %v", err)
+	}
+
+	// leaving attributes out since the summary was initialised drops them when it's initialised again
+	gen.isForced = true
+	gen.writeInitResourceProperties()
+	gen.isForced = false
+	writeConfig(t, gen.dltaPath, "attribute_rules.json", `{"global": {"exclude": ["network.subnet_id"]}}`)
+	rules, err := gen.readAttributeRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.attributeRules = rules
+	content, err = os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.validateResourceSummary(content); err != nil {
+		t.Fatalf("expected the attributes left out to be drift, got %v", err)
+	}
+	if unknown := gen.unknownResourceSummaryPaths(content); len(unknown) != 1 || !strings.Contains(unknown[0], "azurerm_foobar.network.subnet_id: unknown resource path") {
+		t.Fatalf("expected the attribute left out to be reported, got %v", unknown)
+	}
+	gen.writeInitResourceProperties()
+	if _, ok := gen.readResourceProperties()["azurerm_foobar.network.subnet_id"]; ok {
+		t.Fatal("expected the attribute left out to be dropped re-initialising")
+	}
+
+	expected = "azurerm_foobar.json: line 3, column 3: invalid character '}' looking for beginning of object key string"
+	if err := gen.validateResourceSummary([]byte("{\n  \"azurerm_foobar.name\": {\"Published\": true},\n  }")); err == nil || err.Error() != expected {
+		t.Fatalf("expected the syntax error to be located\nexpected: %s\nactual:   %v", expected, err)
+	}
+}
+
+func TestPublishProfiles(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["tags"] = &schema.Schema{Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.dltaPath = t.TempDir()
+	gen.profile = "minimal"
+	gen.writeInitResourceProperties()
+
+	// the summary is shared with the resource, the profile's artefacts aren't
+	if expected := filepath.Join(gen.dltaPath, "r", resourceName, "resource", resourceName+".json"); gen.resourcePropertiesPath() != expected {
+		t.Fatalf("expected the summary of the resource, got %s", gen.resourcePropertiesPath())
+	}
+	if expected := filepath.Join(gen.dltaPath, "r", resourceName, "profiles", "minimal", "module"); gen.resourceDir("module") != expected {
+		t.Fatalf("expected the profile's artefacts within the resource, got %s", gen.resourceDir("module"))
+	}
+	if actual := gen.assetType(); actual != "azurerm_foobar:minimal" {
+		t.Fatalf("expected the profile to be an asset of its own, got %s", actual)
+	}
+
+	// the profile starts with the attributes published, which the user narrows
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := readSummaryProfiles(content)
+	if expected := []string{"azurerm_foobar.location", "azurerm_foobar.name"}; !reflect.DeepEqual(profiles["minimal"], expected) {
+		t.Fatalf("expected the profile to start with the published attributes, got %v", profiles)
+	}
+	summary := make(map[string]any)
+	if err := json.Unmarshal(content, &summary); err != nil {
+		t.Fatal(err)
+	}
+	summary[summaryProfilesKey] = map[string][]string{"minimal": {"azurerm_foobar.name"}, "advanced": {"azurerm_foobar.name", "azurerm_foobar.location", "azurerm_foobar.tags"}}
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(summary)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for profile, expected := range map[string][]string{"": {"azurerm_foobar.location", "azurerm_foobar.name"}, "minimal": {"azurerm_foobar.name"}, "advanced": {"azurerm_foobar.location", "azurerm_foobar.name", "azurerm_foobar.tags"}} {
+		gen.profile = profile
+		if actual := gen.getPublishedResourcePaths(); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected profile %q to publish %v, got %v", profile, expected, actual)
+		}
+	}
+
+	// re-initialising keeps the profiles
+	gen.profile = ""
+	gen.writeInitResourceProperties()
+	gen.profile = "minimal"
+	if err := gen.validateProfile(); err != nil {
+		t.Fatalf("expected the profile to be kept, got %v", err)
+	}
+	gen.profile = "standard"
+	if err := gen.validateProfile(); err == nil || !strings.Contains(err.Error(), "has no profile \"standard\"") {
+		t.Fatalf("expected an unknown profile to be rejected, got %v", err)
+	}
+
+	invalid := fmt.Sprintf(`{"provider_version": %q, "profiles": {"Minimal": ["azurerm_foobar.nme"]}}`, version.ProviderVersion)
+	expected := "azurerm_foobar.json: profiles: Minimal: the name must be lowercase letters, digits, `-` and `_`"
+	if err := gen.validateResourceSummary([]byte(invalid)); err == nil || err.Error() != expected {
+		t.Fatalf("expected the profiles to be validated\nexpected: %s\nactual:   %v", expected, err)
+	}
+	if unknown := gen.unknownResourceSummaryPaths([]byte(invalid)); !reflect.DeepEqual(unknown, []string{`azurerm_foobar.json: profiles: Minimal: [0]: unknown resource path "azurerm_foobar.nme"`}) {
+		t.Fatalf("expected the profile's unknown path to be reported, got %v", unknown)
+	}
+}
+
+func TestSummaryFormat(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Required: true, Description: "The SKU.", ValidateFunc: validation.StringInSlice([]string{"Basic", "Premium"}, false)}
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"ip_rules": {Type: schema.TypeSet, Required: true, Elem: &schema.Schema{Type: schema.TypeString}},
+	}}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if format := string(raw[summaryFormatKey]); format != "2" {
+		t.Fatalf("expected the summary to be format 2, got %s", format)
+	}
+
+	_, sa := parseResourceSummary(content)
+	sku := sa["azurerm_foobar.sku_name"]
+	if sku.DataType != "TypeString" || sku.Description != "The SKU." || sku.Constraints == nil || !reflect.DeepEqual(sku.Constraints.OneOf, []string{"Basic", "Premium"}) {
+		t.Fatalf("expected the schema of the attribute in the summary, got %+v", sku)
+	}
+	if name := sa["azurerm_foobar.name"]; name.Constraints != nil {
+		t.Fatalf("expected no constraints for an attribute without validation, got %+v", name.Constraints)
+	}
+	// the nested attributes keep their schema too
+	if ipRules := sa["azurerm_foobar.network.ip_rules"]; !ipRules.Required || ipRules.DataType != "TypeSet" || ipRules.ElemType != "TypeString" {
+		t.Fatalf("expected the nested attribute's schema in the summary, got %+v", ipRules)
+	}
+}
+
+func TestSensitiveAttributes(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["admin_password"] = &schema.Schema{Type: schema.TypeString, Required: true, Sensitive: true}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	template := gen.terraformTemplateBlock()
+	if !strings.Contains(template, "admin_password		= var.${dlta_terraform_module_name}_admin_password") || strings.Contains(template, "${admin_password}") {
+		t.Fatalf("expected the template to reference the secret rather than embed the value, got:\n%s", template)
+	}
+	if err := validateHcl("template.tf", template); err != nil {
+		t.Fatalf("expected a valid template, got %+v:\n%s", err, template)
+	}
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	pp := gen.getPalletProp(attributes["admin_password"], "admin_password")
+	if pp.Type != "password" || pp.SecretRef == nil || *pp.SecretRef != "${dlta_terraform_module_name}_admin_password" || pp.CurrentValue != "" {
+		t.Fatalf("expected a masked control referencing the secret, got %+v", pp)
+	}
+
+	if declaration := variableDeclaration("admin_password", attributes["admin_password"]); !strings.Contains(declaration, "sensitive = true") {
+		t.Fatalf("expected a sensitive variable, got:\n%s", declaration)
+	}
+
+	// the summary persists the flag, and lets the user mark a secret the schema doesn't
+	sa := gen.readResourceProperties()
+	if !sa["azurerm_foobar.admin_password"].Sensitive || sa["azurerm_foobar.name"].Sensitive {
+		t.Fatalf("expected only admin_password to be sensitive in the summary, got %+v", sa)
+	}
+	gen.resource.Schema["connection_string"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	sa["azurerm_foobar.connection_string"] = summaryAttribute{Published: true, Sensitive: true}
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if published := gen.getPublishedAttributes()["connection_string"]; !published.Sensitive {
+		t.Fatalf("expected the summary to mark connection_string as sensitive")
+	}
+	if template := gen.terraformTemplateBlock(); !strings.Contains(template, "var.${dlta_terraform_module_name}_connection_string") {
+		t.Fatalf("expected the template to reference the secret marked in the summary, got:\n%s", template)
+	}
+
+	gen.resource.Schema["primary_key"] = &schema.Schema{Type: schema.TypeString, Computed: true, Sensitive: true}
+	gen.showOutputs = true
+	gen.paletteOutputs = []string{"primary_key"}
+	if output := gen.terraformOutputBlock(); !strings.Contains(output, "value = azurerm_foobar.this.primary_key\n\tsensitive = true") {
+		t.Fatalf("expected a sensitive output, got:\n%s", output)
+	}
+	if pp := gen.paletteOutputProp(gen.getPaletteOutputAttributes()["primary_key"], "primary_key"); pp.Type != "password" {
+		t.Fatalf("expected a masked output control, got %q", pp.Type)
+	}
+}
+
+func TestPaletteDescriptions(t *testing.T) {
+	cases := map[string]string{
+		"":                                     "",
+		"The SKU of the **Key Vault**.":        "The SKU of the Key Vault.",
+		"See [the docs](https://example.com).": "See the docs.",
+		"Either `Standard` or\n  `Premium`.":   "Either Standard or Premium.",
+		"<b>Bold</b> &lt;script&gt;x&lt;/script&gt;": "Bold x",
+	}
+	for input, expected := range cases {
+		if actual := sanitizeDescription(input); actual != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, input, actual)
+		}
+	}
+
+	gen := testGenerator()
+	if pp := gen.getPalletProp(attribute{DataTypeString: "TypeString"}, "foo"); pp.Description != nil {
+		t.Fatalf("expected no description, got %q", *pp.Description)
+	}
+	pp := gen.getPalletProp(attribute{DataTypeString: "TypeString", Description: "The `sku` to use.", Deprecated: "use `tier`"}, "sku")
+	if pp.Description == nil || *pp.Description != "The sku to use. Deprecated: use tier" {
+		t.Fatalf("expected the sanitized description with the deprecation note, got %v", pp.Description)
+	}
+	pp = gen.getPalletProp(attribute{DataTypeString: "TypeString", Description: "The sku (Deprecated: use tier)", Deprecated: "use tier"}, "sku")
+	if *pp.Description != "The sku (Deprecated: use tier)" {
+		t.Fatalf("expected the deprecation note once, got %q", *pp.Description)
+	}
+}
+
+func TestPaletteTargets(t *testing.T) {
+	if targets, err := parsePaletteTargets("backstage, jsonschema,backstage"); err != nil || !reflect.DeepEqual(targets, []string{paletteTargetBackstage, paletteTargetJsonSchema}) {
+		t.Fatalf("expected the targets in order without duplicates, got %v: %+v", targets, err)
+	}
+	if _, err := parsePaletteTargets("infra-asset,vue"); err == nil {
+		t.Fatalf("expected an error for an unknown target")
+	}
+
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Required: true, ValidateFunc: validation.StringLenBetween(1, 8)}
+	gen.resource.Schema["capacity"] = &schema.Schema{Type: schema.TypeInt, Optional: true, Default: 2, ValidateFunc: validation.IntBetween(1, 10)}
+	gen.resource.Schema["admin_password"] = &schema.Schema{Type: schema.TypeString, Optional: true, Sensitive: true}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(`{"azurerm_foobar.sku_name": {"Published": true}, "azurerm_foobar.capacity": {"Published": true}, "azurerm_foobar.admin_password": {"Published": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	artefacts, err := paletteRenderers[paletteTargetJsonSchema].render(gen)
+	if err != nil {
+		t.Fatalf("rendering the json schema: %+v", err)
+	}
+	var form struct {
+		Schema struct {
+			Required   []string                          `json:"required"`
+			Properties map[string]map[string]interface{} `json:"properties"`
+		} `json:"schema"`
+		UiSchema map[string]interface{} `json:"uiSchema"`
+	}
+	if err := json.Unmarshal([]byte(artefacts[PaletteJsonSchema]), &form); err != nil {
+		t.Fatalf("parsing the json schema: %+v", err)
+	}
+	if !reflect.DeepEqual(form.Schema.Required, []string{"name", "sku_name"}) {
+		t.Fatalf("expected name and sku_name to be required, got %v", form.Schema.Required)
+	}
+	capacity := form.Schema.Properties["capacity"]
+	if capacity["type"] != "integer" || capacity["minimum"] != 1.0 || capacity["maximum"] != 10.0 || capacity["default"] != 2.0 {
+		t.Fatalf("expected an integer between 1 and 10 defaulting to 2, got %+v", capacity)
+	}
+	if sku := form.Schema.Properties["sku_name"]; sku["maxLength"] != 8.0 {
+		t.Fatalf("expected sku_name to have a max length, got %+v", sku)
+	}
+	if ui, _ := form.UiSchema["admin_password"].(map[string]interface{}); ui["ui:widget"] != "password" {
+		t.Fatalf("expected a password widget, got %+v", form.UiSchema["admin_password"])
+	}
+
+	artefacts, err = paletteRenderers[paletteTargetBackstage].render(gen)
+	if err != nil {
+		t.Fatalf("rendering the backstage template: %+v", err)
+	}
+	var template struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Parameters []struct {
+				Title    string   `yaml:"title"`
+				Required []string `yaml:"required"`
+			} `yaml:"parameters"`
+			Steps []struct {
+				Input struct {
+					Url    string            `yaml:"url"`
+					Values map[string]string `yaml:"values"`
+				} `yaml:"input"`
+			} `yaml:"steps"`
+		} `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal([]byte(artefacts[PaletteBackstage]), &template); err != nil {
+		t.Fatalf("parsing the backstage template: %+v\n%s", err, artefacts[PaletteBackstage])
+	}
+	if template.Kind != "Template" || template.Metadata.Name != "azurerm-foobar" || len(template.Spec.Parameters) != 3 {
+		t.Fatalf("unexpected backstage template:\n%s", artefacts[PaletteBackstage])
+	}
+	if required := template.Spec.Parameters[1]; required.Title != "Required" || !reflect.DeepEqual(required.Required, []string{"sku_name"}) {
+		t.Fatalf("expected sku_name on the required page, got %+v", required)
+	}
+	if template.Spec.Steps[0].Input.Values["capacity"] != "${{ parameters.capacity }}" {
+		t.Fatalf("expected the parameters passed to the skeleton, got %+v", template.Spec.Steps[0].Input.Values)
+	}
+	skeleton := artefacts[BackstageSkeleton]
+	if template.Spec.Steps[0].Input.Url != "./skeleton" || !strings.Contains(skeleton, "${{ values.capacity }}") || templatePlaceholderRegex.MatchString(skeleton) {
+		t.Fatalf("expected the skeleton to be the module call rendered from the values, got %q:\n%s", template.Spec.Steps[0].Input.Url, skeleton)
+	}
+	gen.writeResource(skeleton, BackstageSkeleton)
+	if _, err := os.Stat(filepath.Join(gen.resourceDir("backstage"), "skeleton", "main.tf.njk")); err != nil {
+		t.Fatalf("expected the skeleton to be written next to template.yaml: %+v", err)
+	}
+
+	artefacts, err = paletteRenderers[paletteTargetInfraAsset].render(gen)
+	if _, ok := artefacts[PalletteBlock]; err != nil || !ok || len(artefacts) != 1 {
+		t.Fatalf("expected just the palette SQL by default, got %v: %+v", artefacts, err)
+	}
+}
+
+func TestExportPaletteCatalog(t *testing.T) {
+	dltaPath := t.TempDir()
+
+	if err := exportPaletteCatalog(dltaPath, scaffoldOptions{paletteFormat: paletteFormatBoth}); err == nil {
+		t.Fatalf("expected an error without any scaffolded resources")
+	}
+
+	for path, content := range map[string]string{
+		"r/azurerm_resource_group/resource/pallette.sql": "",
+		"d/azurerm_key_vault/resource/palette.json":      "{}",
+		"r/azurerm_retired/resource/pallette.sql":        "",
+		"r/azurerm_retired/resource/" + retireFileName:   "",
+		"r/azurerm_not_registered/resource/pallette.sql": "",
+		"r/azurerm_virtual_network/resource/main.tf":     "",
+	} {
+		if err := os.MkdirAll(filepath.Join(dltaPath, filepath.Dir(path)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dltaPath, path), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resources, err := scaffoldedResources(dltaPath)
+	if err != nil {
+		t.Fatalf("finding the scaffolded resources: %+v", err)
+	}
+	expected := []scaffoldedResource{{name: "azurerm_not_registered", isResource: true}, {name: "azurerm_resource_group", isResource: true}, {name: "azurerm_key_vault"}}
+	if !reflect.DeepEqual(resources, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, resources)
+	}
+
+	if err := exportPaletteCatalog(dltaPath, scaffoldOptions{paletteFormat: paletteFormatBoth}); err != nil {
+		t.Fatalf("exporting the catalog: %+v", err)
+	}
+
+	sql, err := os.ReadFile(filepath.Join(dltaPath, catalogDir, "palette.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sql), "begin;") || !strings.HasSuffix(string(sql), "commit;\n") || strings.Count(string(sql), "on conflict (asset_type)") != 2 {
+		t.Fatalf("expected a transaction upserting both assets, got:\n%s", sql)
+	}
+
+	var entries []paletteCatalogEntry
+	content, err := os.ReadFile(filepath.Join(dltaPath, catalogDir, "palette.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		t.Fatalf("parsing the catalog: %+v", err)
+	}
+	if len(entries) != 2 || entries[0].AssetType != "azurerm_resource_group" || entries[0].HasCost || entries[1].AssetType != "azurerm_key_vault" {
+		t.Fatalf("unexpected catalog entries %+v", entries)
+	}
+
+	if err := exportPaletteCatalog(dltaPath, scaffoldOptions{}); err == nil {
+		t.Fatalf("expected an error replacing the catalog without `-force`")
+	}
+}
+
+func TestNamingProviders(t *testing.T) {
+	gen := testGenerator()
+	gen.ShortCode = "fb"
+
+	if _, ok := gen.getNamingProvider().(tokenNamingProvider); !ok {
+		t.Fatalf("expected the token naming provider by default")
+	}
+
+	gen.naming = namingConfig{Provider: "azurecaf"}
+	naming := gen.getNamingProvider()
+	if actual := naming.nameExpression(""); actual != "azurecaf_name.this.result" {
+		t.Fatalf("unexpected azurecaf name expression %q", actual)
+	}
+	if actual := naming.nameExpression("d"); actual != gen.namingExpression("d") {
+		t.Fatalf("expected nested names to use the token convention, got %q", actual)
+	}
+	expected := `resource "azurecaf_name" "this" {
+	resource_type = "azurerm_foobar"
+	suffixes = [var.dlta_business_short_code, var.dlta_application_short_code, var.dlta_environment_char, var.dlta_location_short_code, var.dlta_instance_id]
+	separator = "-"
+	clean_input = true
+}
+`
+	if actual := naming.supportingBlocks(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	gen.naming = namingConfig{Provider: "webhook", WebhookUrl: "https://naming.example.com/names"}
+	naming = gen.getNamingProvider()
+	if actual := naming.nameExpression(""); actual != "jsondecode(data.http.name.response_body).name" {
+		t.Fatalf("unexpected webhook name expression %q", actual)
+	}
+	block := requiredProvidersBlock(naming.requiredProviders()) + naming.supportingBlocks()
+	if !strings.Contains(block, `url = "https://naming.example.com/names?resource_type=azurerm_foobar&dlta_vendor_asset_short_code=fb&dlta_business_short_code=${urlencode(var.dlta_business_short_code)}`) {
+		t.Fatalf("unexpected webhook block:\n%s", block)
+	}
+	if err := validateHcl("local.tf", block); err != nil {
+		t.Fatalf("expected the webhook block to be valid HCL: %+v", err)
+	}
+
+	gen.dltaPath = t.TempDir()
+	for config, valid := range map[string]bool{
+		`{"provider": "azurecaf"}`: true,
+		`{"provider": "webhook", "webhook_url": "https://naming.example.com"}`: true,
+		`{"provider": "webhook"}`: false,
+		`{"provider": "random"}`:  false,
+	} {
+		writeConfig(t, gen.dltaPath, "naming.json", config)
+		if _, err := gen.readNamingConfig(); valid != (err == nil) {
+			t.Fatalf("%s: expected valid to be %t, got: %+v", config, valid, err)
+		}
+	}
+}
+
+func TestNamingTokens(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	config := `{
+	"tokens": {"dlta_cost_center": {"description": "Cost center", "options": ["cc01", "cc002"]}},
+	"resources": {"azurerm_foobar": {"fields": ["dlta_application_short_code", "dlta_cost_center"]}}
+}`
+	writeConfig(t, gen.dltaPath, "naming.json", config)
+
+	naming, err := gen.readNamingConfig()
+	if err != nil {
+		t.Fatalf("reading naming config: %+v", err)
+	}
+	gen.naming = naming
+
+	if actual := gen.namingExpression(""); actual != `format("%s-%s",var.dlta_application_short_code,var.dlta_cost_center)` {
+		t.Fatalf("unexpected naming expression %q", actual)
+	}
+
+	token, ok := gen.getInjectAttributes()["dlta_cost_center"]
+	if !ok || token.Description != "Cost center" || len(token.PossibleValues) != 2 {
+		t.Fatalf("expected the token to be injected, got %+v", token)
+	}
+
+	if !strings.Contains(gen.terraformTemplateNamingArguments(), "\tdlta_cost_center = ${dlta_cost_center}\n") {
+		t.Fatalf("expected the token to be passed to the module")
+	}
+
+	if actual := gen.namingFieldMaxLength("dlta_cost_center"); actual != 5 {
+		t.Fatalf("expected the longest option to be 5 characters, got %d", actual)
+	}
+
+	gen.resourceName = "azurerm_unlisted"
+	if _, ok := gen.getInjectAttributes()["dlta_cost_center"]; ok {
+		t.Fatalf("expected the token only to be injected where the naming convention uses it")
+	}
+
+	for _, config := range []string{
+		`{"tokens": {"cost_center": {}}}`,
+		`{"tokens": {"dlta_instance_id": {}}}`,
+		`{"resources": {"azurerm_foobar": {"fields": ["dlta_project"]}}}`,
+	} {
+		writeConfig(t, gen.dltaPath, "naming.json", config)
+		if _, err := gen.readNamingConfig(); err == nil {
+			t.Fatalf("%s: expected an error", config)
+		}
+	}
+}
+
+func TestEnvironmentNaming(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	config := `{
+	"default": {"environments": {"s": {"prefix": "tmp"}}},
+	"resources": {"azurerm_foobar": {"fields": ["dlta_application_short_code", "dlta_instance_id"], "environments": {"p": {"fields": ["dlta_application_short_code"]}}}}
+}`
+	writeConfig(t, gen.dltaPath, "naming.json", config)
+
+	naming, err := gen.readNamingConfig()
+	if err != nil {
+		t.Fatalf("reading naming config: %+v", err)
+	}
+	gen.naming = naming
+
+	expected := `lookup({ p = format("%s",var.dlta_application_short_code), s = format("%s-%s-%s","tmp",var.dlta_application_short_code,var.dlta_instance_id) }, var.dlta_environment_char, format("%s-%s",var.dlta_application_short_code,var.dlta_instance_id))`
+	if actual := gen.namingExpression(""); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	if err := validateHcl("local.tf", fmt.Sprintf("locals {\n\tname = %s\n}\n", expected)); err != nil {
+		t.Fatalf("expected the expression to be valid HCL: %+v", err)
+	}
+
+	if actual := gen.getResourceNamingConvention("azurerm_unlisted", false); actual != "${dlta_vendor_asset_short_code}-${dlta_business_short_code}-${dlta_application_short_code}-${dlta_environment_char}-${dlta_location_short_code}-${dlta_instance_id}" {
+		t.Fatalf("expected environment overrides not to change the palette convention, got %q", actual)
+	}
+
+	config = `{"default": {"environments": {"x": {"prefix": "tmp"}}}}`
+	writeConfig(t, gen.dltaPath, "naming.json", config)
+	if _, err := gen.readNamingConfig(); err == nil {
+		t.Fatalf("expected an error for an unknown environment")
+	}
+}
+
+func TestUniqueSuffix(t *testing.T) {
+	gen := testGenerator()
+	gen.naming = namingConfig{Resources: map[string]namingConfigEntry{
+		resourceName: {Fields: []string{"dlta_application_short_code", "dlta_unique_suffix"}},
+	}}
+
+	if !gen.usesUniqueSuffix() {
+		t.Fatalf("expected the unique suffix to be used")
+	}
+	if actual := gen.namingExpression(""); actual != `format("%s-%s",var.dlta_application_short_code,local.dlta_unique_suffix)` {
+		t.Fatalf("unexpected naming expression %q", actual)
+	}
+
+	expected := `substr(sha256(join("/", [data.azurerm_client_config.current.subscription_id, "azurerm_foobar", var.dlta_application_short_code])), 0, 4)`
+	if actual := gen.uniqueSuffixExpression(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	if actual := gen.uniqueSuffixBlock(); actual != "data \"azurerm_client_config\" \"current\" {}\n" {
+		t.Fatalf("unexpected hash block %q", actual)
+	}
+
+	gen.naming.UniqueSuffix = &uniqueSuffixConfig{Mode: "random", Length: 6}
+	if actual := gen.uniqueSuffixExpression(); actual != "random_string.dlta_unique_suffix.result" {
+		t.Fatalf("unexpected random expression %q", actual)
+	}
+	block := gen.uniqueSuffixBlock()
+	if !strings.Contains(block, "length = 6") {
+		t.Fatalf("expected the configured length, got:\n%s", block)
+	}
+	if err := validateHcl("local.tf", block); err != nil {
+		t.Fatalf("expected the block to be valid HCL: %+v", err)
+	}
+	if actual := gen.namingFieldMaxLength(uniqueSuffixField); actual != 6 {
+		t.Fatalf("expected the suffix length to count towards the name length, got %d", actual)
+	}
+
+	gen.naming = namingConfig{}
+	if gen.usesUniqueSuffix() {
+		t.Fatalf("expected the default convention not to use the unique suffix")
+	}
+}
+
+type testAuthorizer struct{}
+
+func (testAuthorizer) Token(_ context.Context, _ *http.Request) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "token", TokenType: "Bearer"}, nil
+}
+
+func (testAuthorizer) AuxiliaryTokens(_ context.Context, _ *http.Request) ([]*oauth2.Token, error) {
+	return nil, nil
+}
+
+func TestLiveOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"value": [{"name": "westeurope", "metadata": {"regionType": "Physical"}}, {"name": "europe", "metadata": {"regionType": "Logical"}}], "nextLink": "http://%s%s?page=2"}`, r.Host, r.URL.Path)
+		case "2":
+			fmt.Fprint(w, `{"value": [{"name": "northeurope", "metadata": {"regionType": "Physical"}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	options, err := armListOptions(context.Background(), server.Client(), testAuthorizer{}, server.URL, liveOptionSources["location"], "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("listing locations: %+v", err)
+	}
+	if strings.Join(options, ",") != "northeurope,westeurope" {
+		t.Fatalf("expected the physical locations across the pages, got %+v", options)
+	}
+
+	gen := testGenerator()
+	gen.resourceName = "azurerm_service_plan"
+	gen.dltaPath = t.TempDir()
+
+	fetches := 0
+	fetch := func(_ context.Context, source liveOptionSource) ([]string, error) {
+		fetches++
+		if source.Path == liveOptionSources["location"].Path {
+			return []string{"uksouth"}, nil
+		}
+		return []string{"P1v3", "S1"}, nil
+	}
+
+	liveOptions, err := gen.loadLiveOptions(context.Background(), fetch)
+	if err != nil || len(liveOptions) != 0 || fetches != 0 {
+		t.Fatalf("expected nothing to be fetched without -refresh-options, got %+v: %+v", liveOptions, err)
+	}
+
+	gen.refreshOptions = true
+	if _, err = gen.loadLiveOptions(context.Background(), fetch); err != nil || fetches != 2 {
+		t.Fatalf("expected the location and sku options to be fetched, got %d fetches: %+v", fetches, err)
+	}
+	if _, err = gen.loadLiveOptions(context.Background(), fetch); err != nil || fetches != 2 {
+		t.Fatalf("expected the cached options to be used, got %d fetches: %+v", fetches, err)
+	}
+
+	gen.refreshOptions = false
+	gen.liveOptions, _ = gen.loadLiveOptions(context.Background(), fetch)
+	pp := gen.getPalletProp(attribute{DataTypeString: "TypeString", ResourcePath: "azurerm_service_plan.sku_name", Required: true}, "sku_name")
+	if pp.Type != "select" || len(pp.Options) != 2 || pp.Options[0].Value != "P1v3" {
+		t.Fatalf("expected the cached sku options, got %+v", pp)
+	}
+	if pp := gen.getPalletProp(attribute{DataTypeString: "TypeString", ResourcePath: "azurerm_service_plan.location"}, "location"); pp.CurrentValue != "uksouth" || len(pp.Options) != 1 {
+		t.Fatalf("expected the cached locations, got %+v", pp)
+	}
+}
+
+func TestNameAvailability(t *testing.T) {
+	check := nameAvailabilityChecks["azurerm_storage_account"]
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Storage/checkNameAvailability" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_ = json.NewEncoder(w).Encode(nameAvailability{NameAvailable: body["name"] != "sttaken", Reason: "AlreadyExists"})
+	}))
+	defer server.Close()
+
+	for name, expected := range map[string]bool{"stfree": true, "sttaken": false} {
+		availability, err := armNameAvailability(context.Background(), server.Client(), testAuthorizer{}, server.URL, check, "00000000-0000-0000-0000-000000000000", name)
+		if err != nil {
+			t.Fatalf("checking %q: %+v", name, err)
+		}
+		if availability.NameAvailable != expected {
+			t.Fatalf("expected %q available to be %t", name, expected)
+		}
+	}
+
+	lookupHost := func(host string) ([]string, error) {
+		if host == "sttaken.blob.core.windows.net" {
+			return []string{"10.0.0.1"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	for name, expected := range map[string]bool{"stfree": true, "sttaken": false} {
+		availability, err := dnsNameAvailability(lookupHost, check, name)
+		if err != nil {
+			t.Fatalf("checking %q: %+v", name, err)
+		}
+		if availability.NameAvailable != expected {
+			t.Fatalf("expected %q available to be %t", name, expected)
+		}
+	}
+
+	gen := testGenerator()
+	gen.resourceName = "azurerm_storage_account"
+	validators := gen.getPalletProp(attribute{}, "Name").Validators
+	if _, ok := validators["nameAvailability"]; !ok {
+		t.Fatalf("expected a name availability validator, got %+v", validators)
+	}
+}
+
+func TestNestedNameRules(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+
+	config := `[
+	{"path": "azurerm_foobar.delegation.name", "mode": "user"},
+	{"path": "azurerm_*.ip_configuration.name", "mode": "user"},
+	{"path": "azurerm_foobar.delegation.service_delegation.name", "mode": "generated"}
+]`
+	writeConfig(t, gen.dltaPath, "nested_names.json", config)
+
+	rules, err := gen.readNestedNames()
+	if err != nil {
+		t.Fatalf("reading nested names: %+v", err)
+	}
+	gen.nestedNames = rules
+
+	cases := map[string]struct {
+		attribute attribute
+		expected  string
+	}{
+		"user":              {attribute: attribute{ResourcePath: "azurerm_foobar.delegation.name"}, expected: "var.azurerm_foobar_delegation_name"},
+		"wildcard":          {attribute: attribute{ResourcePath: "azurerm_network_interface.ip_configuration.name"}, expected: "var.azurerm_network_interface_ip_configuration_name"},
+		"generated":         {attribute: attribute{ResourcePath: "azurerm_foobar.delegation.service_delegation.name", PossibleValues: []string{"Microsoft.Web/serverFarms"}}, expected: "local.delegation_service_delegation_name"},
+		"unmatched enum":    {attribute: attribute{ResourcePath: "azurerm_foobar.rule.name", PossibleValues: []string{"Allow"}}, expected: "var.azurerm_foobar_rule_name"},
+		"wildcard one part": {attribute: attribute{ResourcePath: "azurerm_foobar.nic.ip_configuration.name"}, expected: "local.nic_ip_configuration_name"},
+	}
+	for name, c := range cases {
+		if actual := gen.nameExpression(c.attribute); actual != c.expected {
+			t.Fatalf("%s: expected %q, got %q", name, c.expected, actual)
+		}
+	}
+
+	writeConfig(t, gen.dltaPath, "nested_names.json", `[{"path": "azurerm_foobar.delegation.name", "mode": "random"}]`)
+	if _, err := gen.readNestedNames(); err == nil {
+		t.Fatalf("expected an error for an unknown mode")
+	}
+}
+
+func TestSetPublished(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"bypass":    {Type: schema.TypeString, Optional: true},
+		"subnet_id": {Type: schema.TypeString, Optional: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+
+	if _, err := gen.setPublished([]string{"azurerm_foobar.sku_name"}, true); err == nil || !strings.Contains(err.Error(), "initialise it with `-output-type init`") {
+		t.Fatalf("expected publishing without a summary to fail, got %v", err)
+	}
+	gen.writeInitResourceProperties()
+
+	changed, err := gen.setPublished([]string{"azurerm_foobar.sku_name", "azurerm_foobar.network.*"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"azurerm_foobar.network.bypass", "azurerm_foobar.network.subnet_id", "azurerm_foobar.sku_name"}; !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("expected the globbed paths to be published, got %v", changed)
+	}
+	if _, err := gen.setPublished([]string{"azurerm_foobar.network", "azurerm_foobar.location"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := gen.getPublishedResourcePaths(), []string{"azurerm_foobar.name", "azurerm_foobar.network.bypass", "azurerm_foobar.network.subnet_id", "azurerm_foobar.sku_name"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the summary to be edited, got %v", actual)
+	}
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.validateResourceSummary(content); err != nil || !strings.Contains(string(content), `"format": 2`) {
+		t.Fatalf("expected the rest of the summary to be kept, got %v\n%s", err, content)
+	}
+
+	// nothing is written unless every path is in the schema
+	if _, err := gen.setPublished([]string{"azurerm_foobar.location", "azurerm_foobar.sku_nme"}, true); err == nil || err.Error() != `"azurerm_foobar.sku_nme" doesn't match an attribute of azurerm_foobar, did you mean "azurerm_foobar.sku_name"` {
+		t.Fatalf("expected the mistyped path to be rejected, got %v", err)
+	}
+	if sa := gen.readResourceProperties(); sa["azurerm_foobar.location"].Published {
+		t.Fatal("expected the summary not to be written when a path is rejected")
+	}
+
+	// a profile is edited rather than the Published flags
+	gen.profile = "minimal"
+	gen.writeInitResourceProperties()
+	if _, err := gen.setPublished([]string{"azurerm_foobar.sku_name"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := gen.getPublishedResourcePaths(), []string{"azurerm_foobar.name", "azurerm_foobar.network.bypass", "azurerm_foobar.network.subnet_id"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the profile to be edited, got %v", actual)
+	}
+	gen.profile = ""
+	if sa := gen.readResourceProperties(); !sa["azurerm_foobar.sku_name"].Published {
+		t.Fatal("expected the Published flags to be left when editing a profile")
+	}
+}
+
+func TestPublishConsistency(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Required: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"subnet_id": {Type: schema.TypeString, Required: true},
+		"bypass":    {Type: schema.TypeString, Optional: true},
+	}}}
+	gen.resource.Schema["backup"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"retention_days": {Type: schema.TypeInt, Required: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	if err := gen.validatePublishConsistency(); err != nil {
+		t.Fatalf("expected the summary written by init to be consistent, got %v", err)
+	}
+
+	if _, err := gen.setPublished([]string{"azurerm_foobar.backup.retention_days"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.setPublished([]string{"azurerm_foobar.network.subnet_id"}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"azurerm_foobar.backup.retention_days is published but its block azurerm_foobar.backup isn't", "azurerm_foobar.network is published but its required azurerm_foobar.network.subnet_id isn't"}
+	if actual := gen.publishConsistencyErrors(gen.readResourceProperties()); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the inconsistent paths\nexpected: %v\nactual:   %v", expected, actual)
+	}
+	if err := gen.validatePublishConsistency(); err == nil || !strings.Contains(err.Error(), "\n  azurerm_foobar.backup.retention_days is published") {
+		t.Fatalf("expected scaffolding to be refused, got %v", err)
+	}
+
+	if _, err := gen.setPublished([]string{"azurerm_foobar.backup", "azurerm_foobar.network.subnet_id"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.validatePublishConsistency(); err != nil {
+		t.Fatalf("expected the summary to be consistent once fixed, got %v", err)
+	}
+}
+
+func TestInitAll(t *testing.T) {
+	dltaPath := t.TempDir()
+
+	if _, err := registeredResources("NoSuchService"); err == nil {
+		t.Fatal("expected an unknown service to be rejected")
+	}
+
+	resources, err := registeredResources("Key Vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[bool]bool)
+	for _, r := range resources {
+		if r.serviceName != "KeyVault" {
+			t.Fatalf("expected only the resources of the service, got %s of %s", r.name, r.serviceName)
+		}
+		kinds[r.isResource] = true
+	}
+	if !kinds[true] || !kinds[false] {
+		t.Fatal("expected both the resources and data sources of the service")
+	}
+
+	if err := initAllResourceProperties(dltaPath, "keyvault", scaffoldOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range resources {
+		gen := documentationGenerator{resourceName: r.name, isResource: r.isResource, dltaPath: dltaPath}
+		content, err := os.ReadFile(gen.resourcePropertiesPath())
+		if err != nil {
+			t.Fatalf("expected a summary for %s: %+v", r.name, err)
+		}
+		if !strings.Contains(string(content), `"service": "KeyVault"`) {
+			t.Fatalf("expected the summary of %s to record its service, got %s", r.name, content)
+		}
+	}
+}
+
+func TestValueOverride(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true, Default: "Basic", ValidateFunc: validation.StringInSlice([]string{"Basic", "Standard", "Premium"}, false)}
+	gen.resource.Schema["zones"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	sa := gen.readResourceProperties()
+	sku, zones := sa["azurerm_foobar.sku_name"], sa["azurerm_foobar.zones"]
+	sku.Published, sku.Override = true, &valueOverride{Default: "Standard", Options: []string{"Standard", "Premium"}}
+	zones.Published, zones.Override = true, &valueOverride{Options: []string{"1", "2"}}
+	sa["azurerm_foobar.sku_name"], sa["azurerm_foobar.zones"] = sku, zones
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-initialising keeps the overrides
+	gen.writeInitResourceProperties()
+	if override := gen.readResourceProperties()["azurerm_foobar.sku_name"].Override; override == nil || override.Default != "Standard" {
+		t.Fatalf("expected the override to be kept, got %+v", override)
+	}
+
+	variables := make(map[string]string)
+	for _, v := range gen.getModuleVariables() {
+		variables[v.Name] = variableDeclaration(v.Name, v.Attribute)
+	}
+	for _, expected := range []string{`default = "Standard"`, `condition     = var.sku_name == null ? true : contains(["Standard", "Premium"], var.sku_name)`, `error_message = "The sku_name must be one of Standard, Premium."`} {
+		if !strings.Contains(variables["sku_name"], expected) {
+			t.Fatalf("expected %s in the variable:\n%s", expected, variables["sku_name"])
+		}
+	}
+	if expected := `alltrue([for v in var.zones : contains(["1", "2"], v)])`; !strings.Contains(variables["zones"], expected) {
+		t.Fatalf("expected each element to be validated:\n%s", variables["zones"])
+	}
+
+	attributes := gen.getPublishedAttributes()
+	pp := gen.getPalletProp(attributes["sku_name"], "sku_name")
+	if len(pp.Options) != 2 || pp.Options[0].Value != "Standard" || pp.CurrentValue != "Standard" || pp.DefaultSource == nil || *pp.DefaultSource != defaultSourceSummary {
+		t.Fatalf("expected the control to offer the approved options, got %+v", pp)
+	}
+
+	invalid := fmt.Sprintf(`{"provider_version": %q, "azurerm_foobar.sku_name": {"Published": true, "Override": {"Default": "Basic", "Options": ["Standard", "Ultra"]}}}`, version.ProviderVersion)
+	expected := `azurerm_foobar.json: azurerm_foobar.sku_name: Override: Options: [1]: "Ultra" isn't one of Basic, Standard, Premium
+azurerm_foobar.json: azurerm_foobar.sku_name: Override: Default: "Basic" isn't one of Standard, Ultra`
+	if err := gen.validateResourceSummary([]byte(invalid)); err == nil || err.Error() != expected {
+		t.Fatalf("expected the override to be validated against the schema\nexpected: %s\nactual:   %v", expected, err)
+	}
+}
+
+func TestEnvironmentPublishing(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["public_network_access_enabled"] = &schema.Schema{Type: schema.TypeBool, Optional: true, Default: false}
+	gen.resource.Schema["zone"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	sa := gen.readResourceProperties()
+	public, zone := sa["azurerm_foobar.public_network_access_enabled"], sa["azurerm_foobar.zone"]
+	public.Published, public.Environments = true, []string{"d"}
+	zone.Published, zone.Environments = true, []string{"d", "u"}
+	sa["azurerm_foobar.public_network_access_enabled"], sa["azurerm_foobar.zone"] = public, zone
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-initialising keeps the environments
+	gen.writeInitResourceProperties()
+	if actual := gen.readResourceProperties()["azurerm_foobar.zone"].Environments; !reflect.DeepEqual(actual, []string{"d", "u"}) {
+		t.Fatalf("expected the environments to be kept, got %v", actual)
+	}
+
+	module := gen.terraformModuleBlock()
+	for _, expected := range []string{
+		"\tpublic_network_access_enabled = contains([\"d\"], var.dlta_environment_char) ? var.public_network_access_enabled : false\n",
+		"\tzone = contains([\"d\", \"u\"], var.dlta_environment_char) ? var.zone : null\n",
+		"\tlocation = var.location\n",
+	} {
+		if !strings.Contains(module, expected) {
+			t.Fatalf("expected %q in the module:\n%s", expected, module)
+		}
+	}
+
+	gen.paletteRules = []paletteRule{{Control: "zone", When: "location", NotIn: []string{"westus"}}}
+	filters := make(map[string]string)
+	for _, pp := range gen.paletteCreator().Props {
+		if pp.Filter != nil {
+			filters[pp.ID] = *pp.Filter
+		}
+	}
+	expected := map[string]string{
+		"public_network_access_enabled": "dlta_environment_char == 'd'",
+		"zone":                          "dlta_environment_char in ['d', 'u'] && location != 'westus'",
+	}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Fatalf("expected the controls to be shown only in their environments, got %v", filters)
+	}
+
+	invalid := fmt.Sprintf(`{"provider_version": %q, "azurerm_foobar.name": {"Published": true, "Required": true, "Environments": ["x"]}}`, version.ProviderVersion)
+	expectedErr := `azurerm_foobar.json: azurerm_foobar.name: Environments: [0]: "x" isn't one of d, u, s, p
+azurerm_foobar.json: azurerm_foobar.name: Environments: a required attribute is published in every environment`
+	if err := gen.validateResourceSummary([]byte(invalid)); err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected the environments to be validated\nexpected: %s\nactual:   %v", expectedErr, err)
+	}
+}
+
+func TestSummaryExtends(t *testing.T) {
+	dltaPath := t.TempDir()
+	tags := &schema.Schema{Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	zone := &schema.Schema{Type: schema.TypeString, Optional: true}
+
+	base := testGenerator()
+	base.resourceName = "azurerm_windows_foobar"
+	base.resource.Schema["tags"], base.resource.Schema["zone"] = tags, zone
+	base.dltaPath = dltaPath
+	base.writeInitResourceProperties()
+	if _, err := base.setPublished([]string{"azurerm_windows_foobar.tags", "azurerm_windows_foobar.zone"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := testGenerator()
+	gen.resource.Schema["tags"], gen.resource.Schema["zone"] = tags, zone
+	gen.resource.Schema["docker_image"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.dltaPath = dltaPath
+	gen.writeInitResourceProperties()
+
+	writeSummary := func(g documentationGenerator, extends string) {
+		content, err := os.ReadFile(g.resourcePropertiesPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary := make(map[string]any)
+		if err := json.Unmarshal(content, &summary); err != nil {
+			t.Fatal(err)
+		}
+		summary[summaryExtendsKey] = extends
+		if err := os.WriteFile(g.resourcePropertiesPath(), []byte(writeJson(summary)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSummary(gen, "azurerm_windows_foobar")
+
+	if actual, expected := gen.getPublishedResourcePaths(), []string{"azurerm_foobar.location", "azurerm_foobar.name", "azurerm_foobar.tags", "azurerm_foobar.zone"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the publishing to be inherited, got %v", actual)
+	}
+
+	// targeted overrides, the zone is unpublished though the base publishes it and the image is the resource's own
+	if _, err := gen.setPublished([]string{"azurerm_foobar.zone"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.setPublished([]string{"azurerm_foobar.docker_image"}, true); err != nil {
+		t.Fatal(err)
+	}
+	gen.writeInitResourceProperties()
+	if actual, expected := gen.getPublishedResourcePaths(), []string{"azurerm_foobar.docker_image", "azurerm_foobar.location", "azurerm_foobar.name", "azurerm_foobar.tags"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the overrides to survive re-initialising, got %v", actual)
+	}
+
+	// changes to the base are inherited
+	if _, err := base.setPublished([]string{"azurerm_windows_foobar.tags"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if gen.readResourceProperties()["azurerm_foobar.tags"].Published {
+		t.Fatal("expected the base being unpublished to be inherited")
+	}
+
+	writeSummary(base, "azurerm_foobar")
+	if err := gen.applyExtends(make(map[string]summaryAttribute), []byte(`{"extends": "azurerm_windows_foobar"}`), nil); err == nil || err.Error() != "azurerm_foobar can't be extended by a summary it extends: azurerm_foobar extends azurerm_windows_foobar extends azurerm_foobar" {
+		t.Fatalf("expected the cycle to be rejected, got %v", err)
+	}
+	if err := gen.applyExtends(make(map[string]summaryAttribute), []byte(`{"extends": "azurerm_missing"}`), nil); err == nil || !strings.Contains(err.Error(), "extends azurerm_missing which hasn't been initialised") {
+		t.Fatalf("expected a missing base to be rejected, got %v", err)
+	}
+}
+
+func TestSummaryMatrix(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true, ValidateFunc: validation.StringInSlice([]string{"Basic", "Standard", "Premium"}, false)}
+	gen.resource.Schema["capacity"] = &schema.Schema{Type: schema.TypeInt, Optional: true, ValidateFunc: validation.IntBetween(1, 10)}
+	gen.resource.Schema["zones"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+
+	sa := gen.readResourceProperties()
+	sku := sa["azurerm_foobar.sku_name"]
+	sku.Published, sku.Override = true, &valueOverride{Default: "Standard", Options: []string{"Standard", "Premium"}}
+	sa["azurerm_foobar.sku_name"] = sku
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exportSummaryMatrix(gen.dltaPath, []string{"azurerm_missing"}, scaffoldOptions{}); err == nil {
+		t.Fatal("expected a resource which hasn't been initialised to be rejected")
+	}
+
+	if err := exportSummaryMatrix(gen.dltaPath, nil, scaffoldOptions{matrixFormat: matrixFormatMarkdown}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(gen.dltaPath, catalogDir, "matrix.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `| Resource | Attribute | Type | Required | Published | Constraints |
+| --- | --- | --- | --- | --- | --- |
+| azurerm_foobar | capacity | int | no | no | between 1 and 10 |
+| azurerm_foobar | location | string | yes | yes |  |
+| azurerm_foobar | name | string | yes | yes |  |
+| azurerm_foobar | sku_name | string | no | yes | one of Standard, Premium; defaults to Standard |
+| azurerm_foobar | zones | list(string) | no | no |  |
+`
+	if !strings.HasSuffix(string(content), expected) {
+		t.Fatalf("expected the markdown matrix\nexpected:\n%s\nactual:\n%s", expected, content)
+	}
+
+	if err := exportSummaryMatrix(gen.dltaPath, []string{resourceName}, scaffoldOptions{matrixFormat: matrixFormatCsv}); err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(filepath.Join(gen.dltaPath, catalogDir, "matrix.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 6 || lines[0] != "Resource,Attribute,Type,Required,Published,Constraints" || lines[4] != "azurerm_foobar,sku_name,string,no,yes,\"one of Standard, Premium; defaults to Standard\"" {
+		t.Fatalf("expected the csv matrix, got:\n%s", content)
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+	dir := gen.resourceLockDir()
+
+	release, err := acquireLock(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireLock(dir, 2*lockPollInterval); err == nil || !strings.Contains(err.Error(), "is locked by") {
+		t.Fatalf("expected the held lock to time out, got %v", err)
+	}
+	if _, err := getContent(resourceName, true, gen.dltaPath, "publish", gen.scaffoldOptions); err == nil || !strings.Contains(err.Error(), "is locked by") {
+		t.Fatalf("expected publishing to wait for the lock, got %v", err)
+	}
+	release()
+
+	// a lock left by a run which crashed is broken
+	if _, err := acquireLock(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(filepath.Join(dir, lockFileName), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	release, err = acquireLock(dir, 0)
+	if err != nil {
+		t.Fatalf("expected the stale lock to be broken, got %v", err)
+	}
+	release()
+
+	// runs finding the same stale lock break it once, the lock taken by the first isn't broken by the others
+	for round := 0; round < 10; round++ {
+		if _, err := acquireLock(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, lockFileName), stale, stale); err != nil {
+			t.Fatal(err)
+		}
+		var held, overlapped atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := acquireLock(dir, time.Minute)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if held.Add(1) > 1 {
+					overlapped.Add(1)
+				}
+				time.Sleep(5 * time.Millisecond)
+				held.Add(-1)
+				release()
+			}()
+		}
+		wg.Wait()
+		if overlapped.Load() > 0 {
+			t.Fatalf("expected the runs breaking the stale lock to take turns, %d held it at once", overlapped.Load())
+		}
+	}
+
+	// writers holding the lock take turns, so none of their read-modify-writes are lost
+	counterPath := filepath.Join(dir, "counter")
+	if err := writeFileAtomically(counterPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := acquireLock(dir, time.Minute)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer release()
+			content, err := os.ReadFile(counterPath)
+			if err == nil {
+				err = writeFileAtomically(counterPath, append(content, 'x'))
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(counterPath); len(content) != 8 {
+		t.Fatalf("expected every writer's update to be kept, got %q", content)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") || e.Name() == lockFileName {
+			t.Fatalf("expected no temporary files or lock to be left behind, found %s", e.Name())
+		}
+	}
+}
+
+func TestDeprecationReport(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["tier"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["zone"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"subnet_id":       {Type: schema.TypeString, Optional: true},
+		"legacy_vnet_id":  {Type: schema.TypeString, Optional: true},
+		"public_ip_count": {Type: schema.TypeInt, Optional: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+	gen.profile = "minimal"
+	gen.writeInitResourceProperties()
+	gen.profile = ""
+	if _, err := gen.setPublished([]string{"azurerm_foobar.tier", "azurerm_foobar.zone", "azurerm_foobar.network", "azurerm_foobar.network.legacy_vnet_id"}, true); err != nil {
+		t.Fatal(err)
+	}
+	gen.profile = "minimal"
+	if _, err := gen.setPublished([]string{"azurerm_foobar.network.public_ip_count"}, true); err != nil {
+		t.Fatal(err)
+	}
+	gen.profile = ""
+
+	// the provider since deprecates the tier and nested attributes, and has removed the zone
+	gen.resource.Schema["tier"].Deprecated = "`tier` has been superseded by `sku_name` and will be removed in v4.0 of the provider"
+	network := gen.resource.Schema["network"].Elem.(*schema.Resource)
+	network.Schema["legacy_vnet_id"].Deprecated = "This property is deprecated in favour of subnet_id"
+	network.Schema["public_ip_count"].Deprecated = "This property will be removed in v4.0 of the provider"
+	delete(gen.resource.Schema, "zone")
+	if err := gen.writeSchemaCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	found, summaries, err := findDeprecations(gen.dltaPath, nil, gen.scaffoldOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []deprecatedAttribute{
+		{Resource: resourceName, ResourcePath: "azurerm_foobar.network.legacy_vnet_id", Message: "This property is deprecated in favour of subnet_id", Replacement: "azurerm_foobar.network.subnet_id"},
+		{Resource: resourceName, ResourcePath: "azurerm_foobar.network.public_ip_count", Message: "This property will be removed in v4.0 of the provider"},
+		{Resource: resourceName, ResourcePath: "azurerm_foobar.tier", Message: "`tier` has been superseded by `sku_name` and will be removed in v4.0 of the provider", Replacement: "azurerm_foobar.sku_name"},
+		{Resource: resourceName, ResourcePath: "azurerm_foobar.zone", Removed: true},
+	}
+	if summaries != 1 || !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected the deprecated and removed attributes of the summary and its profile\nexpected: %+v\nactual:   %+v", expected, found)
+	}
+
+	message := "This property has been renamed to `authorized_ip_ranges` within the `network` block and will be removed in v4.0 of the provider"
+	network.Schema["authorized_ip_ranges"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	if actual := deprecationReplacement("azurerm_foobar.tier", message, gen.resource.Schema); actual != "azurerm_foobar.network.authorized_ip_ranges" {
+		t.Fatalf("expected the attribute within the block the message names, got %q", actual)
+	}
+
+	strict := gen.scaffoldOptions
+	strict.strict = true
+	if err := printDeprecations(gen.dltaPath, []string{resourceName}, strict); err == nil || err.Error() != fmt.Sprintf("4 published attributes are deprecated or removed in provider %s", version.ProviderVersion) {
+		t.Fatalf("expected `-strict y` to fail the report, got %v", err)
+	}
+
+	// a resource the provider no longer registers is removed, other errors looking it up aren't
+	retired := testGenerator()
+	retired.resourceName = "azurerm_retired_foobar"
+	retired.dltaPath = gen.dltaPath
+	retired.writeInitResourceProperties()
+	found, _, err = findDeprecations(gen.dltaPath, []string{"azurerm_retired_foobar"}, gen.scaffoldOptions)
+	if err != nil || len(found) != 1 || !found[0].Removed || found[0].Message != `Resource "azurerm_retired_foobar" was not registered!` {
+		t.Fatalf("expected the unregistered resource to be removed, got %+v (%v)", found, err)
+	}
+	if err := os.MkdirAll(filepath.Join(gen.dltaPath, "r", "azurerm_fizz"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := findDeprecations(gen.dltaPath, []string{resourceName}, gen.scaffoldOptions); err == nil || !strings.Contains(err.Error(), "short code collision") {
+		t.Fatalf("expected the short code collision to be returned rather than the resource removed, got %v", err)
+	}
+}
+
+func TestMigrateSummaries(t *testing.T) {
+	for format := 1; format < summaryFormat; format++ {
+		if summaryMigrations[format] == nil {
+			t.Fatalf("expected a migration from format %d", format)
+		}
+	}
+
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true, ValidateFunc: validation.StringInSlice([]string{"Basic", "Standard"}, false)}
+	gen.dltaPath = t.TempDir()
+	gen.serviceName = "Foobar"
+	if err := gen.writeSchemaCache(); err != nil {
+		t.Fatal(err)
+	}
+	gen.writeInitResourceProperties()
+	initialised := gen.readResourceProperties()
+
+	// a format 1 summary, without the schema of its attributes, publishing the sku and an attribute since removed
+	legacy := fmt.Sprintf(`{
+  "provider_version": %q,
+  "azurerm_foobar.name": {"Published": true, "IsBlock": false, "Required": true, "Optional": false, "Computed": false, "DependentResourcePath": ""},
+  "azurerm_foobar.location": {"Published": true, "IsBlock": false, "Required": true, "Optional": false, "Computed": false, "DependentResourcePath": ""},
+  "azurerm_foobar.sku_name": {"Published": true, "IsBlock": false, "Required": false, "Optional": true, "Computed": false, "DependentResourcePath": ""},
+  "azurerm_foobar.zone": {"Published": true, "IsBlock": false, "Required": false, "Optional": true, "Computed": false, "DependentResourcePath": ""}
+}`, version.ProviderVersion)
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateSummaries(gen.dltaPath, nil, gen.scaffoldOptions); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(gen.resourcePropertiesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if summaryFormatOf(raw) != summaryFormat || string(raw[summaryServiceKey]) != `"Foobar"` {
+		t.Fatalf("expected the summary to be migrated to format %d with its service, got:\n%s", summaryFormat, content)
+	}
+	_, migrated := parseResourceSummary(content)
+	sku := migrated["azurerm_foobar.sku_name"]
+	if !sku.Published || sku.DataType != initialised["azurerm_foobar.sku_name"].DataType || !reflect.DeepEqual(sku.PossibleValues, []string{"Basic", "Standard"}) {
+		t.Fatalf("expected the sku to keep its publishing and gain its schema, got %+v", sku)
+	}
+	if zone, ok := migrated["azurerm_foobar.zone"]; !ok || !zone.Published || zone.DataType != "" {
+		t.Fatalf("expected the removed attribute to be left for init, got %+v", zone)
+	}
+
+	// a summary already in the newest format is left alone
+	if changed, err := gen.migrateSummary(); err != nil || changed {
+		t.Fatalf("expected the migrated summary to be unchanged, got %v, %v", changed, err)
+	}
+
+	newer := fmt.Sprintf(`{"provider_version": %q, "format": %d}`, version.ProviderVersion, summaryFormat+1)
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(newer), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateSummaries(gen.dltaPath, []string{resourceName}, gen.scaffoldOptions); err == nil {
+		t.Fatal("expected a summary written by a newer dlta-scaffold to fail")
+	}
+	expected := fmt.Sprintf("azurerm_foobar.json: format: %d is newer than the format %d this dlta-scaffold writes, upgrade dlta-scaffold", summaryFormat+1, summaryFormat)
+	if err := gen.validateResourceSummary([]byte(newer)); err == nil || err.Error() != expected {
+		t.Fatalf("expected the newer format to be rejected\nexpected: %s\nactual:   %v", expected, err)
+	}
+}
+
+func TestResourceHistory(t *testing.T) {
+	gen := testGenerator()
+	gen.dltaPath = t.TempDir()
+	if err := gen.writeSchemaCache(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("USER", "platform")
+
+	for _, operation := range []string{"init", "scaffold"} {
+		if _, err := getContent(resourceName, true, gen.dltaPath, operation, gen.scaffoldOptions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// an operation which fails isn't recorded
+	options := gen.scaffoldOptions
+	options.attributePatterns = []string{"azurerm_foobar.missing"}
+	if _, err := getContent(resourceName, true, gen.dltaPath, "publish", options); err == nil {
+		t.Fatal("expected publishing an unknown attribute to fail")
+	}
+
+	entries, err := gen.readHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected init and scaffold to be recorded, got %+v", entries)
+	}
+	for i, operation := range []string{"init", "scaffold"} {
+		e := entries[i]
+		if e.Operation != operation || e.GeneratorVersion != generatorVersion || e.ProviderVersion != version.ProviderVersion || e.User != "platform" || time.Since(e.Time) > time.Minute {
+			t.Fatalf("expected the %s to be recorded, got %+v", operation, e)
+		}
+	}
+
+	// a line which can't be parsed doesn't hide the rest
+	file, err := os.OpenFile(gen.historyPath(), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString("{\n")
+	file.Close()
+	gen.profile = "minimal"
+	gen.recordHistory("publish")
+	if entries, err = gen.readHistory(); err != nil || len(entries) != 3 || entries[2].Profile != "minimal" {
+		t.Fatalf("expected the malformed line to be skipped, got %+v, %v", entries, err)
+	}
+	if err := gen.printHistory(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishRules(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["sku_tier"] = &schema.Schema{Type: schema.TypeString, Optional: true, Deprecated: "use sku_name"}
+	gen.resource.Schema["subnet_id"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["key_vault_id"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	gen.resource.Schema["tags"] = &schema.Schema{Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}}
+	gen.dltaPath = t.TempDir()
+
+	config := `[
+	{"path": "*.*_id", "publish": false, "unless_dependent": true},
+	{"when": "required", "publish": true},
+	{"path": "azurerm_*.sku*", "when": "optional", "publish": true}
+]`
+	writeConfig(t, gen.dltaPath, "publish_rules.json", config)
+	rules, err := gen.readPublishRules()
+	if err != nil {
+		t.Fatalf("reading publish rules: %+v", err)
+	}
+	gen.publishRules = rules
+
+	gen.writeInitResourceProperties()
+	if actual, expected := gen.getPublishedResourcePaths(), []string{"azurerm_foobar.location", "azurerm_foobar.name", "azurerm_foobar.sku_name"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the rules to decide what's published (the deprecated sku tier isn't), got %v", actual)
+	}
+
+	// re-initialising applies the rules again, the attributes they don't match keep the user's choice
+	sa := gen.readResourceProperties()
+	tags, keyVault, skuName := sa["azurerm_foobar.tags"], sa["azurerm_foobar.key_vault_id"], sa["azurerm_foobar.sku_name"]
+	tags.Published, keyVault.DependentResourcePath, skuName.Published = true, "azurerm_key_vault.id", false
+	sa["azurerm_foobar.tags"], sa["azurerm_foobar.key_vault_id"], sa["azurerm_foobar.sku_name"] = tags, keyVault, skuName
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gen.writeInitResourceProperties()
+	if actual, expected := gen.getPublishedResourcePaths(), []string{"azurerm_foobar.key_vault_id", "azurerm_foobar.location", "azurerm_foobar.name", "azurerm_foobar.sku_name", "azurerm_foobar.tags"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected the rules to be applied on re-initialising, got %v", actual)
+	}
+
+	for _, invalid := range []string{`[{"when": "computed", "publish": true}]`, `[{"path": "[", "publish": true}]`} {
+		writeConfig(t, gen.dltaPath, "publish_rules.json", invalid)
+		if _, err := gen.readPublishRules(); err == nil {
+			t.Fatalf("expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestReferenceRules(t *testing.T) {
+	gen := testGenerator()
+	for _, n := range []string{"resource_group_name", "subnet_id", "service_plan_id", "key_vault_id", "storage_account_name"} {
+		gen.resource.Schema[n] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	}
+	gen.resource.Schema["private_endpoint"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"private_connection_resource_id": {Type: schema.TypeString, Required: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+
+	config := `[
+	{"attribute": "*_vault_id", "asset_type": "azurerm_key_vault", "output": "id"},
+	{"attribute": "service_plan_id", "asset_type": "azurerm_service_plan", "output": "id", "control": "ServicePlan"},
+	{"attribute": "storage_account_name"}
+]`
+	writeConfig(t, gen.dltaPath, "reference_rules.json", config)
+	rules, err := gen.readReferenceRules()
+	if err != nil {
+		t.Fatalf("reading reference rules: %+v", err)
+	}
+	gen.referenceRules = rules
+
+	gen.writeInitResourceProperties()
+	if _, err := gen.setPublished([]string{"azurerm_foobar.*_id", "azurerm_foobar.*_name", "azurerm_foobar.private_endpoint", "azurerm_foobar.private_endpoint.*"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, isDataSource := range []bool{false, true} {
+		gen.isDataSource = isDataSource
+		template := gen.terraformTemplateBlock()
+		resourceGroup := "module.${ResourceGroup}.name"
+		if isDataSource {
+			// a data source names the group it looks in
+			resourceGroup = "\"${DataResourceGroup}\""
+		}
+		for _, expected := range []string{
+			"\tresource_group_name\t\t= " + resourceGroup + "\n",
+			"\tsubnet_id\t\t= module.${subnet_id}.id\n",
+			"\tkey_vault_id\t\t= module.${key_vault_id}.id\n",
+			"\tservice_plan_id\t\t= module.${ServicePlan}.id\n",
+			"\tstorage_account_name\t\t= \"${storage_account_name}\"\n",
+			"\tprivate_connection_resource_id\t\t= module.${private_connection_resource_id}.id\n",
+		} {
+			if !strings.Contains(template, expected) {
+				t.Fatalf("expected %q in the template (data source: %t):\n%s", expected, isDataSource, template)
+			}
+		}
+		if pp := gen.getPalletProp(attribute{}, "resource_group_name"); isDataSource && (pp.ID != "DataResourceGroup" || pp.Disabled || pp.AssetType != nil) {
+			t.Fatalf("expected a data source's resource group to be entered, got %+v", pp)
+		} else if !isDataSource && (pp.ID != "ResourceGroup" || !pp.Disabled) {
+			t.Fatalf("expected the resource group to be set from the group the asset is placed in, got %+v", pp)
+		}
+		if edges := gen.dependencyEdges(); isDataSource && slices.ContainsFunc(edges, func(e dependencyEdge) bool { return e.Control == "ResourceGroup" }) {
+			t.Fatalf("expected a data source not to depend on a resource group asset, got %+v", edges)
+		}
+	}
+
+	for _, invalid := range []string{`[{"attribute": "[", "output": "id"}]`, `[{"attribute": "subnet_id", "output": "id.name"}]`, `[{"attribute": "subnet_id", "output": "id", "control": "a b"}]`} {
+		writeConfig(t, gen.dltaPath, "reference_rules.json", invalid)
+		if _, err := gen.readReferenceRules(); err == nil {
+			t.Fatalf("expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["resource_group_name"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	gen.resource.Schema["service_plan_id"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	gen.resource.Schema["site_config"] = &schema.Schema{Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"virtual_network_subnet_id": {Type: schema.TypeString, Required: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+	gen.writeInitResourceProperties()
+	if _, err := gen.setPublished([]string{"azurerm_foobar.site_config", "azurerm_foobar.site_config.*"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := newDependencyGraph(gen.dependencyEdges())
+	expected := dependencyGraph{
+		Nodes: []string{"azurerm_foobar", "azurerm_resource_group", "azurerm_service_plan", "azurerm_subnet"},
+		Edges: []dependencyEdge{
+			{From: "azurerm_foobar", To: "azurerm_resource_group", ResourcePath: "azurerm_foobar.resource_group_name", Output: "name", Control: "ResourceGroup", Required: true},
+			{From: "azurerm_foobar", To: "azurerm_service_plan", ResourcePath: "azurerm_foobar.service_plan_id", Output: "id", Control: "service_plan_id", Required: true},
+			{From: "azurerm_foobar", To: "azurerm_subnet", ResourcePath: "azurerm_foobar.site_config.virtual_network_subnet_id", Output: "id", Control: "virtual_network_subnet_id"},
+		},
+	}
+	if !reflect.DeepEqual(graph, expected) {
+		t.Fatalf("expected the references the template wires\nexpected: %+v\nactual:   %+v", expected, graph)
+	}
+
+	dot := `digraph dependencies {
+	rankdir = LR
+	"azurerm_foobar"
+	"azurerm_resource_group"
+	"azurerm_service_plan"
+	"azurerm_subnet"
+	"azurerm_foobar" -> "azurerm_resource_group" [label = "resource_group_name"]
+	"azurerm_foobar" -> "azurerm_service_plan" [label = "service_plan_id"]
+	"azurerm_foobar" -> "azurerm_subnet" [label = "site_config.virtual_network_subnet_id", style = dashed]
+}
+`
+	if actual := renderDependencyGraphDot(graph); actual != dot {
+		t.Fatalf("expected the DOT graph\nexpected:\n%s\nactual:\n%s", dot, actual)
+	}
+
+	gen.writeDependencyGraph()
+	content, err := os.ReadFile(filepath.Join(gen.resourceDir("resource"), "graph.dot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "// Code generated by dlta-scaffold") || !strings.HasSuffix(string(content), dot) {
+		t.Fatalf("expected the graph to be written with a provenance header, got:\n%s", content)
+	}
+}
+
+func TestReferenceDetection(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["disk_encryption_set_id"] = &schema.Schema{Type: schema.TypeString, Optional: true, ValidateFunc: constraints{ResourceID: "DiskEncryptionSet"}.validateFunc(schema.TypeString)}
+	gen.resource.Schema["target_resource_id"] = &schema.Schema{Type: schema.TypeString, Optional: true, ValidateFunc: constraints{ResourceID: anyAssetType}.validateFunc(schema.TypeString)}
+	gen.resource.Schema["log_analytics_workspace_id"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["backup_account"] = &schema.Schema{Type: schema.TypeString, Optional: true, Description: "The name of the Storage Account which holds the backups."}
+	gen.resource.Schema["tenant_id"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.resource.Schema["subnet_id"] = &schema.Schema{Type: schema.TypeString, Optional: true}
+	gen.dltaPath = t.TempDir()
+	gen.registry = func() providerRegistry {
+		return providerRegistry{ResourceTypes: []string{"azurerm_disk_encryption_set", "azurerm_log_analytics_workspace", "azurerm_storage_account", "azurerm_subnet"}}
+	}
+
+	if c := getSchemaConstraints(gen.resource.Schema["disk_encryption_set_id"]); c.ResourceID != "DiskEncryptionSet" {
+		t.Fatalf("expected the azurerm ID the attribute is parsed as to be read back, got %+v", c)
+	}
+
+	gen.writeInitResourceProperties()
+	sa := gen.readResourceProperties()
+
+	expected := map[string]*proposedReference{
+		"azurerm_foobar.disk_encryption_set_id":     {AssetType: "azurerm_disk_encryption_set", Output: "id", DetectedBy: referenceDetectionIDFormat},
+		"azurerm_foobar.target_resource_id":         {AssetType: anyAssetType, Output: "id", DetectedBy: referenceDetectionIDFormat},
+		"azurerm_foobar.log_analytics_workspace_id": {AssetType: "azurerm_log_analytics_workspace", Output: "id", DetectedBy: referenceDetectionName},
+		"azurerm_foobar.backup_account":             {AssetType: "azurerm_storage_account", Output: "name", DetectedBy: referenceDetectionDescription},
+		"azurerm_foobar.tenant_id":                  nil,
+		"azurerm_foobar.subnet_id":                  nil, // already a reference rule
+		"azurerm_foobar.location":                   nil,
+	}
+	for rp, reference := range expected {
+		if !reflect.DeepEqual(sa[rp].Reference, reference) {
+			t.Fatalf("expected %s to be proposed as %+v, got %+v", rp, reference, sa[rp].Reference)
+		}
+	}
+
+	// a proposal isn't wired until it's confirmed
+	if _, err := gen.setPublished([]string{"azurerm_foobar.*_id"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if template := gen.terraformTemplateBlock(); strings.Contains(template, "module.${log_analytics_workspace_id}") {
+		t.Fatalf("expected an unconfirmed reference to be a value, got:\n%s", template)
+	}
+
+	sa = gen.readResourceProperties()
+	sa["azurerm_foobar.log_analytics_workspace_id"].Reference.Confirmed = true
+	sa["azurerm_foobar.disk_encryption_set_id"].Reference.Output = ""
+	if err := os.WriteFile(gen.resourcePropertiesPath(), []byte(writeJson(sa)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-initialising keeps the confirmed and rejected references
+	gen.writeInitResourceProperties()
+	sa = gen.readResourceProperties()
+	if r := sa["azurerm_foobar.log_analytics_workspace_id"].Reference; r == nil || !r.Confirmed {
+		t.Fatalf("expected the confirmed reference to be kept, got %+v", r)
+	}
+	if r := sa["azurerm_foobar.disk_encryption_set_id"].Reference; r == nil || r.Output != "" {
+		t.Fatalf("expected the rejected reference to be kept, got %+v", r)
+	}
+
+	template := gen.terraformTemplateBlock()
+	if !strings.Contains(template, "\tlog_analytics_workspace_id\t\t= module.${log_analytics_workspace_id}.id\n") {
+		t.Fatalf("expected the confirmed reference to be wired, got:\n%s", template)
+	}
+	if strings.Contains(template, "module.${disk_encryption_set_id}") {
+		t.Fatalf("expected the rejected reference to be a value, got:\n%s", template)
+	}
+
+	at := gen.getPublishedAttributes()["log_analytics_workspace_id"]
+	if pp := gen.getPalletProp(at, "log_analytics_workspace_id"); pp.Type != paletteAssetType || pp.AssetType == nil || *pp.AssetType != "azurerm_log_analytics_workspace" || pp.Validators != nil {
+		t.Fatalf("expected the confirmed reference to pick a log analytics workspace, got %+v", pp)
+	}
+	if pp := gen.getPalletProp(attribute{DataTypeString: "TypeString"}, "subnet_id"); pp.Type != paletteAssetType || *pp.AssetType != "azurerm_subnet" {
+		t.Fatalf("expected a reference rule to pick a subnet, got %+v", pp)
+	}
+	edges := gen.dependencyEdges()
+	wired := false
+	for _, e := range edges {
+		wired = wired || e.To == "azurerm_log_analytics_workspace"
+	}
+	if !wired {
+		t.Fatalf("expected the confirmed reference in the dependency graph, got %+v", edges)
+	}
+
+	// controls from before the pickers keep the asset entered as the one picked
+	creator := map[string]interface{}{"controls": []interface{}{
+		map[string]interface{}{"id": "subnet_id", "type": "string", "value": "snet", "validators": map[string]interface{}{"required": true, "pattern": "^/subscriptions/"}},
+		map[string]interface{}{"id": "private_connection_resource_id", "type": "string", "value": ""},
+		map[string]interface{}{"id": "ResourceGroup", "type": "string", "value": nil},
+	}}
+	if err := paletteMigrations[9](creator); err != nil {
+		t.Fatal(err)
+	}
+	controls := creator["controls"].([]interface{})
+	if control := controls[0].(map[string]interface{}); control["type"] != paletteAssetType || control["asset_type"] != "azurerm_subnet" || control["value"] != "snet" || !reflect.DeepEqual(control["validators"], map[string]interface{}{"required": true}) {
+		t.Fatalf("expected the migration to pick a subnet, got %+v", control)
+	}
+	if control := controls[1].(map[string]interface{}); control["asset_type"] != anyAssetType {
+		t.Fatalf("expected the migration to pick any asset, got %+v", control)
+	}
+	if control := controls[2].(map[string]interface{}); control["type"] != "string" || control["asset_type"] != nil {
+		t.Fatalf("expected the migration to leave the resource group set by the canvas, got %+v", control)
+	}
+
+	invalid := `{"format": 2, "azurerm_foobar.tenant_id": {"Published": false, "IsBlock": false, "Required": false, "Optional": true, "Computed": false, "DependentResourcePath": "", "Reference": {"AssetType": "azurerm_tenant", "Output": "", "Confirmed": true}}}`
+	if err := validateSummaryContent("azurerm_foobar.json", []byte(invalid), nil); err == nil || !strings.Contains(err.Error(), "Reference: Output: a confirmed reference needs") {
+		t.Fatalf("expected a confirmed reference without an output to be rejected, got %v", err)
+	}
+}
+
+func TestSolutionRootModule(t *testing.T) {
+	dltaPath := t.TempDir()
+	planTemplate := "module \"${dlta_terraform_module_name}\" {\n\tsource = \"__modules_path__//r//azurerm_service_plan//module?ref=main\"\n\tdlta_environment_char = ${dlta_environment_char}\n\tresource_group_name = module.${ResourceGroup}.name\n\tsku_name = \"${sku_name}\"\n\tzone_balancing_enabled = \"${zone_balancing_enabled}\"\n}\n"
+	if err := os.MkdirAll(filepath.Join(dltaPath, "r", "azurerm_service_plan", "resource"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dltaPath, "r", "azurerm_service_plan", "resource", "template.json"), []byte(planTemplate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	solutionPath := filepath.Join(t.TempDir(), "solution.json")
+	writeSolution := func(content string) {
+		if err := os.WriteFile(solutionPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSolution(`{
+	"name": "web-app",
+	"modules_path": "git::https://example.com/modules.git",
+	"environments": ["d", "p"],
+	"assets": [
+		{"name": "app", "asset_type": "azurerm_linux_web_app", "values": {
+			"dlta_terraform_template": "module \"${dlta_terraform_module_name}\" {\n\tsource = \"__modules_path__//r//azurerm_linux_web_app//module?ref=main\"\n\tservice_plan_id = module.${service_plan_id}.id\n\tapp_settings = ${app_settings}\n\tadmin_password = var.${dlta_terraform_module_name}_admin_password\n}\n",
+			"app_settings": {"A": "b"}
+		}},
+		{"name": "plan", "asset_type": "azurerm_service_plan", "values": {"sku_name": "B1", "ResourceGroup": "rg", "zone_balancing_enabled": false}, "environments": {"p": {"sku_name": "P1v3", "zone_balancing_enabled": true}}},
+		{"name": "rg", "asset_type": "azurerm_service_plan", "values": {"dlta_terraform_template": "module \"${dlta_terraform_module_name}\" {\n\tsource = \"__modules_path__//r//azurerm_resource_group//module?ref=main\"\n\tlocation = \"${location}\"\n}\n", "location": "uksouth"}},
+		{"asset_type": "terraform_azurerm", "values": {"terraform_azurerm_azurerm_version": "4.1.0"}}
+	],
+	"connections": [{"from": "app", "control": "service_plan_id", "to": "plan"}]
+}`)
+
+	s, err := readSolution(solutionPath)
+	if err != nil {
+		t.Fatalf("reading the solution: %+v", err)
+	}
+	files, err := renderSolution(dltaPath, s)
+	if err != nil {
+		t.Fatalf("rendering the solution: %+v", err)
+	}
+	if actual := sortedKeys(files); !reflect.DeepEqual(actual, []string{"d.tfvars", "main.tf", "p.tfvars", "variables.tf"}) {
+		t.Fatalf("expected the root configuration and the tfvars of each environment, got %v", actual)
+	}
+
+	main := files["main.tf"]
+	for _, expected := range []string{
+		"version = \"4.1.0\"",
+		"source                 = \"git::https://example.com/modules.git//r//azurerm_service_plan//module?ref=main\"",
+		"dlta_environment_char  = var.dlta_environment_char",
+		"resource_group_name    = module.rg.name",
+		"sku_name               = var.plan_sku_name",
+		"service_plan_id = module.plan.id",
+		"app_settings    = { \"A\" = \"b\" }",
+		"admin_password  = var.app_admin_password",
+		"location = \"uksouth\"",
+	} {
+		if !strings.Contains(main, expected) {
+			t.Fatalf("expected %q in main.tf:\n%s", expected, main)
+		}
+	}
+	// each module comes after those it references
+	if rg, plan, app := strings.Index(main, `module "rg"`), strings.Index(main, `module "plan"`), strings.Index(main, `module "app"`); !(strings.Index(main, "terraform {") < rg && rg < plan && plan < app) {
+		t.Fatalf("expected the modules in dependency order:\n%s", main)
+	}
+	if err := validateHcl("main.tf", main); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(files["variables.tf"], "variable \"app_admin_password\" {") || !strings.Contains(files["variables.tf"], "sensitive   = true") {
+		t.Fatalf("expected a sensitive variable for the secret:\n%s", files["variables.tf"])
+	}
+	if expected := "dlta_environment_char  = \"p\"\nplan_sku_name          = \"P1v3\"\nplan_zone_balancing_enabled = true\n"; formatHcl(expected) != files["p.tfvars"] {
+		t.Fatalf("expected the production values\nexpected:\n%s\nactual:\n%s", formatHcl(expected), files["p.tfvars"])
+	}
+	if !strings.Contains(files["d.tfvars"], "plan_sku_name               = \"B1\"") || strings.Contains(files["d.tfvars"], "admin_password") {
+		t.Fatalf("expected the development values without the secret:\n%s", files["d.tfvars"])
+	}
+
+	for content, expected := range map[string]string{
+		`{"name": "x", "assets": [{"name": "a", "asset_type": "azurerm_service_plan", "values": {"ResourceGroup": "b"}}, {"name": "b", "asset_type": "azurerm_service_plan", "values": {"ResourceGroup": "a"}}]}`: "reference each other in a cycle",
+		`{"name": "x", "assets": [{"name": "a", "asset_type": "azurerm_service_plan", "values": {"ResourceGroup": "missing"}}]}`:                                                                                  "references \"missing\" which isn't an asset on the canvas",
+		`{"name": "x", "modules_path": "m", "assets": [{"name": "a", "asset_type": "azurerm_key_vault"}]}`:                                                                                                        "azurerm_key_vault hasn't been scaffolded",
+	} {
+		writeSolution(content)
+		s, err := readSolution(solutionPath)
+		if err == nil {
+			s.ModulesPath = "m"
+			_, err = renderSolution(dltaPath, s)
+		}
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected %q rendering %s, got %v", expected, content, err)
+		}
+	}
+
+	writeSolution(`{"name": "Web App", "environments": ["x"], "assets": [{"name": "a", "asset_type": "azurerm_service_plan"}, {"name": "a", "asset_type": "azurerm_service_plan"}], "connections": [{"from": "a", "control": "subnet_id", "to": "vnet"}]}`)
+	_, err = readSolution(solutionPath)
+	for _, expected := range []string{"name: must be lowercase", "environments: [0]: \"x\"", "assets: [1]: \"a\" is the name of another asset", "connections: [0]: `to` \"vnet\" isn't an asset"} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestExistingReferences(t *testing.T) {
+	gen := testGenerator()
+	gen.registry = func() providerRegistry {
+		return providerRegistry{DataSources: map[string][]string{"azurerm_resource_group": {"name"}}}
+	}
+	assetType := "azurerm_resource_group"
+	props, ok := gen.existingReferenceProps(PaletteProp{ID: "ResourceGroup", AssetType: &assetType})
+	if !ok || len(props) != 2 {
+		t.Fatalf("expected the mode and the name looking up the resource group, got %+v", props)
+	}
+	if props[0].ID != "ResourceGroup_mode" || props[0].Type != "select" || props[0].CurrentValue != referenceModeManaged {
+		t.Fatalf("expected the mode to default to managed, got %+v", props[0])
+	}
+	if props[1].ID != "ResourceGroup_existing_name" || props[1].Filter == nil || *props[1].Filter != "ResourceGroup_mode == 'existing'" {
+		t.Fatalf("expected the name to be shown when the mode is existing, got %+v", props[1])
+	}
+	anyType := anyAssetType
+	if _, ok := gen.existingReferenceProps(PaletteProp{ID: "target_resource_id", AssetType: &anyType}); ok {
+		t.Fatalf("expected no data source for a reference to any type")
+	}
+	gen.providerSource = "hashicorp/azuread"
+	if _, ok := gen.existingReferenceProps(PaletteProp{ID: "ResourceGroup", AssetType: &assetType}); ok {
+		t.Fatalf("expected no data source looked up in the azurerm provider for another provider's schema")
+	}
+
+	creator := map[string]interface{}{"controls": []interface{}{map[string]interface{}{"id": "ResourceGroup", "asset_type": nil}}}
+	if err := paletteMigrations[10](creator); err != nil {
+		t.Fatal(err)
+	}
+	if control := creator["controls"].([]interface{})[0].(map[string]interface{}); control["asset_type"] != "azurerm_resource_group" {
+		t.Fatalf("expected the migration to pick the resource group from the resource groups, got %+v", control)
+	}
+
+	listed := readProviderRegistry(t.TempDir(), false)
+	if !listed.hasResourceType("azurerm_resource_group") || !reflect.DeepEqual(listed.DataSources["azurerm_resource_group"], []string{"name"}) {
+		t.Fatalf("expected the provider's resource groups and the data source looking one up by name, got %v", listed.DataSources["azurerm_resource_group"])
+	}
+
+	dltaPath := t.TempDir()
+	// the data sources are read from the registry cached with the schemas rather than listed from the provider
+	registry := providerRegistry{ProviderVersion: version.ProviderVersion, DataSources: map[string][]string{"azurerm_service_plan": {"name", "resource_group_name"}}}
+	if err := os.MkdirAll(filepath.Dir(providerRegistryPath(dltaPath)), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(providerRegistryPath(dltaPath), []byte(writeJson(registry)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	graph := `{"edges": [{"from": "azurerm_linux_web_app", "to": "azurerm_service_plan", "output": "id", "control": "service_plan_id"}]}`
+	if err := os.MkdirAll(filepath.Join(dltaPath, "r", "azurerm_linux_web_app", "resource"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dltaPath, "r", "azurerm_linux_web_app", "resource", "graph.json"), []byte(graph), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// scaffolded before the graph was written
+	if err := os.MkdirAll(filepath.Join(dltaPath, "r", "azurerm_windows_web_app", "resource"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	solutionPath := filepath.Join(t.TempDir(), "solution.json")
+	writeSolution := func(content string) {
+		if err := os.WriteFile(solutionPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	template := `"dlta_terraform_template": "module \"${dlta_terraform_module_name}\" {\n\tsource = \"__modules_path__//r//azurerm_linux_web_app//module?ref=main\"\n\tservice_plan_id = module.${service_plan_id}.id\n}\n"`
+	writeSolution(`{
+	"name": "web-app",
+	"modules_path": "m",
+	"assets": [
+		{"name": "app", "asset_type": "azurerm_linux_web_app", "values": {` + template + `}},
+		{"name": "api", "asset_type": "azurerm_linux_web_app", "values": {` + template + `}}
+	],
+	"connections": [
+		{"from": "app", "control": "service_plan_id", "existing": {"name": "plan-shared", "resource_group_name": "rg-shared"}},
+		{"from": "api", "control": "service_plan_id", "existing": {"name": "plan-shared", "resource_group_name": "rg-shared"}}
+	]
+}`)
+	s, err := readSolution(solutionPath)
+	if err != nil {
+		t.Fatalf("reading the solution: %+v", err)
+	}
+	files, err := renderSolution(dltaPath, s)
+	if err != nil {
+		t.Fatalf("rendering the solution: %+v", err)
+	}
+	main := files["main.tf"]
+	for _, expected := range []string{
+		"data \"azurerm_service_plan\" \"app_service_plan_id\" {",
+		"resource_group_name = \"rg-shared\"",
+		"service_plan_id = data.azurerm_service_plan.app_service_plan_id.id",
+	} {
+		if !strings.Contains(main, expected) {
+			t.Fatalf("expected %q in main.tf:\n%s", expected, main)
+		}
+	}
+	// the same resource is looked up once
+	if count := strings.Count(main, "data \"azurerm_service_plan\""); count != 1 || strings.Count(main, "data.azurerm_service_plan.app_service_plan_id.id") != 2 {
+		t.Fatalf("expected one data source shared by both assets:\n%s", main)
+	}
+	if err := validateHcl("main.tf", main); err != nil {
+		t.Fatal(err)
+	}
+
+	for content, expected := range map[string]string{
+		`{"name": "x", "assets": [{"name": "a", "asset_type": "azurerm_linux_web_app"}, {"name": "b", "asset_type": "azurerm_service_plan"}], "connections": [{"from": "a", "control": "service_plan_id", "to": "b", "existing": {"name": "p"}}]}`: "`existing` needs the arguments looking the resource up, instead of `to`",
+		`{"name": "x", "modules_path": "m", "assets": [{"name": "a", "asset_type": "azurerm_linux_web_app", "values": {` + template + `}}], "connections": [{"from": "a", "control": "service_plan_id", "existing": {"name": "p"}}]}`:              "set the service_plan_id_existing_resource_group_name controls",
+		`{"name": "x", "modules_path": "m", "assets": [{"name": "a", "asset_type": "azurerm_windows_web_app", "values": {` + template + `}}], "connections": [{"from": "a", "control": "service_plan_id", "existing": {"name": "p"}}]}`:            "scaffold it again to write one",
+	} {
+		writeSolution(content)
+		s, err := readSolution(solutionPath)
+		if err == nil {
+			_, err = renderSolution(dltaPath, s)
+		}
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected %q rendering %s, got %v", expected, content, err)
+		}
+	}
+}
+
+func TestAttributeRules(t *testing.T) {
+	gen := testGenerator()
+	gen.resource.Schema["public_network_access_enabled"] = &schema.Schema{Type: schema.TypeBool, Required: true}
+	gen.resource.Schema["legacy_enabled"] = &schema.Schema{Type: schema.TypeBool, Optional: true}
+	gen.resource.Schema["sku_name"] = &schema.Schema{Type: schema.TypeString, Optional: true, Computed: true}
+	gen.resource.Schema["network"] = &schema.Schema{Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+		"bypass":    {Type: schema.TypeString, Optional: true, Computed: true},
+		"subnet_id": {Type: schema.TypeString, Optional: true},
+	}}}
+	gen.dltaPath = t.TempDir()
+
+	config := `{
+	"global": {"exclude": ["public_network_access_enabled", "legacy_*"], "include": ["network.*"]},
+	"resources": {
+		"azurerm_foobar": {"exclude": ["network.subnet_id"], "include": ["sku_name", "legacy_enabled"]},
+		"azurerm_other": {"exclude": ["sku_name"]}
+	}
+}`
+	writeConfig(t, gen.dltaPath, "attribute_rules.json", config)
+
+	rules, err := gen.readAttributeRules()
+	if err != nil {
+		t.Fatalf("reading attribute rules: %+v", err)
+	}
+	gen.attributeRules = rules
+
+	attributes := gen.getAllInputAttributes(gen.resource.Schema, attribute{}, false, gen.resourceName)
+	if _, ok := attributes["public_network_access_enabled"]; ok {
+		t.Fatal("expected the globally excluded attribute to be skipped")
+	}
+	if _, ok := attributes["legacy_enabled"]; !ok {
+		t.Fatal("expected the resource's include to override the global exclusion")
+	}
+	if sku := attributes["sku_name"]; sku.Computed {
+		t.Fatalf("expected the included attribute to be scaffolded as an input, got %+v", sku)
+	}
+	if _, ok := attributes["network"].Attributes["subnet_id"]; ok {
+		t.Fatal("expected the nested attribute excluded for the resource to be skipped")
+	}
+	if bypass := attributes["network"].Attributes["bypass"]; bypass.Computed {
+		t.Fatalf("expected the globally included nested attribute to be an input, got %+v", bypass)
+	}
+
+	summary := gen.summariseAttributes(attributes, gen.resourceName, true)
+	if !summary["azurerm_foobar.sku_name"].Published || summary["azurerm_foobar.network"].Published {
+		t.Fatalf("expected the included attribute to be published, got %+v", summary)
+	}
+
+	writeConfig(t, gen.dltaPath, "attribute_rules.json", `{"global": {"exclude": ["["]}}`)
+	if _, err := gen.readAttributeRules(); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
+
+func TestDataSourceNaming(t *testing.T) {
+	gen := testGenerator()
+	gen.isResource = false
+	gen.isDataSource = true
+	gen.resource.Schema["resource_group_name"] = &schema.Schema{Type: schema.TypeString, Required: true}
+
+	if actual := gen.dataSourceLookup(); actual != dataSourceLookupResourceGroup {
+		t.Fatalf("expected a resource group lookup, got %q", actual)
+	}
+	expected := "${dlta_vendor_asset_short_code}-${dlta_business_short_code}-${dlta_application_short_code}-${dlta_environment_char}-${dlta_location_short_code}-${dlta_instance_id}"
+	if actual := gen.dataSourceNameConvention(); actual != expected {
+		t.Fatalf("expected no prefix by default, got %q", actual)
+	}
+	if actual := gen.getResourceNamingConvention("azurerm_subnet", true); actual != "ds-"+expected {
+		t.Fatalf("expected the built in prefix for a subnet, got %q", actual)
+	}
+
+	delete(gen.resource.Schema, "resource_group_name")
+	gen.resource.Schema["key_vault_id"] = &schema.Schema{Type: schema.TypeString, Required: true}
+	if actual := gen.dataSourceLookup(); actual != dataSourceLookupId {
+		t.Fatalf("expected an id lookup, got %q", actual)
+	}
+	if actual := gen.dataSourceNameConvention(); actual != "${name}" {
+		t.Fatalf("expected the looked up name, got %q", actual)
+	}
+
+	prefix := "lookup"
+	gen.naming = namingConfig{
+		DataSourcePrefix: &prefix,
+		DataSources:      map[string]namingConfigEntry{resourceName: {Lookup: dataSourceLookupResourceGroup, Fields: []string{"dlta_application_short_code"}}},
+	}
+	if actual := gen.dataSourceNameConvention(); actual != "lookup-${dlta_application_short_code}" {
+		t.Fatalf("expected the configured prefix and lookup, got %q", actual)
+	}
+
+	if actual := gen.getPalletProp(attribute{}, "dlta_terraform_data_source_name").CurrentValue; actual != "lookup-${dlta_application_short_code}" {
+		t.Fatalf("expected the palette to hold the data source name convention, got %v", actual)
+	}
+}